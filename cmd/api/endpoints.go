@@ -1,6 +1,7 @@
 package main
 
 import (
+	"go-rest-api/internal/core"
 	"go-rest-api/internal/handlers"
 	"net/http"
 
@@ -8,8 +9,9 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-func SetupRouter(userHandler *handlers.UserHandler) *httprouter.Router {
+func SetupRouter(userHandler *handlers.UserHandler, invitationHandler *handlers.InvitationHandler, userImportHandler *handlers.UserImportHandler, graphQLHandler *handlers.GraphQLHandler, logLevelHandler *handlers.LogLevelHandler, healthHandler *handlers.HealthHandler, idempotencyService handlers.IdempotencyService, apiVersions []handlers.APIVersion) *httprouter.Router {
 	router := httprouter.New()
+	router.NotFound = http.HandlerFunc(handlers.NotFound)
 
 	// ... health check endpoint
 	healthPath := "/health"
@@ -21,6 +23,24 @@ func SetupRouter(userHandler *handlers.UserHandler) *httprouter.Router {
 		"GET",
 	))
 
+	// ... liveness and readiness probes
+	livezPath := "/livez"
+	router.GET(livezPath, handlers.MetricsMiddleware(
+		func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+			healthHandler.Livez(w, r)
+		},
+		livezPath,
+		"GET",
+	))
+	readyzPath := "/readyz"
+	router.GET(readyzPath, handlers.MetricsMiddleware(
+		func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+			healthHandler.Readyz(w, r)
+		},
+		readyzPath,
+		"GET",
+	))
+
 	// ... metrics endpoint
 	metricPath := "/metrics"
 	router.GET(metricPath, handlers.MetricsMiddleware(
@@ -31,29 +51,154 @@ func SetupRouter(userHandler *handlers.UserHandler) *httprouter.Router {
 		"GET",
 	))
 
+	// ... openapi document and swagger ui endpoints
+	openAPIPath := "/openapi.json"
+	router.GET(openAPIPath, handlers.MetricsMiddleware(
+		func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+			handlers.OpenAPISpec(w, r)
+		},
+		openAPIPath,
+		"GET",
+	))
+	docsPath := "/docs/*filepath"
+	router.GET(docsPath, handlers.MetricsMiddleware(
+		func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+			handlers.SwaggerUI(w, r, ps.ByName("filepath"))
+		},
+		docsPath,
+		"GET",
+	))
+
+	// ... graphql endpoint
+	graphQLPath := "/graphql"
+	router.POST(graphQLPath, handlers.MetricsMiddleware(
+		handlers.AuthMiddleware(
+			func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+				graphQLHandler.Serve(w, r)
+			},
+			userHandler.JwtSecret,
+			userHandler.Logger,
+			userHandler.AuditLogger,
+		),
+		graphQLPath,
+		"POST",
+	))
+
+	// ... log level endpoints
+	logLevelsPath := "/admin/log-levels"
+	router.GET(logLevelsPath, handlers.MetricsMiddleware(
+		handlers.AuthMiddleware(
+			handlers.RequireRole(
+				func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+					logLevelHandler.ListLogLevels(w, r)
+				},
+				core.RoleAdmin,
+			),
+			userHandler.JwtSecret,
+			userHandler.Logger,
+			userHandler.AuditLogger,
+		),
+		logLevelsPath,
+		"GET",
+	))
+	logLevelPath := "/admin/log-levels/:component"
+	router.PUT(logLevelPath, handlers.MetricsMiddleware(
+		handlers.AuthMiddleware(
+			handlers.BlockImpersonation(handlers.RequireRole(
+				func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+					logLevelHandler.SetLogLevel(w, r)
+				},
+				core.RoleAdmin,
+			)),
+			userHandler.JwtSecret,
+			userHandler.Logger,
+			userHandler.AuditLogger,
+		),
+		logLevelPath,
+		"PUT",
+	))
+
+	// ... rest endpoints, below the prefix of every version and at the root,
+	// where the version is negotiated from the Accept header
+	setupAPIRoutes(routeGroup{router: router, versions: apiVersions}, userHandler, invitationHandler, userImportHandler, idempotencyService)
+	for _, version := range apiVersions {
+		setupAPIRoutes(routeGroup{router: router, versions: apiVersions, prefix: version.Name}, userHandler, invitationHandler, userImportHandler, idempotencyService)
+	}
+
+	return router
+}
+
+// setupAPIRoutes registers the versioned REST endpoints on router.
+func setupAPIRoutes(router routeGroup, userHandler *handlers.UserHandler, invitationHandler *handlers.InvitationHandler, userImportHandler *handlers.UserImportHandler, idempotencyService handlers.IdempotencyService) {
+	notFound := func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		handlers.NotFound(w, r)
+	}
+
 	// ... create user endpoint
 	createUserPath := "/users"
 	router.POST(createUserPath, handlers.MetricsMiddleware(
-		func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-			userHandler.CreateUser(w, r)
-		},
+		handlers.IdempotencyMiddleware(
+			func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+				userHandler.CreateUser(w, r)
+			},
+			idempotencyService,
+			userHandler.Logger,
+		),
 		createUserPath,
 		"POST",
 	))
 
+	// ... list users endpoint
+	listUsersPath := "/users"
+	router.GET(listUsersPath, handlers.MetricsMiddleware(
+		handlers.AuthMiddleware(
+			handlers.RequireRole(
+				func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+					userHandler.ListUsers(w, r)
+				},
+				core.RoleAdmin,
+			),
+			userHandler.JwtSecret,
+			userHandler.Logger,
+			userHandler.AuditLogger,
+		),
+		listUsersPath,
+		"GET",
+	))
+
 	// ... get user by ID endpoint
 	getUserPath := "/users/:id"
-	router.GET(getUserPath, handlers.MetricsMiddleware(
+	getUser := handlers.MetricsMiddleware(
 		handlers.AuthMiddleware(
 			func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 				userHandler.GetUser(w, r)
 			},
 			userHandler.JwtSecret,
 			userHandler.Logger,
+			userHandler.AuditLogger,
 		),
 		getUserPath,
 		"GET",
-	))
+	)
+
+	// ... export users endpoint
+	exportUsersPath := "/users/export"
+	exportUsers := handlers.MetricsMiddleware(
+		handlers.AuthMiddleware(
+			handlers.BlockImpersonation(handlers.RequireRole(
+				func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+					userHandler.ExportUsers(w, r)
+				},
+				core.RoleExporter,
+			)),
+			userHandler.JwtSecret,
+			userHandler.Logger,
+			userHandler.AuditLogger,
+		),
+		exportUsersPath,
+		"GET",
+	)
+	router.GET(getUserPath, byParam("id", map[string]httprouter.Handle{"export": exportUsers}, getUser))
 
 	// ... login user endpoint
 	loginUserPath := "/users/login"
@@ -65,5 +210,248 @@ func SetupRouter(userHandler *handlers.UserHandler) *httprouter.Router {
 		"POST",
 	))
 
-	return router
+	// ... update current user's avatar endpoint
+	updateAvatarPath := "/users/me/avatar"
+	router.PUT(updateAvatarPath, handlers.MetricsMiddleware(
+		handlers.AuthMiddleware(
+			func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+				userHandler.UpdateAvatar(w, r)
+			},
+			userHandler.JwtSecret,
+			userHandler.Logger,
+			userHandler.AuditLogger,
+		),
+		updateAvatarPath,
+		"PUT",
+	))
+
+	// ... get user profile endpoint
+	userProfilePath := "/users/:id/profile"
+	getUserProfile := handlers.MetricsMiddleware(
+		handlers.AuthMiddleware(
+			func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+				userHandler.GetUserProfile(w, r)
+			},
+			userHandler.JwtSecret,
+			userHandler.Logger,
+			userHandler.AuditLogger,
+		),
+		userProfilePath,
+		"GET",
+	)
+
+	// ... get user import job endpoint
+	userImportPath := "/users/imports/:id"
+	getUserImport := handlers.MetricsMiddleware(
+		handlers.AuthMiddleware(
+			handlers.RequireRole(
+				func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+					userImportHandler.GetImport(w, r)
+				},
+				core.RoleAdmin,
+			),
+			userHandler.JwtSecret,
+			userHandler.Logger,
+			userHandler.AuditLogger,
+		),
+		userImportPath,
+		"GET",
+	)
+	router.GET("/users/:id/:resource", byParam("id",
+		map[string]httprouter.Handle{"imports": getUserImport},
+		byParam("resource", map[string]httprouter.Handle{"profile": getUserProfile}, notFound),
+	))
+
+	// ... download user import error report endpoint
+	userImportErrorsPath := "/users/imports/:id/errors"
+	router.GET("/users/:id/:resource/errors", byParam("id",
+		map[string]httprouter.Handle{"imports": handlers.MetricsMiddleware(
+			handlers.AuthMiddleware(
+				handlers.RequireRole(
+					func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+						userImportHandler.GetImportErrors(w, r)
+					},
+					core.RoleAdmin,
+				),
+				userHandler.JwtSecret,
+				userHandler.Logger,
+				userHandler.AuditLogger,
+			),
+			userImportErrorsPath,
+			"GET",
+		)},
+		notFound,
+	))
+
+	// ... create user import job endpoint
+	createUserImportPath := "/users/imports"
+	router.POST(createUserImportPath, handlers.MetricsMiddleware(
+		handlers.AuthMiddleware(
+			handlers.BlockImpersonation(handlers.RequireRole(
+				func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+					userImportHandler.CreateImport(w, r)
+				},
+				core.RoleAdmin,
+			)),
+			userHandler.JwtSecret,
+			userHandler.Logger,
+			userHandler.AuditLogger,
+		),
+		createUserImportPath,
+		"POST",
+	))
+
+	// ... update user profile endpoint
+	router.PATCH(userProfilePath, handlers.MetricsMiddleware(
+		handlers.AuthMiddleware(
+			func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+				userHandler.UpdateUserProfile(w, r)
+			},
+			userHandler.JwtSecret,
+			userHandler.Logger,
+			userHandler.AuditLogger,
+		),
+		userProfilePath,
+		"PATCH",
+	))
+
+	// ... create invitation endpoint
+	invitationsPath := "/invitations"
+	router.POST(invitationsPath, handlers.MetricsMiddleware(
+		handlers.AuthMiddleware(
+			handlers.BlockImpersonation(handlers.RequireRole(
+				handlers.IdempotencyMiddleware(
+					func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+						invitationHandler.CreateInvitation(w, r)
+					},
+					idempotencyService,
+					userHandler.Logger,
+				),
+				core.RoleAdmin,
+			)),
+			userHandler.JwtSecret,
+			userHandler.Logger,
+			userHandler.AuditLogger,
+		),
+		invitationsPath,
+		"POST",
+	))
+
+	// ... list pending invitations endpoint
+	router.GET(invitationsPath, handlers.MetricsMiddleware(
+		handlers.AuthMiddleware(
+			handlers.RequireRole(
+				func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+					invitationHandler.ListInvitations(w, r)
+				},
+				core.RoleAdmin,
+			),
+			userHandler.JwtSecret,
+			userHandler.Logger,
+			userHandler.AuditLogger,
+		),
+		invitationsPath,
+		"GET",
+	))
+
+	// ... revoke invitation endpoint
+	revokeInvitationPath := "/invitations/:id"
+	router.DELETE(revokeInvitationPath, handlers.MetricsMiddleware(
+		handlers.AuthMiddleware(
+			handlers.BlockImpersonation(handlers.RequireRole(
+				func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+					invitationHandler.RevokeInvitation(w, r)
+				},
+				core.RoleAdmin,
+			)),
+			userHandler.JwtSecret,
+			userHandler.Logger,
+			userHandler.AuditLogger,
+		),
+		revokeInvitationPath,
+		"DELETE",
+	))
+
+	// ... accept invitation endpoint
+	acceptInvitationPath := "/invitations/accept"
+	router.POST(acceptInvitationPath, handlers.MetricsMiddleware(
+		handlers.IdempotencyMiddleware(
+			func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+				invitationHandler.AcceptInvitation(w, r)
+			},
+			idempotencyService,
+			userHandler.Logger,
+		),
+		acceptInvitationPath,
+		"POST",
+	))
+
+	// ... impersonate user endpoint
+	impersonatePath := "/admin/users/:id/impersonate"
+	router.POST(impersonatePath, handlers.MetricsMiddleware(
+		handlers.AuthMiddleware(
+			handlers.BlockImpersonation(handlers.RequireRole(
+				func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+					userHandler.ImpersonateUser(w, r)
+				},
+				core.RoleAdmin,
+			)),
+			userHandler.JwtSecret,
+			userHandler.Logger,
+			userHandler.AuditLogger,
+		),
+		impersonatePath,
+		"POST",
+	))
+}
+
+// routeGroup registers routes on router for the API version named prefix, at
+// /<prefix><path>, or for the version negotiated by VersionMiddleware when
+// prefix is empty.
+type routeGroup struct {
+	router   *httprouter.Router
+	versions []handlers.APIVersion
+	prefix   string
+}
+
+func (g routeGroup) GET(path string, handle httprouter.Handle) {
+	g.handle(http.MethodGet, path, handle)
+}
+
+func (g routeGroup) POST(path string, handle httprouter.Handle) {
+	g.handle(http.MethodPost, path, handle)
+}
+
+func (g routeGroup) PUT(path string, handle httprouter.Handle) {
+	g.handle(http.MethodPut, path, handle)
+}
+
+func (g routeGroup) PATCH(path string, handle httprouter.Handle) {
+	g.handle(http.MethodPatch, path, handle)
+}
+
+func (g routeGroup) DELETE(path string, handle httprouter.Handle) {
+	g.handle(http.MethodDelete, path, handle)
+}
+
+func (g routeGroup) handle(method, path string, handle httprouter.Handle) {
+	fullPath := path
+	if g.prefix != "" {
+		fullPath = "/" + g.prefix + path
+	}
+	g.router.Handle(method, fullPath, handlers.VersionMiddleware(handle, g.versions, g.prefix, path, method))
+}
+
+// byParam hands the request to the handler registered for the value of the
+// named parameter, or to fallback. httprouter does not allow a static segment
+// where another route has a parameter, so routes such as /users/imports/:id
+// share a pattern with the /users/:id routes and are told apart here.
+func byParam(name string, handlers map[string]httprouter.Handle, fallback httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if handler, ok := handlers[ps.ByName(name)]; ok {
+			handler(w, r, ps)
+			return
+		}
+		fallback(w, r, ps)
+	}
 }
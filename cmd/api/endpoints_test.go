@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"go-rest-api/internal/core"
+	"go-rest-api/internal/handlers"
+	"go-rest-api/pkg/logger"
+	"go-rest-api/pkg/openapi"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+var pathParam = regexp.MustCompile(`[:*](\w+)`)
+
+// routeOperations returns the "METHOD /path" of every route registered in
+// SetupRouter, read from the path and method given to MetricsMiddleware. The
+// paths are converted to OpenAPI templates, e.g. /users/{id}.
+func routeOperations(t *testing.T) []string {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "endpoints.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	constants := map[string]string{}
+	var operations []string
+	ast.Inspect(file, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.AssignStmt:
+			if ident, ok := node.Lhs[0].(*ast.Ident); ok && len(node.Rhs) == 1 {
+				if lit, ok := node.Rhs[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+					constants[ident.Name], _ = strconv.Unquote(lit.Value)
+				}
+			}
+		case *ast.CallExpr:
+			selector, ok := node.Fun.(*ast.SelectorExpr)
+			if !ok || selector.Sel.Name != "MetricsMiddleware" || len(node.Args) != 3 {
+				return true
+			}
+			path := constants[node.Args[1].(*ast.Ident).Name]
+			method, _ := strconv.Unquote(node.Args[2].(*ast.BasicLit).Value)
+			operations = append(operations, method+" "+pathParam.ReplaceAllString(path, "{$1}"))
+		}
+		return true
+	})
+	return operations
+}
+
+func specOperations(t *testing.T) []string {
+	var document struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openapi.Spec(), &document); err != nil {
+		t.Fatal(err)
+	}
+	var operations []string
+	for path, methods := range document.Paths {
+		for method := range methods {
+			operations = append(operations, strings.ToUpper(method)+" "+path)
+		}
+	}
+	return operations
+}
+
+func TestSetupRouter_MatchesOpenAPI(t *testing.T) {
+	a := assert.New(t)
+	// given
+	router := SetupRouter(&handlers.UserHandler{}, &handlers.InvitationHandler{}, &handlers.UserImportHandler{}, &handlers.GraphQLHandler{}, &handlers.LogLevelHandler{}, &handlers.HealthHandler{}, nil, []handlers.APIVersion{{Name: "v1"}, {Name: "v2"}})
+
+	// when
+	routes := routeOperations(t)
+	operations := specOperations(t)
+
+	// then
+	a.ElementsMatch(routes, operations, "every route must be documented in pkg/openapi/openapi.json and vice versa")
+	for _, operation := range operations {
+		method, path, _ := strings.Cut(operation, " ")
+		path = regexp.MustCompile(`\{\w+\}`).ReplaceAllString(path, "8f7a6c1e-2b3d-4e5f-9a0b-1c2d3e4f5a6b")
+		handle, _, _ := router.Lookup(method, path)
+		a.NotNil(handle, "%s is documented but not routed", operation)
+	}
+}
+
+func TestSetupRouter_VersionPrefixes(t *testing.T) {
+	a := assert.New(t)
+	// given
+	router := SetupRouter(&handlers.UserHandler{}, &handlers.InvitationHandler{}, &handlers.UserImportHandler{}, &handlers.GraphQLHandler{}, &handlers.LogLevelHandler{}, &handlers.HealthHandler{}, nil, []handlers.APIVersion{{Name: "v1"}, {Name: "v2"}})
+
+	unversioned := []string{"GET /health", "GET /livez", "GET /readyz", "GET /metrics", "GET /openapi.json", "GET /docs/{filepath}", "POST /graphql", "GET /admin/log-levels", "PUT /admin/log-levels/{component}"}
+
+	// when
+	operations := specOperations(t)
+
+	// then
+	for _, operation := range operations {
+		method, path, _ := strings.Cut(operation, " ")
+		path = regexp.MustCompile(`\{\w+\}`).ReplaceAllString(path, "8f7a6c1e-2b3d-4e5f-9a0b-1c2d3e4f5a6b")
+		handle, _, _ := router.Lookup(method, "/v2"+path)
+		if slices.Contains(unversioned, operation) {
+			a.Nil(handle, "%s is not versioned", operation)
+		} else {
+			a.NotNil(handle, "%s is not routed below /v2", operation)
+		}
+	}
+}
+
+func TestSetupRouter_ListUsersRequiresAdmin(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockLogger := &logger.MockLogger{}
+	userHandler := &handlers.UserHandler{JwtSecret: "secret", Logger: mockLogger, AuditLogger: mockLogger}
+	router := SetupRouter(userHandler, &handlers.InvitationHandler{}, &handlers.UserImportHandler{}, &handlers.GraphQLHandler{}, &handlers.LogLevelHandler{}, &handlers.HealthHandler{}, nil, []handlers.APIVersion{{Name: "v1"}, {Name: "v2"}})
+	token, _ := core.GenerateAuthToken(uuid.New(), nil, "secret")
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	res := httptest.NewRecorder()
+
+	// when
+	router.ServeHTTP(res, req)
+
+	// then
+	a.Equal(http.StatusForbidden, res.Code)
+	var problem map[string]any
+	a.NoError(json.Unmarshal(res.Body.Bytes(), &problem))
+	a.Equal("role_required", problem["code"])
+}
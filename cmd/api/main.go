@@ -1,29 +1,93 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"go-rest-api/config"
 	"go-rest-api/internal/core"
 	userRepo "go-rest-api/internal/db"
+	"go-rest-api/internal/grpc_handlers"
 	"go-rest-api/internal/handlers"
 	"go-rest-api/internal/kafka_handlers"
+	"go-rest-api/internal/metrics"
+	"go-rest-api/migrations"
 	"go-rest-api/pkg/database"
+	grpcserver "go-rest-api/pkg/grpc"
+	"go-rest-api/pkg/health"
 	httpserver "go-rest-api/pkg/http"
 	"go-rest-api/pkg/kafka"
 	"go-rest-api/pkg/logger"
+	"go-rest-api/pkg/mailer"
+	"go-rest-api/pkg/openapi"
+	"go-rest-api/pkg/schema"
+	"go-rest-api/pkg/storage"
+	"go-rest-api/pkg/tracing"
+	"log"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 func main() {
-	// ... initialize logger
-	logger, err := logger.NewLogger()
+	// ... load the configuration
+	cfg, cfgErr := config.LoadConfig()
+
+	// ... initialize logger, which libraries using log/slog share as well
+	logger, err := logger.NewLogger(logger.Options{
+		Env:   cfg.Env,
+		Level: cfg.LogLevel,
+		ComponentLevels: map[string]string{
+			"http":   cfg.LogLevelHTTP,
+			"db":     cfg.LogLevelDB,
+			"kafka":  cfg.LogLevelKafka,
+			"core":   cfg.LogLevelCore,
+			"access": cfg.LogLevelAccess,
+			"audit":  "info",
+		},
+		SamplingInitial:    cfg.LogSamplingInitial,
+		SamplingThereafter: cfg.LogSamplingThereafter,
+		// ... the access log and the audit trail are written in full
+		UnsampledComponents: []string{"access", "audit"},
+	})
 	if err != nil {
-		logger.Fatal("Failed to initialize logger", "error", err)
+		log.Fatal("Failed to initialize logger: ", err)
+	}
+	defer logger.Sync()
+	slog.SetDefault(slog.New(logger.SlogHandler()))
+	if cfgErr != nil {
+		logger.Fatal("Error loading config", "error", cfgErr)
 	}
 
-	// ... load the configuration
-	cfg, err := config.LoadConfig()
+	// ... export traces, flushing the last spans on the way out
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Enabled:     cfg.TracingEnabled,
+		Endpoint:    cfg.OTLPEndpoint,
+		ServiceName: cfg.OTelServiceName,
+		SampleRatio: cfg.TracingSampleRatio,
+	})
 	if err != nil {
-		logger.Fatal("Error loading config: %v", err)
+		logger.Fatal("Failed to initialize tracing", "error", err)
 	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Error("Failed to flush traces", "error", err)
+		}
+	}()
+
+	// ... log each component at its own level, which SIGUSR1 and SIGUSR2 raise
+	// and lower for a while
+	httpLogger := logger.Named("http")
+	dbLogger := logger.Named("db")
+	kafkaLogger := logger.Named("kafka")
+	coreLogger := logger.Named("core")
+	accessLogger := logger.Named("access")
+	auditLogger := logger.Named("audit")
+	logger.HandleLevelSignals(cfg.LogLevelOverrideTTL)
 
 	// ... connect to database
 	db, err := database.Connect(database.DbConfig{
@@ -32,35 +96,231 @@ func main() {
 		User:     cfg.DBUser,
 		Password: cfg.DBPassword,
 		Name:     cfg.DBName,
-	}, logger)
+	}, dbLogger)
 
 	if err != nil {
 		logger.Fatal("Failed to connect to database", "error", err)
 	}
 	defer db.Close()
+	prometheus.MustRegister(metrics.NewDBPoolCollector(db))
 
 	// ... initialize user repository adapter
-	userRepository := userRepo.NewUserRepository(db, logger)
+	userRepository := userRepo.NewUserRepository(db, dbLogger)
 
 	// ... initialize kafka producer
-	kafkaProucer, err := kafka.NewProducer(cfg.Kafka.Broker, logger)
+	kafkaProucer, err := kafka.NewProducer(cfg.Kafka.Broker, kafkaLogger)
 	if err != nil {
 		logger.Fatal("Failed to initialize Kafka producer", "error", err)
 	}
 	defer kafkaProucer.Close()
 
 	// ... initialize user event service
-	userEventServ := kafka_handlers.NewUserEventService(&kafkaProucer, logger, cfg.Kafka.Topic)
+	userEventServ := kafka_handlers.NewUserEventService(&kafkaProucer, kafkaLogger, cfg.Kafka.Topic)
+
+	// ... initialize blob storage for avatars
+	blobStore, err := newBlobStore(cfg.Storage)
+	if err != nil {
+		logger.Fatal("Failed to initialize blob storage", "error", err)
+	}
+
+	// ... initialize profile schema validator
+	profileValidator, err := schema.LoadProfileValidator(cfg.ProfileSchemaPath)
+	if err != nil {
+		logger.Fatal("Failed to load profile schema", "error", err)
+	}
 
 	// ... initialize user service
-	userService := core.NewUserService(userRepository, logger, userEventServ)
+	userService := core.NewUserService(userRepository, coreLogger, userEventServ, blobStore, profileValidator)
 
-	// ... initialize user handler
-	userHandler := handlers.NewUserHandler(userService, logger, cfg.JWTSecret)
+	// ... initialize invitation service
+	invitationRepository := userRepo.NewInvitationRepository(db, dbLogger)
+	invitationMailer, err := newMailer(cfg.Mail, cfg.Env, coreLogger)
+	if err != nil {
+		logger.Fatal("Invalid MAIL_DRIVER", "error", err)
+	}
+	invitationService := core.NewInvitationService(invitationRepository, userRepository, userService, invitationMailer, coreLogger, cfg.Mail.InvitationAcceptURL)
+
+	// ... initialize idempotency service and purge expired keys hourly
+	idempotencyRepository := userRepo.NewIdempotencyRepository(db, dbLogger)
+	idempotencyService := core.NewIdempotencyService(idempotencyRepository, coreLogger, cfg.IdempotencyKeyTTL)
+	go purgeIdempotencyKeys(idempotencyService, coreLogger)
+
+	// ... initialize user import service and its background worker
+	userImportRepository := userRepo.NewUserImportRepository(db, dbLogger)
+	userImportService := core.NewUserImportService(userImportRepository, userEventServ, coreLogger)
+	// ... stop the import worker on shutdown, before the pool it uses closes
+	importCtx, stopImports := context.WithCancel(context.Background())
+	importsDone := make(chan struct{})
+	go func() {
+		defer close(importsDone)
+		userImportService.Run(importCtx)
+	}()
+	defer func() {
+		stopImports()
+		<-importsDone
+	}()
+
+	// ... initialize handlers
+	userHandler := handlers.NewUserHandler(userService, httpLogger, cfg.JWTSecret)
+	userHandler.AuditLogger = auditLogger
+	userHandler.OpenSignupDisabled = !cfg.OpenSignupEnabled
+	invitationHandler := handlers.NewInvitationHandler(invitationService, httpLogger)
+	userImportHandler := handlers.NewUserImportHandler(userImportService, httpLogger)
+	graphQLHandler, err := handlers.NewGraphQLHandler(userService, invitationService, userImportService, handlers.GraphQLLimits{
+		MaxDepth:      cfg.GraphQLMaxDepth,
+		MaxComplexity: cfg.GraphQLMaxComplexity,
+	}, httpLogger)
+	if err != nil {
+		logger.Fatal("Failed to build GraphQL schema", "error", err)
+	}
+
+	// ... served api versions, oldest first
+	v1 := handlers.APIVersion{Name: "v1"}
+	if v1.DeprecatedAt, err = parseDate(cfg.APIV1DeprecatedAt); err != nil {
+		logger.Fatal("Invalid API_V1_DEPRECATED_AT", "error", err)
+	}
+	if v1.SunsetAt, err = parseDate(cfg.APIV1SunsetAt); err != nil {
+		logger.Fatal("Invalid API_V1_SUNSET_AT", "error", err)
+	}
+	apiVersions := []handlers.APIVersion{v1, {Name: "v2"}}
+
+	// ... check postgres, kafka and the schema version for the readiness probe
+	latestMigration, err := migrations.LatestVersion()
+	if err != nil {
+		logger.Fatal("Failed to read migrations", "error", err)
+	}
+	healthRegistry := health.NewRegistry(health.Options{
+		Timeout:  cfg.HealthCheckTimeout,
+		CacheTTL: cfg.HealthCheckCacheTTL,
+	})
+	healthRegistry.Register(health.Check{Name: "postgres", Checker: health.CheckerFunc(db.Ping)})
+	healthRegistry.Register(health.Check{Name: "kafka", Checker: health.CheckerFunc(kafkaProucer.Check)})
+	healthRegistry.Register(health.Check{Name: "migrations", Checker: health.CheckerFunc(func(ctx context.Context) error {
+		return database.CheckMigrations(ctx, db, latestMigration)
+	})})
+	healthHandler := handlers.NewHealthHandler(healthRegistry, httpLogger)
 
 	// ... setup router
-	router := SetupRouter(userHandler)
+	logLevelHandler := handlers.NewLogLevelHandler(logger.Levels(), cfg.LogLevelOverrideTTL, httpLogger)
+	router := SetupRouter(userHandler, invitationHandler, userImportHandler, graphQLHandler, logLevelHandler, healthHandler, idempotencyService, apiVersions)
+
+	// ... answer cors preflights for every route
+	corsConfig := handlers.CORSConfig{
+		AllowedOrigins:   cfg.CORSAllowedOrigins,
+		AllowedMethods:   cfg.CORSAllowedMethods,
+		AllowedHeaders:   cfg.CORSAllowedHeaders,
+		AllowCredentials: cfg.CORSAllowCredentials,
+		MaxAge:           cfg.CORSMaxAge,
+	}
+	router.GlobalOPTIONS = handlers.CORSPreflight(corsConfig)
+
+	// ... serve locally stored blobs, S3 objects are served by the bucket itself
+	if cfg.Storage.Driver == "local" {
+		router.ServeFiles("/static/*filepath", http.Dir(cfg.Storage.LocalDir))
+	}
+
+	// ... start the gRPC server, stopped once the HTTP server has shut down
+	userServer := grpc_handlers.NewUserServer(userService, logger, cfg.JWTSecret)
+	userServer.AuditLogger = auditLogger
+	userServer.OpenSignupDisabled = !cfg.OpenSignupEnabled
+	grpcServer, grpcHealth := grpc_handlers.NewServer(userServer, cfg.GRPCAPIKeys)
+	grpcserver.StartServer(cfg.GRPCPort, grpcServer, logger)
+	defer grpcServer.GracefulStop()
+
+	// ... optionally validate requests against the OpenAPI document
+	var handler http.Handler = router
+	if cfg.OpenAPIValidationEnabled {
+		validator, err := openapi.NewValidator()
+		if err != nil {
+			logger.Fatal("Failed to load OpenAPI document", "error", err)
+		}
+		handler = handlers.OpenAPIValidationMiddleware(router, validator, httpLogger)
+	}
+
+	// ... log every request, with passwords, tokens and emails masked in bodies
+	redactor, err := handlers.NewRedactor(cfg.AccessLogRedactPaths)
+	if err != nil {
+		logger.Fatal("Invalid ACCESS_LOG_REDACT_PATHS", "error", err)
+	}
+	accessLogConfig := handlers.AccessLogConfig{
+		LogBodies:         cfg.AccessLogBodies,
+		MaxBodySize:       cfg.AccessLogMaxBodySize,
+		TrustForwardedFor: cfg.AccessLogTrustForwardedFor,
+		Redactor:          redactor,
+	}
 
 	// ... start the HTTP server
-	httpserver.StartServer(cfg.APIPort, router, logger)
+	handler = handlers.CORSMiddleware(handlers.LocaleMiddleware(handler), corsConfig)
+	handler = handlers.SecurityHeadersMiddleware(handler, cfg.HSTSMaxAge)
+	handler = handlers.CompressionMiddleware(handlers.AccessLogMiddleware(handler, accessLogConfig, accessLogger))
+	httpserver.StartServer(cfg.APIPort, handlers.TracingMiddleware(handlers.RequestIDMiddleware(handler)), httpLogger, func() {
+		healthRegistry.Shutdown()
+		grpcHealth.Shutdown()
+		time.Sleep(cfg.ShutdownDrainDelay)
+	})
+}
+
+// parseDate parses an optional YYYY-MM-DD date, returning the zero time for
+// an empty one.
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+// newBlobStore returns the blob store of cfg. An empty public URL falls back
+// to the bucket URL for s3 and to the static route of this server for local.
+func newBlobStore(cfg config.StorageConfig) (core.BlobStore, error) {
+	if cfg.Driver == "s3" {
+		return storage.NewS3Store(context.Background(), storage.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			Bucket:    cfg.S3Bucket,
+			Region:    cfg.S3Region,
+			UseSSL:    cfg.S3UseSSL,
+			PublicURL: cfg.PublicURL,
+		})
+	}
+	publicURL := cfg.PublicURL
+	if publicURL == "" {
+		publicURL = "http://localhost:8080/static"
+	}
+	return storage.NewLocalStore(cfg.LocalDir, publicURL)
+}
+
+// newMailer returns the mailer of cfg. There is no default: the log driver
+// writes invitation links, and the tokens in them, to the logs, so it must be
+// chosen explicitly and is refused in production.
+func newMailer(cfg config.MailConfig, env string, logger logger.CustomLogger) (core.Mailer, error) {
+	switch cfg.Driver {
+	case "smtp":
+		return mailer.NewSMTPMailer(mailer.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.From,
+		}), nil
+	case "log":
+		if env == "production" {
+			return nil, errors.New("the log driver is for development only, use smtp in production")
+		}
+		return mailer.NewLogMailer(logger), nil
+	case "":
+		return nil, errors.New("no mail driver configured, set smtp or, for development, log")
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+}
+
+func purgeIdempotencyKeys(idempotencyService *core.IdempotencyService, logger logger.CustomLogger) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		if deleted, err := idempotencyService.PurgeExpired(context.Background()); err == nil && deleted > 0 {
+			logger.Info("purged expired idempotency keys", "count", deleted)
+		}
+	}
 }
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"go-rest-api/config"
+	"go-rest-api/pkg/logger"
+	"go-rest-api/pkg/mailer"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMailer(t *testing.T) {
+	testScenarios := []struct {
+		name         string
+		driver       string
+		env          string
+		expectedType interface{}
+	}{
+		{name: "smtp", driver: "smtp", env: "production", expectedType: &mailer.SMTPMailer{}},
+		{name: "log in development", driver: "log", env: "development", expectedType: &mailer.LogMailer{}},
+		{name: "log in production", driver: "log", env: "production"},
+		{name: "not configured", env: "development"},
+		{name: "unknown", driver: "sendmail", env: "development"},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// when
+			m, err := newMailer(config.MailConfig{Driver: scenario.driver}, scenario.env, &logger.MockLogger{})
+
+			// then
+			if scenario.expectedType == nil {
+				a.Error(err)
+				a.Nil(m)
+				return
+			}
+			a.NoError(err)
+			a.IsType(scenario.expectedType, m)
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
 )
 
@@ -8,15 +10,120 @@ type KafkaConfig struct {
 	Broker string `mapstructure:"KAFKA_BROKER"`
 	Topic  string `mapstructure:"KAFKA_TOPIC"`
 }
+
+type StorageConfig struct {
+	Driver      string `mapstructure:"STORAGE_DRIVER"`
+	LocalDir    string `mapstructure:"STORAGE_LOCAL_DIR"`
+	PublicURL   string `mapstructure:"STORAGE_PUBLIC_URL"`
+	S3Endpoint  string `mapstructure:"S3_ENDPOINT"`
+	S3AccessKey string `mapstructure:"S3_ACCESS_KEY"`
+	S3SecretKey string `mapstructure:"S3_SECRET_KEY"`
+	S3Bucket    string `mapstructure:"S3_BUCKET"`
+	S3Region    string `mapstructure:"S3_REGION"`
+	S3UseSSL    bool   `mapstructure:"S3_USE_SSL"`
+}
+
+type MailConfig struct {
+	Driver              string `mapstructure:"MAIL_DRIVER"`
+	From                string `mapstructure:"MAIL_FROM"`
+	SMTPHost            string `mapstructure:"SMTP_HOST"`
+	SMTPPort            string `mapstructure:"SMTP_PORT"`
+	SMTPUsername        string `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword        string `mapstructure:"SMTP_PASSWORD"`
+	InvitationAcceptURL string `mapstructure:"INVITATION_ACCEPT_URL"`
+}
+
 type Config struct {
-	DBHost     string `mapstructure:"DB_HOST"`
-	DBPort     string `mapstructure:"DB_PORT"`
-	DBUser     string `mapstructure:"DB_USER"`
-	DBPassword string `mapstructure:"DB_PASSWORD"`
-	DBName     string `mapstructure:"DB_NAME"`
-	APIPort    string `mapstructure:"API_PORT"`
-	JWTSecret  string `mapstructure:"JWT_SECRET"`
-	Kafka      KafkaConfig
+	// Env "production" switches the logs to JSON.
+	Env string `mapstructure:"ENV"`
+	// LogLevel is the least severe level logged: debug, info, warn or error.
+	LogLevel string `mapstructure:"LOG_LEVEL"`
+	// LogLevelHTTP, LogLevelDB, LogLevelKafka and LogLevelCore override
+	// LogLevel for one component when set.
+	LogLevelHTTP  string `mapstructure:"LOG_LEVEL_HTTP"`
+	LogLevelDB    string `mapstructure:"LOG_LEVEL_DB"`
+	LogLevelKafka string `mapstructure:"LOG_LEVEL_KAFKA"`
+	LogLevelCore  string `mapstructure:"LOG_LEVEL_CORE"`
+	// LogLevelOverrideTTL is how long levels changed at runtime, by signal or
+	// through the admin endpoint, last before reverting.
+	LogLevelOverrideTTL time.Duration `mapstructure:"LOG_LEVEL_OVERRIDE_TTL"`
+	// LogSamplingInitial lines with the same level and message are logged
+	// each second, then every LogSamplingThereafter-th; 0 logs them all.
+	LogSamplingInitial    int `mapstructure:"LOG_SAMPLING_INITIAL"`
+	LogSamplingThereafter int `mapstructure:"LOG_SAMPLING_THEREAFTER"`
+	// LogLevelAccess overrides LogLevel for the access log; "warn" turns it
+	// off. The access log is never sampled and its level is fixed at startup.
+	LogLevelAccess string `mapstructure:"LOG_LEVEL_ACCESS"`
+	// AccessLogBodies adds request and response bodies to the access log, with
+	// passwords, tokens and emails masked. Only JSON bodies up to
+	// AccessLogMaxBodySize bytes are logged.
+	AccessLogBodies      bool `mapstructure:"ACCESS_LOG_BODIES"`
+	AccessLogMaxBodySize int  `mapstructure:"ACCESS_LOG_MAX_BODY_SIZE"`
+	// AccessLogRedactPaths are JSON paths such as "$.profile.phone" masked in
+	// logged bodies besides the built-in ones.
+	AccessLogRedactPaths []string `mapstructure:"ACCESS_LOG_REDACT_PATHS"`
+	// AccessLogTrustForwardedFor logs the client IP from X-Forwarded-For, for
+	// deployments behind a proxy that sets it.
+	AccessLogTrustForwardedFor bool `mapstructure:"ACCESS_LOG_TRUST_FORWARDED_FOR"`
+	// TracingEnabled exports OpenTelemetry spans to the OTLP gRPC collector at
+	// OTLPEndpoint, such as http://localhost:4317, as OTelServiceName.
+	// TracingSampleRatio is the share of new traces recorded.
+	TracingEnabled     bool    `mapstructure:"TRACING_ENABLED"`
+	OTLPEndpoint       string  `mapstructure:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	OTelServiceName    string  `mapstructure:"OTEL_SERVICE_NAME"`
+	TracingSampleRatio float64 `mapstructure:"TRACING_SAMPLE_RATIO"`
+	DBHost             string  `mapstructure:"DB_HOST"`
+	DBPort             string  `mapstructure:"DB_PORT"`
+	DBUser             string  `mapstructure:"DB_USER"`
+	DBPassword         string  `mapstructure:"DB_PASSWORD"`
+	DBName             string  `mapstructure:"DB_NAME"`
+	APIPort            string  `mapstructure:"API_PORT"`
+	JWTSecret          string  `mapstructure:"JWT_SECRET"`
+	// ProfileSchemaPath points at a JSON Schema file for user profiles. The
+	// built-in schema is used when empty.
+	ProfileSchemaPath string `mapstructure:"PROFILE_SCHEMA_PATH"`
+	OpenSignupEnabled bool   `mapstructure:"OPEN_SIGNUP_ENABLED"`
+	// IdempotencyKeyTTL is how long responses to requests sent with an
+	// Idempotency-Key header are kept for replay.
+	IdempotencyKeyTTL time.Duration `mapstructure:"IDEMPOTENCY_KEY_TTL"`
+	// GraphQLMaxDepth and GraphQLMaxComplexity bound the queries accepted by
+	// the GraphQL endpoint.
+	GraphQLMaxDepth      int `mapstructure:"GRAPHQL_MAX_DEPTH"`
+	GraphQLMaxComplexity int `mapstructure:"GRAPHQL_MAX_COMPLEXITY"`
+	// OpenAPIValidationEnabled validates requests against the OpenAPI document
+	// before they reach the handlers.
+	OpenAPIValidationEnabled bool `mapstructure:"OPENAPI_VALIDATION_ENABLED"`
+	// GRPCPort is where the gRPC server listens, next to the HTTP API.
+	GRPCPort string `mapstructure:"GRPC_PORT"`
+	// GRPCAPIKeys are accepted in the x-api-key metadata of gRPC calls from
+	// internal services, as an alternative to a user's JWT.
+	GRPCAPIKeys []string `mapstructure:"GRPC_API_KEYS"`
+	// APIV1DeprecatedAt and APIV1SunsetAt are dates such as 2026-10-19
+	// announced in the Deprecation and Sunset headers of API v1 responses. v1
+	// is not deprecated while APIV1DeprecatedAt is empty.
+	APIV1DeprecatedAt string `mapstructure:"API_V1_DEPRECATED_AT"`
+	APIV1SunsetAt     string `mapstructure:"API_V1_SUNSET_AT"`
+	// CORSAllowedOrigins are the browser origins allowed to call the API, "*"
+	// for any. Cross-origin requests are refused while it is empty.
+	CORSAllowedOrigins   []string      `mapstructure:"CORS_ALLOWED_ORIGINS"`
+	CORSAllowedMethods   []string      `mapstructure:"CORS_ALLOWED_METHODS"`
+	CORSAllowedHeaders   []string      `mapstructure:"CORS_ALLOWED_HEADERS"`
+	CORSAllowCredentials bool          `mapstructure:"CORS_ALLOW_CREDENTIALS"`
+	CORSMaxAge           time.Duration `mapstructure:"CORS_MAX_AGE"`
+	// HSTSMaxAge is announced in the Strict-Transport-Security header, which
+	// is omitted when it is zero.
+	HSTSMaxAge time.Duration `mapstructure:"HSTS_MAX_AGE"`
+	// HealthCheckTimeout bounds each dependency check of the readiness probe,
+	// whose results are reused for HealthCheckCacheTTL.
+	HealthCheckTimeout  time.Duration `mapstructure:"HEALTH_CHECK_TIMEOUT"`
+	HealthCheckCacheTTL time.Duration `mapstructure:"HEALTH_CHECK_CACHE_TTL"`
+	// ShutdownDrainDelay is how long the API keeps serving after a shutdown
+	// signal, with the readiness probe and gRPC health failing, so that load
+	// balancers stop routing to it before it closes its listeners.
+	ShutdownDrainDelay time.Duration `mapstructure:"SHUTDOWN_DRAIN_DELAY"`
+	Kafka              KafkaConfig
+	Storage            StorageConfig
+	Mail               MailConfig
 }
 
 // LoadConfig reads configuration from file or environment variables.
@@ -26,6 +133,33 @@ func LoadConfig() (Config, error) {
 	viper.SetConfigName(".env")
 	viper.SetConfigType("env")
 
+	viper.SetDefault("LOG_LEVEL", "info")
+	viper.SetDefault("LOG_LEVEL_OVERRIDE_TTL", "15m")
+	viper.SetDefault("LOG_SAMPLING_INITIAL", 100)
+	viper.SetDefault("LOG_SAMPLING_THEREAFTER", 100)
+	viper.SetDefault("ACCESS_LOG_BODIES", false)
+	viper.SetDefault("ACCESS_LOG_MAX_BODY_SIZE", 4096)
+	viper.SetDefault("TRACING_ENABLED", false)
+	viper.SetDefault("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4317")
+	viper.SetDefault("OTEL_SERVICE_NAME", "go-rest-api")
+	viper.SetDefault("TRACING_SAMPLE_RATIO", 1.0)
+	viper.SetDefault("STORAGE_DRIVER", "local")
+	viper.SetDefault("STORAGE_LOCAL_DIR", "./data/blobs")
+	viper.SetDefault("OPEN_SIGNUP_ENABLED", true)
+	viper.SetDefault("SMTP_PORT", "587")
+	viper.SetDefault("IDEMPOTENCY_KEY_TTL", "24h")
+	viper.SetDefault("GRAPHQL_MAX_DEPTH", 8)
+	viper.SetDefault("GRAPHQL_MAX_COMPLEXITY", 1000)
+	viper.SetDefault("GRPC_PORT", "9090")
+	viper.SetDefault("OPENAPI_VALIDATION_ENABLED", false)
+	viper.SetDefault("CORS_ALLOWED_METHODS", []string{"GET", "POST", "PUT", "PATCH", "DELETE"})
+	viper.SetDefault("CORS_ALLOWED_HEADERS", []string{"Authorization", "Content-Type", "Accept", "Accept-Language", "Idempotency-Key", "If-Match", "If-None-Match", "X-Request-ID"})
+	viper.SetDefault("CORS_MAX_AGE", "10m")
+	viper.SetDefault("HSTS_MAX_AGE", "8760h")
+	viper.SetDefault("HEALTH_CHECK_TIMEOUT", "2s")
+	viper.SetDefault("HEALTH_CHECK_CACHE_TTL", "1s")
+	viper.SetDefault("SHUTDOWN_DRAIN_DELAY", "5s")
+
 	viper.AutomaticEnv()
 
 	err := viper.ReadInConfig()
@@ -35,5 +169,7 @@ func LoadConfig() (Config, error) {
 
 	err = viper.Unmarshal(&config)
 	err = viper.Unmarshal(&config.Kafka)
+	err = viper.Unmarshal(&config.Storage)
+	err = viper.Unmarshal(&config.Mail)
 	return config, err
 }
@@ -7,9 +7,13 @@ import (
 	"github.com/google/uuid"
 )
 
-func GenerateAuthToken(userId uuid.UUID, jwtKey string) (string, error) {
+func GenerateAuthToken(userId uuid.UUID, roles []string, jwtKey string) (string, error) {
+	if roles == nil {
+		roles = []string{}
+	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"user_id": userId.String(),
+		"roles":   roles,
 		"exp":     time.Now().Add(time.Minute * 30).Unix(),
 	})
 
@@ -19,3 +23,32 @@ func GenerateAuthToken(userId uuid.UUID, jwtKey string) (string, error) {
 	}
 	return tokenString, nil
 }
+
+// ImpersonationTokenTTL is deliberately much shorter than a login session.
+const ImpersonationTokenTTL = 15 * time.Minute
+
+// GenerateImpersonationToken issues a token that acts as the target user but
+// records the impersonating admin in an RFC 8693 "act" (actor) claim, so both
+// identities are known for every request made with it.
+func GenerateImpersonationToken(target *User, actorId uuid.UUID, jwtKey string) (string, time.Time, error) {
+	roles := target.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	expiresAt := time.Now().Add(ImpersonationTokenTTL)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":     target.ID.String(),
+		"user_id": target.ID.String(),
+		"roles":   roles,
+		"act": map[string]string{
+			"sub": actorId.String(),
+		},
+		"exp": expiresAt.Unix(),
+	})
+
+	tokenString, err := token.SignedString([]byte(jwtKey))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return tokenString, expiresAt, nil
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func encodeTestImage(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestGenerateAvatarThumbnails(t *testing.T) {
+	a := assert.New(t)
+	// given
+	data := encodeTestImage(t, 400, 300)
+
+	// when
+	thumbnails, err := GenerateAvatarThumbnails(data)
+
+	// then
+	a.NoError(err)
+	a.Len(thumbnails, len(AvatarSizes))
+	for i, thumbnail := range thumbnails {
+		a.Equal(AvatarSizes[i], thumbnail.Size)
+		a.Equal("image/png", thumbnail.ContentType)
+		cfg, err := png.DecodeConfig(bytes.NewReader(thumbnail.Data))
+		a.NoError(err)
+		a.Equal(thumbnail.Size, cfg.Width)
+		a.Equal(thumbnail.Size, cfg.Height)
+	}
+}
+
+func TestGenerateAvatarThumbnails_InvalidImage(t *testing.T) {
+	a := assert.New(t)
+
+	// when
+	thumbnails, err := GenerateAvatarThumbnails([]byte("not an image"))
+
+	// then
+	a.ErrorIs(err, ErrInvalidAvatarImage)
+	a.Nil(thumbnails)
+}
+
+func TestGenerateAvatarThumbnails_TooLarge(t *testing.T) {
+	a := assert.New(t)
+	// given
+	data := encodeTestImage(t, maxAvatarDimension+1, 1)
+
+	// when
+	_, err := GenerateAvatarThumbnails(data)
+
+	// then
+	a.ErrorIs(err, ErrInvalidAvatarImage)
+}
+
+func TestIsAllowedAvatarContentType(t *testing.T) {
+	a := assert.New(t)
+
+	a.True(IsAllowedAvatarContentType("image/png"))
+	a.True(IsAllowedAvatarContentType("image/jpeg"))
+	a.False(IsAllowedAvatarContentType("image/svg+xml"))
+	a.False(IsAllowedAvatarContentType("application/pdf"))
+}
@@ -0,0 +1,77 @@
+package core
+
+import "errors"
+
+// Error kinds. Every error returned by the core services that a client can act
+// on wraps exactly one of these, so adapters can map it to a transport status
+// with errors.Is without knowing about individual failures.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrValidation   = errors.New("validation failed")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrGone         = errors.New("gone")
+	// ErrPreconditionFailed means the client's view of a resource is stale,
+	// e.g. an If-Match version that no longer matches.
+	ErrPreconditionFailed = errors.New("precondition failed")
+)
+
+// Error is a domain error. Kind is one of the kinds above, or another Error that
+// wraps one, which allows more specific sentinels such as ErrUserNotFound.
+// Code is a stable machine-readable identifier that clients and message
+// catalogs key on, Params fill the placeholders of the translated message and
+// Message is the English text used in logs. Err is the underlying cause, if
+// any, and is never exposed.
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+	Params  map[string]string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Err != nil {
+		return []error{e.Kind, e.Err}
+	}
+	return []error{e.Kind}
+}
+
+// NewError returns an Error of the given kind.
+func NewError(kind error, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// WrapError returns an Error of the given kind that keeps err as its cause.
+func WrapError(kind error, err error, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message, Err: err}
+}
+
+// ErrorKind returns the kind wrapped by err, or nil when err is not a domain
+// error and should be treated as an internal failure.
+func ErrorKind(err error) error {
+	for _, kind := range []error{ErrNotFound, ErrConflict, ErrValidation, ErrUnauthorized, ErrForbidden, ErrGone, ErrPreconditionFailed} {
+		if errors.Is(err, kind) {
+			return kind
+		}
+	}
+	return nil
+}
+
+// ErrorMessage returns the client message of the outermost domain error in
+// err's chain, or an empty string when there is none.
+func ErrorMessage(err error) string {
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return domainErr.Message
+	}
+	return ""
+}
@@ -0,0 +1,41 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorKind(t *testing.T) {
+	scenarios := []struct {
+		name         string
+		err          error
+		expectedKind error
+	}{
+		{name: "sentinel", err: ErrUserNotFound, expectedKind: ErrNotFound},
+		{name: "wrapped sentinel", err: fmt.Errorf("lookup: %w", ErrUserAlreadyExists), expectedKind: ErrConflict},
+		{name: "nested kind", err: &Error{Kind: ErrInvalidProfile, Message: "invalid profile: locale"}, expectedKind: ErrValidation},
+		{name: "cause is kept", err: WrapError(ErrForbidden, assert.AnError, "forbidden", "nope"), expectedKind: ErrForbidden},
+		{name: "internal error", err: assert.AnError, expectedKind: nil},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			assert.Equal(t, scenario.expectedKind, ErrorKind(scenario.err))
+		})
+	}
+}
+
+func TestError_KeepsCauseButHidesItFromMessage(t *testing.T) {
+	a := assert.New(t)
+
+	// given
+	err := WrapError(ErrConflict, assert.AnError, "username_taken", "username is already taken")
+
+	// then
+	a.ErrorIs(err, assert.AnError)
+	a.Equal("username is already taken", ErrorMessage(err))
+	a.Contains(err.Error(), assert.AnError.Error())
+	a.Empty(ErrorMessage(assert.AnError))
+}
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"context"
+	"go-rest-api/pkg/logger"
+	"time"
+)
+
+const (
+	DefaultIdempotencyKeyTTL = 24 * time.Hour
+	// IdempotencyLockTimeout bounds how long an in-flight request holds its key,
+	// so a crashed request does not block retries for the whole TTL.
+	IdempotencyLockTimeout = time.Minute
+)
+
+var (
+	ErrIdempotencyKeyReused         = NewError(ErrValidation, "idempotency_key_reused", "idempotency key was already used with a different request")
+	ErrIdempotencyRequestInProgress = NewError(ErrConflict, "idempotency_request_in_progress", "a request with this idempotency key is still in progress")
+)
+
+type IdempotencyRepository interface {
+	// ReserveIdempotencyKey inserts the record, or takes over an existing one
+	// that has expired or whose lock has lapsed by now. It returns nil when the
+	// record was reserved and the existing record otherwise.
+	ReserveIdempotencyKey(ctx context.Context, record *IdempotencyRecord, now time.Time) (*IdempotencyRecord, error)
+	CompleteIdempotencyKey(ctx context.Context, scope, key string, statusCode int, headers map[string][]string, body []byte, now time.Time) error
+	DeleteIdempotencyKey(ctx context.Context, scope, key string) error
+	DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error)
+}
+
+type IdempotencyService struct {
+	repo   IdempotencyRepository
+	logger logger.CustomLogger
+	ttl    time.Duration
+}
+
+// NewIdempotencyService creates an IdempotencyService that remembers responses
+// for ttl, or DefaultIdempotencyKeyTTL when ttl is not positive.
+func NewIdempotencyService(repo IdempotencyRepository, logger logger.CustomLogger, ttl time.Duration) *IdempotencyService {
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyKeyTTL
+	}
+	return &IdempotencyService{
+		repo:   repo,
+		logger: logger,
+		ttl:    ttl,
+	}
+}
+
+// Begin claims key within scope for a request with the given fingerprint. It
+// returns nil when the caller now owns the key and must Complete or Release
+// it, and the completed record when the response should be replayed.
+func (s *IdempotencyService) Begin(ctx context.Context, scope, key, fingerprint string) (*IdempotencyRecord, error) {
+	now := time.Now()
+	existing, err := s.repo.ReserveIdempotencyKey(ctx, &IdempotencyRecord{
+		Scope:       scope,
+		Key:         key,
+		Fingerprint: fingerprint,
+		LockedUntil: now.Add(IdempotencyLockTimeout),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(s.ttl),
+	}, now)
+	if err != nil {
+		s.logger.FromContext(ctx).Error("failed to reserve idempotency key", "error", err)
+		return nil, err
+	}
+	if existing == nil {
+		return nil, nil
+	}
+
+	if existing.Fingerprint != fingerprint {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if !existing.IsCompleted() {
+		return nil, ErrIdempotencyRequestInProgress
+	}
+	return existing, nil
+}
+
+// Complete stores the response for a key claimed with Begin.
+func (s *IdempotencyService) Complete(ctx context.Context, scope, key string, statusCode int, headers map[string][]string, body []byte) error {
+	if err := s.repo.CompleteIdempotencyKey(ctx, scope, key, statusCode, headers, body, time.Now()); err != nil {
+		s.logger.FromContext(ctx).Error("failed to store idempotent response", "error", err)
+		return err
+	}
+	return nil
+}
+
+// Release gives up a key claimed with Begin without storing a response, so
+// that a retry runs the request again.
+func (s *IdempotencyService) Release(ctx context.Context, scope, key string) error {
+	if err := s.repo.DeleteIdempotencyKey(ctx, scope, key); err != nil {
+		s.logger.FromContext(ctx).Error("failed to release idempotency key", "error", err)
+		return err
+	}
+	return nil
+}
+
+// PurgeExpired deletes records past their TTL and returns how many were
+// removed.
+func (s *IdempotencyService) PurgeExpired(ctx context.Context) (int64, error) {
+	deleted, err := s.repo.DeleteExpiredIdempotencyKeys(ctx, time.Now())
+	if err != nil {
+		s.logger.FromContext(ctx).Error("failed to purge expired idempotency keys", "error", err)
+		return 0, err
+	}
+	return deleted, nil
+}
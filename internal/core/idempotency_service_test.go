@@ -0,0 +1,84 @@
+package core
+
+import (
+	"context"
+	"go-rest-api/pkg/logger"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestIdempotencyService_Begin_Reserved(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockLogger := logger.MockLogger{}
+	mockRepo := MockIdempotencyRepository{}
+	service := NewIdempotencyService(&mockRepo, &mockLogger, time.Hour)
+	mockRepo.On("ReserveIdempotencyKey", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+
+	// when
+	record, err := service.Begin(context.Background(), "POST /users", "key-1", "fingerprint")
+
+	// then
+	a.NoError(err)
+	a.Nil(record)
+	reserved := mockRepo.Calls[0].Arguments.Get(1).(*IdempotencyRecord)
+	a.Equal("fingerprint", reserved.Fingerprint)
+	a.WithinDuration(time.Now().Add(time.Hour), reserved.ExpiresAt, time.Minute)
+	a.WithinDuration(time.Now().Add(IdempotencyLockTimeout), reserved.LockedUntil, time.Minute)
+}
+
+func TestIdempotencyService_Begin_Replay(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockLogger := logger.MockLogger{}
+	mockRepo := MockIdempotencyRepository{}
+	service := NewIdempotencyService(&mockRepo, &mockLogger, time.Hour)
+	completedAt := time.Now()
+	stored := &IdempotencyRecord{Fingerprint: "fingerprint", StatusCode: 201, Body: []byte(`{}`), CompletedAt: &completedAt}
+	mockRepo.On("ReserveIdempotencyKey", mock.Anything, mock.Anything, mock.Anything).Return(stored, nil)
+
+	// when
+	record, err := service.Begin(context.Background(), "POST /users", "key-1", "fingerprint")
+
+	// then
+	a.NoError(err)
+	a.Equal(stored, record)
+}
+
+func TestIdempotencyService_Begin_Conflicts(t *testing.T) {
+	completedAt := time.Now()
+	testScenarios := []struct {
+		name          string
+		existing      *IdempotencyRecord
+		expectedError error
+	}{
+		{name: "different request", existing: &IdempotencyRecord{Fingerprint: "other", CompletedAt: &completedAt}, expectedError: ErrIdempotencyKeyReused},
+		{name: "in flight", existing: &IdempotencyRecord{Fingerprint: "fingerprint"}, expectedError: ErrIdempotencyRequestInProgress},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			mockLogger := logger.MockLogger{}
+			mockRepo := MockIdempotencyRepository{}
+			service := NewIdempotencyService(&mockRepo, &mockLogger, time.Hour)
+			mockRepo.On("ReserveIdempotencyKey", mock.Anything, mock.Anything, mock.Anything).Return(scenario.existing, nil)
+
+			// when
+			record, err := service.Begin(context.Background(), "POST /users", "key-1", "fingerprint")
+
+			// then
+			a.ErrorIs(err, scenario.expectedError)
+			a.Nil(record)
+		})
+	}
+}
+
+func TestNewIdempotencyService_DefaultTTL(t *testing.T) {
+	service := NewIdempotencyService(&MockIdempotencyRepository{}, &logger.MockLogger{}, 0)
+	assert.Equal(t, DefaultIdempotencyKeyTTL, service.ttl)
+}
@@ -0,0 +1,192 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"go-rest-api/pkg/logger"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultInvitationTTL = 7 * 24 * time.Hour
+	MaxInvitationTTL     = 30 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidInvitation  = NewError(ErrGone, "invalid_invitation", "invitation is invalid or has expired")
+	ErrUserAlreadyExists  = NewError(ErrConflict, "user_already_exists", "a user with this email already exists")
+	ErrInvitationNotFound = NewError(ErrNotFound, "invitation_not_found", "pending invitation not found")
+)
+
+type InvitationRepository interface {
+	// CreateInvitation stores the invitation and revokes any other invitation
+	// still pending for the same email.
+	CreateInvitation(ctx context.Context, invitation *Invitation) (*Invitation, error)
+	ListPendingInvitations(ctx context.Context) ([]*Invitation, error)
+	// RevokeInvitation returns nil when no pending invitation has the given id.
+	RevokeInvitation(ctx context.Context, id string) (*Invitation, error)
+	// ClaimInvitation atomically marks the pending invitation with the given
+	// token hash as accepted. It returns nil when there is none.
+	ClaimInvitation(ctx context.Context, tokenHash string) (*Invitation, error)
+	// ReleaseInvitation undoes a claim when the user could not be created.
+	ReleaseInvitation(ctx context.Context, id uuid.UUID) error
+}
+
+type Mailer interface {
+	SendMail(ctx context.Context, to, subject, body string) error
+}
+
+// UserCreator is the part of UserService used to create invited users, so
+// that password hashing and user created events stay in one place.
+type UserCreator interface {
+	CreateUser(ctx context.Context, user *User) (*User, error)
+}
+
+type InvitationService struct {
+	repo        InvitationRepository
+	userRepo    UserRepository
+	userCreator UserCreator
+	mailer      Mailer
+	logger      logger.CustomLogger
+	acceptURL   string
+}
+
+// NewInvitationService creates an InvitationService. acceptURL is the page the
+// invitee lands on; the invitation token is appended as the "token" query
+// parameter.
+func NewInvitationService(repo InvitationRepository, userRepo UserRepository, userCreator UserCreator, mailer Mailer, logger logger.CustomLogger, acceptURL string) *InvitationService {
+	return &InvitationService{
+		repo:        repo,
+		userRepo:    userRepo,
+		userCreator: userCreator,
+		mailer:      mailer,
+		logger:      logger,
+		acceptURL:   acceptURL,
+	}
+}
+
+// CreateInvitation stores a new invitation and mails the invite link. The
+// plain token only ever leaves the service inside that email. The mail is
+// sent once the invitation is stored, so that nobody receives a link whose
+// token was never saved; when it cannot be sent the invitation is revoked
+// again rather than left pending with nobody holding its link.
+func (s *InvitationService) CreateInvitation(ctx context.Context, email string, roles []string, expiresAt time.Time, invitedBy uuid.UUID) (*Invitation, error) {
+	existing, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		s.logger.FromContext(ctx).Error("failed to check for existing user", "error", err)
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrUserAlreadyExists
+	}
+
+	token, err := generateInvitationToken()
+	if err != nil {
+		s.logger.FromContext(ctx).Error("failed to generate invitation token", "error", err)
+		return nil, err
+	}
+	if roles == nil {
+		roles = []string{}
+	}
+
+	invitation, err := s.repo.CreateInvitation(ctx, &Invitation{
+		ID:        uuid.New(),
+		Email:     email,
+		Roles:     roles,
+		TokenHash: HashInvitationToken(token),
+		InvitedBy: invitedBy,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		s.logger.FromContext(ctx).Error("failed to create invitation", "error", err)
+		return nil, err
+	}
+
+	if err := s.mailer.SendMail(ctx, email, "You have been invited", s.invitationMailBody(token, expiresAt)); err != nil {
+		s.logger.FromContext(ctx).Error("failed to send invitation mail", "error", err, "invitation_id", invitation.ID)
+		// ... revoke even if the client has gone away
+		if _, revokeErr := s.repo.RevokeInvitation(context.WithoutCancel(ctx), invitation.ID.String()); revokeErr != nil {
+			s.logger.FromContext(ctx).Error("failed to revoke undelivered invitation", "error", revokeErr, "invitation_id", invitation.ID)
+		}
+		return nil, err
+	}
+	return invitation, nil
+}
+
+func (s *InvitationService) ListPendingInvitations(ctx context.Context) ([]*Invitation, error) {
+	return s.repo.ListPendingInvitations(ctx)
+}
+
+func (s *InvitationService) RevokeInvitation(ctx context.Context, id string) (*Invitation, error) {
+	invitation, err := s.repo.RevokeInvitation(ctx, id)
+	if err != nil {
+		s.logger.FromContext(ctx).Error("failed to revoke invitation", "error", err)
+		return nil, err
+	}
+	if invitation == nil {
+		return nil, ErrInvitationNotFound
+	}
+	return invitation, nil
+}
+
+// AcceptInvitation creates the invited user with the email and roles from the
+// invitation.
+func (s *InvitationService) AcceptInvitation(ctx context.Context, token, username, password string) (*User, error) {
+	invitation, err := s.repo.ClaimInvitation(ctx, HashInvitationToken(token))
+	if err != nil {
+		s.logger.FromContext(ctx).Error("failed to claim invitation", "error", err)
+		return nil, err
+	}
+	if invitation == nil {
+		return nil, ErrInvalidInvitation
+	}
+
+	user, err := s.userCreator.CreateUser(ctx, &User{
+		Username: username,
+		Email:    invitation.Email,
+		Password: password,
+		Roles:    invitation.Roles,
+	})
+	if err != nil {
+		if releaseErr := s.repo.ReleaseInvitation(ctx, invitation.ID); releaseErr != nil {
+			s.logger.FromContext(ctx).Error("failed to release invitation", "error", releaseErr)
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *InvitationService) invitationMailBody(token string, expiresAt time.Time) string {
+	link := s.acceptURL
+	if parsed, err := url.Parse(s.acceptURL); err == nil {
+		query := parsed.Query()
+		query.Set("token", token)
+		parsed.RawQuery = query.Encode()
+		link = parsed.String()
+	}
+	return fmt.Sprintf("You have been invited to join.\n\nAccept the invitation here: %s\n\nThis link expires on %s.\n",
+		link, expiresAt.UTC().Format(time.RFC1123))
+}
+
+func generateInvitationToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashInvitationToken returns the hex encoded SHA-256 of the token, which is
+// what gets persisted so a database leak does not expose usable links.
+func HashInvitationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,174 @@
+package core
+
+import (
+	"context"
+	"go-rest-api/pkg/logger"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestInvitationService_CreateInvitation(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockLogger := logger.MockLogger{}
+	mockInvitationRepo := MockInvitationRepository{}
+	mockUserRepo := MockUserRepository{}
+	mockMailer := MockMailer{}
+	invitationService := NewInvitationService(&mockInvitationRepo, &mockUserRepo, &MockUserCreator{}, &mockMailer, &mockLogger, "https://app.example.com/accept")
+
+	email := "invitee@gmail.com"
+	expiresAt := time.Now().Add(24 * time.Hour)
+	adminID := uuid.New()
+	mockUserRepo.On("GetUserByEmail", mock.Anything, email).Return(nil, nil)
+	mockInvitationRepo.On("CreateInvitation", mock.Anything, mock.MatchedBy(func(invitation *Invitation) bool {
+		return invitation.Email == email && invitation.InvitedBy == adminID && len(invitation.TokenHash) == 64
+	})).Return(&Invitation{Email: email, Roles: []string{RoleAdmin}}, nil)
+
+	var mailBody string
+	mockMailer.On("SendMail", mock.Anything, email, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		mailBody = args.String(3)
+	}).Return(nil)
+
+	// when
+	invitation, err := invitationService.CreateInvitation(context.Background(), email, []string{RoleAdmin}, expiresAt, adminID)
+
+	// then
+	a.NoError(err)
+	a.Equal(email, invitation.Email)
+	a.Contains(mailBody, "https://app.example.com/accept?token=")
+
+	// ... the mailed token must hash to the stored hash
+	token := strings.Fields(strings.SplitN(mailBody, "token=", 2)[1])[0]
+	storedInvitation := mockInvitationRepo.Calls[0].Arguments.Get(1).(*Invitation)
+	a.Equal(storedInvitation.TokenHash, HashInvitationToken(token))
+}
+
+func TestInvitationService_CreateInvitation_UserAlreadyExists(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockLogger := logger.MockLogger{}
+	mockInvitationRepo := MockInvitationRepository{}
+	mockUserRepo := MockUserRepository{}
+	mockMailer := MockMailer{}
+	invitationService := NewInvitationService(&mockInvitationRepo, &mockUserRepo, &MockUserCreator{}, &mockMailer, &mockLogger, "https://app.example.com/accept")
+
+	mockUserRepo.On("GetUserByEmail", mock.Anything, "existing@gmail.com").Return(&User{ID: uuid.New()}, nil)
+
+	// when
+	invitation, err := invitationService.CreateInvitation(context.Background(), "existing@gmail.com", nil, time.Now().Add(time.Hour), uuid.New())
+
+	// then
+	a.ErrorIs(err, ErrUserAlreadyExists)
+	a.Nil(invitation)
+	mockMailer.AssertNotCalled(t, "SendMail", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestInvitationService_CreateInvitation_MailFails(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockLogger := logger.MockLogger{}
+	mockLogger.On("Error", mock.Anything, mock.Anything).Return()
+	mockInvitationRepo := MockInvitationRepository{}
+	mockUserRepo := MockUserRepository{}
+	mockMailer := MockMailer{}
+	invitationService := NewInvitationService(&mockInvitationRepo, &mockUserRepo, &MockUserCreator{}, &mockMailer, &mockLogger, "https://app.example.com/accept")
+
+	email := "invitee@gmail.com"
+	invitationID := uuid.New()
+	mockUserRepo.On("GetUserByEmail", mock.Anything, email).Return(nil, nil)
+	mockInvitationRepo.On("CreateInvitation", mock.Anything, mock.Anything).Return(&Invitation{ID: invitationID, Email: email}, nil)
+	mockInvitationRepo.On("RevokeInvitation", mock.Anything, invitationID.String()).Return(&Invitation{ID: invitationID, Email: email}, nil)
+	mockMailer.On("SendMail", mock.Anything, email, mock.Anything, mock.Anything).Return(assert.AnError)
+
+	// when
+	invitation, err := invitationService.CreateInvitation(context.Background(), email, nil, time.Now().Add(time.Hour), uuid.New())
+
+	// then
+	a.ErrorIs(err, assert.AnError)
+	a.Nil(invitation)
+	mockMailer.AssertNumberOfCalls(t, "SendMail", 1)
+	mockInvitationRepo.AssertExpectations(t)
+}
+
+func TestInvitationService_AcceptInvitation(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockLogger := logger.MockLogger{}
+	mockInvitationRepo := MockInvitationRepository{}
+	mockUserCreator := MockUserCreator{}
+	invitationService := NewInvitationService(&mockInvitationRepo, &MockUserRepository{}, &mockUserCreator, &MockMailer{}, &mockLogger, "https://app.example.com/accept")
+
+	invitation := Invitation{ID: uuid.New(), Email: "invitee@gmail.com", Roles: []string{RoleAdmin}}
+	createdUser := User{ID: uuid.New(), Username: "invitee", Email: invitation.Email, Roles: invitation.Roles}
+	mockInvitationRepo.On("ClaimInvitation", mock.Anything, HashInvitationToken("token")).Return(&invitation, nil)
+	mockUserCreator.On("CreateUser", mock.Anything, &User{
+		Username: "invitee",
+		Email:    invitation.Email,
+		Password: "password123",
+		Roles:    invitation.Roles,
+	}).Return(&createdUser, nil)
+
+	// when
+	user, err := invitationService.AcceptInvitation(context.Background(), "token", "invitee", "password123")
+
+	// then
+	a.NoError(err)
+	a.Equal(&createdUser, user)
+}
+
+func TestInvitationService_AcceptInvitation_InvalidToken(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockLogger := logger.MockLogger{}
+	mockInvitationRepo := MockInvitationRepository{}
+	mockUserCreator := MockUserCreator{}
+	invitationService := NewInvitationService(&mockInvitationRepo, &MockUserRepository{}, &mockUserCreator, &MockMailer{}, &mockLogger, "https://app.example.com/accept")
+
+	mockInvitationRepo.On("ClaimInvitation", mock.Anything, mock.Anything).Return(nil, nil)
+
+	// when
+	user, err := invitationService.AcceptInvitation(context.Background(), "unknown", "invitee", "password123")
+
+	// then
+	a.ErrorIs(err, ErrInvalidInvitation)
+	a.Nil(user)
+	mockUserCreator.AssertNotCalled(t, "CreateUser", mock.Anything, mock.Anything)
+}
+
+func TestInvitationService_AcceptInvitation_ReleasesClaimOnFailure(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockLogger := logger.MockLogger{}
+	mockInvitationRepo := MockInvitationRepository{}
+	mockUserCreator := MockUserCreator{}
+	invitationService := NewInvitationService(&mockInvitationRepo, &MockUserRepository{}, &mockUserCreator, &MockMailer{}, &mockLogger, "https://app.example.com/accept")
+
+	invitation := Invitation{ID: uuid.New(), Email: "invitee@gmail.com"}
+	mockInvitationRepo.On("ClaimInvitation", mock.Anything, mock.Anything).Return(&invitation, nil)
+	mockInvitationRepo.On("ReleaseInvitation", mock.Anything, invitation.ID).Return(nil)
+	mockUserCreator.On("CreateUser", mock.Anything, mock.Anything).Return(nil, assert.AnError)
+
+	// when
+	user, err := invitationService.AcceptInvitation(context.Background(), "token", "invitee", "password123")
+
+	// then
+	a.Error(err)
+	a.Nil(user)
+	mockInvitationRepo.AssertCalled(t, "ReleaseInvitation", mock.Anything, invitation.ID)
+}
+
+func TestInvitation_IsPending(t *testing.T) {
+	a := assert.New(t)
+	now := time.Now()
+	accepted := now.Add(-time.Minute)
+
+	a.True((&Invitation{ExpiresAt: now.Add(time.Hour)}).IsPending(now))
+	a.False((&Invitation{ExpiresAt: now.Add(-time.Hour)}).IsPending(now))
+	a.False((&Invitation{ExpiresAt: now.Add(time.Hour), AcceptedAt: &accepted}).IsPending(now))
+	a.False((&Invitation{ExpiresAt: now.Add(time.Hour), RevokedAt: &accepted}).IsPending(now))
+}
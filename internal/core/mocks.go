@@ -2,7 +2,10 @@ package core
 
 import (
 	"context"
+	"io"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -27,6 +30,14 @@ func (r *MockUserRepository) GetUserByID(ctx context.Context, id string) (*User,
 	return args.Get(0).(*User), args.Error(1)
 }
 
+func (r *MockUserRepository) GetUsersByIDs(ctx context.Context, ids []string) ([]*User, error) {
+	args := r.Called(ctx, ids)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*User), args.Error(1)
+}
+
 func (r *MockUserRepository) GetUserByEmail(ctx context.Context, email string) (*User, error) {
 	args := r.Called(ctx, email)
 	if args.Get(0) == nil {
@@ -35,6 +46,42 @@ func (r *MockUserRepository) GetUserByEmail(ctx context.Context, email string) (
 	return args.Get(0).(*User), args.Error(1)
 }
 
+func (r *MockUserRepository) UpdateUserAvatar(ctx context.Context, id string, avatarURL string, version int64) (*User, error) {
+	args := r.Called(ctx, id, avatarURL, version)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*User), args.Error(1)
+}
+
+func (r *MockUserRepository) UpdateUserProfile(ctx context.Context, id string, profile map[string]any, version int64) (*User, error) {
+	args := r.Called(ctx, id, profile, version)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*User), args.Error(1)
+}
+
+func (r *MockUserRepository) ListUsers(ctx context.Context, filter UserFilter) ([]*User, error) {
+	args := r.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*User), args.Error(1)
+}
+
+// StreamUsers passes the users given to Return to fn before returning the error.
+func (r *MockUserRepository) StreamUsers(ctx context.Context, filter UserFilter, fn func(*User) error) error {
+	args := r.Called(ctx, filter)
+	users, _ := args.Get(0).([]*User)
+	for _, user := range users {
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
 // ---------------------------------
 // MockUserService
 // ---------------------------------
@@ -76,3 +123,192 @@ func (s *MockUserEventService) PublishUserCreatedEvent(ctx context.Context, user
 	args := s.Called(ctx, user)
 	return args.Error(0)
 }
+
+func (s *MockUserEventService) PublishUsersImportedEvent(ctx context.Context, userImport *UserImport) error {
+	args := s.Called(ctx, userImport)
+	return args.Error(0)
+}
+
+// ---------------------------------
+// MockBlobStore
+// ---------------------------------
+
+type MockBlobStore struct {
+	mock.Mock
+}
+
+func (b *MockBlobStore) Put(ctx context.Context, key string, data io.Reader, size int64, contentType string) (string, error) {
+	args := b.Called(ctx, key, data, size, contentType)
+	return args.String(0), args.Error(1)
+}
+
+func (b *MockBlobStore) Delete(ctx context.Context, key string) error {
+	args := b.Called(ctx, key)
+	return args.Error(0)
+}
+
+// ---------------------------------
+// MockProfileValidator
+// ---------------------------------
+
+type MockProfileValidator struct {
+	mock.Mock
+}
+
+func (v *MockProfileValidator) ValidateProfile(profile map[string]any) error {
+	args := v.Called(profile)
+	return args.Error(0)
+}
+
+// ---------------------------------
+// MockInvitationRepository
+// ---------------------------------
+
+type MockInvitationRepository struct {
+	mock.Mock
+}
+
+func (r *MockInvitationRepository) CreateInvitation(ctx context.Context, invitation *Invitation) (*Invitation, error) {
+	args := r.Called(ctx, invitation)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Invitation), args.Error(1)
+}
+
+func (r *MockInvitationRepository) ListPendingInvitations(ctx context.Context) ([]*Invitation, error) {
+	args := r.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*Invitation), args.Error(1)
+}
+
+func (r *MockInvitationRepository) RevokeInvitation(ctx context.Context, id string) (*Invitation, error) {
+	args := r.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Invitation), args.Error(1)
+}
+
+func (r *MockInvitationRepository) ClaimInvitation(ctx context.Context, tokenHash string) (*Invitation, error) {
+	args := r.Called(ctx, tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*Invitation), args.Error(1)
+}
+
+func (r *MockInvitationRepository) ReleaseInvitation(ctx context.Context, id uuid.UUID) error {
+	args := r.Called(ctx, id)
+	return args.Error(0)
+}
+
+// ---------------------------------
+// MockMailer
+// ---------------------------------
+
+type MockMailer struct {
+	mock.Mock
+}
+
+func (m *MockMailer) SendMail(ctx context.Context, to, subject, body string) error {
+	args := m.Called(ctx, to, subject, body)
+	return args.Error(0)
+}
+
+// ---------------------------------
+// MockUserCreator
+// ---------------------------------
+
+type MockUserCreator struct {
+	mock.Mock
+}
+
+func (c *MockUserCreator) CreateUser(ctx context.Context, user *User) (*User, error) {
+	args := c.Called(ctx, user)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*User), args.Error(1)
+}
+
+// ---------------------------------
+// MockIdempotencyRepository
+// ---------------------------------
+
+type MockIdempotencyRepository struct {
+	mock.Mock
+}
+
+func (r *MockIdempotencyRepository) ReserveIdempotencyKey(ctx context.Context, record *IdempotencyRecord, now time.Time) (*IdempotencyRecord, error) {
+	args := r.Called(ctx, record, now)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*IdempotencyRecord), args.Error(1)
+}
+
+func (r *MockIdempotencyRepository) CompleteIdempotencyKey(ctx context.Context, scope, key string, statusCode int, headers map[string][]string, body []byte, now time.Time) error {
+	args := r.Called(ctx, scope, key, statusCode, headers, body, now)
+	return args.Error(0)
+}
+
+func (r *MockIdempotencyRepository) DeleteIdempotencyKey(ctx context.Context, scope, key string) error {
+	args := r.Called(ctx, scope, key)
+	return args.Error(0)
+}
+
+func (r *MockIdempotencyRepository) DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	args := r.Called(ctx, now)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// ---------------------------------
+// MockUserImportRepository
+// ---------------------------------
+
+type MockUserImportRepository struct {
+	mock.Mock
+}
+
+func (r *MockUserImportRepository) CreateUserImport(ctx context.Context, userImport *UserImport, payload []byte) (*UserImport, error) {
+	args := r.Called(ctx, userImport, payload)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*UserImport), args.Error(1)
+}
+
+func (r *MockUserImportRepository) GetUserImport(ctx context.Context, id string) (*UserImport, error) {
+	args := r.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*UserImport), args.Error(1)
+}
+
+func (r *MockUserImportRepository) ClaimUserImport(ctx context.Context, staleAfter time.Duration) (*UserImport, []byte, error) {
+	args := r.Called(ctx, staleAfter)
+	if args.Get(0) == nil {
+		return nil, nil, args.Error(2)
+	}
+	return args.Get(0).(*UserImport), args.Get(1).([]byte), args.Error(2)
+}
+
+func (r *MockUserImportRepository) SaveUserImportBatch(ctx context.Context, id uuid.UUID, batch *UserImportBatch) (*UserImport, error) {
+	args := r.Called(ctx, id, batch)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*UserImport), args.Error(1)
+}
+
+func (r *MockUserImportRepository) FinishUserImport(ctx context.Context, id uuid.UUID, status string) (*UserImport, error) {
+	args := r.Called(ctx, id, status)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*UserImport), args.Error(1)
+}
@@ -0,0 +1,28 @@
+package core
+
+var ErrInvalidProfile = NewError(ErrValidation, "invalid_profile", "invalid profile")
+
+// MergeProfile applies patch to profile following JSON Merge Patch (RFC 7396)
+// semantics: nested objects are merged recursively and null values remove the
+// key. The original profile is left untouched.
+func MergeProfile(profile, patch map[string]any) map[string]any {
+	merged := make(map[string]any, len(profile)+len(patch))
+	for key, value := range profile {
+		merged[key] = value
+	}
+
+	for key, value := range patch {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		patchObject, isObject := value.(map[string]any)
+		if !isObject {
+			merged[key] = value
+			continue
+		}
+		existing, _ := merged[key].(map[string]any)
+		merged[key] = MergeProfile(existing, patchObject)
+	}
+	return merged
+}
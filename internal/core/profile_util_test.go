@@ -0,0 +1,56 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMergeProfile(t *testing.T) {
+	testScenarios := []struct {
+		name     string
+		profile  map[string]any
+		patch    map[string]any
+		expected map[string]any
+	}{
+		{
+			name:     "adds new keys",
+			profile:  map[string]any{"locale": "en"},
+			patch:    map[string]any{"timezone": "Africa/Johannesburg"},
+			expected: map[string]any{"locale": "en", "timezone": "Africa/Johannesburg"},
+		},
+		{
+			name:     "replaces existing keys",
+			profile:  map[string]any{"locale": "en"},
+			patch:    map[string]any{"locale": "zu"},
+			expected: map[string]any{"locale": "zu"},
+		},
+		{
+			name:     "null removes keys",
+			profile:  map[string]any{"locale": "en", "display_name": "Jane"},
+			patch:    map[string]any{"display_name": nil},
+			expected: map[string]any{"locale": "en"},
+		},
+		{
+			name:     "merges nested objects",
+			profile:  map[string]any{"preferences": map[string]any{"theme": "dark", "digest": true}},
+			patch:    map[string]any{"preferences": map[string]any{"digest": nil, "beta": true}},
+			expected: map[string]any{"preferences": map[string]any{"theme": "dark", "beta": true}},
+		},
+		{
+			name:     "merges into nil profile",
+			profile:  nil,
+			patch:    map[string]any{"locale": "fr"},
+			expected: map[string]any{"locale": "fr"},
+		},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			result := MergeProfile(scenario.profile, scenario.patch)
+			if diff := cmp.Diff(scenario.expected, result); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package core
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("go-rest-api/internal/core")
+
+// endSpan ends span, marking it failed when err is an internal failure.
+// Domain errors such as ErrUserNotFound answer the caller rather than fail,
+// so only their code is recorded.
+func endSpan(span trace.Span, err error) {
+	var domainErr *Error
+	switch {
+	case err == nil:
+	case errors.As(err, &domainErr) && ErrorKind(err) != nil:
+		span.SetAttributes(attribute.String("error.code", domainErr.Code))
+	default:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
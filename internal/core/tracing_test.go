@@ -0,0 +1,71 @@
+package core
+
+import (
+	"context"
+	"go-rest-api/pkg/logger"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestEndSpan(t *testing.T) {
+	testScenarios := []struct {
+		name               string
+		err                error
+		expectedStatus     codes.Code
+		expectedAttributes []attribute.KeyValue
+	}{
+		{name: "success"},
+		{name: "domain error", err: ErrUserNotFound, expectedAttributes: []attribute.KeyValue{attribute.String("error.code", "user_not_found")}},
+		{name: "internal failure", err: assert.AnError, expectedStatus: codes.Error},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			recorder := tracetest.NewSpanRecorder()
+			_, span := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test").Start(context.Background(), "UserService.GetUserByID")
+
+			// when
+			endSpan(span, scenario.err)
+
+			// then
+			ended := recorder.Ended()
+			a.Len(ended, 1)
+			a.Equal(scenario.expectedStatus, ended[0].Status().Code)
+			a.Equal(scenario.expectedAttributes, ended[0].Attributes())
+		})
+	}
+}
+
+func TestUserService_CreateUser_Spans(t *testing.T) {
+	a := assert.New(t)
+	// given
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	mockUserRepo := MockUserRepository{}
+	mockUserEvent := MockUserEventService{}
+	mockUserEvent.On("PublishUserCreatedEvent", mock.Anything, mock.Anything).Return(nil)
+	userService := NewUserService(&mockUserRepo, &logger.MockLogger{}, &mockUserEvent, &MockBlobStore{}, &MockProfileValidator{})
+	testUser := User{ID: uuid.New(), Username: "JohnDoe123", Email: "johndoe@gmail.com", Password: "password"}
+	mockUserRepo.On("CreateUser", mock.Anything, &testUser).Return(&testUser, nil)
+
+	// when
+	_, err := userService.CreateUser(context.Background(), &testUser)
+
+	// then
+	a.NoError(err)
+	ended := recorder.Ended()
+	a.Len(ended, 2)
+	a.Equal("HashPassword", ended[0].Name())
+	a.Equal("UserService.CreateUser", ended[1].Name())
+	a.Equal(ended[1].SpanContext().SpanID(), ended[0].Parent().SpanID())
+}
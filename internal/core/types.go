@@ -2,6 +2,7 @@ package core
 
 import (
 	"github.com/google/uuid"
+	"slices"
 	"time"
 )
 
@@ -10,6 +11,134 @@ type User struct {
 	Username  string
 	Email     string
 	Password  string
+	AvatarURL string
+	Profile   map[string]any
+	Roles     []string
+	// Version is incremented on every update and guards against lost updates
+	// when two clients edit the same user.
+	Version   int64
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
+
+// AnyVersion skips the version check of an update.
+const AnyVersion int64 = 0
+
+const (
+	RoleAdmin = "admin"
+	// RoleExporter allows downloading the full user export.
+	RoleExporter = "exporter"
+)
+
+// IsValidRole reports whether role is one of the roles the API knows about.
+func IsValidRole(role string) bool {
+	return role == RoleAdmin || role == RoleExporter
+}
+
+func (u *User) HasRole(role string) bool {
+	return slices.Contains(u.Roles, role)
+}
+
+// IsAtVersion reports whether an update expecting version may go ahead.
+func (u *User) IsAtVersion(version int64) bool {
+	return version == AnyVersion || u.Version == version
+}
+
+type Invitation struct {
+	ID         uuid.UUID
+	Email      string
+	Roles      []string
+	TokenHash  string
+	InvitedBy  uuid.UUID
+	ExpiresAt  time.Time
+	AcceptedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+func (i *Invitation) IsPending(now time.Time) bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil && now.Before(i.ExpiresAt)
+}
+
+// UserFilter narrows down user listings. Profile is matched by JSON
+// containment, so {"locale": "fr"} matches every profile with that key/value.
+type UserFilter struct {
+	Profile map[string]any
+	Limit   int
+	Offset  int
+}
+
+// IdempotencyRecord is the stored outcome of a request made with an
+// Idempotency-Key. Until CompletedAt is set the request is still in flight and
+// the record acts as a lock, which lapses at LockedUntil.
+type IdempotencyRecord struct {
+	Scope       string
+	Key         string
+	Fingerprint string
+	StatusCode  int
+	Headers     map[string][]string
+	Body        []byte
+	LockedUntil time.Time
+	CompletedAt *time.Time
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+func (r *IdempotencyRecord) IsCompleted() bool {
+	return r.CompletedAt != nil
+}
+
+const (
+	UserImportFormatCSV    = "csv"
+	UserImportFormatNDJSON = "ndjson"
+
+	UserImportStatusPending   = "pending"
+	UserImportStatusRunning   = "running"
+	UserImportStatusCompleted = "completed"
+	UserImportStatusFailed    = "failed"
+)
+
+// UserImport is a bulk user import job. Rows are numbered from 1, not
+// counting the CSV header, and ProcessedRows is the number of leading rows
+// that have been dealt with.
+type UserImport struct {
+	ID            uuid.UUID
+	Status        string
+	Format        string
+	CreatedBy     uuid.UUID
+	TotalRows     int
+	ProcessedRows int
+	ImportedRows  int
+	FailedRows    int
+	Errors        []UserImportRowError
+	CreatedAt     time.Time
+	StartedAt     *time.Time
+	FinishedAt    *time.Time
+}
+
+func (i *UserImport) IsFinished() bool {
+	return i.Status == UserImportStatusCompleted || i.Status == UserImportStatusFailed
+}
+
+// UserImportRowError explains why a row was not imported. Code and Params
+// identify a catalog message, as for domain errors.
+type UserImportRowError struct {
+	Row    int
+	Field  string
+	Code   string
+	Params map[string]string
+}
+
+// ImportedUser is a validated row ready to be inserted.
+type ImportedUser struct {
+	Row  int
+	User *User
+}
+
+// UserImportBatch is the outcome of validating a run of rows. ProcessedRows is
+// the total number of rows processed once the batch is saved.
+type UserImportBatch struct {
+	ProcessedRows int
+	Users         []ImportedUser
+	Errors        []UserImportRowError
+}
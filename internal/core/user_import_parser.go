@@ -0,0 +1,167 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/mail"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// userImportColumns are the fields of an import row, which are also the CSV
+// header names and NDJSON keys.
+var userImportColumns = []string{"username", "email", "password"}
+
+type importRecord struct {
+	Row      int
+	Username string
+	Email    string
+	Password string
+	// Err is set when the row could not be read at all.
+	Err *UserImportRowError
+}
+
+// readImportRecords calls fn for every data row of an import file. Malformed
+// rows are passed on with Err set; only a missing or invalid CSV header and
+// an empty file fail the whole import.
+func readImportRecords(format string, data []byte, fn func(importRecord) error) error {
+	switch format {
+	case UserImportFormatCSV:
+		return readCSVRecords(data, fn)
+	case UserImportFormatNDJSON:
+		return readNDJSONRecords(data, fn)
+	}
+	return NewError(ErrValidation, "import_unsupported_format", "import format must be csv or ndjson")
+}
+
+func readCSVRecords(data []byte, fn func(importRecord) error) error {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return NewError(ErrValidation, "import_empty", "import file is empty")
+	}
+	if err != nil {
+		return WrapError(ErrValidation, err, "import_row_malformed", "import header is malformed")
+	}
+	positions := make(map[string]int, len(header))
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if !slices.Contains(userImportColumns, column) {
+			return &Error{Kind: ErrValidation, Code: "import_unknown_column", Message: "unknown import column " + column, Params: map[string]string{"column": column}}
+		}
+		positions[column] = i
+	}
+	for _, column := range userImportColumns {
+		if _, ok := positions[column]; !ok {
+			return &Error{Kind: ErrValidation, Code: "import_missing_column", Message: "missing import column " + column, Params: map[string]string{"column": column}}
+		}
+	}
+
+	for row := 1; ; row++ {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		record := importRecord{Row: row}
+		var parseErr *csv.ParseError
+		switch {
+		case errors.As(err, &parseErr):
+			record.Err = &UserImportRowError{Row: row, Code: "import_row_malformed"}
+		case err != nil:
+			return err
+		case len(fields) != len(header):
+			record.Err = &UserImportRowError{Row: row, Code: "import_row_malformed"}
+		default:
+			record.Username = fields[positions["username"]]
+			record.Email = fields[positions["email"]]
+			record.Password = fields[positions["password"]]
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+}
+
+func readNDJSONRecords(data []byte, fn func(importRecord) error) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), MaxUserImportSize)
+
+	row := 0
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		row++
+
+		var fields struct {
+			Username string `json:"username"`
+			Email    string `json:"email"`
+			Password string `json:"password"`
+		}
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.DisallowUnknownFields()
+		record := importRecord{Row: row}
+		if err := decoder.Decode(&fields); err != nil || decoder.More() {
+			record.Err = &UserImportRowError{Row: row, Code: "import_row_malformed"}
+		} else {
+			record.Username, record.Email, record.Password = fields.Username, fields.Email, fields.Password
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return WrapError(ErrValidation, err, "import_row_malformed", "import file is malformed")
+	}
+	if row == 0 {
+		return NewError(ErrValidation, "import_empty", "import file is empty")
+	}
+	return nil
+}
+
+// validateImportRecord applies the same rules as POST /users to a row.
+func validateImportRecord(record importRecord) []UserImportRowError {
+	var rowErrors []UserImportRowError
+	fail := func(field, code string, params map[string]string) {
+		rowErrors = append(rowErrors, UserImportRowError{Row: record.Row, Field: field, Code: code, Params: params})
+	}
+	lengthParam := func(n int) map[string]string {
+		return map[string]string{"param": strconv.Itoa(n)}
+	}
+
+	switch length := utf8.RuneCountInString(record.Username); {
+	case length == 0:
+		fail("username", "validation.required", nil)
+	case length < 3:
+		fail("username", "validation.min_length", lengthParam(3))
+	case length > 50:
+		fail("username", "validation.max_length", lengthParam(50))
+	}
+
+	if record.Email == "" {
+		fail("email", "validation.required", nil)
+	} else if address, err := mail.ParseAddress(record.Email); err != nil || address.Address != record.Email {
+		fail("email", "validation.email", nil)
+	}
+
+	// ... bcrypt only looks at the first 72 bytes
+	switch length := utf8.RuneCountInString(record.Password); {
+	case length == 0:
+		fail("password", "validation.required", nil)
+	case length < 6:
+		fail("password", "validation.min_length", lengthParam(6))
+	case len(record.Password) > 72:
+		fail("password", "validation.max_length", lengthParam(72))
+	}
+	return rowErrors
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func collectImportRecords(t *testing.T, format, data string) ([]importRecord, error) {
+	t.Helper()
+	var records []importRecord
+	err := readImportRecords(format, []byte(data), func(record importRecord) error {
+		records = append(records, record)
+		return nil
+	})
+	return records, err
+}
+
+func TestReadImportRecords_CSV(t *testing.T) {
+	a := assert.New(t)
+
+	// when
+	records, err := collectImportRecords(t, UserImportFormatCSV, "email,username,password\njane@gmail.com,jane,secret1\nbroken\n")
+
+	// then
+	a.NoError(err)
+	a.Len(records, 2)
+	a.Equal(importRecord{Row: 1, Username: "jane", Email: "jane@gmail.com", Password: "secret1"}, records[0])
+	a.Equal(2, records[1].Row)
+	a.Equal("import_row_malformed", records[1].Err.Code)
+}
+
+func TestReadImportRecords_NDJSON(t *testing.T) {
+	a := assert.New(t)
+
+	// when
+	records, err := collectImportRecords(t, UserImportFormatNDJSON, "{\"username\":\"jane\",\"email\":\"jane@gmail.com\",\"password\":\"secret1\"}\n\n{\"username\":\"john\",\"admin\":true}\n")
+
+	// then
+	a.NoError(err)
+	a.Len(records, 2)
+	a.Equal(importRecord{Row: 1, Username: "jane", Email: "jane@gmail.com", Password: "secret1"}, records[0])
+	a.Equal("import_row_malformed", records[1].Err.Code)
+}
+
+func TestReadImportRecords_FileErrors(t *testing.T) {
+	testScenarios := []struct {
+		name         string
+		format       string
+		data         string
+		expectedCode string
+	}{
+		{name: "empty csv", format: UserImportFormatCSV, data: "", expectedCode: "import_empty"},
+		{name: "empty ndjson", format: UserImportFormatNDJSON, data: "\n\n", expectedCode: "import_empty"},
+		{name: "missing column", format: UserImportFormatCSV, data: "username,email\n", expectedCode: "import_missing_column"},
+		{name: "unknown column", format: UserImportFormatCSV, data: "username,email,password,roles\n", expectedCode: "import_unknown_column"},
+		{name: "unknown format", format: "xml", data: "<users/>", expectedCode: "import_unsupported_format"},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+
+			// when
+			_, err := collectImportRecords(t, scenario.format, scenario.data)
+
+			// then
+			a.ErrorIs(err, ErrValidation)
+			var domainErr *Error
+			a.ErrorAs(err, &domainErr)
+			a.Equal(scenario.expectedCode, domainErr.Code)
+		})
+	}
+}
+
+func TestValidateImportRecord(t *testing.T) {
+	a := assert.New(t)
+
+	// when
+	rowErrors := validateImportRecord(importRecord{Row: 4, Username: "jo", Email: "not-an-email", Password: ""})
+
+	// then
+	a.Equal([]UserImportRowError{
+		{Row: 4, Field: "username", Code: "validation.min_length", Params: map[string]string{"param": "3"}},
+		{Row: 4, Field: "email", Code: "validation.email"},
+		{Row: 4, Field: "password", Code: "validation.required"},
+	}, rowErrors)
+	a.Empty(validateImportRecord(importRecord{Row: 1, Username: "jane", Email: "jane@gmail.com", Password: "secret1"}))
+}
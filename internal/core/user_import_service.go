@@ -0,0 +1,262 @@
+package core
+
+import (
+	"context"
+	"go-rest-api/pkg/logger"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxUserImportSize caps the size of an import file.
+	MaxUserImportSize = 32 << 20
+	// userImportBatchSize is the number of rows inserted and reported per
+	// transaction.
+	userImportBatchSize = 200
+	// userImportPollInterval is how often the worker looks for imports created
+	// on other instances.
+	userImportPollInterval = 5 * time.Second
+	// UserImportStaleAfter is how long a running import may go without
+	// progress before another worker takes it over.
+	UserImportStaleAfter = 5 * time.Minute
+)
+
+var ErrUserImportNotFound = NewError(ErrNotFound, "user_import_not_found", "user import not found")
+
+type UserImportRepository interface {
+	CreateUserImport(ctx context.Context, userImport *UserImport, payload []byte) (*UserImport, error)
+	// GetUserImport returns nil when no import has the given id.
+	GetUserImport(ctx context.Context, id string) (*UserImport, error)
+	// ClaimUserImport marks the oldest pending import, or a running one that
+	// made no progress for staleAfter, as running and returns it with its
+	// payload. It returns nil when there is nothing to do.
+	ClaimUserImport(ctx context.Context, staleAfter time.Duration) (*UserImport, []byte, error)
+	// SaveUserImportBatch inserts the users of the batch, reporting those whose
+	// email or username is taken as row errors, and records the progress in
+	// the same transaction so that an interrupted import can be resumed.
+	SaveUserImportBatch(ctx context.Context, id uuid.UUID, batch *UserImportBatch) (*UserImport, error)
+	// FinishUserImport sets the final status and drops the payload.
+	FinishUserImport(ctx context.Context, id uuid.UUID, status string) (*UserImport, error)
+}
+
+type UserImportService struct {
+	repo         UserImportRepository
+	eventService UserEventService
+	logger       logger.CustomLogger
+	// wake nudges the worker when an import is created on this instance.
+	wake chan struct{}
+}
+
+// NewUserImportService creates a UserImportService. Imported users get a single
+// summary event per import rather than a user created event each.
+func NewUserImportService(repo UserImportRepository, eventService UserEventService, logger logger.CustomLogger) *UserImportService {
+	return &UserImportService{
+		repo:         repo,
+		eventService: eventService,
+		logger:       logger,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// CreateImport checks the file's structure, stores it and queues it for the
+// worker. Invalid rows do not fail the import; they are reported per row.
+func (s *UserImportService) CreateImport(ctx context.Context, format string, data []byte, createdBy uuid.UUID) (*UserImport, error) {
+	totalRows := 0
+	if err := readImportRecords(format, data, func(importRecord) error {
+		totalRows++
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	userImport, err := s.repo.CreateUserImport(ctx, &UserImport{
+		ID:        uuid.New(),
+		Status:    UserImportStatusPending,
+		Format:    format,
+		CreatedBy: createdBy,
+		TotalRows: totalRows,
+		CreatedAt: time.Now(),
+	}, data)
+	if err != nil {
+		s.logger.FromContext(ctx).Error("failed to create user import", "error", err)
+		return nil, err
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return userImport, nil
+}
+
+// GetImport returns ErrUserImportNotFound when the import does not exist.
+func (s *UserImportService) GetImport(ctx context.Context, id string) (*UserImport, error) {
+	userImport, err := s.repo.GetUserImport(ctx, id)
+	if err != nil {
+		s.logger.FromContext(ctx).Error("failed to get user import", "error", err)
+		return nil, err
+	}
+	if userImport == nil {
+		return nil, ErrUserImportNotFound
+	}
+	return userImport, nil
+}
+
+// Run processes queued imports until ctx is cancelled.
+func (s *UserImportService) Run(ctx context.Context) {
+	ticker := time.NewTicker(userImportPollInterval)
+	defer ticker.Stop()
+	for {
+		s.processQueued(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *UserImportService) processQueued(ctx context.Context) {
+	for ctx.Err() == nil {
+		userImport, payload, err := s.repo.ClaimUserImport(ctx, UserImportStaleAfter)
+		if err != nil {
+			s.logger.FromContext(ctx).Error("failed to claim user import", "error", err)
+			return
+		}
+		if userImport == nil {
+			return
+		}
+
+		status := UserImportStatusCompleted
+		if err := s.process(ctx, userImport, payload); err != nil {
+			if ctx.Err() != nil {
+				// ... leave it running, another worker resumes it once stale
+				return
+			}
+			s.logger.FromContext(ctx).Error("user import failed", "error", err, "import_id", userImport.ID)
+			status = UserImportStatusFailed
+		}
+
+		finished, err := s.repo.FinishUserImport(ctx, userImport.ID, status)
+		if err != nil {
+			s.logger.FromContext(ctx).Error("failed to finish user import", "error", err)
+			continue
+		}
+		if err := s.eventService.PublishUsersImportedEvent(ctx, finished); err != nil {
+			s.logger.FromContext(ctx).Error("failed to publish users imported event", "error", err)
+		}
+	}
+}
+
+// process imports the rows of userImport that have not been processed yet.
+// Rows that were processed before an interruption are skipped but still count
+// towards the duplicate check.
+func (s *UserImportService) process(ctx context.Context, userImport *UserImport, payload []byte) error {
+	seenEmails := map[string]bool{}
+	seenUsernames := map[string]bool{}
+	var pending []importRecord
+	batch := &UserImportBatch{}
+
+	flush := func() error {
+		if len(pending) == 0 && len(batch.Errors) == 0 {
+			return nil
+		}
+		users, err := s.hashImportedUsers(ctx, pending)
+		if err != nil {
+			return err
+		}
+		batch.Users = users
+		if _, err := s.repo.SaveUserImportBatch(ctx, userImport.ID, batch); err != nil {
+			return err
+		}
+		pending = nil
+		batch = &UserImportBatch{ProcessedRows: batch.ProcessedRows}
+		return nil
+	}
+
+	err := readImportRecords(userImport.Format, payload, func(record importRecord) error {
+		record.Email = strings.ToLower(strings.TrimSpace(record.Email))
+		record.Username = strings.TrimSpace(record.Username)
+		resumed := record.Row <= userImport.ProcessedRows
+
+		rowErrors := validateImportRecord(record)
+		if record.Err != nil {
+			rowErrors = []UserImportRowError{*record.Err}
+		}
+		if len(rowErrors) == 0 {
+			if seenEmails[record.Email] {
+				rowErrors = append(rowErrors, UserImportRowError{Row: record.Row, Field: "email", Code: "import_duplicate_row", Params: map[string]string{"field": "email"}})
+			}
+			if seenUsernames[record.Username] {
+				rowErrors = append(rowErrors, UserImportRowError{Row: record.Row, Field: "username", Code: "import_duplicate_row", Params: map[string]string{"field": "username"}})
+			}
+			seenEmails[record.Email] = true
+			seenUsernames[record.Username] = true
+		}
+		if resumed {
+			return nil
+		}
+
+		batch.ProcessedRows = record.Row
+		if len(rowErrors) > 0 {
+			batch.Errors = append(batch.Errors, rowErrors...)
+		} else {
+			pending = append(pending, record)
+		}
+		if record.Row%userImportBatchSize == 0 {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+// hashImportedUsers hashes the passwords of the records in parallel, which is
+// where most of the time of an import goes.
+func (s *UserImportService) hashImportedUsers(ctx context.Context, records []importRecord) ([]ImportedUser, error) {
+	users := make([]ImportedUser, len(records))
+	errs := make([]error, len(records))
+	semaphore := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	for i, record := range records {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			hashedPassword, err := HashPassword(record.Password)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			now := time.Now()
+			users[i] = ImportedUser{Row: record.Row, User: &User{
+				ID:        uuid.New(),
+				Username:  record.Username,
+				Email:     record.Email,
+				Password:  hashedPassword,
+				Roles:     []string{},
+				CreatedAt: now,
+				UpdatedAt: now,
+			}}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			s.logger.FromContext(ctx).Error("failed to hash imported password", "error", err)
+			return nil, err
+		}
+	}
+	return users, nil
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"context"
+	"go-rest-api/pkg/logger"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestUserImportService_CreateImport(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockRepo := MockUserImportRepository{}
+	userImportService := NewUserImportService(&mockRepo, &MockUserEventService{}, &logger.MockLogger{})
+	data := []byte("username,email,password\njane,jane@gmail.com,secret1\njohn,john@gmail.com,secret2\n")
+	adminID := uuid.New()
+	mockRepo.On("CreateUserImport", mock.Anything, mock.MatchedBy(func(userImport *UserImport) bool {
+		return userImport.TotalRows == 2 && userImport.Status == UserImportStatusPending && userImport.CreatedBy == adminID
+	}), data).Return(&UserImport{TotalRows: 2}, nil)
+
+	// when
+	userImport, err := userImportService.CreateImport(context.Background(), UserImportFormatCSV, data, adminID)
+
+	// then
+	a.NoError(err)
+	a.Equal(2, userImport.TotalRows)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUserImportService_CreateImport_InvalidFile(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockRepo := MockUserImportRepository{}
+	userImportService := NewUserImportService(&mockRepo, &MockUserEventService{}, &logger.MockLogger{})
+
+	// when
+	userImport, err := userImportService.CreateImport(context.Background(), UserImportFormatCSV, []byte("name,mail\n"), uuid.New())
+
+	// then
+	a.ErrorIs(err, ErrValidation)
+	a.Nil(userImport)
+	mockRepo.AssertNotCalled(t, "CreateUserImport", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUserImportService_ProcessQueued(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockLogger := logger.MockLogger{}
+	mockRepo := MockUserImportRepository{}
+	mockUserEvent := MockUserEventService{}
+	userImportService := NewUserImportService(&mockRepo, &mockUserEvent, &mockLogger)
+
+	userImport := &UserImport{ID: uuid.New(), Format: UserImportFormatNDJSON, Status: UserImportStatusRunning}
+	payload := []byte(`{"username":"jane","email":"Jane@gmail.com","password":"secret1"}
+{"username":"jo","email":"jo@gmail.com","password":"secret2"}
+{"username":"janet","email":"jane@gmail.com","password":"secret3"}
+`)
+	finished := &UserImport{ID: userImport.ID, Status: UserImportStatusCompleted}
+	var savedBatch *UserImportBatch
+	mockRepo.On("ClaimUserImport", mock.Anything, UserImportStaleAfter).Return(userImport, payload, nil).Once()
+	mockRepo.On("ClaimUserImport", mock.Anything, UserImportStaleAfter).Return(nil, nil, nil).Once()
+	mockRepo.On("SaveUserImportBatch", mock.Anything, userImport.ID, mock.Anything).Run(func(args mock.Arguments) {
+		savedBatch = args.Get(2).(*UserImportBatch)
+	}).Return(userImport, nil)
+	mockRepo.On("FinishUserImport", mock.Anything, userImport.ID, UserImportStatusCompleted).Return(finished, nil)
+	mockUserEvent.On("PublishUsersImportedEvent", mock.Anything, finished).Return(nil)
+
+	// when
+	userImportService.processQueued(context.Background())
+
+	// then
+	a.Equal(3, savedBatch.ProcessedRows)
+	a.Len(savedBatch.Users, 1)
+	a.Equal("jane@gmail.com", savedBatch.Users[0].User.Email)
+	a.NoError(VerifyPassword(savedBatch.Users[0].User.Password, "secret1"))
+	a.Equal([]UserImportRowError{
+		{Row: 2, Field: "username", Code: "validation.min_length", Params: map[string]string{"param": "3"}},
+		{Row: 3, Field: "email", Code: "import_duplicate_row", Params: map[string]string{"field": "email"}},
+	}, savedBatch.Errors)
+	mockUserEvent.AssertExpectations(t)
+}
+
+func TestUserImportService_ProcessQueued_Resumes(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockRepo := MockUserImportRepository{}
+	mockUserEvent := MockUserEventService{}
+	userImportService := NewUserImportService(&mockRepo, &mockUserEvent, &logger.MockLogger{})
+
+	userImport := &UserImport{ID: uuid.New(), Format: UserImportFormatCSV, Status: UserImportStatusRunning, ProcessedRows: 1}
+	payload := []byte("username,email,password\njane,jane@gmail.com,secret1\njane,jane2@gmail.com,secret2\n")
+	var savedBatch *UserImportBatch
+	mockRepo.On("ClaimUserImport", mock.Anything, UserImportStaleAfter).Return(userImport, payload, nil).Once()
+	mockRepo.On("ClaimUserImport", mock.Anything, UserImportStaleAfter).Return(nil, nil, nil).Once()
+	mockRepo.On("SaveUserImportBatch", mock.Anything, userImport.ID, mock.Anything).Run(func(args mock.Arguments) {
+		savedBatch = args.Get(2).(*UserImportBatch)
+	}).Return(userImport, nil)
+	mockRepo.On("FinishUserImport", mock.Anything, userImport.ID, UserImportStatusCompleted).Return(userImport, nil)
+	mockUserEvent.On("PublishUsersImportedEvent", mock.Anything, userImport).Return(nil)
+
+	// when
+	userImportService.processQueued(context.Background())
+
+	// then
+	a.Equal(2, savedBatch.ProcessedRows)
+	a.Empty(savedBatch.Users)
+	a.Equal([]UserImportRowError{
+		{Row: 2, Field: "username", Code: "import_duplicate_row", Params: map[string]string{"field": "username"}},
+	}, savedBatch.Errors)
+}
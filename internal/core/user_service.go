@@ -1,41 +1,89 @@
 package core
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"go-rest-api/internal/metrics"
 	"go-rest-api/pkg/logger"
+	"io"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+var (
+	ErrUserNotFound          = NewError(ErrNotFound, "user_not_found", "user not found")
+	ErrUsernameTaken         = NewError(ErrConflict, "username_taken", "username is already taken")
+	ErrInvalidCredentials    = NewError(ErrUnauthorized, "invalid_credentials", "invalid email or password")
+	ErrCannotImpersonateSelf = NewError(ErrValidation, "cannot_impersonate_self", "cannot impersonate yourself")
+	ErrUserVersionMismatch   = NewError(ErrPreconditionFailed, "version_mismatch", "user was modified by another request")
+)
+
 type UserEventService interface {
 	PublishUserCreatedEvent(ctx context.Context, user *User) error
+	PublishUsersImportedEvent(ctx context.Context, userImport *UserImport) error
 }
 
 type UserRepository interface {
 	CreateUser(ctx context.Context, user *User) (*User, error)
 	GetUserByID(ctx context.Context, id string) (*User, error)
+	// GetUsersByIDs returns the users that exist among ids, in no particular
+	// order.
+	GetUsersByIDs(ctx context.Context, ids []string) ([]*User, error)
 	GetUserByEmail(ctx context.Context, email string) (*User, error)
+	// UpdateUserAvatar and UpdateUserProfile only update the user while it is
+	// still at version, unless version is AnyVersion, and bump its version.
+	// They return ErrUserVersionMismatch when the user has moved on.
+	UpdateUserAvatar(ctx context.Context, id string, avatarURL string, version int64) (*User, error)
+	UpdateUserProfile(ctx context.Context, id string, profile map[string]any, version int64) (*User, error)
+	ListUsers(ctx context.Context, filter UserFilter) ([]*User, error)
+	// StreamUsers calls fn for every user matching the filter's profile, in
+	// listing order, without loading them all at once. Limit and Offset are
+	// ignored. An error returned by fn stops the stream and is returned as is.
+	StreamUsers(ctx context.Context, filter UserFilter, fn func(*User) error) error
+}
+
+// ProfileValidator checks a complete profile document against the configured
+// JSON Schema.
+type ProfileValidator interface {
+	ValidateProfile(profile map[string]any) error
+}
+
+// BlobStore stores binary objects such as avatar thumbnails and returns the
+// public URL they can be fetched from.
+type BlobStore interface {
+	Put(ctx context.Context, key string, data io.Reader, size int64, contentType string) (string, error)
+	Delete(ctx context.Context, key string) error
 }
 
 type UserService struct {
 	repo             UserRepository
 	logger           logger.CustomLogger
 	userEventService UserEventService
+	blobStore        BlobStore
+	profileValidator ProfileValidator
 }
 
-func NewUserService(repo UserRepository, logger logger.CustomLogger, userEventService UserEventService) *UserService {
+func NewUserService(repo UserRepository, logger logger.CustomLogger, userEventService UserEventService, blobStore BlobStore, profileValidator ProfileValidator) *UserService {
 	return &UserService{
 		repo:             repo,
 		logger:           logger,
 		userEventService: userEventService,
+		blobStore:        blobStore,
+		profileValidator: profileValidator,
 	}
 }
 
-func (s *UserService) CreateUser(ctx context.Context, user *User) (*User, error) {
+func (s *UserService) CreateUser(ctx context.Context, user *User) (_ *User, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.CreateUser")
+	defer func() { endSpan(span, err) }()
+
+	_, hashSpan := tracer.Start(ctx, "HashPassword")
 	hashedPassword, err := HashPassword(user.Password)
+	hashSpan.End()
 	if err != nil {
-		s.logger.Error("failed to hash password: ", err)
+		s.logger.FromContext(ctx).Error("failed to hash password", "error", err)
 		return nil, err
 	}
 	user.ID = uuid.New()
@@ -44,46 +92,218 @@ func (s *UserService) CreateUser(ctx context.Context, user *User) (*User, error)
 	user.Password = hashedPassword
 	result, err := s.repo.CreateUser(ctx, user)
 	if err != nil {
-		s.logger.Error("failed to create user: ", err)
+		s.logger.FromContext(ctx).Error("failed to create user", "error", err)
 		return nil, err
 	}
 
+	metrics.Signups.Inc()
+
 	// ... publish user created event
 	go func() {
 		if err := s.userEventService.PublishUserCreatedEvent(ctx, user); err != nil {
-			s.logger.Error("failed to publish user created event: ", err)
+			s.logger.FromContext(ctx).Error("failed to publish user created event", "error", err)
 		}
 	}()
 
 	return result, nil
 }
 
-func (s *UserService) GetUserByID(ctx context.Context, id string) (*User, error) {
-	return s.repo.GetUserByID(ctx, id)
+// GetUserByID returns ErrUserNotFound when the user does not exist.
+func (s *UserService) GetUserByID(ctx context.Context, id string) (_ *User, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.GetUserByID")
+	defer func() { endSpan(span, err) }()
+
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// GetUsersByIDs loads several users in one repository call. Ids that do not
+// exist are left out rather than reported.
+func (s *UserService) GetUsersByIDs(ctx context.Context, ids []string) (_ []*User, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.GetUsersByIDs")
+	defer func() { endSpan(span, err) }()
+
+	users, err := s.repo.GetUsersByIDs(ctx, ids)
+	if err != nil {
+		s.logger.FromContext(ctx).Error("failed to get users by ids", "error", err)
+		return nil, err
+	}
+	return users, nil
 }
 
-func (s *UserService) LoginUser(ctx context.Context, email, password, jwtSecret string) (string, error) {
+func (s *UserService) LoginUser(ctx context.Context, email, password, jwtSecret string) (_ string, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.LoginUser")
+	defer func() { endSpan(span, err) }()
+
 	user, err := s.repo.GetUserByEmail(ctx, email)
 	if err != nil {
-		s.logger.Error("failed to get user by email for authentication: ", err)
+		s.logger.FromContext(ctx).Error("failed to get user by email for authentication", "error", err)
 		return "", err
 	}
 
 	if user == nil {
-		s.logger.Error("user not found with email: ", email)
-		return "", nil
+		s.logger.FromContext(ctx).Error("user not found", "email", email)
+		metrics.Logins.WithLabelValues("failed").Inc()
+		return "", ErrInvalidCredentials
 	}
 
-	if err = VerifyPassword(user.Password, password); err != nil {
-		s.logger.Error("password verification failed: ", err)
-		return "", err
+	_, verifySpan := tracer.Start(ctx, "VerifyPassword")
+	err = VerifyPassword(user.Password, password)
+	verifySpan.End()
+	if err != nil {
+		s.logger.FromContext(ctx).Error("password verification failed", "error", err)
+		metrics.Logins.WithLabelValues("failed").Inc()
+		return "", ErrInvalidCredentials
 	}
 
-	token, err := GenerateAuthToken(user.ID, jwtSecret)
+	token, err := GenerateAuthToken(user.ID, user.Roles, jwtSecret)
 	if err != nil {
-		s.logger.Error("failed to generate auth token: ", err)
+		s.logger.FromContext(ctx).Error("failed to generate auth token", "error", err)
 		return "", err
 	}
 
+	metrics.Logins.WithLabelValues("succeeded").Inc()
+	metrics.TokensIssued.WithLabelValues("auth").Inc()
 	return token, nil
 }
+
+// ImpersonateUser issues a short-lived impersonation token for the target user
+// on behalf of the given admin.
+func (s *UserService) ImpersonateUser(ctx context.Context, actorID uuid.UUID, targetID, jwtSecret string) (_ string, _ time.Time, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.ImpersonateUser")
+	defer func() { endSpan(span, err) }()
+
+	if actorID.String() == targetID {
+		return "", time.Time{}, ErrCannotImpersonateSelf
+	}
+
+	target, err := s.repo.GetUserByID(ctx, targetID)
+	if err != nil {
+		s.logger.FromContext(ctx).Error("failed to get user for impersonation", "error", err)
+		return "", time.Time{}, err
+	}
+	if target == nil {
+		return "", time.Time{}, ErrUserNotFound
+	}
+
+	token, expiresAt, err := GenerateImpersonationToken(target, actorID, jwtSecret)
+	if err != nil {
+		s.logger.FromContext(ctx).Error("failed to generate impersonation token", "error", err)
+		return "", time.Time{}, err
+	}
+
+	metrics.TokensIssued.WithLabelValues("impersonation").Inc()
+	s.logger.FromContext(ctx).Info("impersonation started", "actor_id", actorID, "subject_id", target.ID, "expires_at", expiresAt)
+	return token, expiresAt, nil
+}
+
+// UpdateAvatar renders the uploaded image into thumbnails, stores them in the
+// blob store and points the user's avatar_url at the largest one. The user must
+// still be at version.
+func (s *UserService) UpdateAvatar(ctx context.Context, userID string, data []byte, version int64) (_ *User, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.UpdateAvatar")
+	defer func() { endSpan(span, err) }()
+
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		s.logger.FromContext(ctx).Error("failed to get user for avatar update", "error", err)
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if !user.IsAtVersion(version) {
+		return nil, ErrUserVersionMismatch
+	}
+
+	thumbnails, err := GenerateAvatarThumbnails(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var avatarURL string
+	for _, thumbnail := range thumbnails {
+		key := fmt.Sprintf("avatars/%s/%d.png", user.ID, thumbnail.Size)
+		url, err := s.blobStore.Put(ctx, key, bytes.NewReader(thumbnail.Data), int64(len(thumbnail.Data)), thumbnail.ContentType)
+		if err != nil {
+			s.logger.FromContext(ctx).Error("failed to store avatar thumbnail", "error", err)
+			return nil, err
+		}
+		avatarURL = url
+	}
+
+	// ... thumbnails are overwritten in place, so bust caches with a version
+	avatarURL = fmt.Sprintf("%s?v=%d", avatarURL, time.Now().Unix())
+	return s.nonNilUser(s.repo.UpdateUserAvatar(ctx, userID, avatarURL, version))
+}
+
+// UpdateUserProfile merge-patches the user's profile and validates the result
+// against the profile schema before storing it. The user must still be at
+// version.
+func (s *UserService) UpdateUserProfile(ctx context.Context, id string, patch map[string]any, version int64) (_ *User, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.UpdateUserProfile")
+	defer func() { endSpan(span, err) }()
+
+	user, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		s.logger.FromContext(ctx).Error("failed to get user for profile update", "error", err)
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if !user.IsAtVersion(version) {
+		return nil, ErrUserVersionMismatch
+	}
+
+	profile := MergeProfile(user.Profile, patch)
+	if err := s.profileValidator.ValidateProfile(profile); err != nil {
+		return nil, &Error{
+			Kind:    ErrInvalidProfile,
+			Code:    ErrInvalidProfile.Code,
+			Message: "invalid profile: " + err.Error(),
+			Params:  map[string]string{"reason": err.Error()},
+		}
+	}
+
+	return s.nonNilUser(s.repo.UpdateUserProfile(ctx, id, profile, version))
+}
+
+func (s *UserService) ListUsers(ctx context.Context, filter UserFilter) (_ []*User, err error) {
+	ctx, span := tracer.Start(ctx, "UserService.ListUsers")
+	defer func() { endSpan(span, err) }()
+
+	users, err := s.repo.ListUsers(ctx, filter)
+	if err != nil {
+		s.logger.FromContext(ctx).Error("failed to list users", "error", err)
+		return nil, err
+	}
+	return users, nil
+}
+
+// ExportUsers calls fn for every user matching the filter, ignoring pagination.
+func (s *UserService) ExportUsers(ctx context.Context, filter UserFilter, fn func(*User) error) (err error) {
+	ctx, span := tracer.Start(ctx, "UserService.ExportUsers")
+	defer func() { endSpan(span, err) }()
+
+	if err := s.repo.StreamUsers(ctx, filter, fn); err != nil {
+		s.logger.FromContext(ctx).Error("failed to export users", "error", err)
+		return err
+	}
+	return nil
+}
+
+// nonNilUser turns the repository's "nil, nil" for a user deleted in the
+// meantime into ErrUserNotFound.
+func (s *UserService) nonNilUser(user *User, err error) (*User, error) {
+	if err == nil && user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, err
+}
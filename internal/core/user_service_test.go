@@ -2,12 +2,17 @@ package core
 
 import (
 	"context"
+	"errors"
+	"go-rest-api/internal/metrics"
 	"go-rest-api/pkg/logger"
+	"strings"
 	"testing"
 	"time"
 
+	jwt "github.com/golang-jwt/jwt/v5"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -19,7 +24,7 @@ func TestUserService_CreateUser(t *testing.T) {
 	mockUserRepo := MockUserRepository{}
 	mockUserEvent := MockUserEventService{}
 	mockUserEvent.On("PublishUserCreatedEvent", mock.Anything, mock.Anything).Return(nil)
-	userService := NewUserService(&mockUserRepo, &mockLogger, &mockUserEvent)
+	userService := NewUserService(&mockUserRepo, &mockLogger, &mockUserEvent, &MockBlobStore{}, &MockProfileValidator{})
 
 	testUser := User{
 		ID:       uuid.New(),
@@ -47,7 +52,7 @@ func TestUserService_CreateUser_ReturnsError(t *testing.T) {
 	mockUserRepo := MockUserRepository{}
 	mockUserEvent := MockUserEventService{}
 	mockUserEvent.On("PublishUserCreatedEvent", mock.Anything, mock.Anything).Return(nil)
-	userService := NewUserService(&mockUserRepo, &mockLogger, &mockUserEvent)
+	userService := NewUserService(&mockUserRepo, &mockLogger, &mockUserEvent, &MockBlobStore{}, &MockProfileValidator{})
 
 	testUser := User{
 		ID:       uuid.New(),
@@ -71,7 +76,7 @@ func TestUserService_GetUser(t *testing.T) {
 	mockUserRepo := MockUserRepository{}
 	mockUserEvent := MockUserEventService{}
 	mockUserEvent.On("PublishUserCreatedEvent", mock.Anything, mock.Anything).Return(nil)
-	userService := NewUserService(&mockUserRepo, &mockLogger, &mockUserEvent)
+	userService := NewUserService(&mockUserRepo, &mockLogger, &mockUserEvent, &MockBlobStore{}, &MockProfileValidator{})
 
 	testUser := User{
 		ID:        uuid.New(),
@@ -98,7 +103,7 @@ func TestUserService_GetUser_NotFound(t *testing.T) {
 	mockUserRepo := MockUserRepository{}
 	mockUserEvent := MockUserEventService{}
 	mockUserEvent.On("PublishUserCreatedEvent", mock.Anything, mock.Anything).Return(nil)
-	userService := NewUserService(&mockUserRepo, &mockLogger, &mockUserEvent)
+	userService := NewUserService(&mockUserRepo, &mockLogger, &mockUserEvent, &MockBlobStore{}, &MockProfileValidator{})
 
 	mockUserRepo.On("GetUserByID", mock.Anything, "non-existent-id").Return(nil, nil)
 
@@ -106,7 +111,8 @@ func TestUserService_GetUser_NotFound(t *testing.T) {
 	user, err := userService.GetUserByID(context.Background(), "non-existent-id")
 
 	// then
-	a.NoError(err)
+	a.ErrorIs(err, ErrUserNotFound)
+	a.ErrorIs(err, ErrNotFound)
 	a.Nil(user)
 }
 
@@ -117,7 +123,7 @@ func TestUserService_GetUser_ReturnAnError(t *testing.T) {
 	mockUserRepo := MockUserRepository{}
 	mockUserEvent := MockUserEventService{}
 	mockUserEvent.On("PublishUserCreatedEvent", mock.Anything, mock.Anything).Return(nil)
-	userService := NewUserService(&mockUserRepo, &mockLogger, &mockUserEvent)
+	userService := NewUserService(&mockUserRepo, &mockLogger, &mockUserEvent, &MockBlobStore{}, &MockProfileValidator{})
 
 	mockUserRepo.On("GetUserByID", mock.Anything, "some-id").Return(nil, assert.AnError)
 
@@ -137,7 +143,7 @@ func TestUserService_LoginUser(t *testing.T) {
 	mockUserRepo := MockUserRepository{}
 	mockUserEvent := MockUserEventService{}
 	mockUserEvent.On("PublishUserCreatedEvent", mock.Anything, mock.Anything).Return(nil)
-	userService := NewUserService(&mockUserRepo, &mockLogger, &mockUserEvent)
+	userService := NewUserService(&mockUserRepo, &mockLogger, &mockUserEvent, &MockBlobStore{}, &MockProfileValidator{})
 
 	hashedPassword, err := HashPassword("password")
 	testUser := User{
@@ -151,12 +157,17 @@ func TestUserService_LoginUser(t *testing.T) {
 	jwtSecret := "mysecretkey"
 	mockUserRepo.On("GetUserByEmail", mock.Anything, testUser.Email).Return(&testUser, nil)
 
+	succeeded := testutil.ToFloat64(metrics.Logins.WithLabelValues("succeeded"))
+	issued := testutil.ToFloat64(metrics.TokensIssued.WithLabelValues("auth"))
+
 	// when
 	token, err := userService.LoginUser(context.Background(), testUser.Email, "password", jwtSecret)
 
 	// then
 	a.NoError(err)
 	a.NotEmpty(token)
+	a.Equal(succeeded+1, testutil.ToFloat64(metrics.Logins.WithLabelValues("succeeded")))
+	a.Equal(issued+1, testutil.ToFloat64(metrics.TokensIssued.WithLabelValues("auth")))
 }
 
 func TestUserService_Login_WrongPassword(t *testing.T) {
@@ -168,7 +179,7 @@ func TestUserService_Login_WrongPassword(t *testing.T) {
 	mockUserRepo := MockUserRepository{}
 	mockUserEvent := MockUserEventService{}
 	mockUserEvent.On("PublishUserCreatedEvent", mock.Anything, mock.Anything).Return(nil)
-	userService := NewUserService(&mockUserRepo, &mockLogger, &mockUserEvent)
+	userService := NewUserService(&mockUserRepo, &mockLogger, &mockUserEvent, &MockBlobStore{}, &MockProfileValidator{})
 
 	hashedPassword, err := HashPassword("password")
 	testUser := User{
@@ -182,12 +193,15 @@ func TestUserService_Login_WrongPassword(t *testing.T) {
 	jwtSecret := "mysecretkey"
 	mockUserRepo.On("GetUserByEmail", mock.Anything, testUser.Email).Return(&testUser, nil)
 
+	failed := testutil.ToFloat64(metrics.Logins.WithLabelValues("failed"))
+
 	// when
 	token, err := userService.LoginUser(context.Background(), testUser.Email, "wrongpassword", jwtSecret)
 
 	// then
-	a.Error(err)
+	a.ErrorIs(err, ErrInvalidCredentials)
 	a.Empty(token)
+	a.Equal(failed+1, testutil.ToFloat64(metrics.Logins.WithLabelValues("failed")))
 }
 
 func TestUserService_Login_ReturnsError(t *testing.T) {
@@ -199,7 +213,7 @@ func TestUserService_Login_ReturnsError(t *testing.T) {
 	mockUserRepo := MockUserRepository{}
 	mockUserEvent := MockUserEventService{}
 	mockUserEvent.On("PublishUserCreatedEvent", mock.Anything, mock.Anything).Return(nil)
-	userService := NewUserService(&mockUserRepo, &mockLogger, &mockUserEvent)
+	userService := NewUserService(&mockUserRepo, &mockLogger, &mockUserEvent, &MockBlobStore{}, &MockProfileValidator{})
 
 	mockUserRepo.On("GetUserByEmail", mock.Anything, "non-existent-email").Return(&User{}, assert.AnError)
 
@@ -210,3 +224,295 @@ func TestUserService_Login_ReturnsError(t *testing.T) {
 	a.Error(err)
 	a.Empty(token)
 }
+
+func TestUserService_UpdateAvatar(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockLogger := logger.MockLogger{}
+	mockUserRepo := MockUserRepository{}
+	mockUserEvent := MockUserEventService{}
+	mockBlobStore := MockBlobStore{}
+	userService := NewUserService(&mockUserRepo, &mockLogger, &mockUserEvent, &mockBlobStore, &MockProfileValidator{})
+
+	testUser := User{
+		ID:       uuid.New(),
+		Username: "JohnDoe123",
+		Email:    "johndoe@gmail.com",
+		Version:  1,
+	}
+	avatarURL := "http://localhost/avatars/" + testUser.ID.String() + "/256.png"
+	mockUserRepo.On("GetUserByID", mock.Anything, testUser.ID.String()).Return(&testUser, nil)
+	mockBlobStore.On("Put", mock.Anything, mock.Anything, mock.Anything, mock.Anything, "image/png").Return(avatarURL, nil)
+	mockUserRepo.On("UpdateUserAvatar", mock.Anything, testUser.ID.String(), mock.MatchedBy(func(url string) bool {
+		return strings.HasPrefix(url, avatarURL+"?v=")
+	}), int64(1)).Return(&testUser, nil)
+
+	// when
+	user, err := userService.UpdateAvatar(context.Background(), testUser.ID.String(), encodeTestImage(t, 32, 32), 1)
+
+	// then
+	a.NoError(err)
+	a.Equal(&testUser, user)
+	mockBlobStore.AssertNumberOfCalls(t, "Put", len(AvatarSizes))
+}
+
+func TestUserService_UpdateAvatar_UserNotFound(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockLogger := logger.MockLogger{}
+	mockUserRepo := MockUserRepository{}
+	mockUserEvent := MockUserEventService{}
+	mockBlobStore := MockBlobStore{}
+	userService := NewUserService(&mockUserRepo, &mockLogger, &mockUserEvent, &mockBlobStore, &MockProfileValidator{})
+
+	mockUserRepo.On("GetUserByID", mock.Anything, "non-existent-id").Return(nil, nil)
+
+	// when
+	user, err := userService.UpdateAvatar(context.Background(), "non-existent-id", encodeTestImage(t, 32, 32), 1)
+
+	// then
+	a.ErrorIs(err, ErrUserNotFound)
+	a.Nil(user)
+	mockBlobStore.AssertNotCalled(t, "Put")
+}
+
+func TestUserService_UpdateAvatar_InvalidImage(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockLogger := logger.MockLogger{}
+	mockUserRepo := MockUserRepository{}
+	mockUserEvent := MockUserEventService{}
+	mockBlobStore := MockBlobStore{}
+	userService := NewUserService(&mockUserRepo, &mockLogger, &mockUserEvent, &mockBlobStore, &MockProfileValidator{})
+
+	testUser := User{ID: uuid.New()}
+	mockUserRepo.On("GetUserByID", mock.Anything, testUser.ID.String()).Return(&testUser, nil)
+
+	// when
+	user, err := userService.UpdateAvatar(context.Background(), testUser.ID.String(), []byte("garbage"), AnyVersion)
+
+	// then
+	a.ErrorIs(err, ErrInvalidAvatarImage)
+	a.Nil(user)
+	mockBlobStore.AssertNotCalled(t, "Put")
+}
+
+func TestUserService_UpdateUserProfile(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockLogger := logger.MockLogger{}
+	mockUserRepo := MockUserRepository{}
+	mockUserEvent := MockUserEventService{}
+	mockProfileValidator := MockProfileValidator{}
+	userService := NewUserService(&mockUserRepo, &mockLogger, &mockUserEvent, &MockBlobStore{}, &mockProfileValidator)
+
+	testUser := User{
+		ID:      uuid.New(),
+		Profile: map[string]any{"locale": "en", "display_name": "John"},
+		Version: 3,
+	}
+	expectedProfile := map[string]any{"locale": "zu", "display_name": "John"}
+	mockUserRepo.On("GetUserByID", mock.Anything, testUser.ID.String()).Return(&testUser, nil)
+	mockProfileValidator.On("ValidateProfile", expectedProfile).Return(nil)
+	mockUserRepo.On("UpdateUserProfile", mock.Anything, testUser.ID.String(), expectedProfile, int64(3)).Return(&User{ID: testUser.ID, Profile: expectedProfile}, nil)
+
+	// when
+	user, err := userService.UpdateUserProfile(context.Background(), testUser.ID.String(), map[string]any{"locale": "zu"}, 3)
+
+	// then
+	a.NoError(err)
+	a.Equal(expectedProfile, user.Profile)
+}
+
+func TestUserService_UpdateUserProfile_InvalidProfile(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockLogger := logger.MockLogger{}
+	mockUserRepo := MockUserRepository{}
+	mockUserEvent := MockUserEventService{}
+	mockProfileValidator := MockProfileValidator{}
+	userService := NewUserService(&mockUserRepo, &mockLogger, &mockUserEvent, &MockBlobStore{}, &mockProfileValidator)
+
+	testUser := User{ID: uuid.New(), Profile: map[string]any{}}
+	mockUserRepo.On("GetUserByID", mock.Anything, testUser.ID.String()).Return(&testUser, nil)
+	mockProfileValidator.On("ValidateProfile", mock.Anything).Return(assert.AnError)
+
+	// when
+	user, err := userService.UpdateUserProfile(context.Background(), testUser.ID.String(), map[string]any{"locale": 12}, AnyVersion)
+
+	// then
+	a.ErrorIs(err, ErrInvalidProfile)
+	a.Nil(user)
+	mockUserRepo.AssertNotCalled(t, "UpdateUserProfile", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUserService_UpdateUserProfile_VersionMismatch(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockLogger := logger.MockLogger{}
+	mockUserRepo := MockUserRepository{}
+	mockUserEvent := MockUserEventService{}
+	mockProfileValidator := MockProfileValidator{}
+	userService := NewUserService(&mockUserRepo, &mockLogger, &mockUserEvent, &MockBlobStore{}, &mockProfileValidator)
+
+	testUser := User{ID: uuid.New(), Profile: map[string]any{}, Version: 4}
+	mockUserRepo.On("GetUserByID", mock.Anything, testUser.ID.String()).Return(&testUser, nil)
+
+	// when
+	user, err := userService.UpdateUserProfile(context.Background(), testUser.ID.String(), map[string]any{"locale": "fr"}, 3)
+
+	// then
+	a.ErrorIs(err, ErrUserVersionMismatch)
+	a.ErrorIs(err, ErrPreconditionFailed)
+	a.Nil(user)
+	mockUserRepo.AssertNotCalled(t, "UpdateUserProfile", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUserService_UpdateUserProfile_UserNotFound(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockLogger := logger.MockLogger{}
+	mockUserRepo := MockUserRepository{}
+	mockUserEvent := MockUserEventService{}
+	userService := NewUserService(&mockUserRepo, &mockLogger, &mockUserEvent, &MockBlobStore{}, &MockProfileValidator{})
+
+	mockUserRepo.On("GetUserByID", mock.Anything, "non-existent-id").Return(nil, nil)
+
+	// when
+	user, err := userService.UpdateUserProfile(context.Background(), "non-existent-id", map[string]any{"locale": "fr"}, 1)
+
+	// then
+	a.ErrorIs(err, ErrUserNotFound)
+	a.Nil(user)
+}
+
+func TestUserService_ListUsers(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockLogger := logger.MockLogger{}
+	mockUserRepo := MockUserRepository{}
+	mockUserEvent := MockUserEventService{}
+	userService := NewUserService(&mockUserRepo, &mockLogger, &mockUserEvent, &MockBlobStore{}, &MockProfileValidator{})
+
+	filter := UserFilter{Profile: map[string]any{"locale": "fr"}, Limit: 10}
+	users := []*User{{ID: uuid.New()}, {ID: uuid.New()}}
+	mockUserRepo.On("ListUsers", mock.Anything, filter).Return(users, nil)
+
+	// when
+	result, err := userService.ListUsers(context.Background(), filter)
+
+	// then
+	a.NoError(err)
+	a.Equal(users, result)
+}
+
+func TestUserService_GetUsersByIDs(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockLogger := logger.MockLogger{}
+	mockUserRepo := MockUserRepository{}
+	userService := NewUserService(&mockUserRepo, &mockLogger, &MockUserEventService{}, &MockBlobStore{}, &MockProfileValidator{})
+
+	users := []*User{{ID: uuid.New()}}
+	ids := []string{users[0].ID.String(), uuid.NewString()}
+	mockUserRepo.On("GetUsersByIDs", mock.Anything, ids).Return(users, nil)
+
+	// when
+	result, err := userService.GetUsersByIDs(context.Background(), ids)
+
+	// then
+	a.NoError(err)
+	a.Equal(users, result)
+}
+
+func TestUserService_ExportUsers(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockLogger := logger.MockLogger{}
+	mockLogger.On("Error", mock.Anything, mock.Anything).Return()
+	mockUserRepo := MockUserRepository{}
+	userService := NewUserService(&mockUserRepo, &mockLogger, &MockUserEventService{}, &MockBlobStore{}, &MockProfileValidator{})
+
+	filter := UserFilter{Profile: map[string]any{"locale": "fr"}}
+	users := []*User{{ID: uuid.New()}, {ID: uuid.New()}, {ID: uuid.New()}}
+	mockUserRepo.On("StreamUsers", mock.Anything, filter).Return(users, nil)
+	stop := errors.New("client went away")
+
+	// when
+	var exported []*User
+	err := userService.ExportUsers(context.Background(), filter, func(user *User) error {
+		exported = append(exported, user)
+		if len(exported) == 2 {
+			return stop
+		}
+		return nil
+	})
+
+	// then
+	a.ErrorIs(err, stop)
+	a.Equal(users[:2], exported)
+}
+
+func TestUserService_ImpersonateUser(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockLogger := logger.MockLogger{}
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+	mockUserRepo := MockUserRepository{}
+	mockUserEvent := MockUserEventService{}
+	userService := NewUserService(&mockUserRepo, &mockLogger, &mockUserEvent, &MockBlobStore{}, &MockProfileValidator{})
+
+	jwtSecret := "mysecretkey"
+	actorID := uuid.New()
+	target := User{ID: uuid.New(), Roles: []string{}}
+	mockUserRepo.On("GetUserByID", mock.Anything, target.ID.String()).Return(&target, nil)
+
+	// when
+	token, expiresAt, err := userService.ImpersonateUser(context.Background(), actorID, target.ID.String(), jwtSecret)
+
+	// then
+	a.NoError(err)
+	a.WithinDuration(time.Now().Add(ImpersonationTokenTTL), expiresAt, time.Minute)
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) { return []byte(jwtSecret), nil })
+	a.NoError(err)
+	a.Equal(target.ID.String(), claims["sub"])
+	a.Equal(target.ID.String(), claims["user_id"])
+	a.Equal(map[string]interface{}{"sub": actorID.String()}, claims["act"])
+	mockLogger.AssertCalled(t, "Info", "impersonation started", mock.Anything)
+}
+
+func TestUserService_ImpersonateUser_Self(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockLogger := logger.MockLogger{}
+	mockUserRepo := MockUserRepository{}
+	mockUserEvent := MockUserEventService{}
+	userService := NewUserService(&mockUserRepo, &mockLogger, &mockUserEvent, &MockBlobStore{}, &MockProfileValidator{})
+	actorID := uuid.New()
+
+	// when
+	token, _, err := userService.ImpersonateUser(context.Background(), actorID, actorID.String(), "mysecretkey")
+
+	// then
+	a.ErrorIs(err, ErrCannotImpersonateSelf)
+	a.ErrorIs(err, ErrValidation)
+	a.Empty(token)
+}
+
+func TestUserService_ImpersonateUser_NotFound(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockLogger := logger.MockLogger{}
+	mockUserRepo := MockUserRepository{}
+	mockUserEvent := MockUserEventService{}
+	userService := NewUserService(&mockUserRepo, &mockLogger, &mockUserEvent, &MockBlobStore{}, &MockProfileValidator{})
+	mockUserRepo.On("GetUserByID", mock.Anything, "non-existent-id").Return(nil, nil)
+
+	// when
+	token, _, err := userService.ImpersonateUser(context.Background(), uuid.New(), "non-existent-id", "mysecretkey")
+
+	// then
+	a.ErrorIs(err, ErrUserNotFound)
+	a.Empty(token)
+}
@@ -0,0 +1,49 @@
+package db
+
+import (
+	"errors"
+	"go-rest-api/internal/core"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// PostgreSQL error codes translated into domain errors, see
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgUniqueViolation           = "23505"
+	pgForeignKeyViolation       = "23503"
+	pgCheckViolation            = "23514"
+	pgInvalidTextRepresentation = "22P02"
+)
+
+// uniqueViolations maps unique constraints to the domain error reported when
+// they are violated.
+var uniqueViolations = map[string]*core.Error{
+	"users_email_key":    core.ErrUserAlreadyExists,
+	"users_username_key": core.ErrUsernameTaken,
+}
+
+// translateError turns constraint and input errors reported by PostgreSQL into
+// core errors so that callers can tell a client mistake from an outage. Any
+// other error is returned unchanged.
+func translateError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	switch pgErr.Code {
+	case pgUniqueViolation:
+		if kind, ok := uniqueViolations[pgErr.ConstraintName]; ok {
+			return &core.Error{Kind: kind, Code: kind.Code, Message: kind.Message, Err: err}
+		}
+		return core.WrapError(core.ErrConflict, err, "resource_conflict", "resource already exists")
+	case pgForeignKeyViolation:
+		return core.WrapError(core.ErrConflict, err, "referenced_resource_missing", "referenced resource does not exist")
+	case pgCheckViolation:
+		return core.WrapError(core.ErrValidation, err, "constraint_violation", "value violates a constraint")
+	case pgInvalidTextRepresentation:
+		return core.WrapError(core.ErrValidation, err, "malformed_value", "malformed identifier or value")
+	}
+	return err
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"go-rest-api/internal/core"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslateError(t *testing.T) {
+	scenarios := []struct {
+		name         string
+		err          error
+		expectedKind error
+	}{
+		{
+			name:         "duplicate email",
+			err:          &pgconn.PgError{Code: pgUniqueViolation, ConstraintName: "users_email_key"},
+			expectedKind: core.ErrUserAlreadyExists,
+		},
+		{
+			name:         "duplicate username",
+			err:          &pgconn.PgError{Code: pgUniqueViolation, ConstraintName: "users_username_key"},
+			expectedKind: core.ErrUsernameTaken,
+		},
+		{
+			name:         "unknown unique constraint",
+			err:          &pgconn.PgError{Code: pgUniqueViolation, ConstraintName: "invitations_token_hash_key"},
+			expectedKind: core.ErrConflict,
+		},
+		{
+			name:         "malformed uuid",
+			err:          &pgconn.PgError{Code: pgInvalidTextRepresentation},
+			expectedKind: core.ErrValidation,
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+
+			// when
+			err := translateError(scenario.err)
+
+			// then
+			a.ErrorIs(err, scenario.expectedKind)
+			a.ErrorIs(err, scenario.err)
+		})
+	}
+}
+
+func TestTranslateError_PassesThroughOtherErrors(t *testing.T) {
+	a := assert.New(t)
+	connErr := &pgconn.PgError{Code: "08006"}
+
+	// then
+	a.Equal(assert.AnError, translateError(assert.AnError))
+	a.Equal(error(connErr), translateError(connErr))
+}
@@ -0,0 +1,142 @@
+package db
+
+import (
+	"context"
+	"go-rest-api/internal/core"
+	"go-rest-api/pkg/logger"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const idempotencyColumns = `scope, key, fingerprint, status_code, headers, body, locked_until, completed_at, created_at, expires_at`
+
+type IdempotencyRepository struct {
+	db     *pgxpool.Pool
+	logger logger.CustomLogger
+}
+
+func NewIdempotencyRepository(db *pgxpool.Pool, logger logger.CustomLogger) core.IdempotencyRepository {
+	return &IdempotencyRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (rec *IdempotencyRecord) ToCoreIdempotencyRecord() *core.IdempotencyRecord {
+	record := &core.IdempotencyRecord{
+		Scope:       rec.Scope,
+		Key:         rec.Key,
+		Fingerprint: rec.Fingerprint,
+		Headers:     rec.Headers,
+		Body:        rec.Body,
+		LockedUntil: rec.LockedUntil,
+		CompletedAt: rec.CompletedAt,
+		CreatedAt:   rec.CreatedAt,
+		ExpiresAt:   rec.ExpiresAt,
+	}
+	if rec.StatusCode != nil {
+		record.StatusCode = *rec.StatusCode
+	}
+	return record
+}
+
+func scanIdempotencyRecord(row pgx.Row, record *IdempotencyRecord) error {
+	return row.Scan(
+		&record.Scope,
+		&record.Key,
+		&record.Fingerprint,
+		&record.StatusCode,
+		&record.Headers,
+		&record.Body,
+		&record.LockedUntil,
+		&record.CompletedAt,
+		&record.CreatedAt,
+		&record.ExpiresAt,
+	)
+}
+
+// ReserveIdempotencyKey relies on the (scope, key) primary key as the lock:
+// only one concurrent insert wins, and a losing request sees the winner's row.
+func (r *IdempotencyRepository) ReserveIdempotencyKey(ctx context.Context, record *core.IdempotencyRecord, now time.Time) (*core.IdempotencyRecord, error) {
+	const reserveQuery = `INSERT INTO idempotency_keys (scope, key, fingerprint, locked_until, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (scope, key) DO UPDATE SET
+			fingerprint = EXCLUDED.fingerprint,
+			status_code = NULL,
+			headers = NULL,
+			body = NULL,
+			completed_at = NULL,
+			locked_until = EXCLUDED.locked_until,
+			created_at = EXCLUDED.created_at,
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= $7
+			OR (idempotency_keys.completed_at IS NULL AND idempotency_keys.locked_until <= $7)`
+	const selectQuery = `SELECT ` + idempotencyColumns + ` FROM idempotency_keys WHERE scope = $1 AND key = $2`
+
+	// ... the existing row may be released between the two statements, in
+	// which case the reservation is simply tried again
+	for attempt := 0; attempt < 3; attempt++ {
+		tag, err := r.db.Exec(ctx, reserveQuery,
+			record.Scope,
+			record.Key,
+			record.Fingerprint,
+			record.LockedUntil,
+			record.CreatedAt,
+			record.ExpiresAt,
+			now,
+		)
+		if err != nil {
+			r.logger.FromContext(ctx).Error("failed to reserve idempotency key", "error", err)
+			return nil, translateError(err)
+		}
+		if tag.RowsAffected() == 1 {
+			return nil, nil
+		}
+
+		existing := &IdempotencyRecord{}
+		err = scanIdempotencyRecord(r.db.QueryRow(ctx, selectQuery, record.Scope, record.Key), existing)
+		if err == pgx.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			r.logger.FromContext(ctx).Error("failed to get idempotency key", "error", err)
+			return nil, translateError(err)
+		}
+		return existing.ToCoreIdempotencyRecord(), nil
+	}
+	return nil, core.ErrIdempotencyRequestInProgress
+}
+
+func (r *IdempotencyRepository) CompleteIdempotencyKey(ctx context.Context, scope, key string, statusCode int, headers map[string][]string, body []byte, now time.Time) error {
+	const query = `UPDATE idempotency_keys SET status_code = $3, headers = $4, body = $5, completed_at = $6
+		WHERE scope = $1 AND key = $2`
+
+	if _, err := r.db.Exec(ctx, query, scope, key, statusCode, headers, body, now); err != nil {
+		r.logger.FromContext(ctx).Error("failed to complete idempotency key", "error", err)
+		return translateError(err)
+	}
+	return nil
+}
+
+func (r *IdempotencyRepository) DeleteIdempotencyKey(ctx context.Context, scope, key string) error {
+	const query = `DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2`
+
+	if _, err := r.db.Exec(ctx, query, scope, key); err != nil {
+		r.logger.FromContext(ctx).Error("failed to delete idempotency key", "error", err)
+		return translateError(err)
+	}
+	return nil
+}
+
+func (r *IdempotencyRepository) DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	const query = `DELETE FROM idempotency_keys WHERE expires_at <= $1`
+
+	tag, err := r.db.Exec(ctx, query, now)
+	if err != nil {
+		r.logger.FromContext(ctx).Error("failed to delete expired idempotency keys", "error", err)
+		return 0, translateError(err)
+	}
+	return tag.RowsAffected(), nil
+}
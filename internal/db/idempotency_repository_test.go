@@ -0,0 +1,112 @@
+package db
+
+import (
+	"context"
+	"go-rest-api/internal/core"
+	"go-rest-api/pkg/logger"
+	"go-rest-api/test"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type IdempotencyRepositoryTestSuite struct {
+	suite.Suite
+	idempotencyRepo core.IdempotencyRepository
+	tearDown        func()
+}
+
+func (testSuite *IdempotencyRepositoryTestSuite) SetupSuite() {
+	t := testSuite.T()
+	dbPool, tear := test.CreateDbTestContainer(context.Background(), t)
+	testSuite.tearDown = tear
+	mockLogger := logger.MockLogger{}
+	mockLogger.On("Error", mock.Anything, mock.Anything).Return()
+	testSuite.idempotencyRepo = NewIdempotencyRepository(dbPool, &mockLogger)
+}
+
+func (testSuite *IdempotencyRepositoryTestSuite) TearDownSuite() {
+	if testSuite.tearDown != nil {
+		testSuite.tearDown()
+	}
+}
+
+func newIdempotencyRecord(key, fingerprint string, now time.Time) *core.IdempotencyRecord {
+	return &core.IdempotencyRecord{
+		Scope:       "user:POST /invitations",
+		Key:         key,
+		Fingerprint: fingerprint,
+		LockedUntil: now.Add(time.Minute),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(time.Hour),
+	}
+}
+
+func (testSuite *IdempotencyRepositoryTestSuite) TestIdempotencyRepository_ReserveAndComplete() {
+	t := testSuite.T()
+	a := assert.New(t)
+	ctx := context.Background()
+	// given
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	record := newIdempotencyRecord("reserve", "fingerprint", now)
+
+	// when
+	reserved, err := testSuite.idempotencyRepo.ReserveIdempotencyKey(ctx, record, now)
+	inFlight, errInFlight := testSuite.idempotencyRepo.ReserveIdempotencyKey(ctx, record, now)
+	errComplete := testSuite.idempotencyRepo.CompleteIdempotencyKey(ctx, record.Scope, record.Key, 201, map[string][]string{"Location": {"/invitations/1"}}, []byte(`{"id":1}`), now)
+	completed, errCompleted := testSuite.idempotencyRepo.ReserveIdempotencyKey(ctx, record, now)
+
+	// then
+	a.NoError(err)
+	a.Nil(reserved)
+	a.NoError(errInFlight)
+	a.False(inFlight.IsCompleted())
+	a.NoError(errComplete)
+	a.NoError(errCompleted)
+	a.True(completed.IsCompleted())
+	a.Equal(201, completed.StatusCode)
+	a.Equal([]string{"/invitations/1"}, completed.Headers["Location"])
+	a.Equal([]byte(`{"id":1}`), completed.Body)
+}
+
+func (testSuite *IdempotencyRepositoryTestSuite) TestIdempotencyRepository_ReserveAfterExpiry() {
+	t := testSuite.T()
+	a := assert.New(t)
+	ctx := context.Background()
+	// given
+	now := time.Now().UTC()
+	_, err := testSuite.idempotencyRepo.ReserveIdempotencyKey(ctx, newIdempotencyRecord("expired", "old", now), now)
+	a.NoError(err)
+	later := now.Add(2 * time.Hour)
+
+	// when
+	existing, err := testSuite.idempotencyRepo.ReserveIdempotencyKey(ctx, newIdempotencyRecord("expired", "new", later), later)
+
+	// then
+	a.NoError(err)
+	a.Nil(existing)
+}
+
+func (testSuite *IdempotencyRepositoryTestSuite) TestIdempotencyRepository_DeleteExpired() {
+	t := testSuite.T()
+	a := assert.New(t)
+	ctx := context.Background()
+	// given
+	now := time.Now().UTC()
+	_, err := testSuite.idempotencyRepo.ReserveIdempotencyKey(ctx, newIdempotencyRecord("purge", "fingerprint", now), now)
+	a.NoError(err)
+
+	// when
+	deleted, err := testSuite.idempotencyRepo.DeleteExpiredIdempotencyKeys(ctx, now.Add(2*time.Hour))
+
+	// then
+	a.NoError(err)
+	a.GreaterOrEqual(deleted, int64(1))
+}
+
+func TestIdempotencyRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(IdempotencyRepositoryTestSuite))
+}
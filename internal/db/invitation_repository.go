@@ -0,0 +1,174 @@
+package db
+
+import (
+	"context"
+	"go-rest-api/internal/core"
+	"go-rest-api/pkg/logger"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const invitationColumns = `id, email, roles, token_hash, invited_by, expires_at, accepted_at, revoked_at, created_at`
+
+type InvitationRepository struct {
+	db     *pgxpool.Pool
+	logger logger.CustomLogger
+}
+
+func NewInvitationRepository(db *pgxpool.Pool, logger logger.CustomLogger) core.InvitationRepository {
+	return &InvitationRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (inv *Invitation) ToCoreInvitation() *core.Invitation {
+	return &core.Invitation{
+		ID:         inv.ID,
+		Email:      inv.Email,
+		Roles:      inv.Roles,
+		TokenHash:  inv.TokenHash,
+		InvitedBy:  inv.InvitedBy,
+		ExpiresAt:  inv.ExpiresAt,
+		AcceptedAt: inv.AcceptedAt,
+		RevokedAt:  inv.RevokedAt,
+		CreatedAt:  inv.CreatedAt,
+	}
+}
+
+func scanInvitation(row pgx.Row, invitation *Invitation) error {
+	return row.Scan(
+		&invitation.ID,
+		&invitation.Email,
+		&invitation.Roles,
+		&invitation.TokenHash,
+		&invitation.InvitedBy,
+		&invitation.ExpiresAt,
+		&invitation.AcceptedAt,
+		&invitation.RevokedAt,
+		&invitation.CreatedAt,
+	)
+}
+
+func (r *InvitationRepository) CreateInvitation(ctx context.Context, invitation *core.Invitation) (*core.Invitation, error) {
+	const revokeQuery = `UPDATE invitations SET revoked_at = NOW()
+		WHERE email = $1 AND accepted_at IS NULL AND revoked_at IS NULL`
+	const insertQuery = `INSERT INTO invitations (id, email, roles, token_hash, invited_by, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING ` + invitationColumns
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		r.logger.FromContext(ctx).Error("failed to begin transaction", "error", err)
+		return nil, translateError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	// ... re-inviting someone replaces their previous invitation
+	if _, err := tx.Exec(ctx, revokeQuery, invitation.Email); err != nil {
+		r.logger.FromContext(ctx).Error("failed to revoke previous invitations", "error", err, "email", invitation.Email)
+		return nil, translateError(err)
+	}
+
+	created := &Invitation{}
+	err = scanInvitation(tx.QueryRow(ctx, insertQuery,
+		invitation.ID,
+		invitation.Email,
+		invitation.Roles,
+		invitation.TokenHash,
+		invitation.InvitedBy,
+		invitation.ExpiresAt,
+		invitation.CreatedAt,
+	), created)
+	if err != nil {
+		r.logger.FromContext(ctx).Error("failed to create invitation", "error", err, "email", invitation.Email)
+		return nil, translateError(err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		r.logger.FromContext(ctx).Error("failed to commit invitation", "error", err)
+		return nil, translateError(err)
+	}
+	return created.ToCoreInvitation(), nil
+}
+
+func (r *InvitationRepository) ListPendingInvitations(ctx context.Context) ([]*core.Invitation, error) {
+	const query = `SELECT ` + invitationColumns + ` FROM invitations
+		WHERE accepted_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		r.logger.FromContext(ctx).Error("failed to list pending invitations", "error", err)
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	invitations := []*core.Invitation{}
+	for rows.Next() {
+		invitation := &Invitation{}
+		if err := scanInvitation(rows, invitation); err != nil {
+			r.logger.FromContext(ctx).Error("failed to scan invitation", "error", err)
+			return nil, translateError(err)
+		}
+		invitations = append(invitations, invitation.ToCoreInvitation())
+	}
+	if err := rows.Err(); err != nil {
+		r.logger.FromContext(ctx).Error("failed to list pending invitations", "error", err)
+		return nil, translateError(err)
+	}
+	return invitations, nil
+}
+
+func (r *InvitationRepository) RevokeInvitation(ctx context.Context, id string) (*core.Invitation, error) {
+	const query = `UPDATE invitations SET revoked_at = NOW()
+		WHERE id = $1 AND accepted_at IS NULL AND revoked_at IS NULL
+		RETURNING ` + invitationColumns
+
+	invitation := &Invitation{}
+	err := scanInvitation(r.db.QueryRow(ctx, query, id), invitation)
+
+	if err != nil && err == pgx.ErrNoRows {
+		r.logger.FromContext(ctx).Info("pending invitation not found", "id", id)
+		return nil, nil
+	}
+
+	if err != nil {
+		r.logger.FromContext(ctx).Error("failed to revoke invitation", "error", err, "id", id)
+		return nil, translateError(err)
+	}
+
+	return invitation.ToCoreInvitation(), nil
+}
+
+func (r *InvitationRepository) ClaimInvitation(ctx context.Context, tokenHash string) (*core.Invitation, error) {
+	const query = `UPDATE invitations SET accepted_at = NOW()
+		WHERE token_hash = $1 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+		RETURNING ` + invitationColumns
+
+	invitation := &Invitation{}
+	err := scanInvitation(r.db.QueryRow(ctx, query, tokenHash), invitation)
+
+	if err != nil && err == pgx.ErrNoRows {
+		r.logger.FromContext(ctx).Info("pending invitation not found for token")
+		return nil, nil
+	}
+
+	if err != nil {
+		r.logger.FromContext(ctx).Error("failed to claim invitation", "error", err)
+		return nil, translateError(err)
+	}
+
+	return invitation.ToCoreInvitation(), nil
+}
+
+func (r *InvitationRepository) ReleaseInvitation(ctx context.Context, id uuid.UUID) error {
+	const query = `UPDATE invitations SET accepted_at = NULL WHERE id = $1`
+
+	if _, err := r.db.Exec(ctx, query, id); err != nil {
+		r.logger.FromContext(ctx).Error("failed to release invitation", "error", err, "id", id)
+		return err
+	}
+	return nil
+}
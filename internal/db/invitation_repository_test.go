@@ -0,0 +1,148 @@
+package db
+
+import (
+	"context"
+	"go-rest-api/internal/core"
+	"go-rest-api/pkg/logger"
+	"go-rest-api/test"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type InvitationRepositoryTestSuite struct {
+	suite.Suite
+	invitationRepo core.InvitationRepository
+	admin          *core.User
+	tearDown       func()
+}
+
+func (testSuite *InvitationRepositoryTestSuite) SetupSuite() {
+	t := testSuite.T()
+	dbPool, tear := test.CreateDbTestContainer(context.Background(), t)
+	testSuite.tearDown = tear
+	mockLogger := logger.MockLogger{}
+	mockLogger.On("Error", mock.Anything, mock.Anything).Return()
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+	testSuite.invitationRepo = NewInvitationRepository(dbPool, &mockLogger)
+
+	admin, err := NewUserRepository(dbPool, &mockLogger).CreateUser(context.Background(), &core.User{
+		ID:        uuid.New(),
+		Username:  "admin",
+		Email:     "admin@gmail.com",
+		Password:  "hashedpassword",
+		Roles:     []string{core.RoleAdmin},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create admin user: %v", err)
+	}
+	testSuite.admin = admin
+}
+
+func (testSuite *InvitationRepositoryTestSuite) TearDownSuite() {
+	if testSuite.tearDown != nil {
+		testSuite.tearDown()
+	}
+}
+
+func (testSuite *InvitationRepositoryTestSuite) newInvitation(email, tokenHash string, expiresAt time.Time) *core.Invitation {
+	return &core.Invitation{
+		ID:        uuid.New(),
+		Email:     email,
+		Roles:     []string{core.RoleAdmin},
+		TokenHash: tokenHash,
+		InvitedBy: testSuite.admin.ID,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+}
+
+func (testSuite *InvitationRepositoryTestSuite) TestInvitationRepository_ClaimInvitation() {
+	t := testSuite.T()
+	a := assert.New(t)
+	// given
+	invitation := testSuite.newInvitation("claim@gmail.com", core.HashInvitationToken("claim"), time.Now().Add(time.Hour))
+	_, err := testSuite.invitationRepo.CreateInvitation(context.Background(), invitation)
+	a.NoError(err)
+
+	// when
+	claimed, err := testSuite.invitationRepo.ClaimInvitation(context.Background(), invitation.TokenHash)
+	claimedAgain, errAgain := testSuite.invitationRepo.ClaimInvitation(context.Background(), invitation.TokenHash)
+
+	// then
+	a.NoError(err)
+	a.Equal(invitation.ID, claimed.ID)
+	a.Equal([]string{core.RoleAdmin}, claimed.Roles)
+	a.NotNil(claimed.AcceptedAt)
+	a.NoError(errAgain)
+	a.Nil(claimedAgain)
+}
+
+func (testSuite *InvitationRepositoryTestSuite) TestInvitationRepository_ClaimInvitation_Expired() {
+	t := testSuite.T()
+	a := assert.New(t)
+	// given
+	invitation := testSuite.newInvitation("expired@gmail.com", core.HashInvitationToken("expired"), time.Now().Add(-time.Hour))
+	_, err := testSuite.invitationRepo.CreateInvitation(context.Background(), invitation)
+	a.NoError(err)
+
+	// when
+	claimed, err := testSuite.invitationRepo.ClaimInvitation(context.Background(), invitation.TokenHash)
+
+	// then
+	a.NoError(err)
+	a.Nil(claimed)
+}
+
+func (testSuite *InvitationRepositoryTestSuite) TestInvitationRepository_CreateInvitation_RevokesPrevious() {
+	t := testSuite.T()
+	a := assert.New(t)
+	// given
+	first := testSuite.newInvitation("reinvite@gmail.com", core.HashInvitationToken("first"), time.Now().Add(time.Hour))
+	second := testSuite.newInvitation("reinvite@gmail.com", core.HashInvitationToken("second"), time.Now().Add(time.Hour))
+
+	// when
+	_, err := testSuite.invitationRepo.CreateInvitation(context.Background(), first)
+	a.NoError(err)
+	_, err = testSuite.invitationRepo.CreateInvitation(context.Background(), second)
+	a.NoError(err)
+
+	// then
+	pending, err := testSuite.invitationRepo.ListPendingInvitations(context.Background())
+	a.NoError(err)
+	var pendingIDs []uuid.UUID
+	for _, invitation := range pending {
+		pendingIDs = append(pendingIDs, invitation.ID)
+	}
+	a.Contains(pendingIDs, second.ID)
+	a.NotContains(pendingIDs, first.ID)
+}
+
+func (testSuite *InvitationRepositoryTestSuite) TestInvitationRepository_RevokeInvitation() {
+	t := testSuite.T()
+	a := assert.New(t)
+	// given
+	invitation := testSuite.newInvitation("revoke@gmail.com", core.HashInvitationToken("revoke"), time.Now().Add(time.Hour))
+	_, err := testSuite.invitationRepo.CreateInvitation(context.Background(), invitation)
+	a.NoError(err)
+
+	// when
+	revoked, err := testSuite.invitationRepo.RevokeInvitation(context.Background(), invitation.ID.String())
+
+	// then
+	a.NoError(err)
+	a.NotNil(revoked.RevokedAt)
+	claimed, err := testSuite.invitationRepo.ClaimInvitation(context.Background(), invitation.TokenHash)
+	a.NoError(err)
+	a.Nil(claimed)
+}
+
+func TestInvitationRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(InvitationRepositoryTestSuite))
+}
@@ -6,10 +6,62 @@ import (
 )
 
 type User struct {
-	ID        uuid.UUID `db:"id"`
-	Username  string    `db:"username"`
-	Email     string    `db:"email"`
-	Password  string    `db:"password"`
-	CreatedAt time.Time `db:"created_at"`
-	UpdatedAt time.Time `db:"updated_at"`
+	ID        uuid.UUID      `db:"id"`
+	Username  string         `db:"username"`
+	Email     string         `db:"email"`
+	Password  string         `db:"password"`
+	AvatarURL string         `db:"avatar_url"`
+	Profile   map[string]any `db:"profile"`
+	Roles     []string       `db:"roles"`
+	Version   int64          `db:"version"`
+	CreatedAt time.Time      `db:"created_at"`
+	UpdatedAt time.Time      `db:"updated_at"`
+}
+
+type Invitation struct {
+	ID         uuid.UUID  `db:"id"`
+	Email      string     `db:"email"`
+	Roles      []string   `db:"roles"`
+	TokenHash  string     `db:"token_hash"`
+	InvitedBy  uuid.UUID  `db:"invited_by"`
+	ExpiresAt  time.Time  `db:"expires_at"`
+	AcceptedAt *time.Time `db:"accepted_at"`
+	RevokedAt  *time.Time `db:"revoked_at"`
+	CreatedAt  time.Time  `db:"created_at"`
+}
+
+type IdempotencyRecord struct {
+	Scope       string              `db:"scope"`
+	Key         string              `db:"key"`
+	Fingerprint string              `db:"fingerprint"`
+	StatusCode  *int                `db:"status_code"`
+	Headers     map[string][]string `db:"headers"`
+	Body        []byte              `db:"body"`
+	LockedUntil time.Time           `db:"locked_until"`
+	CompletedAt *time.Time          `db:"completed_at"`
+	CreatedAt   time.Time           `db:"created_at"`
+	ExpiresAt   time.Time           `db:"expires_at"`
+}
+
+type UserImport struct {
+	ID            uuid.UUID            `db:"id"`
+	Status        string               `db:"status"`
+	Format        string               `db:"format"`
+	CreatedBy     uuid.UUID            `db:"created_by"`
+	TotalRows     int                  `db:"total_rows"`
+	ProcessedRows int                  `db:"processed_rows"`
+	ImportedRows  int                  `db:"imported_rows"`
+	FailedRows    int                  `db:"failed_rows"`
+	Errors        []UserImportRowError `db:"errors"`
+	CreatedAt     time.Time            `db:"created_at"`
+	StartedAt     *time.Time           `db:"started_at"`
+	FinishedAt    *time.Time           `db:"finished_at"`
+}
+
+// UserImportRowError is an element of the errors JSONB column.
+type UserImportRowError struct {
+	Row    int               `json:"row"`
+	Field  string            `json:"field,omitempty"`
+	Code   string            `json:"code"`
+	Params map[string]string `json:"params,omitempty"`
 }
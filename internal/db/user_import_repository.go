@@ -0,0 +1,312 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"go-rest-api/internal/core"
+	"go-rest-api/pkg/logger"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// userImportColumns is the column list scanned by scanUserImport. The payload
+// is only selected when an import is claimed.
+const userImportColumns = `id, status, format, created_by, total_rows, processed_rows, imported_rows, failed_rows, errors, created_at, started_at, finished_at`
+
+type UserImportRepository struct {
+	db     *pgxpool.Pool
+	logger logger.CustomLogger
+}
+
+func NewUserImportRepository(db *pgxpool.Pool, logger logger.CustomLogger) core.UserImportRepository {
+	return &UserImportRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (imp *UserImport) ToCoreUserImport() *core.UserImport {
+	rowErrors := make([]core.UserImportRowError, 0, len(imp.Errors))
+	for _, rowErr := range imp.Errors {
+		rowErrors = append(rowErrors, core.UserImportRowError{
+			Row:    rowErr.Row,
+			Field:  rowErr.Field,
+			Code:   rowErr.Code,
+			Params: rowErr.Params,
+		})
+	}
+	return &core.UserImport{
+		ID:            imp.ID,
+		Status:        imp.Status,
+		Format:        imp.Format,
+		CreatedBy:     imp.CreatedBy,
+		TotalRows:     imp.TotalRows,
+		ProcessedRows: imp.ProcessedRows,
+		ImportedRows:  imp.ImportedRows,
+		FailedRows:    imp.FailedRows,
+		Errors:        rowErrors,
+		CreatedAt:     imp.CreatedAt,
+		StartedAt:     imp.StartedAt,
+		FinishedAt:    imp.FinishedAt,
+	}
+}
+
+func toUserImportRowErrors(rowErrors []core.UserImportRowError) []UserImportRowError {
+	result := make([]UserImportRowError, 0, len(rowErrors))
+	for _, rowErr := range rowErrors {
+		result = append(result, UserImportRowError{
+			Row:    rowErr.Row,
+			Field:  rowErr.Field,
+			Code:   rowErr.Code,
+			Params: rowErr.Params,
+		})
+	}
+	return result
+}
+
+func scanUserImport(row pgx.Row, userImport *UserImport, extra ...any) error {
+	return row.Scan(append([]any{
+		&userImport.ID,
+		&userImport.Status,
+		&userImport.Format,
+		&userImport.CreatedBy,
+		&userImport.TotalRows,
+		&userImport.ProcessedRows,
+		&userImport.ImportedRows,
+		&userImport.FailedRows,
+		&userImport.Errors,
+		&userImport.CreatedAt,
+		&userImport.StartedAt,
+		&userImport.FinishedAt,
+	}, extra...)...)
+}
+
+func (r *UserImportRepository) CreateUserImport(ctx context.Context, userImport *core.UserImport, payload []byte) (*core.UserImport, error) {
+	const query = `INSERT INTO user_imports (id, status, format, payload, created_by, total_rows, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7) RETURNING ` + userImportColumns
+
+	created := &UserImport{}
+	err := scanUserImport(r.db.QueryRow(ctx, query,
+		userImport.ID,
+		userImport.Status,
+		userImport.Format,
+		payload,
+		userImport.CreatedBy,
+		userImport.TotalRows,
+		userImport.CreatedAt,
+	), created)
+	if err != nil {
+		r.logger.FromContext(ctx).Error("failed to create user import", "error", err)
+		return nil, translateError(err)
+	}
+	return created.ToCoreUserImport(), nil
+}
+
+func (r *UserImportRepository) GetUserImport(ctx context.Context, id string) (*core.UserImport, error) {
+	const query = `SELECT ` + userImportColumns + ` FROM user_imports WHERE id = $1`
+
+	userImport := &UserImport{}
+	err := scanUserImport(r.db.QueryRow(ctx, query, id), userImport)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		r.logger.FromContext(ctx).Error("failed to get user import", "error", err, "id", id)
+		return nil, translateError(err)
+	}
+	return userImport.ToCoreUserImport(), nil
+}
+
+// ClaimUserImport uses SKIP LOCKED so that several workers never pick up the
+// same import.
+func (r *UserImportRepository) ClaimUserImport(ctx context.Context, staleAfter time.Duration) (*core.UserImport, []byte, error) {
+	const query = `UPDATE user_imports SET status = 'running', started_at = COALESCE(started_at, NOW()), updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM user_imports
+			WHERE status = 'pending' OR (status = 'running' AND updated_at < NOW() - make_interval(secs => $1))
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + userImportColumns + `, payload`
+
+	userImport := &UserImport{}
+	var payload []byte
+	err := scanUserImport(r.db.QueryRow(ctx, query, staleAfter.Seconds()), userImport, &payload)
+	if err == pgx.ErrNoRows {
+		return nil, nil, nil
+	}
+	if err != nil {
+		r.logger.FromContext(ctx).Error("failed to claim user import", "error", err)
+		return nil, nil, translateError(err)
+	}
+	return userImport.ToCoreUserImport(), payload, nil
+}
+
+func (r *UserImportRepository) SaveUserImportBatch(ctx context.Context, id uuid.UUID, batch *core.UserImportBatch) (*core.UserImport, error) {
+	const query = `UPDATE user_imports SET
+			processed_rows = $2,
+			imported_rows = imported_rows + $3,
+			failed_rows = $2 - imported_rows - $3,
+			errors = errors || $4::jsonb,
+			updated_at = NOW()
+		WHERE id = $1 RETURNING ` + userImportColumns
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		r.logger.FromContext(ctx).Error("failed to begin user import transaction", "error", err)
+		return nil, translateError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	imported, conflicts, err := r.insertImportedUsers(ctx, tx, batch.Users)
+	if err != nil {
+		r.logger.FromContext(ctx).Error("failed to insert imported users", "error", err, "id", id)
+		return nil, translateError(err)
+	}
+	rowErrors := append(toUserImportRowErrors(batch.Errors), conflicts...)
+	slices.SortStableFunc(rowErrors, func(a, b UserImportRowError) int {
+		return a.Row - b.Row
+	})
+
+	userImport := &UserImport{}
+	if err := scanUserImport(tx.QueryRow(ctx, query, id, batch.ProcessedRows, imported, rowErrors), userImport); err != nil {
+		r.logger.FromContext(ctx).Error("failed to save user import progress", "error", err, "id", id)
+		return nil, translateError(err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		r.logger.FromContext(ctx).Error("failed to commit user import batch", "error", err, "id", id)
+		return nil, translateError(err)
+	}
+	return userImport.ToCoreUserImport(), nil
+}
+
+// insertImportedUsers copies the users into the users table and returns how
+// many were inserted and a row error for each one whose email or username is
+// already taken.
+func (r *UserImportRepository) insertImportedUsers(ctx context.Context, tx pgx.Tx, users []core.ImportedUser) (int, []UserImportRowError, error) {
+	const takenQuery = `SELECT email, username FROM users WHERE email = ANY($1) OR username = ANY($2)`
+
+	if len(users) == 0 {
+		return 0, nil, nil
+	}
+
+	emails := make([]string, 0, len(users))
+	usernames := make([]string, 0, len(users))
+	for _, imported := range users {
+		emails = append(emails, imported.User.Email)
+		usernames = append(usernames, imported.User.Username)
+	}
+	rows, err := tx.Query(ctx, takenQuery, emails, usernames)
+	if err != nil {
+		return 0, nil, err
+	}
+	takenEmails := map[string]bool{}
+	takenUsernames := map[string]bool{}
+	for rows.Next() {
+		var email, username string
+		if err := rows.Scan(&email, &username); err != nil {
+			rows.Close()
+			return 0, nil, err
+		}
+		takenEmails[email] = true
+		takenUsernames[username] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	var conflicts []UserImportRowError
+	toInsert := make([]core.ImportedUser, 0, len(users))
+	for _, imported := range users {
+		switch {
+		case takenEmails[imported.User.Email]:
+			conflicts = append(conflicts, UserImportRowError{Row: imported.Row, Field: "email", Code: core.ErrUserAlreadyExists.Code})
+		case takenUsernames[imported.User.Username]:
+			conflicts = append(conflicts, UserImportRowError{Row: imported.Row, Field: "username", Code: core.ErrUsernameTaken.Code})
+		default:
+			toInsert = append(toInsert, imported)
+		}
+	}
+
+	// ... a concurrent signup can still take an email between the check and
+	// the copy, in which case the rows are inserted one by one instead
+	copied, err := copyImportedUsers(ctx, tx, toInsert)
+	var pgErr *pgconn.PgError
+	if err == nil || !errors.As(err, &pgErr) || pgErr.Code != pgUniqueViolation {
+		return copied, conflicts, err
+	}
+	inserted, insertConflicts, err := insertImportedUsersOneByOne(ctx, tx, toInsert)
+	return inserted, append(conflicts, insertConflicts...), err
+}
+
+func copyImportedUsers(ctx context.Context, tx pgx.Tx, users []core.ImportedUser) (int, error) {
+	if len(users) == 0 {
+		return 0, nil
+	}
+
+	savepoint, err := tx.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer savepoint.Rollback(ctx)
+
+	copied, err := savepoint.CopyFrom(ctx,
+		pgx.Identifier{"users"},
+		[]string{"id", "username", "email", "password", "roles", "created_at", "updated_at"},
+		pgx.CopyFromSlice(len(users), func(i int) ([]any, error) {
+			user := users[i].User
+			return []any{user.ID, user.Username, user.Email, user.Password, user.Roles, user.CreatedAt, user.UpdatedAt}, nil
+		}),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return int(copied), savepoint.Commit(ctx)
+}
+
+func insertImportedUsersOneByOne(ctx context.Context, tx pgx.Tx, users []core.ImportedUser) (int, []UserImportRowError, error) {
+	const query = `INSERT INTO users (id, username, email, password, roles, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT DO NOTHING`
+
+	batch := &pgx.Batch{}
+	for _, imported := range users {
+		user := imported.User
+		batch.Queue(query, user.ID, user.Username, user.Email, user.Password, user.Roles, user.CreatedAt, user.UpdatedAt)
+	}
+	results := tx.SendBatch(ctx, batch)
+	defer results.Close()
+
+	inserted := 0
+	var conflicts []UserImportRowError
+	for _, imported := range users {
+		tag, err := results.Exec()
+		if err != nil {
+			return 0, nil, err
+		}
+		if tag.RowsAffected() == 0 {
+			conflicts = append(conflicts, UserImportRowError{Row: imported.Row, Code: "resource_conflict"})
+			continue
+		}
+		inserted++
+	}
+	return inserted, conflicts, nil
+}
+
+func (r *UserImportRepository) FinishUserImport(ctx context.Context, id uuid.UUID, status string) (*core.UserImport, error) {
+	const query = `UPDATE user_imports SET status = $2, payload = ''::bytea, finished_at = NOW(), updated_at = NOW()
+		WHERE id = $1 RETURNING ` + userImportColumns
+
+	userImport := &UserImport{}
+	if err := scanUserImport(r.db.QueryRow(ctx, query, id, status), userImport); err != nil {
+		r.logger.FromContext(ctx).Error("failed to finish user import", "error", err, "id", id)
+		return nil, translateError(err)
+	}
+	return userImport.ToCoreUserImport(), nil
+}
@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"go-rest-api/internal/core"
+	"go-rest-api/pkg/logger"
+	"go-rest-api/test"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type UserImportRepositoryTestSuite struct {
+	suite.Suite
+	userRepo       core.UserRepository
+	userImportRepo core.UserImportRepository
+	admin          *core.User
+	tearDown       func()
+}
+
+func (testSuite *UserImportRepositoryTestSuite) SetupSuite() {
+	t := testSuite.T()
+	dbPool, tear := test.CreateDbTestContainer(context.Background(), t)
+	testSuite.tearDown = tear
+	mockLogger := logger.MockLogger{}
+	mockLogger.On("Error", mock.Anything, mock.Anything).Return()
+	testSuite.userRepo = NewUserRepository(dbPool, &mockLogger)
+	testSuite.userImportRepo = NewUserImportRepository(dbPool, &mockLogger)
+
+	admin, err := testSuite.userRepo.CreateUser(context.Background(), newImportedUser("importer", "importer@gmail.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSuite.admin = admin
+}
+
+func (testSuite *UserImportRepositoryTestSuite) TearDownSuite() {
+	if testSuite.tearDown != nil {
+		testSuite.tearDown()
+	}
+}
+
+func newImportedUser(username, email string) *core.User {
+	now := time.Now().UTC()
+	return &core.User{
+		ID:        uuid.New(),
+		Username:  username,
+		Email:     email,
+		Password:  "hashed",
+		Roles:     []string{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+func (testSuite *UserImportRepositoryTestSuite) TestUserImportRepository_ClaimSaveAndFinish() {
+	t := testSuite.T()
+	a := assert.New(t)
+	ctx := context.Background()
+	// given
+	created, err := testSuite.userImportRepo.CreateUserImport(ctx, &core.UserImport{
+		ID:        uuid.New(),
+		Status:    core.UserImportStatusPending,
+		Format:    core.UserImportFormatCSV,
+		CreatedBy: testSuite.admin.ID,
+		TotalRows: 4,
+		CreatedAt: time.Now().UTC(),
+	}, []byte("username,email,password\n"))
+	a.NoError(err)
+
+	// when
+	claimed, payload, errClaim := testSuite.userImportRepo.ClaimUserImport(ctx, time.Minute)
+	again, _, errAgain := testSuite.userImportRepo.ClaimUserImport(ctx, time.Minute)
+	saved, errSave := testSuite.userImportRepo.SaveUserImportBatch(ctx, created.ID, &core.UserImportBatch{
+		ProcessedRows: 4,
+		Users: []core.ImportedUser{
+			{Row: 1, User: newImportedUser("alice", "alice@gmail.com")},
+			{Row: 2, User: newImportedUser("bob", "importer@gmail.com")},
+			{Row: 4, User: newImportedUser("carol", "carol@gmail.com")},
+		},
+		Errors: []core.UserImportRowError{{Row: 3, Field: "email", Code: "validation.email"}},
+	})
+	finished, errFinish := testSuite.userImportRepo.FinishUserImport(ctx, created.ID, core.UserImportStatusCompleted)
+	alice, errAlice := testSuite.userRepo.GetUserByEmail(ctx, "alice@gmail.com")
+
+	// then
+	a.NoError(errClaim)
+	a.Equal(created.ID, claimed.ID)
+	a.Equal(core.UserImportStatusRunning, claimed.Status)
+	a.NotNil(claimed.StartedAt)
+	a.Equal([]byte("username,email,password\n"), payload)
+	a.NoError(errAgain)
+	a.Nil(again)
+
+	a.NoError(errSave)
+	a.Equal(4, saved.ProcessedRows)
+	a.Equal(2, saved.ImportedRows)
+	a.Equal(2, saved.FailedRows)
+	a.Equal([]core.UserImportRowError{
+		{Row: 2, Field: "email", Code: core.ErrUserAlreadyExists.Code},
+		{Row: 3, Field: "email", Code: "validation.email"},
+	}, saved.Errors)
+
+	a.NoError(errFinish)
+	a.True(finished.IsFinished())
+	a.NotNil(finished.FinishedAt)
+	a.NoError(errAlice)
+	a.Equal("alice", alice.Username)
+}
+
+func (testSuite *UserImportRepositoryTestSuite) TestUserImportRepository_GetUserImportNotFound() {
+	t := testSuite.T()
+	a := assert.New(t)
+
+	// when
+	userImport, err := testSuite.userImportRepo.GetUserImport(context.Background(), uuid.New().String())
+
+	// then
+	a.NoError(err)
+	a.Nil(userImport)
+}
+
+func TestUserImportRepositoryTestSuite(t *testing.T) {
+	suite.Run(t, new(UserImportRepositoryTestSuite))
+}
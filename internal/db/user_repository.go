@@ -9,6 +9,18 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// userColumns is the column list scanned by scanUser. The password hash is
+// deliberately excluded and only selected where it is needed.
+const userColumns = `id, username, email, COALESCE(avatar_url, ''), profile, roles, version, created_at, updated_at`
+
+const (
+	// streamUsersQuery fetches the rows of the user_stream cursor in batches.
+	streamUsersQuery = `FETCH 500 FROM user_stream`
+
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
 type UserRepository struct {
 	db     *pgxpool.Pool
 	logger logger.CustomLogger
@@ -26,41 +38,58 @@ func (usr *User) ToCoreUser() *core.User {
 		ID:        usr.ID,
 		Username:  usr.Username,
 		Email:     usr.Email,
+		AvatarURL: usr.AvatarURL,
+		Profile:   usr.Profile,
+		Roles:     usr.Roles,
+		Version:   usr.Version,
 		CreatedAt: usr.CreatedAt,
 		UpdatedAt: usr.UpdatedAt,
 	}
 }
 
+func scanUser(row pgx.Row, user *User) error {
+	return row.Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.AvatarURL,
+		&user.Profile,
+		&user.Roles,
+		&user.Version,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+}
+
 func (u *UserRepository) CreateUser(ctx context.Context, user *core.User) (*core.User, error) {
-	const query = `INSERT INTO users (id, username, email, password, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6)`
+	const query = `INSERT INTO users (id, username, email, password, roles, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`
 
+	roles := user.Roles
+	if roles == nil {
+		roles = []string{}
+	}
 	_, err := u.db.Exec(ctx, query,
 		user.ID,
 		user.Username,
 		user.Email,
 		user.Password,
+		roles,
 		user.CreatedAt,
 		user.UpdatedAt,
 	)
 
 	if err != nil {
-		u.logger.Error(err)
-		return nil, err
+		u.logger.FromContext(ctx).Error("failed to create user", "error", err)
+		return nil, translateError(err)
 	}
 	createdUser := &User{}
 	// Fetch the created user
-	getUserQuery := `SELECT id, username, email, created_at, updated_at FROM users WHERE id = $1`
-	err = u.db.QueryRow(ctx, getUserQuery, user.ID).Scan(
-		&createdUser.ID,
-		&createdUser.Username,
-		&createdUser.Email,
-		&createdUser.CreatedAt,
-		&createdUser.UpdatedAt,
-	)
+	getUserQuery := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+	err = scanUser(u.db.QueryRow(ctx, getUserQuery, user.ID), createdUser)
 
 	if err != nil {
-		u.logger.Error("failed to fetch created user", err, user.ID)
-		return nil, err
+		u.logger.FromContext(ctx).Error("failed to fetch created user", "error", err, "id", user.ID)
+		return nil, translateError(err)
 	}
 
 	// Map to core.User and return
@@ -68,33 +97,53 @@ func (u *UserRepository) CreateUser(ctx context.Context, user *core.User) (*core
 }
 
 func (u *UserRepository) GetUserByID(ctx context.Context, id string) (*core.User, error) {
-	const query = `SELECT id, username, email, created_at, updated_at FROM users WHERE id = $1`
+	const query = `SELECT ` + userColumns + ` FROM users WHERE id = $1`
 
 	user := &User{}
-	err := u.db.QueryRow(ctx, query, id).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
+	err := scanUser(u.db.QueryRow(ctx, query, id), user)
 
 	if err != nil && err == pgx.ErrNoRows {
-		u.logger.Info("user not found", id)
+		u.logger.FromContext(ctx).Info("user not found", "id", id)
 		return nil, nil
 	}
 
 	if err != nil {
-		u.logger.Error("failed to get user by id", err, id)
-		return nil, err
+		u.logger.FromContext(ctx).Error("failed to get user by id", "error", err, "id", id)
+		return nil, translateError(err)
 	}
 
 	// Map to core.User and return
 	return user.ToCoreUser(), nil
 }
 
+func (u *UserRepository) GetUsersByIDs(ctx context.Context, ids []string) ([]*core.User, error) {
+	const query = `SELECT ` + userColumns + ` FROM users WHERE id = ANY($1::uuid[])`
+
+	rows, err := u.db.Query(ctx, query, ids)
+	if err != nil {
+		u.logger.FromContext(ctx).Error("failed to get users by ids", "error", err)
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	users := []*core.User{}
+	for rows.Next() {
+		user := &User{}
+		if err := scanUser(rows, user); err != nil {
+			u.logger.FromContext(ctx).Error("failed to scan user", "error", err)
+			return nil, translateError(err)
+		}
+		users = append(users, user.ToCoreUser())
+	}
+	if err := rows.Err(); err != nil {
+		u.logger.FromContext(ctx).Error("failed to get users by ids", "error", err)
+		return nil, translateError(err)
+	}
+	return users, nil
+}
+
 func (u *UserRepository) GetUserByEmail(ctx context.Context, email string) (*core.User, error) {
-	const query = `SELECT id, username, email, password, created_at, updated_at FROM users WHERE email = $1`
+	const query = `SELECT id, username, email, password, COALESCE(avatar_url, ''), profile, roles, version, created_at, updated_at FROM users WHERE email = $1`
 
 	user := &User{}
 	err := u.db.QueryRow(ctx, query, email).Scan(
@@ -102,18 +151,22 @@ func (u *UserRepository) GetUserByEmail(ctx context.Context, email string) (*cor
 		&user.Username,
 		&user.Email,
 		&user.Password,
+		&user.AvatarURL,
+		&user.Profile,
+		&user.Roles,
+		&user.Version,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
 
 	if err != nil && err == pgx.ErrNoRows {
-		u.logger.Info("user not found", email)
+		u.logger.FromContext(ctx).Info("user not found", "email", email)
 		return nil, nil
 	}
 
 	if err != nil {
-		u.logger.Error("failed to get user by email", err, email)
-		return nil, err
+		u.logger.FromContext(ctx).Error("failed to get user by email", "error", err, "email", email)
+		return nil, translateError(err)
 	}
 
 	// Map to core.User and return
@@ -121,3 +174,152 @@ func (u *UserRepository) GetUserByEmail(ctx context.Context, email string) (*cor
 	result.Password = user.Password
 	return result, nil
 }
+
+func (u *UserRepository) UpdateUserAvatar(ctx context.Context, id string, avatarURL string, version int64) (*core.User, error) {
+	const query = `UPDATE users SET avatar_url = $2, version = version + 1, updated_at = NOW()
+		WHERE id = $1 AND ($3 = 0 OR version = $3) RETURNING ` + userColumns
+
+	user := &User{}
+	err := scanUser(u.db.QueryRow(ctx, query, id, avatarURL, version), user)
+
+	if err != nil && err == pgx.ErrNoRows {
+		return nil, u.versionMismatchOrNotFound(ctx, id)
+	}
+
+	if err != nil {
+		u.logger.FromContext(ctx).Error("failed to update user avatar", "error", err, "id", id)
+		return nil, translateError(err)
+	}
+
+	return user.ToCoreUser(), nil
+}
+
+func (u *UserRepository) UpdateUserProfile(ctx context.Context, id string, profile map[string]any, version int64) (*core.User, error) {
+	const query = `UPDATE users SET profile = $2, version = version + 1, updated_at = NOW()
+		WHERE id = $1 AND ($3 = 0 OR version = $3) RETURNING ` + userColumns
+
+	user := &User{}
+	err := scanUser(u.db.QueryRow(ctx, query, id, profile, version), user)
+
+	if err != nil && err == pgx.ErrNoRows {
+		return nil, u.versionMismatchOrNotFound(ctx, id)
+	}
+
+	if err != nil {
+		u.logger.FromContext(ctx).Error("failed to update user profile", "error", err, "id", id)
+		return nil, translateError(err)
+	}
+
+	return user.ToCoreUser(), nil
+}
+
+// versionMismatchOrNotFound tells apart why a versioned update matched no row:
+// it returns core.ErrUserVersionMismatch when the user exists and nil when it
+// does not.
+func (u *UserRepository) versionMismatchOrNotFound(ctx context.Context, id string) error {
+	const query = `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`
+
+	var exists bool
+	if err := u.db.QueryRow(ctx, query, id).Scan(&exists); err != nil {
+		u.logger.FromContext(ctx).Error("failed to check user existence", "error", err, "id", id)
+		return translateError(err)
+	}
+	if !exists {
+		u.logger.FromContext(ctx).Info("user not found", "id", id)
+		return nil
+	}
+	return core.ErrUserVersionMismatch
+}
+
+// ListUsers returns users ordered by creation time. The profile filter uses
+// JSONB containment (@>) so it can be served by the GIN index on profile.
+func (u *UserRepository) ListUsers(ctx context.Context, filter core.UserFilter) ([]*core.User, error) {
+	const query = `SELECT ` + userColumns + ` FROM users WHERE profile @> $1 ORDER BY created_at, id LIMIT $2 OFFSET $3`
+
+	profileFilter := filter.Profile
+	if profileFilter == nil {
+		profileFilter = map[string]any{}
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	limit = min(limit, maxListLimit)
+
+	rows, err := u.db.Query(ctx, query, profileFilter, limit, max(filter.Offset, 0))
+	if err != nil {
+		u.logger.FromContext(ctx).Error("failed to list users", "error", err)
+		return nil, translateError(err)
+	}
+	defer rows.Close()
+
+	users := []*core.User{}
+	for rows.Next() {
+		user := &User{}
+		if err := scanUser(rows, user); err != nil {
+			u.logger.FromContext(ctx).Error("failed to scan user", "error", err)
+			return nil, translateError(err)
+		}
+		users = append(users, user.ToCoreUser())
+	}
+	if err := rows.Err(); err != nil {
+		u.logger.FromContext(ctx).Error("failed to list users", "error", err)
+		return nil, translateError(err)
+	}
+	return users, nil
+}
+
+// StreamUsers reads the users through a server-side cursor in a read-only,
+// repeatable read transaction, so the export sees one consistent snapshot and
+// memory use does not depend on the number of users.
+func (u *UserRepository) StreamUsers(ctx context.Context, filter core.UserFilter, fn func(*core.User) error) error {
+	const declareQuery = `DECLARE user_stream NO SCROLL CURSOR FOR
+		SELECT ` + userColumns + ` FROM users WHERE profile @> $1 ORDER BY created_at, id`
+
+	profileFilter := filter.Profile
+	if profileFilter == nil {
+		profileFilter = map[string]any{}
+	}
+
+	tx, err := u.db.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		u.logger.FromContext(ctx).Error("failed to begin user stream transaction", "error", err)
+		return translateError(err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, declareQuery, profileFilter); err != nil {
+		u.logger.FromContext(ctx).Error("failed to declare user stream cursor", "error", err)
+		return translateError(err)
+	}
+
+	for {
+		rows, err := tx.Query(ctx, streamUsersQuery)
+		if err != nil {
+			u.logger.FromContext(ctx).Error("failed to fetch users", "error", err)
+			return translateError(err)
+		}
+		fetched := 0
+		for rows.Next() {
+			fetched++
+			user := &User{}
+			if err := scanUser(rows, user); err != nil {
+				rows.Close()
+				u.logger.FromContext(ctx).Error("failed to scan user", "error", err)
+				return translateError(err)
+			}
+			if err := fn(user.ToCoreUser()); err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			u.logger.FromContext(ctx).Error("failed to fetch users", "error", err)
+			return translateError(err)
+		}
+		if fetched == 0 {
+			return nil
+		}
+	}
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"fmt"
 	"go-rest-api/internal/core"
 	"go-rest-api/pkg/logger"
 	"go-rest-api/test"
@@ -27,8 +28,7 @@ func (testSuite *UserRepositoryTestSuite) SetupSuite() {
 	dbPool, tear := test.CreateDbTestContainer(context.Background(), t)
 	testSuite.tearDown = tear
 	mockLogger := logger.MockLogger{}
-	mockLogger.On("Error", mock.Anything).Return()
-	mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything).Return()
+	mockLogger.On("Error", mock.Anything, mock.Anything).Return()
 	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
 	testSuite.userRepo = NewUserRepository(dbPool, &mockLogger)
 }
@@ -60,6 +60,8 @@ func (testSuite *UserRepositoryTestSuite) TestUserRepository_CreateUser() {
 		ID:        testUser.ID,
 		Username:  testUser.Username,
 		Email:     testUser.Email,
+		Profile:   map[string]any{},
+		Roles:     []string{},
 		CreatedAt: testUser.CreatedAt,
 		UpdatedAt: testUser.UpdatedAt,
 	}
@@ -118,6 +120,8 @@ func (testSuite *UserRepositoryTestSuite) TestUserRepository_GetUserByID() {
 		ID:        testUser.ID,
 		Username:  testUser.Username,
 		Email:     testUser.Email,
+		Profile:   map[string]any{},
+		Roles:     []string{},
 		CreatedAt: testUser.CreatedAt,
 		UpdatedAt: testUser.UpdatedAt,
 	}
@@ -174,6 +178,8 @@ func (testSuite *UserRepositoryTestSuite) TestUserRepository_GetUserByEmail() {
 		ID:        testUser.ID,
 		Username:  testUser.Username,
 		Email:     testUser.Email,
+		Profile:   map[string]any{},
+		Roles:     []string{},
 		CreatedAt: testUser.CreatedAt,
 		UpdatedAt: testUser.UpdatedAt,
 	}
@@ -194,6 +200,198 @@ func (testSuite *UserRepositoryTestSuite) TestUserRepository_GetUserByEmail_User
 	a.Nil(user)
 }
 
+func (testSuite *UserRepositoryTestSuite) TestUserRepository_UpdateUserAvatar() {
+	t := testSuite.T()
+	a := assert.New(t)
+	// given
+	testUser := core.User{
+		ID:        uuid.New(),
+		Username:  "JohnDoeAvatar",
+		Email:     "johndoeavatar@gmail.com",
+		Password:  "hashedpassword",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	_, err := testSuite.userRepo.CreateUser(context.Background(), &testUser)
+	a.NoError(err)
+	avatarURL := "http://localhost:8080/static/avatars/" + testUser.ID.String() + "/256.png"
+
+	// when
+	user, err := testSuite.userRepo.UpdateUserAvatar(context.Background(), testUser.ID.String(), avatarURL, 1)
+
+	// then
+	a.NoError(err)
+	a.Equal(avatarURL, user.AvatarURL)
+	a.Equal(int64(2), user.Version)
+}
+
+func (testSuite *UserRepositoryTestSuite) TestUserRepository_UpdateUserAvatar_UserNotFound() {
+	t := testSuite.T()
+	a := assert.New(t)
+	// when
+	user, err := testSuite.userRepo.UpdateUserAvatar(context.Background(), uuid.New().String(), "http://localhost/avatar.png", core.AnyVersion)
+
+	// then
+	a.NoError(err)
+	a.Nil(user)
+}
+
+func (testSuite *UserRepositoryTestSuite) TestUserRepository_UpdateUserProfile() {
+	t := testSuite.T()
+	a := assert.New(t)
+	// given
+	testUser := core.User{
+		ID:        uuid.New(),
+		Username:  "JohnDoeProfile",
+		Email:     "johndoeprofile@gmail.com",
+		Password:  "hashedpassword",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	_, err := testSuite.userRepo.CreateUser(context.Background(), &testUser)
+	a.NoError(err)
+	profile := map[string]any{
+		"locale":      "zu",
+		"preferences": map[string]any{"theme": "dark"},
+	}
+
+	// when
+	user, err := testSuite.userRepo.UpdateUserProfile(context.Background(), testUser.ID.String(), profile, 1)
+
+	// then
+	a.NoError(err)
+	if diff := cmp.Diff(profile, user.Profile); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func (testSuite *UserRepositoryTestSuite) TestUserRepository_UpdateUserProfile_VersionMismatch() {
+	t := testSuite.T()
+	a := assert.New(t)
+	// given
+	testUser := core.User{
+		ID:        uuid.New(),
+		Username:  "JohnDoeStale",
+		Email:     "johndoestale@gmail.com",
+		Password:  "hashedpassword",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	_, err := testSuite.userRepo.CreateUser(context.Background(), &testUser)
+	a.NoError(err)
+	_, err = testSuite.userRepo.UpdateUserProfile(context.Background(), testUser.ID.String(), map[string]any{"locale": "fr"}, 1)
+	a.NoError(err)
+
+	// when
+	user, err := testSuite.userRepo.UpdateUserProfile(context.Background(), testUser.ID.String(), map[string]any{"locale": "zu"}, 1)
+
+	// then
+	a.ErrorIs(err, core.ErrUserVersionMismatch)
+	a.Nil(user)
+}
+
+func (testSuite *UserRepositoryTestSuite) TestUserRepository_ListUsers_FilterByProfile() {
+	t := testSuite.T()
+	a := assert.New(t)
+	// given
+	frenchUser := core.User{
+		ID:        uuid.New(),
+		Username:  "JeanDupont",
+		Email:     "jeandupont@gmail.com",
+		Password:  "hashedpassword",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	otherUser := core.User{
+		ID:        uuid.New(),
+		Username:  "JaneSmith",
+		Email:     "janesmith@gmail.com",
+		Password:  "hashedpassword",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	for _, user := range []*core.User{&frenchUser, &otherUser} {
+		_, err := testSuite.userRepo.CreateUser(context.Background(), user)
+		a.NoError(err)
+	}
+	_, err := testSuite.userRepo.UpdateUserProfile(context.Background(), frenchUser.ID.String(), map[string]any{
+		"locale":      "fr-FR",
+		"preferences": map[string]any{"theme": "dark"},
+	}, core.AnyVersion)
+	a.NoError(err)
+
+	// when
+	users, err := testSuite.userRepo.ListUsers(context.Background(), core.UserFilter{
+		Profile: map[string]any{"preferences": map[string]any{"theme": "dark"}},
+	})
+
+	// then
+	a.NoError(err)
+	a.Len(users, 1)
+	a.Equal(frenchUser.ID, users[0].ID)
+}
+
+func (testSuite *UserRepositoryTestSuite) TestUserRepository_GetUsersByIDs() {
+	t := testSuite.T()
+	a := assert.New(t)
+	ctx := context.Background()
+	// given
+	user := core.User{
+		ID:        uuid.New(),
+		Username:  "Batched",
+		Email:     "batched@gmail.com",
+		Password:  "hashedpassword",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	_, err := testSuite.userRepo.CreateUser(ctx, &user)
+	a.NoError(err)
+
+	// when
+	users, err := testSuite.userRepo.GetUsersByIDs(ctx, []string{user.ID.String(), uuid.NewString()})
+
+	// then
+	a.NoError(err)
+	a.Len(users, 1)
+	a.Equal(user.ID, users[0].ID)
+	a.Empty(users[0].Password)
+}
+
+func (testSuite *UserRepositoryTestSuite) TestUserRepository_StreamUsers() {
+	t := testSuite.T()
+	a := assert.New(t)
+	ctx := context.Background()
+	// given
+	var created []uuid.UUID
+	for i := range 3 {
+		user := core.User{
+			ID:        uuid.New(),
+			Username:  fmt.Sprintf("Streamed%d", i),
+			Email:     fmt.Sprintf("streamed%d@gmail.com", i),
+			Password:  "hashedpassword",
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		_, err := testSuite.userRepo.CreateUser(ctx, &user)
+		a.NoError(err)
+		_, err = testSuite.userRepo.UpdateUserProfile(ctx, user.ID.String(), map[string]any{"team": "streamed"}, core.AnyVersion)
+		a.NoError(err)
+		created = append(created, user.ID)
+	}
+
+	// when
+	var streamed []uuid.UUID
+	err := testSuite.userRepo.StreamUsers(ctx, core.UserFilter{Profile: map[string]any{"team": "streamed"}, Limit: 1}, func(user *core.User) error {
+		a.Empty(user.Password)
+		streamed = append(streamed, user.ID)
+		return nil
+	})
+
+	// then
+	a.NoError(err)
+	a.Equal(created, streamed)
+}
+
 func TestNewUserRepositoryTestSuite(t *testing.T) {
 	suite.Run(t, new(UserRepositoryTestSuite))
 }
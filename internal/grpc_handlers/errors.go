@@ -0,0 +1,98 @@
+package grpc_handlers
+
+import (
+	"context"
+	"errors"
+	"go-rest-api/internal/core"
+	"go-rest-api/internal/handlers"
+	"go-rest-api/internal/i18n"
+	"go-rest-api/pkg/logger"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// errorDomain is the domain of the ErrorInfo detail attached to every error.
+const errorDomain = "go-rest-api"
+
+// statusMappings maps domain errors to gRPC codes the way problemMappings maps
+// them to HTTP statuses. Reason is the code reported when the error is not a
+// core.Error with a code of its own.
+var statusMappings = []struct {
+	kind   error
+	code   codes.Code
+	reason string
+}{
+	{core.ErrValidation, codes.InvalidArgument, "validation_error"},
+	{core.ErrNotFound, codes.NotFound, "not_found"},
+	{core.ErrConflict, codes.AlreadyExists, "conflict"},
+	{core.ErrUnauthorized, codes.Unauthenticated, "unauthorized"},
+	{core.ErrForbidden, codes.PermissionDenied, "forbidden"},
+	{core.ErrGone, codes.NotFound, "gone"},
+	{core.ErrPreconditionFailed, codes.FailedPrecondition, "precondition_failed"},
+}
+
+// statusError turns an error into a gRPC status error. Domain errors keep
+// their stable code as the reason of an ErrorInfo detail and get a message in
+// the caller's language; validation errors also list the offending fields.
+// Anything else is logged and reported as internal_error.
+func statusError(ctx context.Context, logger logger.CustomLogger, err error) error {
+	for _, mapping := range statusMappings {
+		if !errors.Is(err, mapping.kind) {
+			continue
+		}
+		var validationErr *handlers.ValidationError
+		var domainErr *core.Error
+		switch {
+		case errors.As(err, &validationErr):
+			return newStatus(ctx, mapping.code, "validation_failed", nil, fieldViolations(ctx, validationErr))
+		case errors.As(err, &domainErr) && domainErr.Code != "":
+			return newStatus(ctx, mapping.code, domainErr.Code, domainErr.Params)
+		}
+		message := i18n.Translate(callLocale(ctx), "title."+mapping.reason, nil)
+		return withErrorInfo(status.New(mapping.code, message), mapping.reason, nil)
+	}
+	logger.FromContext(ctx).Error("grpc request failed", "error", err)
+	return newStatus(ctx, codes.Internal, "internal_error", nil)
+}
+
+// newStatus returns a status error with a translated message and an
+// ErrorInfo detail carrying the code.
+func newStatus(ctx context.Context, code codes.Code, reason string, params map[string]string, details ...protoadapt.MessageV1) error {
+	return withErrorInfo(status.New(code, i18n.Translate(callLocale(ctx), reason, params)), reason, params, details...)
+}
+
+func withErrorInfo(st *status.Status, reason string, params map[string]string, details ...protoadapt.MessageV1) error {
+	info := &errdetails.ErrorInfo{Reason: reason, Domain: errorDomain, Metadata: params}
+	withDetails, err := st.WithDetails(append([]protoadapt.MessageV1{info}, details...)...)
+	if err != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+func fieldViolations(ctx context.Context, validationErr *handlers.ValidationError) *errdetails.BadRequest {
+	badRequest := &errdetails.BadRequest{}
+	for _, field := range validationErr.Localize(callLocale(ctx)) {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       field.Field,
+			Description: field.Message,
+			Reason:      field.Rule,
+		})
+	}
+	return badRequest
+}
+
+// callLocale negotiates the language of messages from the accept-language
+// metadata, like LocaleMiddleware does from the Accept-Language header.
+func callLocale(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("accept-language")
+	if len(values) == 0 {
+		return i18n.DefaultLocale
+	}
+	return i18n.Negotiate(values[0])
+}
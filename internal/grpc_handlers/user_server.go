@@ -0,0 +1,171 @@
+package grpc_handlers
+
+import (
+	"context"
+	"go-rest-api/internal/core"
+	"go-rest-api/internal/handlers"
+	"go-rest-api/pkg/logger"
+	userv1 "go-rest-api/pkg/pb/user/v1"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// UserService is the part of core.UserService exposed over gRPC.
+type UserService interface {
+	CreateUser(ctx context.Context, user *core.User) (*core.User, error)
+	GetUserByID(ctx context.Context, id string) (*core.User, error)
+	LoginUser(ctx context.Context, email, password, jwtSecret string) (string, error)
+	ListUsers(ctx context.Context, filter core.UserFilter) ([]*core.User, error)
+}
+
+// UserServer implements the user.v1.UserService gRPC service on top of the
+// same core service and request validation as the REST UserHandler.
+type UserServer struct {
+	userv1.UnimplementedUserServiceServer
+	userService UserService
+	Logger      logger.CustomLogger
+	// AuditLogger writes the audit trail of impersonated calls, Logger unless
+	// set.
+	AuditLogger logger.CustomLogger
+	JwtSecret   string
+	// OpenSignupDisabled rejects CreateUser like it rejects POST /users.
+	OpenSignupDisabled bool
+}
+
+func NewUserServer(userService UserService, logger logger.CustomLogger, jwtSecret string) *UserServer {
+	return &UserServer{
+		userService: userService,
+		Logger:      logger,
+		AuditLogger: logger,
+		JwtSecret:   jwtSecret,
+	}
+}
+
+// NewServer returns a gRPC server with the user service, the standard health
+// service and server reflection registered, behind the auth and metrics
+// interceptors. The health service is returned as well, to report the server
+// as NOT_SERVING with its Shutdown method once a shutdown begins.
+func NewServer(userServer *UserServer, apiKeys []string) (*grpc.Server, *health.Server) {
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(MetricsUnaryInterceptor, AuthUnaryInterceptor(userServer.JwtSecret, apiKeys, userServer.Logger, userServer.AuditLogger)),
+		grpc.ChainStreamInterceptor(MetricsStreamInterceptor, AuthStreamInterceptor(userServer.JwtSecret, apiKeys, userServer.Logger, userServer.AuditLogger)),
+	)
+	userv1.RegisterUserServiceServer(server, userServer)
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(userv1.UserService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+
+	reflection.Register(server)
+	return server, healthServer
+}
+
+func (s *UserServer) CreateUser(ctx context.Context, req *userv1.CreateUserRequest) (*userv1.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if s.OpenSignupDisabled {
+		return nil, newStatus(ctx, codes.PermissionDenied, "signup_disabled", nil)
+	}
+
+	userReq := handlers.CreateUserRequest{Username: req.GetUsername(), Email: req.GetEmail(), Password: req.GetPassword()}
+	if err := userReq.Validate(); err != nil {
+		return nil, statusError(ctx, s.Logger, err)
+	}
+
+	user := userReq.ToUser()
+	result, err := s.userService.CreateUser(ctx, &user)
+	if err != nil {
+		return nil, statusError(ctx, s.Logger, err)
+	}
+	return s.toUser(ctx, result)
+}
+
+func (s *UserServer) GetUser(ctx context.Context, req *userv1.GetUserRequest) (*userv1.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if _, err := uuid.Parse(req.GetId()); err != nil {
+		return nil, newStatus(ctx, codes.InvalidArgument, "invalid_user_id", nil)
+	}
+
+	user, err := s.userService.GetUserByID(ctx, req.GetId())
+	if err != nil {
+		return nil, statusError(ctx, s.Logger, err)
+	}
+	return s.toUser(ctx, user)
+}
+
+func (s *UserServer) Login(ctx context.Context, req *userv1.LoginRequest) (*userv1.LoginResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	userReq := handlers.LoginUserRequest{Email: req.GetEmail(), Password: req.GetPassword()}
+	if err := userReq.Validate(); err != nil {
+		return nil, statusError(ctx, s.Logger, err)
+	}
+
+	token, err := s.userService.LoginUser(ctx, strings.ToLower(userReq.Email), userReq.Password, s.JwtSecret)
+	if err != nil {
+		return nil, statusError(ctx, s.Logger, err)
+	}
+	return &userv1.LoginResponse{Token: token}, nil
+}
+
+func (s *UserServer) ListUsers(ctx context.Context, req *userv1.ListUsersRequest) (*userv1.ListUsersResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if err := requireRole(ctx, core.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	filter := core.UserFilter{Limit: 20, Offset: int(req.GetOffset())}
+	if req.GetLimit() != 0 {
+		filter.Limit = int(req.GetLimit())
+	}
+	if filter.Limit < 1 || filter.Limit > 100 {
+		return nil, newStatus(ctx, codes.InvalidArgument, "invalid_limit", nil)
+	}
+	if filter.Offset < 0 {
+		return nil, newStatus(ctx, codes.InvalidArgument, "invalid_offset", nil)
+	}
+	if req.GetProfile() != nil {
+		filter.Profile = req.GetProfile().AsMap()
+	}
+
+	users, err := s.userService.ListUsers(ctx, filter)
+	if err != nil {
+		return nil, statusError(ctx, s.Logger, err)
+	}
+
+	response := &userv1.ListUsersResponse{
+		Users:  make([]*userv1.User, 0, len(users)),
+		Limit:  int32(filter.Limit),
+		Offset: int32(filter.Offset),
+	}
+	for _, user := range users {
+		result, err := s.toUser(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		response.Users = append(response.Users, result)
+	}
+	return response, nil
+}
+
+// toUser converts a user to its protobuf message.
+func (s *UserServer) toUser(ctx context.Context, user *core.User) (*userv1.User, error) {
+	message, err := handlers.ToUserProto(*user)
+	if err != nil {
+		return nil, statusError(ctx, s.Logger, err)
+	}
+	return message, nil
+}
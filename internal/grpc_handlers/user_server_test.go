@@ -0,0 +1,224 @@
+package grpc_handlers
+
+import (
+	"context"
+	"go-rest-api/internal/core"
+	"go-rest-api/pkg/logger"
+	userv1 "go-rest-api/pkg/pb/user/v1"
+	"net"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const testJWTSecret = "secret"
+
+// newTestClient serves a UserServer over an in-memory connection and returns
+// a client connection to it.
+func newTestClient(t *testing.T, userRepo *core.MockUserRepository) *grpc.ClientConn {
+	conn, _ := newTestServer(t, userRepo)
+	return conn
+}
+
+// newTestServer is newTestClient that also returns the health service of the
+// server.
+func newTestServer(t *testing.T, userRepo *core.MockUserRepository) (*grpc.ClientConn, *health.Server) {
+	mockLogger := &logger.MockLogger{}
+	mockLogger.On("Error", mock.Anything, mock.Anything).Return()
+	userService := core.NewUserService(userRepo, mockLogger, &core.MockUserEventService{}, &core.MockBlobStore{}, &core.MockProfileValidator{})
+	server, healthServer := NewServer(NewUserServer(userService, mockLogger, testJWTSecret), []string{"internal-key"})
+
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, healthServer
+}
+
+func errorReason(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info.Reason
+		}
+	}
+	return ""
+}
+
+func TestUserServer_GetUser(t *testing.T) {
+	user := &core.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com", Password: "hash", Roles: []string{}, Profile: map[string]any{"locale": "fr"}, Version: 3}
+	token, _ := core.GenerateAuthToken(uuid.New(), nil, testJWTSecret)
+
+	testScenarios := []struct {
+		name           string
+		metadata       []string
+		expectedCode   codes.Code
+		expectedReason string
+	}{
+		{name: "jwt", metadata: []string{"authorization", "Bearer " + token}, expectedCode: codes.OK},
+		{name: "api key", metadata: []string{"x-api-key", "internal-key"}, expectedCode: codes.OK},
+		{name: "no credentials", expectedCode: codes.Unauthenticated, expectedReason: "credentials_required"},
+		{name: "invalid api key", metadata: []string{"x-api-key", "wrong"}, expectedCode: codes.Unauthenticated, expectedReason: "invalid_api_key"},
+		{name: "invalid token", metadata: []string{"authorization", "Bearer invalid"}, expectedCode: codes.Unauthenticated, expectedReason: "invalid_token"},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			userRepo := &core.MockUserRepository{}
+			userRepo.On("GetUserByID", mock.Anything, user.ID.String()).Return(user, nil)
+			client := userv1.NewUserServiceClient(newTestClient(t, userRepo))
+			ctx := metadata.AppendToOutgoingContext(context.Background(), scenario.metadata...)
+
+			// when
+			result, err := client.GetUser(ctx, &userv1.GetUserRequest{Id: user.ID.String()})
+
+			// then
+			a.Equal(scenario.expectedCode, status.Code(err))
+			if scenario.expectedCode != codes.OK {
+				a.Equal(scenario.expectedReason, errorReason(err))
+				return
+			}
+			a.Equal("alice", result.Username)
+			a.Equal("fr", result.Profile.AsMap()["locale"])
+			a.Equal(int64(3), result.Version)
+		})
+	}
+}
+
+func TestUserServer_GetUserNotFound(t *testing.T) {
+	a := assert.New(t)
+	// given
+	userRepo := &core.MockUserRepository{}
+	userRepo.On("GetUserByID", mock.Anything, mock.Anything).Return(nil, nil)
+	client := userv1.NewUserServiceClient(newTestClient(t, userRepo))
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "internal-key", "accept-language", "fr")
+
+	// when
+	_, err := client.GetUser(ctx, &userv1.GetUserRequest{Id: uuid.NewString()})
+
+	// then
+	a.Equal(codes.NotFound, status.Code(err))
+	a.Equal("user_not_found", errorReason(err))
+	a.Equal("Utilisateur introuvable", status.Convert(err).Message())
+}
+
+func TestUserServer_CreateUserValidation(t *testing.T) {
+	a := assert.New(t)
+	// given
+	client := userv1.NewUserServiceClient(newTestClient(t, &core.MockUserRepository{}))
+
+	// when
+	_, err := client.CreateUser(context.Background(), &userv1.CreateUserRequest{Username: "al", Email: "alice@example.com", Password: "password"})
+
+	// then
+	a.Equal(codes.InvalidArgument, status.Code(err))
+	a.Equal("validation_failed", errorReason(err))
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, detail := range status.Convert(err).Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			violations = badRequest.FieldViolations
+		}
+	}
+	a.Len(violations, 1)
+	a.Equal("username", violations[0].Field)
+	a.Equal("min", violations[0].Reason)
+}
+
+func TestUserServer_ListUsersInvalidLimit(t *testing.T) {
+	a := assert.New(t)
+	// given
+	client := userv1.NewUserServiceClient(newTestClient(t, &core.MockUserRepository{}))
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "internal-key")
+
+	// when
+	_, err := client.ListUsers(ctx, &userv1.ListUsersRequest{Limit: 101})
+
+	// then
+	a.Equal(codes.InvalidArgument, status.Code(err))
+	a.Equal("invalid_limit", errorReason(err))
+}
+
+func TestUserServer_ListUsersRequiresAdmin(t *testing.T) {
+	userToken, _ := core.GenerateAuthToken(uuid.New(), nil, testJWTSecret)
+	adminToken, _ := core.GenerateAuthToken(uuid.New(), []string{core.RoleAdmin}, testJWTSecret)
+
+	testScenarios := []struct {
+		name           string
+		metadata       []string
+		expectedCode   codes.Code
+		expectedReason string
+	}{
+		{name: "admin", metadata: []string{"authorization", "Bearer " + adminToken}, expectedCode: codes.OK},
+		{name: "api key", metadata: []string{"x-api-key", "internal-key"}, expectedCode: codes.OK},
+		{name: "user", metadata: []string{"authorization", "Bearer " + userToken}, expectedCode: codes.PermissionDenied, expectedReason: "role_required"},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			userRepo := &core.MockUserRepository{}
+			userRepo.On("ListUsers", mock.Anything, mock.Anything).Return([]*core.User{}, nil)
+			client := userv1.NewUserServiceClient(newTestClient(t, userRepo))
+			ctx := metadata.AppendToOutgoingContext(context.Background(), scenario.metadata...)
+
+			// when
+			_, err := client.ListUsers(ctx, &userv1.ListUsersRequest{})
+
+			// then
+			a.Equal(scenario.expectedCode, status.Code(err))
+			a.Equal(scenario.expectedReason, errorReason(err))
+			if scenario.expectedCode != codes.OK {
+				userRepo.AssertNotCalled(t, "ListUsers", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestUserServer_HealthCheck(t *testing.T) {
+	a := assert.New(t)
+	// given
+	client := healthpb.NewHealthClient(newTestClient(t, &core.MockUserRepository{}))
+
+	// when
+	response, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: userv1.UserService_ServiceDesc.ServiceName})
+
+	// then
+	a.NoError(err)
+	a.Equal(healthpb.HealthCheckResponse_SERVING, response.Status)
+}
+
+func TestUserServer_HealthCheckAfterShutdown(t *testing.T) {
+	a := assert.New(t)
+	// given
+	conn, healthServer := newTestServer(t, &core.MockUserRepository{})
+	client := healthpb.NewHealthClient(conn)
+
+	// when
+	healthServer.Shutdown()
+	response, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: userv1.UserService_ServiceDesc.ServiceName})
+
+	// then
+	a.NoError(err)
+	a.Equal(healthpb.HealthCheckResponse_NOT_SERVING, response.Status)
+}
@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"go-rest-api/pkg/logger"
+	"go-rest-api/pkg/redact"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AccessLogConfig says what AccessLogMiddleware logs besides the request line.
+type AccessLogConfig struct {
+	// LogBodies adds the request and response bodies, for debugging. Only JSON
+	// bodies are logged, after Redactor has masked them.
+	LogBodies bool
+	// MaxBodySize bounds the bytes kept of each body. Larger bodies are left
+	// out of the log.
+	MaxBodySize int
+	// TrustForwardedFor takes the client IP from X-Forwarded-For, which only a
+	// proxy in front of the API can be trusted to set.
+	TrustForwardedFor bool
+	Redactor          *redact.Redactor
+}
+
+// NewRedactor returns the redactor for the bodies of the API: the fields
+// tagged in types.go, members named like credentials or emails anywhere, such
+// as in GraphQL variables, and paths of its own. GraphQL queries are masked
+// entirely, since their arguments may hold credentials.
+func NewRedactor(paths []string) (*redact.Redactor, error) {
+	redactor := redact.New()
+	for _, v := range []any{
+		CreateUserRequest{}, LoginUserRequest{}, LoginUserResponse{}, ImpersonationResponse{},
+		ListUsersResponse{}, ListUsersResponseV2{},
+		CreateInvitationRequest{}, AcceptInvitationRequest{}, ListInvitationsResponse{},
+	} {
+		redactor.Struct(v)
+	}
+	for _, key := range []string{"password", "token", "secret"} {
+		redactor.Key(key, redact.Secret)
+	}
+	redactor.Key("email", redact.Email)
+	if err := redactor.Path("$.query", redact.Secret); err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		if err := redactor.Path(path, redact.Secret); err != nil {
+			return nil, err
+		}
+	}
+	return redactor, nil
+}
+
+// accessLogEntry collects what the route handlers learn about a request for
+// its access log line: MetricsMiddleware the route pattern and AuthMiddleware
+// the principal.
+type accessLogEntry struct {
+	route   string
+	userID  string
+	actorID string
+}
+
+// requestAccessLogEntry returns the entry of r, nil when AccessLogMiddleware
+// did not run.
+func requestAccessLogEntry(r *http.Request) *accessLogEntry {
+	entry, _ := r.Context().Value("access_log").(*accessLogEntry)
+	return entry
+}
+
+// AccessLogMiddleware logs a line for every request once it is answered, with
+// its method, route pattern, status, latency, response bytes, client IP and
+// the user it was made for. It must be wrapped by RequestIDMiddleware, so
+// that the line carries the request id, and by CompressionMiddleware, so
+// that bodies and bytes are logged as the handlers wrote them.
+func AccessLogMiddleware(next http.Handler, config AccessLogConfig, logger logger.CustomLogger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry := &accessLogEntry{}
+		aw := &accessLogWriter{ResponseWriter: w, status: http.StatusOK}
+
+		var requestBody *cappedBuffer
+		if config.LogBodies {
+			if r.Body != nil && r.Body != http.NoBody {
+				requestBody = &cappedBuffer{max: config.MaxBodySize}
+				r.Body = teeReadCloser{Reader: io.TeeReader(r.Body, requestBody), Closer: r.Body}
+			}
+			aw.body = &cappedBuffer{max: config.MaxBodySize}
+		}
+		requestContentType := r.Header.Get("Content-Type")
+
+		next.ServeHTTP(aw, r.WithContext(context.WithValue(r.Context(), "access_log", entry)))
+
+		route := entry.route
+		if route == "" {
+			route = "unmatched"
+		}
+		keysAndValues := []interface{}{
+			"method", r.Method,
+			"route", route,
+			"path", r.URL.Path,
+			"status", aw.status,
+			"latency", time.Since(start),
+			"bytes", aw.bytes,
+			"client_ip", clientIP(r, config.TrustForwardedFor),
+		}
+		if entry.userID != "" {
+			keysAndValues = append(keysAndValues, "user_id", entry.userID)
+		}
+		if entry.actorID != "" {
+			keysAndValues = append(keysAndValues, "actor_id", entry.actorID)
+		}
+		if requestBody != nil && requestBody.Len() > 0 {
+			keysAndValues = append(keysAndValues, "request_body", config.loggedBody(requestContentType, requestBody))
+		}
+		if aw.body != nil && aw.body.Len() > 0 {
+			keysAndValues = append(keysAndValues, "response_body", config.loggedBody(aw.Header().Get("Content-Type"), aw.body))
+		}
+
+		logger := logger.FromContext(r.Context())
+		message := r.Method + " " + route
+		if slices.Contains(pollingRoutes, entry.route) {
+			logger.Debug(message, keysAndValues...)
+			return
+		}
+		logger.Info(message, keysAndValues...)
+	})
+}
+
+// loggedBody returns body as it goes into the log: redacted JSON, or a note
+// saying why it was left out.
+func (c AccessLogConfig) loggedBody(contentType string, body *cappedBuffer) string {
+	if !isJSONContentType(contentType) {
+		return "[omitted: not JSON]"
+	}
+	if body.truncated {
+		return "[omitted: larger than " + strconv.Itoa(c.MaxBodySize) + " bytes]"
+	}
+	if c.Redactor == nil {
+		return "[omitted: no redactor]"
+	}
+	redacted, err := c.Redactor.JSON(body.Bytes())
+	if err != nil {
+		return "[omitted: malformed JSON]"
+	}
+	return string(redacted)
+}
+
+// isJSONContentType reports whether contentType is JSON, including the
+// versioned and problem media types. Bodies without a type are read as JSON,
+// as decodeBody does.
+func isJSONContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == jsonContentType || strings.HasSuffix(mediaType, "+json")
+}
+
+// clientIP returns the address the request came from: the first address of
+// X-Forwarded-For when the proxy setting it is trusted, the peer address
+// otherwise.
+func clientIP(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			if ip := net.ParseIP(strings.TrimSpace(first)); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// accessLogWriter records the status and size of a response and, when bodies
+// are logged, its start.
+type accessLogWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	bytes       int
+	body        *cappedBuffer
+}
+
+func (aw *accessLogWriter) WriteHeader(status int) {
+	if !aw.wroteHeader && status >= 200 {
+		aw.status = status
+		aw.wroteHeader = true
+	}
+	aw.ResponseWriter.WriteHeader(status)
+}
+
+func (aw *accessLogWriter) Write(p []byte) (int, error) {
+	aw.wroteHeader = true
+	n, err := aw.ResponseWriter.Write(p)
+	aw.bytes += n
+	if aw.body != nil {
+		aw.body.Write(p[:n])
+	}
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (aw *accessLogWriter) Unwrap() http.ResponseWriter {
+	return aw.ResponseWriter
+}
+
+// cappedBuffer keeps the first max bytes written to it and remembers whether
+// there were more.
+type cappedBuffer struct {
+	bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); len(p) > room {
+		b.truncated = true
+		b.Buffer.Write(p[:max(room, 0)])
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// teeReadCloser reads through a TeeReader and closes the original body.
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
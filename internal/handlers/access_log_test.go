@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go-rest-api/internal/core"
+	"go-rest-api/pkg/logger"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAccessLogMiddleware(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockLogger := &logger.MockLogger{}
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+	userID := uuid.New()
+	token, err := core.GenerateAuthToken(userID, nil, testJwtSecret)
+	a.NoError(err)
+
+	handle := MetricsMiddleware(AuthMiddleware(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, "created")
+	}, testJwtSecret, mockLogger, mockLogger), "/users/:id", http.MethodPut)
+	handler := AccessLogMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handle(w, r, nil)
+	}), AccessLogConfig{}, mockLogger)
+	req := httptest.NewRequest(http.MethodPut, "/users/42", strings.NewReader(`{"password":"secret"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.RemoteAddr = "203.0.113.7:52144"
+
+	// when
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// then
+	mockLogger.AssertCalled(t, "Info", "PUT /users/:id", mock.Anything)
+	fields := loggedFields(mockLogger)
+	a.Equal("/users/:id", fields["route"])
+	a.Equal("/users/42", fields["path"])
+	a.Equal(http.StatusCreated, fields["status"])
+	a.Equal(len("created"), fields["bytes"])
+	a.Equal("203.0.113.7", fields["client_ip"])
+	a.Equal(userID.String(), fields["user_id"])
+	a.NotContains(fields, "actor_id")
+	a.NotContains(fields, "request_body")
+	a.NotContains(fields, "response_body")
+}
+
+func TestAccessLogMiddleware_Bodies(t *testing.T) {
+	redactor, err := NewRedactor([]string{"$.data[*].profile.phone"})
+	assert.NoError(t, err)
+
+	testScenarios := []struct {
+		name                 string
+		contentType          string
+		requestBody          string
+		responseContentType  string
+		responseBody         string
+		maxBodySize          int
+		expectedRequestBody  string
+		expectedResponseBody string
+	}{
+		{
+			name:                 "login",
+			contentType:          "application/json",
+			requestBody:          `{"email":"john@gmail.com","password":"secret"}`,
+			responseContentType:  "application/json",
+			responseBody:         `{"token":"eyJhbGciOiJIUzI1NiJ9"}`,
+			maxBodySize:          1024,
+			expectedRequestBody:  `{"email":"j***@gmail.com","password":"[REDACTED]"}`,
+			expectedResponseBody: `{"token":"[REDACTED]"}`,
+		},
+		{
+			name:                 "users",
+			responseContentType:  "application/vnd.gorestapi.v2+json",
+			responseBody:         `{"data":[{"username":"john","email":"john@gmail.com","profile":{"phone":"+33612345678"}}]}`,
+			maxBodySize:          1024,
+			expectedResponseBody: `{"data":[{"username":"john","email":"j***@gmail.com","profile":{"phone":"[REDACTED]"}}]}`,
+		},
+		{
+			name:                 "graphql",
+			contentType:          "application/json",
+			requestBody:          `{"query":"mutation { login(email: \"john@gmail.com\", password: \"secret\") { token } }","variables":{"password":"secret"}}`,
+			responseContentType:  "application/json",
+			responseBody:         `{"data":{"login":{"token":"eyJhbGciOiJIUzI1NiJ9"}}}`,
+			maxBodySize:          1024,
+			expectedRequestBody:  `{"query":"[REDACTED]","variables":{"password":"[REDACTED]"}}`,
+			expectedResponseBody: `{"data":{"login":{"token":"[REDACTED]"}}}`,
+		},
+		{
+			name:                 "not json",
+			contentType:          "text/csv",
+			requestBody:          "username,email,password\njohn,john@gmail.com,secret\n",
+			responseContentType:  "application/x-protobuf",
+			responseBody:         "\x0a\x04john",
+			maxBodySize:          1024,
+			expectedRequestBody:  "[omitted: not JSON]",
+			expectedResponseBody: "[omitted: not JSON]",
+		},
+		{
+			name:                 "too large",
+			contentType:          "application/json",
+			requestBody:          `{"password":"` + strings.Repeat("x", 64) + `"}`,
+			responseContentType:  "application/json",
+			responseBody:         `{}`,
+			maxBodySize:          32,
+			expectedRequestBody:  "[omitted: larger than 32 bytes]",
+			expectedResponseBody: `{}`,
+		},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			mockLogger := &logger.MockLogger{}
+			mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+			handler := AccessLogMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var decoded any
+				if r.Header.Get("Content-Type") == "application/json" {
+					a.NoError(json.NewDecoder(r.Body).Decode(&decoded))
+				} else {
+					io.Copy(io.Discard, r.Body)
+				}
+				w.Header().Set("Content-Type", scenario.responseContentType)
+				io.WriteString(w, scenario.responseBody)
+			}), AccessLogConfig{LogBodies: true, MaxBodySize: scenario.maxBodySize, Redactor: redactor}, mockLogger)
+			req := httptest.NewRequest(http.MethodPost, "/users/login", strings.NewReader(scenario.requestBody))
+			if scenario.contentType != "" {
+				req.Header.Set("Content-Type", scenario.contentType)
+			}
+
+			// when
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			// then
+			fields := loggedFields(mockLogger)
+			if scenario.expectedRequestBody == "" {
+				a.NotContains(fields, "request_body")
+			} else if strings.HasPrefix(scenario.expectedRequestBody, "[") {
+				a.Equal(scenario.expectedRequestBody, fields["request_body"])
+			} else {
+				a.JSONEq(scenario.expectedRequestBody, fields["request_body"].(string))
+			}
+			if strings.HasPrefix(scenario.expectedResponseBody, "[") {
+				a.Equal(scenario.expectedResponseBody, fields["response_body"])
+			} else {
+				a.JSONEq(scenario.expectedResponseBody, fields["response_body"].(string))
+			}
+		})
+	}
+}
+
+func TestAccessLogMiddleware_InsideCompression(t *testing.T) {
+	a := assert.New(t)
+	// given
+	responseBody := `{"users":["` + strings.Repeat("x", 2*compressionMinSize) + `"]}`
+	redactor, err := NewRedactor(nil)
+	a.NoError(err)
+	mockLogger := &logger.MockLogger{}
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+	handler := CompressionMiddleware(AccessLogMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, responseBody)
+	}), AccessLogConfig{LogBodies: true, MaxBodySize: 4096, Redactor: redactor}, mockLogger))
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	res := httptest.NewRecorder()
+
+	// when
+	handler.ServeHTTP(res, req)
+
+	// then
+	a.Equal("gzip", res.Header().Get("Content-Encoding"))
+	fields := loggedFields(mockLogger)
+	a.JSONEq(responseBody, fields["response_body"].(string))
+	a.Equal(len(responseBody), fields["bytes"])
+}
+
+func TestAccessLogMiddleware_QuietRoute(t *testing.T) {
+	// given
+	mockLogger := &logger.MockLogger{}
+	mockLogger.On("Debug", mock.Anything, mock.Anything).Return()
+	handle := MetricsMiddleware(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		io.WriteString(w, `{"status":"available"}`)
+	}, "/health", http.MethodGet)
+	handler := AccessLogMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handle(w, r, nil)
+	}), AccessLogConfig{}, mockLogger)
+
+	// when
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	// then
+	mockLogger.AssertCalled(t, "Debug", "GET /health", mock.Anything)
+	mockLogger.AssertNotCalled(t, "Info", mock.Anything, mock.Anything)
+}
+
+func TestClientIP(t *testing.T) {
+	testScenarios := []struct {
+		name              string
+		forwardedFor      string
+		trustForwardedFor bool
+		expectedIP        string
+	}{
+		{name: "peer", expectedIP: "192.0.2.1"},
+		{name: "untrusted forwarded for", forwardedFor: "203.0.113.7", expectedIP: "192.0.2.1"},
+		{name: "trusted forwarded for", forwardedFor: "203.0.113.7, 10.0.0.1", trustForwardedFor: true, expectedIP: "203.0.113.7"},
+		{name: "malformed forwarded for", forwardedFor: "unknown", trustForwardedFor: true, expectedIP: "192.0.2.1"},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			// given
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			if scenario.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", scenario.forwardedFor)
+			}
+
+			// when
+			ip := clientIP(req, scenario.trustForwardedFor)
+
+			// then
+			assert.Equal(t, scenario.expectedIP, ip)
+		})
+	}
+}
+
+// loggedFields returns the key-value pairs of the last line logged to
+// mockLogger.
+func loggedFields(mockLogger *logger.MockLogger) map[string]any {
+	keysAndValues := mockLogger.Calls[len(mockLogger.Calls)-1].Arguments.Get(1).([]interface{})
+	fields := map[string]any{}
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		fields[keysAndValues[i].(string)] = keysAndValues[i+1]
+	}
+	return fields
+}
@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressionMinSize is the smallest body worth compressing. Smaller bodies
+// are sent as they are, unless the handler flushes before reaching it.
+const compressionMinSize = 1024
+
+// compressedContentTypes are not compressed again: they are compressed
+// formats already, or media that do not shrink.
+var compressedContentTypes = []string{
+	"image/", "video/", "audio/", "font/woff",
+	"application/zip", "application/gzip", "application/zstd", "application/x-7z-compressed", "application/pdf",
+}
+
+var gzipWriters = sync.Pool{New: func() any {
+	return gzip.NewWriter(io.Discard)
+}}
+
+var zstdWriters = sync.Pool{New: func() any {
+	encoder, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderConcurrency(1))
+	return encoder
+}}
+
+// CompressionMiddleware compresses response bodies with zstd or gzip, as
+// negotiated from Accept-Encoding. Bodies are buffered up to
+// compressionMinSize to decide; smaller ones, already compressed content
+// types and responses that set their own Content-Encoding are left alone.
+// Flushing starts compression early, so streamed responses such as exports
+// reach the client as they are written.
+//
+// A compressed response is a different representation, so its strong ETag
+// gets the coding appended, e.g. "3-v1-json-gzip". The coding is removed
+// again from the tags of If-Match and If-None-Match before the handler
+// compares them with its own.
+func CompressionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		ifNoneMatch := r.Header.Get("If-None-Match")
+		if r.Header.Get("If-Match") != "" || ifNoneMatch != "" {
+			r = r.Clone(r.Context())
+			for _, name := range []string{"If-Match", "If-None-Match"} {
+				if value := r.Header.Get(name); value != "" {
+					r.Header.Set(name, stripETagCodings(value))
+				}
+			}
+		}
+
+		encoding := negotiateContentEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding, ifNoneMatch: ifNoneMatch, status: http.StatusOK}
+		next.ServeHTTP(cw, r)
+		cw.Close()
+	})
+}
+
+// stripETagCodings removes the coding that compression appended from each
+// strong entity tag of an If-Match or If-None-Match list.
+func stripETagCodings(value string) string {
+	tags := strings.Split(value, ",")
+	for i, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		for _, coding := range []string{"zstd", "gzip"} {
+			if stripped, ok := strings.CutSuffix(tag, "-"+coding+`"`); ok && !strings.HasPrefix(tag, "W/") {
+				tag = stripped + `"`
+				break
+			}
+		}
+		tags[i] = tag
+	}
+	return strings.Join(tags, ", ")
+}
+
+// etagWithCoding returns etag with coding appended, or etag unchanged when
+// it is weak or missing.
+func etagWithCoding(etag, coding string) string {
+	if !strings.HasPrefix(etag, `"`) {
+		return etag
+	}
+	return strings.TrimSuffix(etag, `"`) + "-" + coding + `"`
+}
+
+// negotiateContentEncoding returns "zstd", "gzip" or "" for no compression.
+// zstd wins when both are equally acceptable.
+func negotiateContentEncoding(acceptEncoding string) string {
+	qualities := map[string]float64{}
+	for _, accepted := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(accepted), ";")
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		qualities[strings.ToLower(strings.TrimSpace(coding))] = quality
+	}
+	if wildcard, ok := qualities["*"]; ok {
+		for _, coding := range []string{"zstd", "gzip"} {
+			if _, ok := qualities[coding]; !ok {
+				qualities[coding] = wildcard
+			}
+		}
+	}
+
+	best, bestQuality := "", 0.0
+	for _, coding := range []string{"zstd", "gzip"} {
+		if quality := qualities[coding]; quality > bestQuality {
+			best, bestQuality = coding, quality
+		}
+	}
+	return best
+}
+
+// compressWriter buffers the start of a response until it knows whether to
+// compress it, then either streams it through an encoder or passes it on.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	// ifNoneMatch is the request's If-None-Match as the client sent it.
+	ifNoneMatch string
+	status      int
+	// wroteHeader is set once the handler has sent its status, decided once
+	// the header has gone out to the client.
+	wroteHeader bool
+	decided     bool
+	buf         bytes.Buffer
+	encoder     io.WriteCloser
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader || cw.decided {
+		return
+	}
+	// ... informational responses go out as they are
+	if status >= 100 && status < 200 {
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	cw.status = status
+	cw.wroteHeader = true
+	if !bodyAllowed(status) {
+		cw.decide(false)
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	cw.wroteHeader = true
+	if !cw.decided {
+		cw.buf.Write(p)
+		if cw.buf.Len() < compressionMinSize {
+			return len(p), nil
+		}
+		if err := cw.decide(cw.compressible()); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if cw.encoder != nil {
+		return cw.encoder.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// Flush sends what was written so far. A response flushed before reaching
+// compressionMinSize is treated as a stream and compressed anyway.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if err := cw.decide(cw.compressible()); err != nil {
+			return
+		}
+	}
+	if flusher, ok := cw.encoder.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Close sends a response that stayed below compressionMinSize and finishes
+// the compressed stream.
+func (cw *compressWriter) Close() error {
+	if !cw.decided {
+		if err := cw.decide(false); err != nil {
+			return err
+		}
+	}
+	if cw.encoder == nil {
+		return nil
+	}
+	err := cw.encoder.Close()
+	switch encoder := cw.encoder.(type) {
+	case *gzip.Writer:
+		encoder.Reset(io.Discard)
+		gzipWriters.Put(encoder)
+	case *zstd.Encoder:
+		encoder.Reset(io.Discard)
+		zstdWriters.Put(encoder)
+	}
+	cw.encoder = nil
+	return err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// compressible reports whether the response may be compressed.
+func (cw *compressWriter) compressible() bool {
+	header := cw.Header()
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" || cw.status == http.StatusPartialContent || !bodyAllowed(cw.status) {
+		return false
+	}
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(cw.buf.Bytes())
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for _, compressed := range compressedContentTypes {
+		if strings.HasPrefix(mediaType, compressed) {
+			return false
+		}
+	}
+	return true
+}
+
+// decide sends the header, with compression or not, followed by the buffered
+// start of the body.
+func (cw *compressWriter) decide(compress bool) error {
+	cw.decided = true
+	if compress {
+		header := cw.Header()
+		if header.Get("Content-Type") == "" {
+			header.Set("Content-Type", http.DetectContentType(cw.buf.Bytes()))
+		}
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", cw.encoding)
+		if etag := header.Get("ETag"); etag != "" {
+			header.Set("ETag", etagWithCoding(etag, cw.encoding))
+		}
+		switch cw.encoding {
+		case "zstd":
+			encoder := zstdWriters.Get().(*zstd.Encoder)
+			encoder.Reset(cw.ResponseWriter)
+			cw.encoder = encoder
+		default:
+			encoder := gzipWriters.Get().(*gzip.Writer)
+			encoder.Reset(cw.ResponseWriter)
+			cw.encoder = encoder
+		}
+	}
+	if cw.status == http.StatusNotModified {
+		cw.notModifiedETag()
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	if cw.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if cw.encoder != nil {
+		_, err = cw.encoder.Write(cw.buf.Bytes())
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buf.Bytes())
+	}
+	cw.buf.Reset()
+	return err
+}
+
+// notModifiedETag gives a 304 the ETag of the compressed representation when
+// that is the one the client asked about, so that it updates that entry.
+func (cw *compressWriter) notModifiedETag() {
+	header := cw.Header()
+	etag := etagWithCoding(header.Get("ETag"), cw.encoding)
+	if etag == header.Get("ETag") {
+		return
+	}
+	for _, candidate := range strings.Split(cw.ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			header.Set("ETag", etag)
+			return
+		}
+	}
+}
+
+// bodyAllowed reports whether a response with status may have a body.
+func bodyAllowed(status int) bool {
+	return status != http.StatusNoContent && status != http.StatusNotModified
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"go-rest-api/internal/core"
+	"go-rest-api/internal/metrics"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/klauspost/compress/zstd"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateContentEncoding(t *testing.T) {
+	testScenarios := []struct {
+		acceptEncoding   string
+		expectedEncoding string
+	}{
+		{acceptEncoding: "", expectedEncoding: ""},
+		{acceptEncoding: "gzip", expectedEncoding: "gzip"},
+		{acceptEncoding: "gzip, deflate, br, zstd", expectedEncoding: "zstd"},
+		{acceptEncoding: "zstd;q=0.5, gzip", expectedEncoding: "gzip"},
+		{acceptEncoding: "*", expectedEncoding: "zstd"},
+		{acceptEncoding: "*, zstd;q=0", expectedEncoding: "gzip"},
+		{acceptEncoding: "identity, br", expectedEncoding: ""},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.acceptEncoding, func(t *testing.T) {
+			assert.Equal(t, scenario.expectedEncoding, negotiateContentEncoding(scenario.acceptEncoding))
+		})
+	}
+}
+
+func TestCompressionMiddleware(t *testing.T) {
+	largeJSON := `{"users":[` + strings.Repeat(`{"username":"john"},`, 100) + `{}]}`
+
+	testScenarios := []struct {
+		name             string
+		acceptEncoding   string
+		contentType      string
+		body             string
+		expectedEncoding string
+	}{
+		{name: "gzip", acceptEncoding: "gzip", contentType: "application/json", body: largeJSON, expectedEncoding: "gzip"},
+		{name: "zstd", acceptEncoding: "gzip, zstd", contentType: "application/json", body: largeJSON, expectedEncoding: "zstd"},
+		{name: "not accepted", acceptEncoding: "", contentType: "application/json", body: largeJSON},
+		{name: "small body", acceptEncoding: "gzip", contentType: "application/json", body: `{"status":"available"}`},
+		{name: "compressed content type", acceptEncoding: "gzip", contentType: "image/png", body: strings.Repeat("x", 2048)},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			handler := CompressionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", scenario.contentType)
+				w.WriteHeader(http.StatusOK)
+				io.WriteString(w, scenario.body)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			req.Header.Set("Accept-Encoding", scenario.acceptEncoding)
+			res := httptest.NewRecorder()
+
+			// when
+			handler.ServeHTTP(res, req)
+
+			// then
+			a.Equal(http.StatusOK, res.Code)
+			a.Equal(scenario.expectedEncoding, res.Header().Get("Content-Encoding"))
+			a.Equal("Accept-Encoding", res.Header().Get("Vary"))
+			a.Equal(scenario.contentType, res.Header().Get("Content-Type"))
+			a.Equal(scenario.body, decompress(t, scenario.expectedEncoding, res.Body))
+		})
+	}
+}
+
+func TestCompressionMiddleware_ETag(t *testing.T) {
+	largeJSON := `{"users":[` + strings.Repeat(`{"username":"john"},`, 100) + `{}]}`
+
+	testScenarios := []struct {
+		name           string
+		acceptEncoding string
+		ifNoneMatch    string
+		etag           string
+		body           string
+		expectedStatus int
+		expectedETag   string
+	}{
+		{name: "compressed", acceptEncoding: "gzip", etag: `"3-v1-json"`, body: largeJSON, expectedStatus: http.StatusOK, expectedETag: `"3-v1-json-gzip"`},
+		{name: "zstd", acceptEncoding: "zstd", etag: `"3-v1-json"`, body: largeJSON, expectedStatus: http.StatusOK, expectedETag: `"3-v1-json-zstd"`},
+		{name: "weak", acceptEncoding: "gzip", etag: `W/"3-v1-json"`, body: largeJSON, expectedStatus: http.StatusOK, expectedETag: `W/"3-v1-json"`},
+		{name: "small body", acceptEncoding: "gzip", etag: `"3-v1-json"`, body: `{}`, expectedStatus: http.StatusOK, expectedETag: `"3-v1-json"`},
+		{name: "not modified compressed", acceptEncoding: "gzip", ifNoneMatch: `"3-v1-json-gzip"`, etag: `"3-v1-json"`, body: largeJSON, expectedStatus: http.StatusNotModified, expectedETag: `"3-v1-json-gzip"`},
+		{name: "not modified identity", acceptEncoding: "gzip", ifNoneMatch: `"3-v1-json"`, etag: `"3-v1-json"`, body: largeJSON, expectedStatus: http.StatusNotModified, expectedETag: `"3-v1-json"`},
+		{name: "modified", acceptEncoding: "gzip", ifNoneMatch: `"2-v1-json-gzip"`, etag: `"3-v1-json"`, body: largeJSON, expectedStatus: http.StatusOK, expectedETag: `"3-v1-json-gzip"`},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			handler := CompressionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if writeNotModified(w, r, scenario.etag) {
+					return
+				}
+				io.WriteString(w, scenario.body)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+			req.Header.Set("Accept-Encoding", scenario.acceptEncoding)
+			if scenario.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", scenario.ifNoneMatch)
+			}
+			res := httptest.NewRecorder()
+
+			// when
+			handler.ServeHTTP(res, req)
+
+			// then
+			a.Equal(scenario.expectedStatus, res.Code)
+			a.Equal(scenario.expectedETag, res.Header().Get("ETag"))
+		})
+	}
+}
+
+func TestCompressionMiddleware_IfMatchWithCoding(t *testing.T) {
+	a := assert.New(t)
+	// given
+	var version int64
+	var versionErr error
+	handler := CompressionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version, versionErr = ifMatchVersion(r, jsonEncoder{jsonContentType}, &core.User{Version: 3})
+	}))
+	req := httptest.NewRequest(http.MethodPatch, "/users/1/profile", nil)
+	req.Header.Set("If-Match", `"2-v1-json", "3-v1-json-gzip"`)
+	res := httptest.NewRecorder()
+
+	// when
+	handler.ServeHTTP(res, req)
+
+	// then
+	a.NoError(versionErr)
+	a.Equal(int64(3), version)
+}
+
+func TestCompressionMiddleware_KeepsContentEncoding(t *testing.T) {
+	a := assert.New(t)
+	// given
+	handler := CompressionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "br")
+		w.Write(bytes.Repeat([]byte{0}, 2048))
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	res := httptest.NewRecorder()
+
+	// when
+	handler.ServeHTTP(res, req)
+
+	// then
+	a.Equal("br", res.Header().Get("Content-Encoding"))
+	a.Equal(2048, res.Body.Len())
+}
+
+func TestCompressionMiddleware_StreamsThroughMetricsMiddleware(t *testing.T) {
+	a := assert.New(t)
+	// given
+	flushed := make(chan string, 1)
+	handle := MetricsMiddleware(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"id":1}`+"\n")
+		a.NoError(http.NewResponseController(w).Flush())
+		flushed <- w.Header().Get("Content-Encoding")
+		io.WriteString(w, `{"id":2}`+"\n")
+	}, "/compression-test", "GET")
+	handler := CompressionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handle(w, r, nil)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/compression-test", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	res := httptest.NewRecorder()
+
+	// when
+	handler.ServeHTTP(res, req)
+
+	// then
+	a.Equal("gzip", <-flushed)
+	a.True(res.Flushed)
+	a.Equal(http.StatusCreated, res.Code)
+	a.Equal(`{"id":1}`+"\n"+`{"id":2}`+"\n", decompress(t, "gzip", res.Body))
+	a.Equal(1.0, testutil.ToFloat64(metrics.RequestCount.WithLabelValues("/compression-test", "GET", "201", "2xx")))
+}
+
+func decompress(t *testing.T, encoding string, body io.Reader) string {
+	var reader io.Reader = body
+	switch encoding {
+	case "gzip":
+		gzipReader, err := gzip.NewReader(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = gzipReader
+	case "zstd":
+		zstdReader, err := zstd.NewReader(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer zstdReader.Close()
+		reader = zstdReader
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
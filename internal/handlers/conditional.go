@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"go-rest-api/internal/core"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// userETag is the strong entity tag of the representation of a user sent to
+// r with encoder, e.g. "3-v2-msgpack". It is derived from the user's version,
+// the API version and the encoding, since each of them changes the bytes of
+// the response and caches must not serve one in place of another.
+func userETag(r *http.Request, encoder responseEncoder, user *core.User) string {
+	format := "json"
+	switch encoder.ContentType() {
+	case msgpackContentType:
+		format = "msgpack"
+	case protobufContentType:
+		format = "protobuf"
+	}
+	return `"` + strconv.FormatInt(user.Version, 10) + "-" + requestAPIVersion(r) + "-" + format + `"`
+}
+
+// writeNotModified sets the ETag header and, when the request's If-None-Match
+// lists it, answers 304 Not Modified. It reports whether it did so.
+func writeNotModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		// ... If-None-Match uses the weak comparison
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchVersion returns the version of user the client based its update on.
+// Updates without an If-Match header are rejected with 428 so that clients
+// cannot overwrite each other's changes by accident. Otherwise one of the
+// listed tags must be identical to the current tag of user for the
+// representation sent to r with encoder, weak tags never are, and the update
+// is made against that version; "*" yields core.AnyVersion. When none
+// matches it returns core.ErrUserVersionMismatch.
+func ifMatchVersion(r *http.Request, encoder responseEncoder, user *core.User) (int64, error) {
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" {
+		return 0, &requestError{status: http.StatusPreconditionRequired, code: "if_match_required"}
+	}
+
+	etag := userETag(r, encoder, user)
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		// ... If-Match uses the strong comparison
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return core.AnyVersion, nil
+		}
+		if candidate == etag {
+			return user.Version, nil
+		}
+	}
+	return 0, core.ErrUserVersionMismatch
+}
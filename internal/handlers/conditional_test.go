@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"go-rest-api/internal/core"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIfMatchVersion(t *testing.T) {
+	testScenarios := []struct {
+		name            string
+		ifMatch         string
+		expectedVersion int64
+		expectedStatus  int
+	}{
+		{name: "current", ifMatch: `"7-v1-json"`, expectedVersion: 7},
+		{name: "current in list", ifMatch: `"6-v1-json", "7-v1-json"`, expectedVersion: 7},
+		{name: "any", ifMatch: "*", expectedVersion: core.AnyVersion},
+		{name: "missing", ifMatch: "", expectedStatus: http.StatusPreconditionRequired},
+		{name: "stale", ifMatch: `"6-v1-json"`, expectedStatus: http.StatusPreconditionFailed},
+		{name: "stale first in list", ifMatch: `"6-v1-json", "9-v1-json"`, expectedStatus: http.StatusPreconditionFailed},
+		{name: "other representation", ifMatch: `"7-v2-msgpack"`, expectedStatus: http.StatusPreconditionFailed},
+		{name: "version prefix only", ifMatch: `"7-anything"`, expectedStatus: http.StatusPreconditionFailed},
+		{name: "bare version", ifMatch: `"7"`, expectedStatus: http.StatusPreconditionFailed},
+		{name: "weak", ifMatch: `W/"7-v1-json"`, expectedStatus: http.StatusPreconditionFailed},
+		{name: "unquoted", ifMatch: "7-v1-json", expectedStatus: http.StatusPreconditionFailed},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			req := httptest.NewRequest(http.MethodPatch, "/users/1/profile", nil)
+			if scenario.ifMatch != "" {
+				req.Header.Set("If-Match", scenario.ifMatch)
+			}
+
+			// when
+			version, err := ifMatchVersion(req, jsonEncoder{jsonContentType}, &core.User{Version: 7})
+
+			// then
+			if scenario.expectedStatus == 0 {
+				a.NoError(err)
+				a.Equal(scenario.expectedVersion, version)
+				return
+			}
+			res := httptest.NewRecorder()
+			writeError(res, req, nil, err)
+			a.Equal(scenario.expectedStatus, res.Code)
+		})
+	}
+}
+
+func TestWriteNotModified(t *testing.T) {
+	testScenarios := []struct {
+		name                string
+		ifNoneMatch         string
+		expectedNotModified bool
+	}{
+		{name: "no header", ifNoneMatch: "", expectedNotModified: false},
+		{name: "match", ifNoneMatch: `"3-v1-json"`, expectedNotModified: true},
+		{name: "weak match in list", ifNoneMatch: `"1-v1-json", W/"3-v1-json"`, expectedNotModified: true},
+		{name: "any", ifNoneMatch: "*", expectedNotModified: true},
+		{name: "stale", ifNoneMatch: `"2-v1-json"`, expectedNotModified: false},
+		{name: "other representation", ifNoneMatch: `"3-v2-json"`, expectedNotModified: false},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+			if scenario.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", scenario.ifNoneMatch)
+			}
+			res := httptest.NewRecorder()
+
+			// when
+			notModified := writeNotModified(res, req, userETag(req, jsonEncoder{jsonContentType}, &core.User{Version: 3}))
+
+			// then
+			a.Equal(scenario.expectedNotModified, notModified)
+			a.Equal(`"3-v1-json"`, res.Header().Get("ETag"))
+			if notModified {
+				a.Equal(http.StatusNotModified, res.Code)
+			}
+		})
+	}
+}
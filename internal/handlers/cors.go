@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// corsExposedHeaders are the response headers browser clients may read
+// besides the CORS-safelisted ones.
+var corsExposedHeaders = []string{
+	"ETag", "Location", "Retry-After", "Content-Language", "Deprecation", "Sunset",
+	idempotentReplayedHeader, requestIDHeader,
+}
+
+// CORSConfig lists what cross-origin browser clients may do. An origin of "*"
+// allows any origin.
+type CORSConfig struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration
+}
+
+// allowedOrigin returns the Access-Control-Allow-Origin value for origin, or
+// "" when the origin is not allowed. Credentialed responses must name the
+// origin rather than "*".
+func (c CORSConfig) allowedOrigin(origin string) string {
+	if origin == "" {
+		return ""
+	}
+	for _, allowed := range c.AllowedOrigins {
+		if strings.EqualFold(allowed, origin) {
+			return origin
+		}
+		if allowed == "*" {
+			if c.AllowCredentials {
+				return origin
+			}
+			return "*"
+		}
+	}
+	return ""
+}
+
+// CORSMiddleware adds the CORS headers to responses for allowed origins.
+// Preflight requests are answered by CORSPreflight, which is installed as the
+// router's GlobalOPTIONS handler.
+func CORSMiddleware(next http.Handler, config CORSConfig) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		if allowOrigin := config.allowedOrigin(r.Header.Get("Origin")); allowOrigin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+			if config.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CORSPreflight answers OPTIONS requests for routes that exist. httprouter
+// calls it with the route's methods in the Allow header. Preflights from
+// allowed origins for allowed methods and headers get the CORS headers;
+// anything else gets a plain 204 with Allow, which browsers treat as a
+// refusal.
+func CORSPreflight(config CORSConfig) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+
+		method := r.Header.Get("Access-Control-Request-Method")
+		allowOrigin := config.allowedOrigin(r.Header.Get("Origin"))
+		routeMethods := strings.Split(header.Get("Allow"), ", ")
+		if method == "" || allowOrigin == "" || !slices.Contains(routeMethods, method) || !containsFold(config.AllowedMethods, method) {
+			clearCORSHeaders(header)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		var requested []string
+		for _, name := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+			if name = strings.TrimSpace(name); name == "" {
+				continue
+			}
+			if !containsFold(config.AllowedHeaders, name) {
+				clearCORSHeaders(header)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			requested = append(requested, name)
+		}
+
+		// ... preflight responses expose nothing, they have no body to read
+		header.Del("Access-Control-Expose-Headers")
+		header.Set("Access-Control-Allow-Origin", allowOrigin)
+		if config.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+		header.Set("Access-Control-Allow-Methods", method)
+		if len(requested) > 0 {
+			header.Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+		}
+		if config.MaxAge > 0 {
+			header.Set("Access-Control-Max-Age", strconv.Itoa(int(config.MaxAge.Seconds())))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// clearCORSHeaders removes the headers CORSMiddleware added, for preflights
+// that are refused.
+func clearCORSHeaders(header http.Header) {
+	header.Del("Access-Control-Allow-Origin")
+	header.Del("Access-Control-Allow-Credentials")
+	header.Del("Access-Control-Expose-Headers")
+}
+
+func containsFold(values []string, value string) bool {
+	return slices.ContainsFunc(values, func(v string) bool {
+		return strings.EqualFold(v, value)
+	})
+}
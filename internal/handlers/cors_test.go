@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORSPreflight(t *testing.T) {
+	config := CORSConfig{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedMethods: []string{"GET", "POST", "PATCH"},
+		AllowedHeaders: []string{"Authorization", "Content-Type"},
+		MaxAge:         10 * time.Minute,
+	}
+
+	testScenarios := []struct {
+		name            string
+		origin          string
+		method          string
+		headers         string
+		expectedOrigin  string
+		expectedMethods string
+		expectedHeaders string
+	}{
+		{name: "allowed", origin: "https://app.example.com", method: "PATCH", headers: "authorization, content-type", expectedOrigin: "https://app.example.com", expectedMethods: "PATCH", expectedHeaders: "authorization, content-type"},
+		{name: "unknown origin", origin: "https://evil.example.com", method: "PATCH"},
+		{name: "method not configured", origin: "https://app.example.com", method: "DELETE"},
+		{name: "method not routed", origin: "https://app.example.com", method: "POST"},
+		{name: "header not allowed", origin: "https://app.example.com", method: "GET", headers: "X-Debug"},
+		{name: "not a preflight", origin: "https://app.example.com"},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			router := httprouter.New()
+			router.GET("/users/:id", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {})
+			router.PATCH("/users/:id", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {})
+			router.DELETE("/users/:id", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {})
+			router.GlobalOPTIONS = CORSPreflight(config)
+			handler := CORSMiddleware(router, config)
+
+			req := httptest.NewRequest(http.MethodOptions, "/users/42", nil)
+			req.Header.Set("Origin", scenario.origin)
+			if scenario.method != "" {
+				req.Header.Set("Access-Control-Request-Method", scenario.method)
+			}
+			if scenario.headers != "" {
+				req.Header.Set("Access-Control-Request-Headers", scenario.headers)
+			}
+			res := httptest.NewRecorder()
+
+			// when
+			handler.ServeHTTP(res, req)
+
+			// then
+			a.Equal(http.StatusNoContent, res.Code)
+			a.Contains(res.Header().Get("Allow"), "PATCH")
+			a.Equal(scenario.expectedOrigin, res.Header().Get("Access-Control-Allow-Origin"))
+			a.Equal(scenario.expectedMethods, res.Header().Get("Access-Control-Allow-Methods"))
+			a.Equal(scenario.expectedHeaders, res.Header().Get("Access-Control-Allow-Headers"))
+			a.Empty(res.Header().Get("Access-Control-Expose-Headers"))
+			if scenario.expectedOrigin != "" {
+				a.Equal("600", res.Header().Get("Access-Control-Max-Age"))
+			}
+		})
+	}
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	testScenarios := []struct {
+		name                string
+		config              CORSConfig
+		origin              string
+		expectedOrigin      string
+		expectedCredentials string
+	}{
+		{name: "listed origin", config: CORSConfig{AllowedOrigins: []string{"https://app.example.com"}}, origin: "https://app.example.com", expectedOrigin: "https://app.example.com"},
+		{name: "wildcard", config: CORSConfig{AllowedOrigins: []string{"*"}}, origin: "https://app.example.com", expectedOrigin: "*"},
+		{name: "wildcard with credentials", config: CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}, origin: "https://app.example.com", expectedOrigin: "https://app.example.com", expectedCredentials: "true"},
+		{name: "unknown origin", config: CORSConfig{AllowedOrigins: []string{"https://app.example.com"}}, origin: "https://evil.example.com"},
+		{name: "cors disabled", origin: "https://app.example.com"},
+		{name: "same origin", config: CORSConfig{AllowedOrigins: []string{"*"}}},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			handler := CORSMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}), scenario.config)
+			req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+			if scenario.origin != "" {
+				req.Header.Set("Origin", scenario.origin)
+			}
+			res := httptest.NewRecorder()
+
+			// when
+			handler.ServeHTTP(res, req)
+
+			// then
+			a.Equal(http.StatusOK, res.Code)
+			a.Equal("Origin", res.Header().Get("Vary"))
+			a.Equal(scenario.expectedOrigin, res.Header().Get("Access-Control-Allow-Origin"))
+			a.Equal(scenario.expectedCredentials, res.Header().Get("Access-Control-Allow-Credentials"))
+			if scenario.expectedOrigin != "" {
+				a.Contains(res.Header().Get("Access-Control-Expose-Headers"), "X-Request-ID")
+			}
+		})
+	}
+}
@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-rest-api/internal/core"
+	"go-rest-api/pkg/logger"
+	userv1 "go-rest-api/pkg/pb/user/v1"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	jsonContentType     = "application/json"
+	msgpackContentType  = "application/msgpack"
+	protobufContentType = "application/x-protobuf"
+)
+
+// mediaTypeAliases maps the other names clients use for the body encodings
+// to the media type we answer with.
+var mediaTypeAliases = map[string]string{
+	"application/x-msgpack":   msgpackContentType,
+	"application/vnd.msgpack": msgpackContentType,
+	"application/protobuf":    protobufContentType,
+}
+
+func init() {
+	// ... ids are strings in every encoding, not msgpack's binary form of a
+	// uuid.UUID
+	msgpack.Register(uuid.UUID{},
+		func(e *msgpack.Encoder, v reflect.Value) error {
+			return e.EncodeString(v.Interface().(uuid.UUID).String())
+		},
+		func(d *msgpack.Decoder, v reflect.Value) error {
+			s, err := d.DecodeString()
+			if err != nil {
+				return err
+			}
+			id, err := uuid.Parse(s)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(id))
+			return nil
+		},
+	)
+}
+
+// responseEncoder writes response bodies in one media type.
+type responseEncoder interface {
+	ContentType() string
+	Encode(w io.Writer, v any) error
+}
+
+// jsonEncoder writes JSON. contentType is application/json or the vendor
+// media type the client asked for to select an API version.
+type jsonEncoder struct {
+	contentType string
+}
+
+func (e jsonEncoder) ContentType() string { return e.contentType }
+
+func (e jsonEncoder) Encode(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// msgpackEncoder writes MessagePack with the keys of the JSON encoding.
+type msgpackEncoder struct{}
+
+func (msgpackEncoder) ContentType() string { return msgpackContentType }
+
+func (msgpackEncoder) Encode(w io.Writer, v any) error {
+	encoder := msgpack.NewEncoder(w)
+	encoder.SetCustomStructTag("json")
+	return encoder.Encode(v)
+}
+
+// protobufEncoder writes the messages of proto/user/v1/user.proto.
+type protobufEncoder struct{}
+
+func (protobufEncoder) ContentType() string { return protobufContentType }
+
+func (protobufEncoder) Encode(w io.Writer, v any) error {
+	message, err := protoMessage(v)
+	if err != nil {
+		return err
+	}
+	data, err := proto.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// negotiateEncoder picks the response encoder for the Accept header of r: the
+// type with the highest quality, the first of them on a tie. Requests without
+// an Accept header get JSON; requests accepting none of the encodings get a
+// 406.
+func negotiateEncoder(r *http.Request) (responseEncoder, error) {
+	return negotiateEncoderOf(r, jsonContentType, msgpackContentType, protobufContentType)
+}
+
+// negotiateEncoderOf is negotiateEncoder for responses that only have some of
+// the encodings, such as those without a protobuf message. JSON must be one
+// of offered.
+func negotiateEncoderOf(r *http.Request, offered ...string) (responseEncoder, error) {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return jsonEncoder{jsonContentType}, nil
+	}
+
+	var best responseEncoder
+	bestQuality := 0.0
+	for _, accepted := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		if alias, ok := mediaTypeAliases[mediaType]; ok {
+			mediaType = alias
+		}
+
+		var encoder responseEncoder
+		switch {
+		case mediaType == jsonContentType, mediaType == "application/*", mediaType == "*/*":
+			encoder = jsonEncoder{jsonContentType}
+		case strings.HasPrefix(mediaType, "application/vnd.gorestapi.") && strings.HasSuffix(mediaType, "+json"):
+			encoder = jsonEncoder{mediaType}
+		case mediaType == msgpackContentType:
+			encoder = msgpackEncoder{}
+		case mediaType == protobufContentType:
+			encoder = protobufEncoder{}
+		default:
+			continue
+		}
+		if _, isJSON := encoder.(jsonEncoder); !isJSON && !slices.Contains(offered, encoder.ContentType()) {
+			continue
+		}
+		if quality > bestQuality {
+			best, bestQuality = encoder, quality
+		}
+	}
+
+	if best == nil {
+		return nil, &requestError{http.StatusNotAcceptable, "response_type_not_acceptable", map[string]string{"types": strings.Join(offered, ", ")}}
+	}
+	return best, nil
+}
+
+// writeResponse writes v with status using encoder. The body is encoded
+// before anything is sent, so a value the encoding cannot represent still
+// gets a proper error response.
+func writeResponse(w http.ResponseWriter, r *http.Request, logger logger.CustomLogger, encoder responseEncoder, status int, v any) {
+	var body bytes.Buffer
+	if err := encoder.Encode(&body, v); err != nil {
+		writeError(w, r, logger, fmt.Errorf("failed to encode %T as %s: %w", v, encoder.ContentType(), err))
+		return
+	}
+	w.Header().Set("Content-Type", encoder.ContentType())
+	if !slices.Contains(w.Header().Values("Vary"), "Accept") {
+		w.Header().Add("Vary", "Accept")
+	}
+	w.WriteHeader(status)
+	w.Write(body.Bytes())
+}
+
+// decodeBody decodes a request body into dst in the encoding named by its
+// Content-Type. Bodies without a Content-Type are read as JSON, as they were
+// before other encodings were accepted.
+func decodeBody(w http.ResponseWriter, r *http.Request, dst any) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if alias, ok := mediaTypeAliases[mediaType]; ok {
+		mediaType = alias
+	}
+	switch mediaType {
+	case "", jsonContentType:
+		return decodeJSON(w, r, dst)
+	case msgpackContentType, protobufContentType:
+	default:
+		return &requestError{http.StatusUnsupportedMediaType, "body_unsupported_type", map[string]string{"types": strings.Join([]string{jsonContentType, msgpackContentType, protobufContentType}, ", ")}}
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+	if err != nil {
+		return decodeError(err)
+	}
+	if len(data) == 0 {
+		return &requestError{status: http.StatusBadRequest, code: "body_empty"}
+	}
+	if mediaType == msgpackContentType {
+		decoder := msgpack.NewDecoder(bytes.NewReader(data))
+		decoder.SetCustomStructTag("json")
+		decoder.DisallowUnknownFields(true)
+		if err := decoder.Decode(dst); err != nil {
+			return &requestError{status: http.StatusBadRequest, code: "body_malformed"}
+		}
+		return nil
+	}
+	if err := unmarshalProto(data, dst); err != nil {
+		return &requestError{status: http.StatusBadRequest, code: "body_malformed"}
+	}
+	return nil
+}
+
+// errNoProtobufForm is returned for values that have no protobuf message.
+var errNoProtobufForm = errors.New("no protobuf message")
+
+// protoMessage converts a response DTO to its protobuf message.
+func protoMessage(v any) (proto.Message, error) {
+	switch v := v.(type) {
+	case UserResponse:
+		return userProto(userResponseV1AsV2(v))
+	case UserResponseV2:
+		return userProto(v)
+	case ListUsersResponse:
+		response := &userv1.ListUsersResponse{Limit: int32(v.Limit), Offset: int32(v.Offset)}
+		for _, user := range v.Users {
+			message, err := userProto(userResponseV1AsV2(user))
+			if err != nil {
+				return nil, err
+			}
+			response.Users = append(response.Users, message)
+		}
+		return response, nil
+	case ListUsersResponseV2:
+		response := &userv1.ListUsersResponse{Limit: int32(v.Pagination.Limit), Offset: int32(v.Pagination.Offset)}
+		for _, user := range v.Data {
+			message, err := userProto(user)
+			if err != nil {
+				return nil, err
+			}
+			response.Users = append(response.Users, message)
+		}
+		return response, nil
+	case LoginUserResponse:
+		return &userv1.LoginResponse{Token: v.Token}, nil
+	case ImpersonationResponse:
+		return &userv1.ImpersonationResponse{Token: v.Token, ExpiresAt: timestamppb.New(v.ExpiresAt)}, nil
+	case map[string]any:
+		return structpb.NewStruct(v)
+	case proto.Message:
+		return v, nil
+	}
+	return nil, errNoProtobufForm
+}
+
+// unmarshalProto decodes the protobuf form of a request DTO into dst.
+func unmarshalProto(data []byte, dst any) error {
+	switch dst := dst.(type) {
+	case *CreateUserRequest:
+		var message userv1.CreateUserRequest
+		if err := proto.Unmarshal(data, &message); err != nil {
+			return err
+		}
+		*dst = CreateUserRequest{Username: message.GetUsername(), Email: message.GetEmail(), Password: message.GetPassword()}
+	case *LoginUserRequest:
+		var message userv1.LoginRequest
+		if err := proto.Unmarshal(data, &message); err != nil {
+			return err
+		}
+		*dst = LoginUserRequest{Email: message.GetEmail(), Password: message.GetPassword()}
+	case *map[string]any:
+		var message structpb.Struct
+		if err := proto.Unmarshal(data, &message); err != nil {
+			return err
+		}
+		*dst = message.AsMap()
+	default:
+		return errNoProtobufForm
+	}
+	return nil
+}
+
+// ToUserProto converts a user to its protobuf message. Like ToUserResponse it
+// never includes the password hash.
+func ToUserProto(u core.User) (*userv1.User, error) {
+	return userProto(ToUserResponseV2(u))
+}
+
+// userResponseV1AsV2 carries a v1 user over to the v2 DTO, without the
+// profile and version that v1 does not expose.
+func userResponseV1AsV2(u UserResponse) UserResponseV2 {
+	return UserResponseV2{Id: u.Id, Username: u.Username, Email: u.Email, AvatarURL: u.AvatarURL, Roles: u.Roles, CreatedAt: u.CreatedAt, UpdatedAt: u.UpdatedAt}
+}
+
+func userProto(u UserResponseV2) (*userv1.User, error) {
+	user := &userv1.User{
+		Id:        u.Id.String(),
+		Username:  u.Username,
+		Email:     u.Email,
+		AvatarUrl: u.AvatarURL,
+		Roles:     u.Roles,
+		Version:   u.Version,
+		CreatedAt: timestamppb.New(u.CreatedAt),
+		UpdatedAt: timestamppb.New(u.UpdatedAt),
+	}
+	if u.Profile != nil {
+		profile, err := structpb.NewStruct(u.Profile)
+		if err != nil {
+			return nil, err
+		}
+		user.Profile = profile
+	}
+	return user, nil
+}
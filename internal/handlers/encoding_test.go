@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"go-rest-api/pkg/logger"
+	userv1 "go-rest-api/pkg/pb/user/v1"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestNegotiateEncoder(t *testing.T) {
+	testScenarios := []struct {
+		accept              string
+		expectedContentType string
+		expectedErr         bool
+	}{
+		{accept: "", expectedContentType: "application/json"},
+		{accept: "*/*", expectedContentType: "application/json"},
+		{accept: "application/msgpack", expectedContentType: "application/msgpack"},
+		{accept: "application/x-msgpack", expectedContentType: "application/msgpack"},
+		{accept: "application/protobuf", expectedContentType: "application/x-protobuf"},
+		{accept: "application/json;q=0.5, application/x-protobuf", expectedContentType: "application/x-protobuf"},
+		{accept: "application/msgpack, application/json", expectedContentType: "application/msgpack"},
+		{accept: "application/vnd.gorestapi.v2+json", expectedContentType: "application/vnd.gorestapi.v2+json"},
+		{accept: "text/html, application/msgpack;q=0", expectedErr: true},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.accept, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			req.Header.Set("Accept", scenario.accept)
+
+			// when
+			encoder, err := negotiateEncoder(req)
+
+			// then
+			if scenario.expectedErr {
+				a.Equal(&requestError{http.StatusNotAcceptable, "response_type_not_acceptable", map[string]string{"types": "application/json, application/msgpack, application/x-protobuf"}}, err)
+				return
+			}
+			a.NoError(err)
+			a.Equal(scenario.expectedContentType, encoder.ContentType())
+		})
+	}
+}
+
+func TestNegotiateEncoderOf(t *testing.T) {
+	testScenarios := []struct {
+		accept              string
+		expectedContentType string
+		expectedErr         bool
+	}{
+		{accept: "", expectedContentType: "application/json"},
+		{accept: "*/*", expectedContentType: "application/json"},
+		{accept: "application/msgpack", expectedContentType: "application/msgpack"},
+		{accept: "application/vnd.gorestapi.v2+json", expectedContentType: "application/vnd.gorestapi.v2+json"},
+		{accept: "application/x-protobuf, application/json;q=0.5", expectedContentType: "application/json"},
+		{accept: "application/x-protobuf", expectedErr: true},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.accept, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			req := httptest.NewRequest(http.MethodGet, "/invitations", nil)
+			req.Header.Set("Accept", scenario.accept)
+
+			// when
+			encoder, err := negotiateEncoderOf(req, jsonContentType, msgpackContentType)
+
+			// then
+			if scenario.expectedErr {
+				a.Equal(&requestError{http.StatusNotAcceptable, "response_type_not_acceptable", map[string]string{"types": "application/json, application/msgpack"}}, err)
+				return
+			}
+			a.NoError(err)
+			a.Equal(scenario.expectedContentType, encoder.ContentType())
+		})
+	}
+}
+
+func TestWriteResponse(t *testing.T) {
+	id := uuid.MustParse("8f7a6c1e-2b3d-4e5f-9a0b-1c2d3e4f5a6b")
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	user := UserResponseV2{Id: id, Username: "john", Email: "john@gmail.com", Roles: []string{"admin"}, Profile: map[string]any{"locale": "fr"}, Version: 2, CreatedAt: createdAt, UpdatedAt: createdAt}
+
+	t.Run("msgpack", func(t *testing.T) {
+		a := assert.New(t)
+		// given
+		res := httptest.NewRecorder()
+
+		// when
+		writeResponse(res, httptest.NewRequest(http.MethodGet, "/users", nil), &logger.MockLogger{}, msgpackEncoder{}, http.StatusOK, user)
+
+		// then
+		a.Equal(http.StatusOK, res.Code)
+		a.Equal("application/msgpack", res.Header().Get("Content-Type"))
+		a.Equal("Accept", res.Header().Get("Vary"))
+		var decoded map[string]any
+		a.NoError(msgpack.Unmarshal(res.Body.Bytes(), &decoded))
+		a.Equal(id.String(), decoded["id"])
+		a.Equal("john", decoded["username"])
+		a.Equal(map[string]any{"locale": "fr"}, decoded["profile"])
+	})
+
+	t.Run("protobuf", func(t *testing.T) {
+		a := assert.New(t)
+		// given
+		res := httptest.NewRecorder()
+
+		// when
+		writeResponse(res, httptest.NewRequest(http.MethodGet, "/users", nil), &logger.MockLogger{}, protobufEncoder{}, http.StatusOK, ListUsersResponseV2{Data: []UserResponseV2{user}, Pagination: Pagination{Limit: 20}})
+
+		// then
+		a.Equal(http.StatusOK, res.Code)
+		a.Equal("application/x-protobuf", res.Header().Get("Content-Type"))
+		var decoded userv1.ListUsersResponse
+		a.NoError(proto.Unmarshal(res.Body.Bytes(), &decoded))
+		a.Equal(int32(20), decoded.GetLimit())
+		a.Len(decoded.GetUsers(), 1)
+		a.Equal(id.String(), decoded.GetUsers()[0].GetId())
+		a.Equal(int64(2), decoded.GetUsers()[0].GetVersion())
+		a.Equal(map[string]any{"locale": "fr"}, decoded.GetUsers()[0].GetProfile().AsMap())
+	})
+
+	t.Run("protobuf without a message", func(t *testing.T) {
+		a := assert.New(t)
+		// given
+		res := httptest.NewRecorder()
+		mockLogger := &logger.MockLogger{}
+		mockLogger.On("Error", mock.Anything, mock.Anything).Return()
+
+		// when
+		writeResponse(res, httptest.NewRequest(http.MethodGet, "/users", nil), mockLogger, protobufEncoder{}, http.StatusOK, InvitationResponse{})
+
+		// then
+		a.Equal(http.StatusInternalServerError, res.Code)
+		a.Equal(problemContentType, res.Header().Get("Content-Type"))
+	})
+}
+
+func TestDecodeBody(t *testing.T) {
+	msgpackBody, _ := msgpack.Marshal(map[string]string{"email": "john@gmail.com", "password": "secret"})
+	protobufBody, _ := proto.Marshal(&userv1.LoginRequest{Email: "john@gmail.com", Password: "secret"})
+	unknownFieldBody, _ := msgpack.Marshal(map[string]string{"email": "john@gmail.com", "admin": "true"})
+
+	testScenarios := []struct {
+		name            string
+		contentType     string
+		body            []byte
+		expectedRequest LoginUserRequest
+		expectedErr     error
+	}{
+		{
+			name:            "json",
+			contentType:     "application/json; charset=utf-8",
+			body:            []byte(`{"email":"john@gmail.com","password":"secret"}`),
+			expectedRequest: LoginUserRequest{Email: "john@gmail.com", Password: "secret"},
+		},
+		{
+			name:            "no content type",
+			body:            []byte(`{"email":"john@gmail.com","password":"secret"}`),
+			expectedRequest: LoginUserRequest{Email: "john@gmail.com", Password: "secret"},
+		},
+		{
+			name:            "msgpack",
+			contentType:     "application/msgpack",
+			body:            msgpackBody,
+			expectedRequest: LoginUserRequest{Email: "john@gmail.com", Password: "secret"},
+		},
+		{
+			name:            "protobuf",
+			contentType:     "application/x-protobuf",
+			body:            protobufBody,
+			expectedRequest: LoginUserRequest{Email: "john@gmail.com", Password: "secret"},
+		},
+		{
+			name:        "msgpack unknown field",
+			contentType: "application/msgpack",
+			body:        unknownFieldBody,
+			expectedErr: &requestError{status: http.StatusBadRequest, code: "body_malformed"},
+		},
+		{
+			name:        "empty protobuf",
+			contentType: "application/x-protobuf",
+			expectedErr: &requestError{status: http.StatusBadRequest, code: "body_empty"},
+		},
+		{
+			name:        "unsupported type",
+			contentType: "text/plain",
+			body:        []byte("john"),
+			expectedErr: &requestError{http.StatusUnsupportedMediaType, "body_unsupported_type", map[string]string{"types": "application/json, application/msgpack, application/x-protobuf"}},
+		},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			req := httptest.NewRequest(http.MethodPost, "/users/login", bytes.NewReader(scenario.body))
+			if scenario.contentType != "" {
+				req.Header.Set("Content-Type", scenario.contentType)
+			}
+
+			// when
+			var loginReq LoginUserRequest
+			err := decodeBody(httptest.NewRecorder(), req, &loginReq)
+
+			// then
+			a.Equal(scenario.expectedErr, err)
+			if scenario.expectedErr == nil {
+				a.Equal(scenario.expectedRequest, loginReq)
+			}
+		})
+	}
+}
+
+func TestDecodeBody_ProtobufProfilePatch(t *testing.T) {
+	a := assert.New(t)
+	// given
+	patch, _ := structpb.NewStruct(map[string]any{"locale": "fr", "bio": nil})
+	body, _ := proto.Marshal(patch)
+	req := httptest.NewRequest(http.MethodPatch, "/users/42/profile", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/protobuf")
+
+	// when
+	var decoded map[string]any
+	err := decodeBody(httptest.NewRecorder(), req, &decoded)
+
+	// then
+	a.NoError(err)
+	expected, _ := json.Marshal(map[string]any{"locale": "fr", "bio": nil})
+	actual, _ := json.Marshal(decoded)
+	a.JSONEq(string(expected), string(actual))
+}
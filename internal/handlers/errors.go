@@ -1,5 +1,171 @@
 package handlers
 
-type APIError struct {
-	Error string `json:"error"`
+import (
+	"encoding/json"
+	"errors"
+	"go-rest-api/internal/core"
+	"go-rest-api/internal/i18n"
+	"go-rest-api/pkg/logger"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	problemContentType = "application/problem+json"
+	requestIDHeader    = "X-Request-ID"
+)
+
+// Problem is an RFC 7807 problem details object. Code is a stable identifier
+// for the failure that, unlike Title and Detail, is not translated. RequestID
+// is an extension member that lets clients quote a failure back to us.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Code      string       `json:"code"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+type problemMapping struct {
+	kind      error
+	status    int
+	problemID string
+}
+
+// problemMappings maps domain errors to problem types. The first entry the
+// error matches with errors.Is wins, so more specific errors go first. Titles
+// are looked up in the message catalogs as "title.<problem id>".
+var problemMappings = []problemMapping{
+	{core.ErrInvalidProfile, http.StatusUnprocessableEntity, "invalid-profile"},
+	{core.ErrIdempotencyKeyReused, http.StatusUnprocessableEntity, "idempotency-key-reused"},
+	{core.ErrValidation, http.StatusBadRequest, "validation-error"},
+	{core.ErrNotFound, http.StatusNotFound, "not-found"},
+	{core.ErrConflict, http.StatusConflict, "conflict"},
+	{core.ErrUnauthorized, http.StatusUnauthorized, "unauthorized"},
+	{core.ErrForbidden, http.StatusForbidden, "forbidden"},
+	{core.ErrGone, http.StatusGone, "gone"},
+	{core.ErrPreconditionFailed, http.StatusPreconditionFailed, "precondition-failed"},
+}
+
+// requestError is a failure of the HTTP request itself, such as a malformed
+// body or a missing token, rather than of the domain.
+type requestError struct {
+	status int
+	code   string
+	params map[string]string
+}
+
+func (e *requestError) Error() string {
+	return i18n.Translate(i18n.DefaultLocale, e.code, e.params)
+}
+
+// writeError is the single place errors become HTTP responses. Errors that
+// are not domain errors are logged and reported as a bare 500 so that no
+// internal detail leaks to the client.
+func writeError(w http.ResponseWriter, r *http.Request, logger logger.CustomLogger, err error) {
+	locale := requestLocale(r)
+
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		writeProblemJSON(w, r, Problem{
+			Type:   "about:blank",
+			Title:  statusTitle(locale, reqErr.status),
+			Status: reqErr.status,
+			Code:   reqErr.code,
+			Detail: i18n.Translate(locale, reqErr.code, reqErr.params),
+		})
+		return
+	}
+
+	for _, mapping := range problemMappings {
+		if errors.Is(err, mapping.kind) {
+			problem := Problem{
+				Type:   "/problems/" + mapping.problemID,
+				Title:  i18n.Translate(locale, "title."+strings.ReplaceAll(mapping.problemID, "-", "_"), nil),
+				Status: mapping.status,
+			}
+			var validationErr *ValidationError
+			var domainErr *core.Error
+			switch {
+			case errors.As(err, &validationErr):
+				problem.Code = "validation_failed"
+				problem.Errors = validationErr.Localize(locale)
+			case errors.As(err, &domainErr):
+				problem.Code = domainErr.Code
+				problem.Detail = i18n.Translate(locale, domainErr.Code, domainErr.Params)
+			}
+			if problem.Code == "" {
+				problem.Code = strings.ReplaceAll(mapping.problemID, "-", "_")
+			}
+			if problem.Detail == "" {
+				problem.Detail = i18n.Translate(locale, problem.Code, nil)
+			}
+			writeProblemJSON(w, r, problem)
+			return
+		}
+	}
+
+	writeProblemJSON(w, r, Problem{
+		Type:   "about:blank",
+		Title:  statusTitle(locale, http.StatusInternalServerError),
+		Status: http.StatusInternalServerError,
+		Code:   "internal_error",
+		Detail: i18n.Translate(locale, "internal_error", nil),
+	})
+	if logger != nil {
+		logger.FromContext(r.Context()).Error("request failed", "error", err)
+	}
+}
+
+// writeProblem reports a failure of the HTTP request itself with the message
+// registered for code.
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code string) {
+	writeError(w, r, nil, &requestError{status: status, code: code})
+}
+
+// NotFound answers requests that match no route.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, http.StatusNotFound, "route_not_found")
+}
+
+// statusTitle is the translated HTTP status phrase, which RFC 7807 asks for
+// as the title of about:blank problems.
+func statusTitle(locale string, status int) string {
+	key := "status." + strconv.Itoa(status)
+	if title := i18n.Translate(locale, key, nil); title != key {
+		return title
+	}
+	return http.StatusText(status)
+}
+
+func writeProblemJSON(w http.ResponseWriter, r *http.Request, problem Problem) {
+	problem.Instance = r.URL.Path
+	problem.RequestID = requestID(w, r)
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// requestID returns the id RequestIDMiddleware gave the request. Without the
+// middleware it takes the one the client or a proxy sent, or assigns a new
+// one. Either way it is echoed in the X-Request-ID header.
+func requestID(w http.ResponseWriter, r *http.Request) string {
+	id := w.Header().Get(requestIDHeader)
+	if id == "" {
+		id = logger.RequestIDFromContext(r.Context())
+	}
+	if id == "" && validRequestID(r.Header.Get(requestIDHeader)) {
+		id = r.Header.Get(requestIDHeader)
+	}
+	if id == "" {
+		id = uuid.NewString()
+	}
+	w.Header().Set(requestIDHeader, id)
+	return id
 }
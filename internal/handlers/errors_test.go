@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-rest-api/internal/core"
+	"go-rest-api/pkg/logger"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWriteError_MapsDomainErrors(t *testing.T) {
+	scenarios := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedType   string
+		expectedCode   string
+	}{
+		{name: "not found", err: core.ErrUserNotFound, expectedStatus: http.StatusNotFound, expectedType: "/problems/not-found", expectedCode: "user_not_found"},
+		{name: "conflict", err: fmt.Errorf("insert: %w", core.ErrUserAlreadyExists), expectedStatus: http.StatusConflict, expectedType: "/problems/conflict", expectedCode: "user_already_exists"},
+		{name: "validation", err: core.NewError(core.ErrValidation, "invalid_limit", "bad"), expectedStatus: http.StatusBadRequest, expectedType: "/problems/validation-error", expectedCode: "invalid_limit"},
+		{name: "invalid profile", err: &core.Error{Kind: core.ErrInvalidProfile, Code: "invalid_profile", Message: "invalid profile: locale", Params: map[string]string{"reason": "locale"}}, expectedStatus: http.StatusUnprocessableEntity, expectedType: "/problems/invalid-profile", expectedCode: "invalid_profile"},
+		{name: "unauthorized", err: core.ErrInvalidCredentials, expectedStatus: http.StatusUnauthorized, expectedType: "/problems/unauthorized", expectedCode: "invalid_credentials"},
+		{name: "forbidden", err: core.NewError(core.ErrForbidden, "profile_forbidden", "no"), expectedStatus: http.StatusForbidden, expectedType: "/problems/forbidden", expectedCode: "profile_forbidden"},
+		{name: "gone", err: core.ErrInvalidInvitation, expectedStatus: http.StatusGone, expectedType: "/problems/gone", expectedCode: "invalid_invitation"},
+		{name: "precondition failed", err: core.ErrUserVersionMismatch, expectedStatus: http.StatusPreconditionFailed, expectedType: "/problems/precondition-failed", expectedCode: "version_mismatch"},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+
+			// given
+			req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+			req.Header.Set("X-Request-ID", "req-123")
+			res := httptest.NewRecorder()
+
+			// when
+			writeError(res, req, &logger.MockLogger{}, scenario.err)
+
+			// then
+			a.Equal(scenario.expectedStatus, res.Code)
+			a.Equal("application/problem+json", res.Header().Get("Content-Type"))
+			a.Equal("req-123", res.Header().Get("X-Request-ID"))
+			var problem Problem
+			a.NoError(json.Unmarshal(res.Body.Bytes(), &problem))
+			a.Equal(scenario.expectedType, problem.Type)
+			a.Equal(scenario.expectedStatus, problem.Status)
+			a.Equal(scenario.expectedCode, problem.Code)
+			a.Equal("/users/42", problem.Instance)
+			a.Equal("req-123", problem.RequestID)
+			a.NotEmpty(problem.Title)
+			a.NotEmpty(problem.Detail)
+		})
+	}
+}
+
+func TestWriteError_HidesInternalErrors(t *testing.T) {
+	a := assert.New(t)
+
+	// given
+	mockLogger := logger.MockLogger{}
+	mockLogger.On("Error", mock.Anything, mock.Anything).Return()
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	res := httptest.NewRecorder()
+
+	// when
+	writeError(res, req, &mockLogger, fmt.Errorf("dial tcp 10.0.0.1:5432: connection refused"))
+
+	// then
+	a.Equal(http.StatusInternalServerError, res.Code)
+	var problem Problem
+	a.NoError(json.Unmarshal(res.Body.Bytes(), &problem))
+	a.Equal("about:blank", problem.Type)
+	a.Equal("internal_error", problem.Code)
+	a.NotContains(problem.Detail, "10.0.0.1")
+	a.NotEmpty(problem.RequestID)
+	a.Equal(problem.RequestID, res.Header().Get("X-Request-ID"))
+	mockLogger.AssertNumberOfCalls(t, "Error", 1)
+}
+
+func TestWriteError_Localized(t *testing.T) {
+	testScenarios := []struct {
+		name           string
+		acceptLanguage string
+		expectedTitle  string
+		expectedDetail string
+	}{
+		{name: "default", acceptLanguage: "", expectedTitle: "Resource not found", expectedDetail: "User not found"},
+		{name: "french", acceptLanguage: "fr-CA,fr;q=0.9,en;q=0.5", expectedTitle: "Ressource introuvable", expectedDetail: "Utilisateur introuvable"},
+		{name: "zulu", acceptLanguage: "zu-ZA", expectedTitle: "Insiza ayitholakali", expectedDetail: "Umsebenzisi akatholakali"},
+		{name: "unsupported falls back to english", acceptLanguage: "de-DE", expectedTitle: "Resource not found", expectedDetail: "User not found"},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+
+			// given
+			handler := LocaleMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeError(w, r, nil, core.ErrUserNotFound)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+			req.Header.Set("Accept-Language", scenario.acceptLanguage)
+			res := httptest.NewRecorder()
+
+			// when
+			handler.ServeHTTP(res, req)
+
+			// then
+			var problem Problem
+			a.NoError(json.Unmarshal(res.Body.Bytes(), &problem))
+			a.Equal("user_not_found", problem.Code)
+			a.Equal(scenario.expectedTitle, problem.Title)
+			a.Equal(scenario.expectedDetail, problem.Detail)
+		})
+	}
+}
+
+func TestWriteError_LocalizesFieldErrors(t *testing.T) {
+	a := assert.New(t)
+
+	// given
+	req := httptest.NewRequest(http.MethodPost, "/users", nil)
+	req.Header.Set("Accept-Language", "fr")
+	res := httptest.NewRecorder()
+	err := (&CreateUserRequest{Username: "john", Email: "john@gmail.com", Password: "123"}).Validate()
+
+	// when
+	writeError(res, req, nil, err)
+
+	// then
+	var problem Problem
+	a.NoError(json.Unmarshal(res.Body.Bytes(), &problem))
+	a.Equal("validation_failed", problem.Code)
+	a.Equal("Un ou plusieurs champs sont invalides", problem.Detail)
+	a.Equal([]FieldError{{Field: "password", Rule: "min", Message: "doit contenir au moins 6 caractères"}}, problem.Errors)
+}
@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-rest-api/internal/core"
+	"go-rest-api/internal/i18n"
+	"go-rest-api/pkg/logger"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// GraphQLHandler serves the GraphQL endpoint. Its resolvers go through the
+// same services as the REST handlers, so the same rules and errors apply.
+type GraphQLHandler struct {
+	schema            graphql.Schema
+	userService       UserService
+	invitationService InvitationService
+	userImportService UserImportService
+	limits            GraphQLLimits
+	Logger            logger.CustomLogger
+}
+
+func NewGraphQLHandler(userService UserService, invitationService InvitationService, userImportService UserImportService, limits GraphQLLimits, logger logger.CustomLogger) (*GraphQLHandler, error) {
+	h := &GraphQLHandler{
+		userService:       userService,
+		invitationService: invitationService,
+		userImportService: userImportService,
+		limits:            limits,
+		Logger:            logger,
+	}
+	schema, err := h.newSchema()
+	if err != nil {
+		return nil, err
+	}
+	h.schema = schema
+	return h, nil
+}
+
+func (req *GraphQLRequest) Validate() error {
+	return validateStruct(req)
+}
+
+// graphQLError is a resolver error with a stable code in its extensions and
+// a message in the request's language.
+type graphQLError struct {
+	message    string
+	extensions map[string]interface{}
+}
+
+func (e *graphQLError) Error() string {
+	return e.message
+}
+
+func (e *graphQLError) Extensions() map[string]interface{} {
+	return e.extensions
+}
+
+// Serve executes a GraphQL request given as a JSON body. Failures of the HTTP
+// request itself are problem documents like everywhere else; once the query
+// is parsed, errors are reported in the GraphQL response's errors list.
+func (h *GraphQLHandler) Serve(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	var req GraphQLRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
+	locale := requestLocale(r)
+	ctx = context.WithValue(ctx, "locale", locale)
+	ctx = context.WithValue(ctx, "user_loader", newUserLoader(h.userService.GetUsersByIDs))
+
+	document, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"})})
+	if err != nil {
+		writeGraphQLResult(w, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+	if validation := graphql.ValidateDocument(&h.schema, document, nil); !validation.IsValid {
+		writeGraphQLResult(w, &graphql.Result{Errors: validation.Errors})
+		return
+	}
+	if err := checkGraphQLLimits(h.schema, document, req.OperationName, req.Variables, h.limits); err != nil {
+		gqlErr := h.resolverError(ctx, err)
+		writeGraphQLResult(w, &graphql.Result{Errors: []gqlerrors.FormattedError{{Message: gqlErr.message, Extensions: gqlErr.extensions}}})
+		return
+	}
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        h.schema,
+		AST:           document,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       ctx,
+	})
+	writeGraphQLResult(w, result)
+}
+
+func writeGraphQLResult(w http.ResponseWriter, result *graphql.Result) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
+
+// resolverError turns an error into a graphQLError the way writeError turns
+// it into a problem: domain and request errors keep their code, anything else
+// is logged and reported as internal_error.
+func (h *GraphQLHandler) resolverError(ctx context.Context, err error) *graphQLError {
+	locale, _ := ctx.Value("locale").(string)
+
+	var reqErr *requestError
+	var validationErr *ValidationError
+	var domainErr *core.Error
+	switch {
+	case errors.As(err, &reqErr):
+		return newGraphQLError(locale, reqErr.code, reqErr.params)
+	case errors.As(err, &validationErr):
+		gqlErr := newGraphQLError(locale, "validation_failed", nil)
+		gqlErr.extensions["fields"] = validationErr.Localize(locale)
+		return gqlErr
+	case errors.As(err, &domainErr):
+		return newGraphQLError(locale, domainErr.Code, domainErr.Params)
+	}
+	for _, mapping := range problemMappings {
+		if errors.Is(err, mapping.kind) {
+			return newGraphQLError(locale, strings.ReplaceAll(mapping.problemID, "-", "_"), nil)
+		}
+	}
+	h.Logger.FromContext(ctx).Error("graphql resolver failed", "error", err)
+	return newGraphQLError(locale, "internal_error", nil)
+}
+
+func newGraphQLError(locale, code string, params map[string]string) *graphQLError {
+	return &graphQLError{
+		message:    i18n.Translate(locale, code, params),
+		extensions: map[string]interface{}{"code": code},
+	}
+}
+
+// requireGraphQLRole is RequireRole for resolvers.
+func requireGraphQLRole(ctx context.Context, role string) error {
+	roles, _ := ctx.Value("roles").([]string)
+	if !slices.Contains(roles, role) {
+		return &requestError{http.StatusForbidden, "role_required", map[string]string{"role": role}}
+	}
+	return nil
+}
+
+// blockGraphQLImpersonation is BlockImpersonation for resolvers.
+func blockGraphQLImpersonation(ctx context.Context) error {
+	if actorID, _ := ctx.Value("actor_id").(string); actorID != "" {
+		return &requestError{status: http.StatusForbidden, code: "impersonation_not_allowed"}
+	}
+	return nil
+}
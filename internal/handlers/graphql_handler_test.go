@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"go-rest-api/internal/core"
+	"go-rest-api/pkg/logger"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type graphQLTestResponse struct {
+	Data   map[string]any `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func newTestGraphQLHandler(t *testing.T, userRepo *core.MockUserRepository, invitationRepo *core.MockInvitationRepository) *GraphQLHandler {
+	mockLogger := &logger.MockLogger{}
+	mockLogger.On("Error", mock.Anything, mock.Anything).Return()
+	userService := core.NewUserService(userRepo, mockLogger, &core.MockUserEventService{}, &core.MockBlobStore{}, &core.MockProfileValidator{})
+	invitationService := core.NewInvitationService(invitationRepo, userRepo, userService, &core.MockMailer{}, mockLogger, "")
+	userImportService := core.NewUserImportService(&core.MockUserImportRepository{}, &core.MockUserEventService{}, mockLogger)
+	handler, err := NewGraphQLHandler(userService, invitationService, userImportService, GraphQLLimits{MaxDepth: 4, MaxComplexity: 100}, mockLogger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return handler
+}
+
+func serveGraphQL(handler *GraphQLHandler, roles []string, query string, variables map[string]any) graphQLTestResponse {
+	body, _ := json.Marshal(GraphQLRequest{Query: query, Variables: variables})
+	req := httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body))
+	ctx := context.WithValue(req.Context(), "user_id", "8f7a6c1e-2b3d-4e5f-9a0b-1c2d3e4f5a6b")
+	ctx = context.WithValue(ctx, "roles", roles)
+	res := httptest.NewRecorder()
+	handler.Serve(res, req.WithContext(ctx))
+
+	var response graphQLTestResponse
+	json.NewDecoder(res.Body).Decode(&response)
+	return response
+}
+
+func TestGraphQLHandler_BatchesUserLookups(t *testing.T) {
+	a := assert.New(t)
+	// given
+	alice := &core.User{ID: uuid.New(), Username: "alice", Roles: []string{}}
+	bob := &core.User{ID: uuid.New(), Username: "bob", Roles: []string{}}
+	expiresAt := time.Now().Add(time.Hour)
+	invitationRepo := &core.MockInvitationRepository{}
+	invitationRepo.On("ListPendingInvitations", mock.Anything).Return([]*core.Invitation{
+		{ID: uuid.New(), Email: "a@b.com", Roles: []string{}, InvitedBy: alice.ID, ExpiresAt: expiresAt},
+		{ID: uuid.New(), Email: "c@d.com", Roles: []string{}, InvitedBy: bob.ID, ExpiresAt: expiresAt},
+		{ID: uuid.New(), Email: "e@f.com", Roles: []string{}, InvitedBy: alice.ID, ExpiresAt: expiresAt},
+	}, nil)
+	userRepo := &core.MockUserRepository{}
+	userRepo.On("GetUsersByIDs", mock.Anything, []string{alice.ID.String(), bob.ID.String()}).Return([]*core.User{bob, alice}, nil).Once()
+	handler := newTestGraphQLHandler(t, userRepo, invitationRepo)
+
+	// when
+	response := serveGraphQL(handler, []string{core.RoleAdmin}, `{ invitations { email invitedBy { username } } }`, nil)
+
+	// then
+	a.Empty(response.Errors)
+	a.Equal([]any{
+		map[string]any{"email": "a@b.com", "invitedBy": map[string]any{"username": "alice"}},
+		map[string]any{"email": "c@d.com", "invitedBy": map[string]any{"username": "bob"}},
+		map[string]any{"email": "e@f.com", "invitedBy": map[string]any{"username": "alice"}},
+	}, response.Data["invitations"])
+	userRepo.AssertExpectations(t)
+}
+
+func TestGraphQLHandler_RequiresRole(t *testing.T) {
+	testScenarios := []struct {
+		name  string
+		query string
+	}{
+		{name: "invitations", query: `{ invitations { email } }`},
+		{name: "users", query: `{ users { id email } }`},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			handler := newTestGraphQLHandler(t, &core.MockUserRepository{}, &core.MockInvitationRepository{})
+
+			// when
+			response := serveGraphQL(handler, []string{}, scenario.query, nil)
+
+			// then
+			a.Len(response.Errors, 1)
+			a.Equal("role_required", response.Errors[0].Extensions["code"])
+			a.Equal("The admin role is required", response.Errors[0].Message)
+		})
+	}
+}
+
+func TestGraphQLHandler_UpdateProfileOfAnotherUser(t *testing.T) {
+	a := assert.New(t)
+	// given
+	handler := newTestGraphQLHandler(t, &core.MockUserRepository{}, &core.MockInvitationRepository{})
+
+	// when
+	response := serveGraphQL(handler, []string{}, `mutation($id: ID!) { updateProfile(id: $id, patch: {locale: "fr"}, version: 1) { id } }`, map[string]any{"id": uuid.NewString()})
+
+	// then
+	a.Len(response.Errors, 1)
+	a.Equal("profile_forbidden", response.Errors[0].Extensions["code"])
+}
+
+func TestGraphQLHandler_ComplexityLimit(t *testing.T) {
+	testScenarios := []struct {
+		name         string
+		query        string
+		variables    map[string]any
+		expectedCode string
+	}{
+		{name: "list limit", query: `{ users(limit: 100) { id username email } }`, expectedCode: "graphql_too_complex"},
+		{name: "list limit variable", query: `query($limit: Int) { users(limit: $limit) { id username email } }`, variables: map[string]any{"limit": 50}, expectedCode: "graphql_too_complex"},
+		{name: "within limit", query: `{ users(limit: 10) { id username email } }`},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			userRepo := &core.MockUserRepository{}
+			userRepo.On("ListUsers", mock.Anything, mock.Anything).Return([]*core.User{}, nil)
+			handler := newTestGraphQLHandler(t, userRepo, &core.MockInvitationRepository{})
+
+			// when
+			response := serveGraphQL(handler, []string{core.RoleAdmin}, scenario.query, scenario.variables)
+
+			// then
+			if scenario.expectedCode == "" {
+				a.Empty(response.Errors)
+				a.Equal([]any{}, response.Data["users"])
+				return
+			}
+			a.Len(response.Errors, 1)
+			a.Equal(scenario.expectedCode, response.Errors[0].Extensions["code"])
+			a.Nil(response.Data)
+		})
+	}
+}
+
+func TestGraphQLHandler_DepthLimit(t *testing.T) {
+	a := assert.New(t)
+	// given
+	handler := newTestGraphQLHandler(t, &core.MockUserRepository{}, &core.MockInvitationRepository{})
+	handler.limits.MaxDepth = 2
+
+	// when
+	response := serveGraphQL(handler, []string{}, `{ userImport(id: "x") { ...importer } } fragment importer on UserImport { createdBy { id } }`, nil)
+
+	// then
+	a.Len(response.Errors, 1)
+	a.Equal("graphql_too_deep", response.Errors[0].Extensions["code"])
+	a.Equal("Query is nested more than 2 levels deep", response.Errors[0].Message)
+}
+
+func TestGraphQLHandler_InvalidRequest(t *testing.T) {
+	a := assert.New(t)
+	// given
+	handler := newTestGraphQLHandler(t, &core.MockUserRepository{}, &core.MockInvitationRepository{})
+	req := httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewBufferString(`{"variables":{}}`))
+	res := httptest.NewRecorder()
+
+	// when
+	handler.Serve(res, req)
+
+	// then
+	a.Equal(http.StatusBadRequest, res.Code)
+	a.Equal(problemContentType, res.Header().Get("Content-Type"))
+}
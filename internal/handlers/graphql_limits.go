@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// graphQLDefaultListSize is the number of items assumed for a list field
+// without a limit argument when estimating the complexity of a query.
+const graphQLDefaultListSize = 20
+
+// GraphQLLimits bounds the queries the GraphQL endpoint executes.
+type GraphQLLimits struct {
+	// MaxDepth is the deepest nesting of selections allowed, root fields being
+	// at depth 1.
+	MaxDepth int
+	// MaxComplexity caps the estimated cost of a query. Every field costs 1
+	// and the selections below a list field count once per item it may
+	// return, taken from its limit argument.
+	MaxComplexity int
+}
+
+// checkGraphQLLimits measures the selected operation of a validated document
+// and returns a requestError when it is too deep or too complex.
+func checkGraphQLLimits(schema graphql.Schema, document *ast.Document, operationName string, variables map[string]any, limits GraphQLLimits) error {
+	measurer := &graphQLMeasurer{
+		schema:    schema,
+		variables: variables,
+		fragments: map[string]*ast.FragmentDefinition{},
+		visiting:  map[string]bool{},
+	}
+	var operation *ast.OperationDefinition
+	for _, definition := range document.Definitions {
+		switch definition := definition.(type) {
+		case *ast.FragmentDefinition:
+			measurer.fragments[definition.Name.Value] = definition
+		case *ast.OperationDefinition:
+			if operation == nil && (operationName == "" || definition.Name != nil && definition.Name.Value == operationName) {
+				operation = definition
+			}
+		}
+	}
+	if operation == nil {
+		return nil
+	}
+
+	root := schema.QueryType()
+	if operation.Operation == ast.OperationTypeMutation {
+		root = schema.MutationType()
+	}
+	depth, complexity := measurer.measure(operation.SelectionSet, root, 1)
+	if depth > limits.MaxDepth {
+		return &requestError{status: http.StatusBadRequest, code: "graphql_too_deep", params: map[string]string{"max": strconv.Itoa(limits.MaxDepth)}}
+	}
+	if complexity > limits.MaxComplexity {
+		return &requestError{status: http.StatusBadRequest, code: "graphql_too_complex", params: map[string]string{"max": strconv.Itoa(limits.MaxComplexity)}}
+	}
+	return nil
+}
+
+type graphQLMeasurer struct {
+	schema    graphql.Schema
+	variables map[string]any
+	fragments map[string]*ast.FragmentDefinition
+	// visiting guards against fragment cycles, which validation rejects
+	// anyway.
+	visiting map[string]bool
+}
+
+// measure returns the depth and complexity of a selection set whose fields
+// belong to parent. parent is nil below fields the schema does not describe,
+// such as the introspection ones.
+func (m *graphQLMeasurer) measure(selectionSet *ast.SelectionSet, parent *graphql.Object, depth int) (int, int) {
+	if selectionSet == nil {
+		return 0, 0
+	}
+	maxDepth, complexity := 0, 0
+	for _, selection := range selectionSet.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			fieldDepth, fieldComplexity := depth, 1
+			child, listSize := m.fieldType(parent, selection)
+			if selection.SelectionSet != nil {
+				childDepth, childComplexity := m.measure(selection.SelectionSet, child, depth+1)
+				fieldDepth = max(fieldDepth, childDepth)
+				fieldComplexity += childComplexity * listSize
+			}
+			maxDepth = max(maxDepth, fieldDepth)
+			complexity += fieldComplexity
+		case *ast.InlineFragment:
+			target := parent
+			if selection.TypeCondition != nil {
+				target, _ = m.schema.Type(selection.TypeCondition.Name.Value).(*graphql.Object)
+			}
+			fragmentDepth, fragmentComplexity := m.measure(selection.SelectionSet, target, depth)
+			maxDepth = max(maxDepth, fragmentDepth)
+			complexity += fragmentComplexity
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			fragment, ok := m.fragments[name]
+			if !ok || m.visiting[name] {
+				continue
+			}
+			m.visiting[name] = true
+			target, _ := m.schema.Type(fragment.TypeCondition.Name.Value).(*graphql.Object)
+			fragmentDepth, fragmentComplexity := m.measure(fragment.SelectionSet, target, depth)
+			delete(m.visiting, name)
+			maxDepth = max(maxDepth, fragmentDepth)
+			complexity += fragmentComplexity
+		}
+	}
+	return maxDepth, complexity
+}
+
+// fieldType returns the object type of a field's value and how many items
+// it is assumed to hold: 1 unless the field is a list.
+func (m *graphQLMeasurer) fieldType(parent *graphql.Object, field *ast.Field) (*graphql.Object, int) {
+	if parent == nil {
+		return nil, 1
+	}
+	definition, ok := parent.Fields()[field.Name.Value]
+	if !ok {
+		return nil, 1
+	}
+	object, _ := graphql.GetNamed(definition.Type).(*graphql.Object)
+
+	fieldType := definition.Type
+	if nonNull, ok := fieldType.(*graphql.NonNull); ok {
+		fieldType = nonNull.OfType
+	}
+	if _, ok := fieldType.(*graphql.List); !ok {
+		return object, 1
+	}
+	return object, m.limitArgument(field)
+}
+
+func (m *graphQLMeasurer) limitArgument(field *ast.Field) int {
+	for _, argument := range field.Arguments {
+		if argument.Name.Value != "limit" {
+			continue
+		}
+		switch value := argument.Value.(type) {
+		case *ast.IntValue:
+			if limit, err := strconv.Atoi(value.Value); err == nil && limit > 0 {
+				return limit
+			}
+		case *ast.Variable:
+			if limit, ok := m.variables[value.Name.Value].(float64); ok && limit > 0 {
+				return int(limit)
+			}
+		}
+	}
+	return graphQLDefaultListSize
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"go-rest-api/internal/core"
+	"sync"
+)
+
+// userLoader batches the user lookups made while resolving one GraphQL
+// request. Load only registers the id and returns a thunk: graphql-go runs
+// the thunks of a level after it has visited all of its fields, so the first
+// thunk to run fetches every id registered so far in a single call instead of
+// one repository call per user.
+type userLoader struct {
+	fetch func(ctx context.Context, ids []string) ([]*core.User, error)
+
+	mu      sync.Mutex
+	pending []string
+	queued  map[string]bool
+	users   map[string]*core.User
+	errs    map[string]error
+}
+
+func newUserLoader(fetch func(ctx context.Context, ids []string) ([]*core.User, error)) *userLoader {
+	return &userLoader{
+		fetch:  fetch,
+		queued: map[string]bool{},
+		users:  map[string]*core.User{},
+		errs:   map[string]error{},
+	}
+}
+
+// userLoaderFrom returns the loader of the request being resolved.
+func userLoaderFrom(ctx context.Context) *userLoader {
+	loader, _ := ctx.Value("user_loader").(*userLoader)
+	return loader
+}
+
+// Load returns a thunk resolving to the user with the given id, or to nil
+// when there is none. Each id is fetched at most once per request.
+func (l *userLoader) Load(ctx context.Context, id string) func() (interface{}, error) {
+	l.mu.Lock()
+	if !l.queued[id] {
+		l.queued[id] = true
+		l.pending = append(l.pending, id)
+	}
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if len(l.pending) > 0 {
+			l.dispatch(ctx)
+		}
+		if err := l.errs[id]; err != nil {
+			return nil, err
+		}
+		if user, ok := l.users[id]; ok {
+			return user, nil
+		}
+		return nil, nil
+	}
+}
+
+func (l *userLoader) dispatch(ctx context.Context) {
+	ids := l.pending
+	l.pending = nil
+	users, err := l.fetch(ctx, ids)
+	if err != nil {
+		for _, id := range ids {
+			l.errs[id] = err
+		}
+		return
+	}
+	for _, user := range users {
+		l.users[user.ID.String()] = user
+	}
+}
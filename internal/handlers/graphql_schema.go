@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"go-rest-api/internal/core"
+	"go-rest-api/internal/i18n"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// graphQLJSON carries free-form JSON such as profiles and profile filters.
+var graphQLJSON = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "JSON",
+	Description: "An arbitrary JSON value.",
+	Serialize: func(value interface{}) interface{} {
+		return value
+	},
+	ParseValue: func(value interface{}) interface{} {
+		return value
+	},
+	ParseLiteral: parseJSONLiteral,
+})
+
+// parseJSONLiteral converts an inline GraphQL value into the Go value
+// encoding/json would produce for the same JSON.
+func parseJSONLiteral(value ast.Value) interface{} {
+	switch value := value.(type) {
+	case *ast.StringValue:
+		return value.Value
+	case *ast.BooleanValue:
+		return value.Value
+	case *ast.IntValue:
+		number, _ := strconv.ParseFloat(value.Value, 64)
+		return number
+	case *ast.FloatValue:
+		number, _ := strconv.ParseFloat(value.Value, 64)
+		return number
+	case *ast.ObjectValue:
+		object := make(map[string]interface{}, len(value.Fields))
+		for _, field := range value.Fields {
+			object[field.Name.Value] = parseJSONLiteral(field.Value)
+		}
+		return object
+	case *ast.ListValue:
+		list := make([]interface{}, 0, len(value.Values))
+		for _, item := range value.Values {
+			list = append(list, parseJSONLiteral(item))
+		}
+		return list
+	}
+	return nil
+}
+
+// graphQLField returns a field resolved from a source of type S by get.
+func graphQLField[S any](fieldType graphql.Output, get func(S) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Type: fieldType,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return get(p.Source.(S)), nil
+		},
+	}
+}
+
+// loadUser resolves to the user with the given id through the request's
+// userLoader.
+func loadUser(p graphql.ResolveParams, id uuid.UUID) (interface{}, error) {
+	return userLoaderFrom(p.Context).Load(p.Context, id.String()), nil
+}
+
+func (h *GraphQLHandler) newSchema() (graphql.Schema, error) {
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"id":        graphQLField(graphql.NewNonNull(graphql.ID), func(u *core.User) interface{} { return u.ID.String() }),
+			"username":  graphQLField(graphql.NewNonNull(graphql.String), func(u *core.User) interface{} { return u.Username }),
+			"email":     graphQLField(graphql.NewNonNull(graphql.String), func(u *core.User) interface{} { return u.Email }),
+			"avatarUrl": graphQLField(graphql.String, func(u *core.User) interface{} { return u.AvatarURL }),
+			"roles":     graphQLField(graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))), func(u *core.User) interface{} { return u.Roles }),
+			"profile":   graphQLField(graphQLJSON, func(u *core.User) interface{} { return u.Profile }),
+			"version":   graphQLField(graphql.NewNonNull(graphql.Int), func(u *core.User) interface{} { return u.Version }),
+			"createdAt": graphQLField(graphql.NewNonNull(graphql.DateTime), func(u *core.User) interface{} { return u.CreatedAt }),
+			"updatedAt": graphQLField(graphql.NewNonNull(graphql.DateTime), func(u *core.User) interface{} { return u.UpdatedAt }),
+		},
+	})
+
+	invitationType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Invitation",
+		Fields: graphql.Fields{
+			"id":    graphQLField(graphql.NewNonNull(graphql.ID), func(i *core.Invitation) interface{} { return i.ID.String() }),
+			"email": graphQLField(graphql.NewNonNull(graphql.String), func(i *core.Invitation) interface{} { return i.Email }),
+			"roles": graphQLField(graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))), func(i *core.Invitation) interface{} { return i.Roles }),
+			"invitedBy": &graphql.Field{
+				Type: userType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadUser(p, p.Source.(*core.Invitation).InvitedBy)
+				},
+			},
+			"expiresAt": graphQLField(graphql.NewNonNull(graphql.DateTime), func(i *core.Invitation) interface{} { return i.ExpiresAt }),
+			"createdAt": graphQLField(graphql.NewNonNull(graphql.DateTime), func(i *core.Invitation) interface{} { return i.CreatedAt }),
+		},
+	})
+
+	userImportRowErrorType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "UserImportRowError",
+		Fields: graphql.Fields{
+			"row":   graphQLField(graphql.NewNonNull(graphql.Int), func(e core.UserImportRowError) interface{} { return e.Row }),
+			"field": graphQLField(graphql.String, func(e core.UserImportRowError) interface{} { return e.Field }),
+			"code":  graphQLField(graphql.NewNonNull(graphql.String), func(e core.UserImportRowError) interface{} { return e.Code }),
+			"message": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					rowErr := p.Source.(core.UserImportRowError)
+					locale, _ := p.Context.Value("locale").(string)
+					return i18n.Translate(locale, rowErr.Code, rowErr.Params), nil
+				},
+			},
+		},
+	})
+
+	userImportType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "UserImport",
+		Fields: graphql.Fields{
+			"id":            graphQLField(graphql.NewNonNull(graphql.ID), func(i *core.UserImport) interface{} { return i.ID.String() }),
+			"status":        graphQLField(graphql.NewNonNull(graphql.String), func(i *core.UserImport) interface{} { return i.Status }),
+			"format":        graphQLField(graphql.NewNonNull(graphql.String), func(i *core.UserImport) interface{} { return i.Format }),
+			"totalRows":     graphQLField(graphql.NewNonNull(graphql.Int), func(i *core.UserImport) interface{} { return i.TotalRows }),
+			"processedRows": graphQLField(graphql.NewNonNull(graphql.Int), func(i *core.UserImport) interface{} { return i.ProcessedRows }),
+			"importedRows":  graphQLField(graphql.NewNonNull(graphql.Int), func(i *core.UserImport) interface{} { return i.ImportedRows }),
+			"failedRows":    graphQLField(graphql.NewNonNull(graphql.Int), func(i *core.UserImport) interface{} { return i.FailedRows }),
+			"createdBy": &graphql.Field{
+				Type: userType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadUser(p, p.Source.(*core.UserImport).CreatedBy)
+				},
+			},
+			"errors":     graphQLField(graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(userImportRowErrorType))), func(i *core.UserImport) interface{} { return i.Errors }),
+			"createdAt":  graphQLField(graphql.NewNonNull(graphql.DateTime), func(i *core.UserImport) interface{} { return i.CreatedAt }),
+			"startedAt":  graphQLField(graphql.DateTime, func(i *core.UserImport) interface{} { return i.StartedAt }),
+			"finishedAt": graphQLField(graphql.DateTime, func(i *core.UserImport) interface{} { return i.FinishedAt }),
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"me": &graphql.Field{
+				Type:    userType,
+				Resolve: h.resolveMe,
+			},
+			"user": &graphql.Field{
+				Type: userType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: h.resolveUser,
+			},
+			"users": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(userType))),
+				Args: graphql.FieldConfigArgument{
+					"profile": &graphql.ArgumentConfig{Type: graphQLJSON, Description: "Matches users whose profile contains this object."},
+					"limit":   &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 20},
+					"offset":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+				},
+				Resolve: h.resolveUsers,
+			},
+			"invitations": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(invitationType))),
+				Resolve: h.resolveInvitations,
+			},
+			"userImport": &graphql.Field{
+				Type: userImportType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: h.resolveUserImport,
+			},
+		},
+	})
+
+	mutationType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"updateProfile": &graphql.Field{
+				Type:        graphql.NewNonNull(userType),
+				Description: "Applies a JSON merge patch to your own profile. version is the one last read, or 0 to skip the check; the update fails with version_mismatch when the user changed since.",
+				Args: graphql.FieldConfigArgument{
+					"id":      &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+					"patch":   &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphQLJSON)},
+					"version": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: h.resolveUpdateProfile,
+			},
+			"createInvitation": &graphql.Field{
+				Type: graphql.NewNonNull(invitationType),
+				Args: graphql.FieldConfigArgument{
+					"email":     &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					"roles":     &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+					"expiresAt": &graphql.ArgumentConfig{Type: graphql.DateTime},
+				},
+				Resolve: h.resolveCreateInvitation,
+			},
+			"revokeInvitation": &graphql.Field{
+				Type: graphql.NewNonNull(invitationType),
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: h.resolveRevokeInvitation,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:    queryType,
+		Mutation: mutationType,
+	})
+}
+
+func (h *GraphQLHandler) resolveMe(p graphql.ResolveParams) (interface{}, error) {
+	userID, _ := p.Context.Value("user_id").(string)
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, h.resolverError(p.Context, &requestError{status: http.StatusUnauthorized, code: "unauthorized"})
+	}
+	return loadUser(p, id)
+}
+
+func (h *GraphQLHandler) resolveUser(p graphql.ResolveParams) (interface{}, error) {
+	id, err := uuid.Parse(p.Args["id"].(string))
+	if err != nil {
+		return nil, h.resolverError(p.Context, core.NewError(core.ErrValidation, "invalid_user_id", "valid user id is required"))
+	}
+	return loadUser(p, id)
+}
+
+func (h *GraphQLHandler) resolveUsers(p graphql.ResolveParams) (interface{}, error) {
+	if err := requireGraphQLRole(p.Context, core.RoleAdmin); err != nil {
+		return nil, h.resolverError(p.Context, err)
+	}
+	filter := core.UserFilter{Limit: p.Args["limit"].(int), Offset: p.Args["offset"].(int)}
+	if filter.Limit < 1 || filter.Limit > 100 {
+		return nil, h.resolverError(p.Context, core.NewError(core.ErrValidation, "invalid_limit", "limit must be a number between 1 and 100"))
+	}
+	if filter.Offset < 0 {
+		return nil, h.resolverError(p.Context, core.NewError(core.ErrValidation, "invalid_offset", "offset must be a non-negative number"))
+	}
+	if profile, ok := p.Args["profile"]; ok && profile != nil {
+		profileFilter, ok := profile.(map[string]interface{})
+		if !ok {
+			return nil, h.resolverError(p.Context, &requestError{status: http.StatusBadRequest, code: "profile_filter_not_object"})
+		}
+		filter.Profile = profileFilter
+	}
+
+	users, err := h.userService.ListUsers(p.Context, filter)
+	if err != nil {
+		return nil, h.resolverError(p.Context, err)
+	}
+	return users, nil
+}
+
+func (h *GraphQLHandler) resolveInvitations(p graphql.ResolveParams) (interface{}, error) {
+	if err := requireGraphQLRole(p.Context, core.RoleAdmin); err != nil {
+		return nil, h.resolverError(p.Context, err)
+	}
+	invitations, err := h.invitationService.ListPendingInvitations(p.Context)
+	if err != nil {
+		return nil, h.resolverError(p.Context, err)
+	}
+	return invitations, nil
+}
+
+func (h *GraphQLHandler) resolveUserImport(p graphql.ResolveParams) (interface{}, error) {
+	if err := requireGraphQLRole(p.Context, core.RoleAdmin); err != nil {
+		return nil, h.resolverError(p.Context, err)
+	}
+	id := p.Args["id"].(string)
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, h.resolverError(p.Context, core.NewError(core.ErrValidation, "invalid_import_id", "valid import id is required"))
+	}
+	userImport, err := h.userImportService.GetImport(p.Context, id)
+	if err != nil {
+		return nil, h.resolverError(p.Context, err)
+	}
+	return userImport, nil
+}
+
+func (h *GraphQLHandler) resolveUpdateProfile(p graphql.ResolveParams) (interface{}, error) {
+	id := p.Args["id"].(string)
+	// ... users may only edit their own profile
+	if userID, _ := p.Context.Value("user_id").(string); userID != id {
+		return nil, h.resolverError(p.Context, core.NewError(core.ErrForbidden, "profile_forbidden", "cannot update another user's profile"))
+	}
+	patch, ok := p.Args["patch"].(map[string]interface{})
+	if !ok {
+		return nil, h.resolverError(p.Context, &requestError{status: http.StatusBadRequest, code: "profile_patch_not_object"})
+	}
+
+	user, err := h.userService.UpdateUserProfile(p.Context, id, patch, int64(p.Args["version"].(int)))
+	if err != nil {
+		return nil, h.resolverError(p.Context, err)
+	}
+	return user, nil
+}
+
+func (h *GraphQLHandler) resolveCreateInvitation(p graphql.ResolveParams) (interface{}, error) {
+	if err := requireGraphQLRole(p.Context, core.RoleAdmin); err != nil {
+		return nil, h.resolverError(p.Context, err)
+	}
+	if err := blockGraphQLImpersonation(p.Context); err != nil {
+		return nil, h.resolverError(p.Context, err)
+	}
+	userID, _ := p.Context.Value("user_id").(string)
+	invitedBy, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, h.resolverError(p.Context, &requestError{status: http.StatusUnauthorized, code: "unauthorized"})
+	}
+
+	invitationReq := CreateInvitationRequest{Email: p.Args["email"].(string)}
+	if roles, ok := p.Args["roles"].([]interface{}); ok {
+		invitationReq.Roles = make([]string, 0, len(roles))
+		for _, role := range roles {
+			invitationReq.Roles = append(invitationReq.Roles, role.(string))
+		}
+	}
+	if expiresAt, ok := p.Args["expiresAt"].(time.Time); ok {
+		invitationReq.ExpiresAt = &expiresAt
+	}
+
+	now := time.Now()
+	if err := invitationReq.Validate(now); err != nil {
+		return nil, h.resolverError(p.Context, err)
+	}
+	expiresAt := now.Add(core.DefaultInvitationTTL)
+	if invitationReq.ExpiresAt != nil {
+		expiresAt = *invitationReq.ExpiresAt
+	}
+
+	invitation, err := h.invitationService.CreateInvitation(p.Context, strings.ToLower(invitationReq.Email), invitationReq.Roles, expiresAt, invitedBy)
+	if err != nil {
+		return nil, h.resolverError(p.Context, err)
+	}
+	return invitation, nil
+}
+
+func (h *GraphQLHandler) resolveRevokeInvitation(p graphql.ResolveParams) (interface{}, error) {
+	if err := requireGraphQLRole(p.Context, core.RoleAdmin); err != nil {
+		return nil, h.resolverError(p.Context, err)
+	}
+	if err := blockGraphQLImpersonation(p.Context); err != nil {
+		return nil, h.resolverError(p.Context, err)
+	}
+	id := p.Args["id"].(string)
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, h.resolverError(p.Context, core.NewError(core.ErrValidation, "invalid_invitation_id", "valid invitation id is required"))
+	}
+
+	invitation, err := h.invitationService.RevokeInvitation(p.Context, id)
+	if err != nil {
+		return nil, h.resolverError(p.Context, err)
+	}
+	return invitation, nil
+}
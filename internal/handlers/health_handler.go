@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go-rest-api/pkg/health"
+	"go-rest-api/pkg/logger"
+	"net/http"
+)
+
+type HealthHandler struct {
+	registry *health.Registry
+	Logger   logger.CustomLogger
+}
+
+func NewHealthHandler(registry *health.Registry, logger logger.CustomLogger) *HealthHandler {
+	return &HealthHandler{
+		registry: registry,
+		Logger:   logger,
+	}
+}
+
+// Livez reports that the process is up and serving. It checks no dependency,
+// since restarting the API would not bring a database back.
+func (h *HealthHandler) Livez(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", jsonContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(`{"status":"ok"}`))
+}
+
+// Readyz reports whether the API can serve requests, with the status and
+// latency of every dependency. It answers 503 Service Unavailable when a
+// check fails or a shutdown has begun. Why a check fails is only logged.
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	report := h.registry.Check(r.Context())
+
+	status := http.StatusOK
+	if report.Status != health.StatusOK {
+		status = http.StatusServiceUnavailable
+		failing := map[string]string{}
+		for name, result := range report.Checks {
+			if result.Status != health.StatusOK {
+				failing[name] = result.Error.Error()
+			}
+		}
+		h.Logger.FromContext(r.Context()).Warn("not ready", "failing_checks", failing, "shutting_down", report.ShuttingDown)
+	}
+
+	w.Header().Set("Content-Type", jsonContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"go-rest-api/pkg/health"
+	"go-rest-api/pkg/logger"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestHealthHandler_Livez(t *testing.T) {
+	a := assert.New(t)
+	// given
+	registry := health.NewRegistry(health.Options{Timeout: time.Second})
+	registry.Register(health.Check{Name: "postgres", Checker: health.CheckerFunc(func(ctx context.Context) error { return assert.AnError })})
+	res := httptest.NewRecorder()
+
+	// when
+	NewHealthHandler(registry, &logger.MockLogger{}).Livez(res, httptest.NewRequest(http.MethodGet, "/livez", nil))
+
+	// then
+	a.Equal(http.StatusOK, res.Code)
+	a.JSONEq(`{"status":"ok"}`, res.Body.String())
+}
+
+func TestHealthHandler_Readyz(t *testing.T) {
+	testScenarios := []struct {
+		name           string
+		kafkaErr       error
+		shutdown       bool
+		expectedStatus int
+		expectedReport health.Report
+	}{
+		{
+			name:           "ready",
+			expectedStatus: http.StatusOK,
+			expectedReport: health.Report{Status: health.StatusOK, Checks: map[string]health.Result{"postgres": {Status: health.StatusOK}, "kafka": {Status: health.StatusOK}}},
+		},
+		{
+			name:           "dependency down",
+			kafkaErr:       assert.AnError,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedReport: health.Report{Status: health.StatusFailing, Checks: map[string]health.Result{"postgres": {Status: health.StatusOK}, "kafka": {Status: health.StatusFailing}}},
+		},
+		{
+			name:           "shutting down",
+			shutdown:       true,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedReport: health.Report{Status: health.StatusFailing, ShuttingDown: true, Checks: map[string]health.Result{}},
+		},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			registry := health.NewRegistry(health.Options{Timeout: time.Second})
+			registry.Register(health.Check{Name: "postgres", Checker: health.CheckerFunc(func(ctx context.Context) error { return nil })})
+			registry.Register(health.Check{Name: "kafka", Checker: health.CheckerFunc(func(ctx context.Context) error { return scenario.kafkaErr })})
+			if scenario.shutdown {
+				registry.Shutdown()
+			}
+			mockLogger := &logger.MockLogger{}
+			mockLogger.On("Warn", "not ready", mock.Anything).Return()
+			res := httptest.NewRecorder()
+
+			// when
+			NewHealthHandler(registry, mockLogger).Readyz(res, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			// then
+			a.Equal(scenario.expectedStatus, res.Code)
+			a.Equal("no-store", res.Header().Get("Cache-Control"))
+			a.NotContains(res.Body.String(), "error")
+			var report health.Report
+			a.NoError(json.NewDecoder(res.Body).Decode(&report))
+			for name, result := range report.Checks {
+				a.False(result.CheckedAt.IsZero())
+				a.GreaterOrEqual(result.LatencyMs, 0.0)
+				report.Checks[name] = health.Result{Status: result.Status}
+			}
+			a.Equal(scenario.expectedReport, report)
+			if scenario.kafkaErr != nil {
+				mockLogger.AssertCalled(t, "Warn", "not ready", []interface{}{"failing_checks", map[string]string{"kafka": scenario.kafkaErr.Error()}, "shutting_down", false})
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"go-rest-api/internal/core"
+	"go-rest-api/pkg/logger"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	idempotencyRetryAfterSecs = 1
+)
+
+type IdempotencyService interface {
+	Begin(ctx context.Context, scope, key, fingerprint string) (*core.IdempotencyRecord, error)
+	Complete(ctx context.Context, scope, key string, statusCode int, headers map[string][]string, body []byte) error
+	Release(ctx context.Context, scope, key string) error
+}
+
+// IdempotencyMiddleware makes a POST endpoint safe to retry. Requests carrying
+// an Idempotency-Key header run once per key: retries with the same body get
+// the stored response replayed, a different body is rejected, and a retry
+// racing the original request is told to try again later. Server errors are
+// not stored, so the request can be retried with the same key.
+//
+// Keys are scoped to the caller, so wrap it inside AuthMiddleware on
+// authenticated routes.
+func IdempotencyMiddleware(next httprouter.Handle, idempotencyService IdempotencyService, logger logger.CustomLogger) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			next(w, r, ps)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			writeError(w, r, logger, &requestError{http.StatusBadRequest, "invalid_idempotency_key", map[string]string{"max": strconv.Itoa(maxIdempotencyKeyLength)}})
+			return
+		}
+
+		// ... read no more than the handler would accept and hand the rest on,
+		// so oversized bodies are still rejected by the handler itself
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBodySize+1))
+		if err != nil {
+			writeError(w, r, logger, &requestError{status: http.StatusBadRequest, code: "body_invalid"})
+			return
+		}
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+
+		scope := idempotencyScope(r)
+		record, err := idempotencyService.Begin(r.Context(), scope, key, requestFingerprint(r, body))
+		if err != nil {
+			if errors.Is(err, core.ErrIdempotencyRequestInProgress) {
+				w.Header().Set("Retry-After", strconv.Itoa(idempotencyRetryAfterSecs))
+			}
+			writeError(w, r, logger, err)
+			return
+		}
+		if record != nil {
+			replayResponse(w, record)
+			return
+		}
+
+		recorder := &recordingWriter{ResponseWriter: w, status: http.StatusOK, before: w.Header().Clone()}
+		completed := false
+		defer func() {
+			// ... store the response even if the client has gone away
+			ctx := context.WithoutCancel(r.Context())
+			if !completed || recorder.status >= http.StatusInternalServerError {
+				idempotencyService.Release(ctx, scope, key)
+				return
+			}
+			idempotencyService.Complete(ctx, scope, key, recorder.status, recorder.changedHeaders(), recorder.body.Bytes())
+		}()
+		next(recorder, r, ps)
+		completed = true
+	}
+}
+
+// idempotencyScope keeps keys of different callers, endpoints and API
+// versions apart, since versions answer the same request differently.
+func idempotencyScope(r *http.Request) string {
+	userID, _ := r.Context().Value("user_id").(string)
+	if userID == "" {
+		userID = "anonymous"
+	}
+	return userID + " " + r.Method + " /" + requestAPIVersion(r) + r.URL.Path
+}
+
+// requestFingerprint identifies what a request asks for: besides its target
+// and body, the encoding and language negotiated for the response, since a
+// replay must not answer a retry in another representation.
+func requestFingerprint(r *http.Request, body []byte) string {
+	representation := r.Header.Get("Accept")
+	if encoder, err := negotiateEncoder(r); err == nil {
+		representation = encoder.ContentType()
+	}
+	hash := sha256.New()
+	io.WriteString(hash, r.Method+" "+r.URL.RequestURI()+"\n")
+	io.WriteString(hash, representation+" "+requestLocale(r)+"\n")
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func replayResponse(w http.ResponseWriter, record *core.IdempotencyRecord) {
+	for name, values := range record.Headers {
+		w.Header()[name] = values
+	}
+	w.Header().Set(idempotentReplayedHeader, "true")
+	w.WriteHeader(record.StatusCode)
+	w.Write(record.Body)
+}
+
+// recordingWriter passes the response through while keeping a copy of it.
+type recordingWriter struct {
+	http.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	before      http.Header
+	wroteHeader bool
+}
+
+func (rw *recordingWriter) WriteHeader(status int) {
+	if !rw.wroteHeader {
+		rw.status = status
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
+
+// unstoredHeaders describe how outer middleware, such as
+// CompressionMiddleware, encoded the body for this particular request. The
+// stored body is the one the handler wrote, so replays get them afresh.
+var unstoredHeaders = []string{requestIDHeader, "Content-Encoding", "Content-Length", "Vary"}
+
+// changedHeaders returns the headers set by the handler, leaving out those
+// that outer middleware set for this particular request, like X-Request-ID.
+func (rw *recordingWriter) changedHeaders() map[string][]string {
+	headers := map[string][]string{}
+	for name, values := range rw.Header() {
+		if slices.Contains(unstoredHeaders, name) || slices.Equal(rw.before[name], values) {
+			continue
+		}
+		headers[name] = values
+	}
+	return headers
+}
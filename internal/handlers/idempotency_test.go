@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"go-rest-api/internal/core"
+	"go-rest-api/pkg/logger"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newIdempotentRequest(key, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/invitations/accept", bytes.NewBufferString(body))
+	req.Header.Set(idempotencyKeyHeader, key)
+	return req
+}
+
+func TestIdempotencyMiddleware_StoresResponse(t *testing.T) {
+	a := assert.New(t)
+	// given
+	repo := core.MockIdempotencyRepository{}
+	repo.On("ReserveIdempotencyKey", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+	repo.On("CompleteIdempotencyKey", mock.Anything, "anonymous POST /v1/invitations/accept", "key-1", http.StatusCreated,
+		map[string][]string{"Content-Type": {"application/json"}}, []byte(`{"id":1}`), mock.Anything).Return(nil)
+	service := core.NewIdempotencyService(&repo, &logger.MockLogger{}, time.Hour)
+	var receivedBody []byte
+	handler := IdempotencyMiddleware(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		json.NewDecoder(r.Body).Decode(&receivedBody)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":1}`))
+	}, service, &logger.MockLogger{})
+
+	// when
+	res := httptest.NewRecorder()
+	res.Header().Set(requestIDHeader, "req-1")
+	handler(res, newIdempotentRequest("key-1", `"aGk="`), nil)
+
+	// then
+	a.Equal(http.StatusCreated, res.Code)
+	a.Equal([]byte("hi"), receivedBody)
+	repo.AssertExpectations(t)
+}
+
+func TestIdempotencyMiddleware_ReplaysResponse(t *testing.T) {
+	a := assert.New(t)
+	// given
+	req := newIdempotentRequest("key-1", `{"token":"t"}`)
+	completedAt := time.Now()
+	repo := core.MockIdempotencyRepository{}
+	repo.On("ReserveIdempotencyKey", mock.Anything, mock.Anything, mock.Anything).Return(&core.IdempotencyRecord{
+		Fingerprint: requestFingerprint(req, []byte(`{"token":"t"}`)),
+		StatusCode:  http.StatusCreated,
+		Headers:     map[string][]string{"Content-Type": {"application/json"}},
+		Body:        []byte(`{"id":1}`),
+		CompletedAt: &completedAt,
+	}, nil)
+	service := core.NewIdempotencyService(&repo, &logger.MockLogger{}, time.Hour)
+	called := false
+	handler := IdempotencyMiddleware(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		called = true
+	}, service, &logger.MockLogger{})
+
+	// when
+	res := httptest.NewRecorder()
+	handler(res, req, nil)
+
+	// then
+	a.False(called)
+	a.Equal(http.StatusCreated, res.Code)
+	a.Equal(`{"id":1}`, res.Body.String())
+	a.Equal("application/json", res.Header().Get("Content-Type"))
+	a.Equal("true", res.Header().Get(idempotentReplayedHeader))
+}
+
+func TestIdempotencyMiddleware_ReplaysThroughCompression(t *testing.T) {
+	a := assert.New(t)
+	// given
+	body := `{"data":"` + strings.Repeat("a", 2*compressionMinSize) + `"}`
+	stored := &core.IdempotencyRecord{Fingerprint: requestFingerprint(newIdempotentRequest("key-1", `{}`), []byte(`{}`))}
+	repo := core.MockIdempotencyRepository{}
+	repo.On("ReserveIdempotencyKey", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil).Once()
+	repo.On("ReserveIdempotencyKey", mock.Anything, mock.Anything, mock.Anything).Return(stored, nil)
+	repo.On("CompleteIdempotencyKey", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		completedAt := time.Now()
+		stored.StatusCode = args.Int(3)
+		stored.Headers = args.Get(4).(map[string][]string)
+		stored.Body = args.Get(5).([]byte)
+		stored.CompletedAt = &completedAt
+	}).Return(nil)
+	service := core.NewIdempotencyService(&repo, &logger.MockLogger{}, time.Hour)
+	handle := IdempotencyMiddleware(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(body))
+	}, service, &logger.MockLogger{})
+	handler := CompressionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handle(w, r, nil)
+	}))
+	first := newIdempotentRequest("key-1", `{}`)
+	first.Header.Set("Accept-Encoding", "gzip")
+	handler.ServeHTTP(httptest.NewRecorder(), first)
+
+	// when
+	compressed := newIdempotentRequest("key-1", `{}`)
+	compressed.Header.Set("Accept-Encoding", "gzip")
+	compressedRes := httptest.NewRecorder()
+	handler.ServeHTTP(compressedRes, compressed)
+	plainRes := httptest.NewRecorder()
+	handler.ServeHTTP(plainRes, newIdempotentRequest("key-1", `{}`))
+
+	// then
+	a.NotContains(stored.Headers, "Content-Encoding")
+	a.NotContains(stored.Headers, "Vary")
+	a.Equal(body, string(stored.Body))
+	a.Equal("true", compressedRes.Header().Get(idempotentReplayedHeader))
+	a.Equal("gzip", compressedRes.Header().Get("Content-Encoding"))
+	reader, err := gzip.NewReader(compressedRes.Body)
+	a.NoError(err)
+	decompressed, _ := io.ReadAll(reader)
+	a.Equal(body, string(decompressed))
+	a.Equal("true", plainRes.Header().Get(idempotentReplayedHeader))
+	a.Empty(plainRes.Header().Get("Content-Encoding"))
+	a.Equal(body, plainRes.Body.String())
+	a.Equal([]string{"Accept-Encoding"}, plainRes.Header().Values("Vary"))
+}
+
+func TestIdempotencyMiddleware_Rejections(t *testing.T) {
+	completedAt := time.Now()
+	testScenarios := []struct {
+		name           string
+		existing       *core.IdempotencyRecord
+		expectedStatus int
+		expectedCode   string
+	}{
+		{name: "different body", existing: &core.IdempotencyRecord{Fingerprint: "other", CompletedAt: &completedAt}, expectedStatus: http.StatusUnprocessableEntity, expectedCode: "idempotency_key_reused"},
+		{name: "in flight", existing: nil, expectedStatus: http.StatusConflict, expectedCode: "idempotency_request_in_progress"},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			req := newIdempotentRequest("key-1", `{}`)
+			existing := scenario.existing
+			if existing == nil {
+				existing = &core.IdempotencyRecord{Fingerprint: requestFingerprint(req, []byte(`{}`))}
+			}
+			repo := core.MockIdempotencyRepository{}
+			repo.On("ReserveIdempotencyKey", mock.Anything, mock.Anything, mock.Anything).Return(existing, nil)
+			service := core.NewIdempotencyService(&repo, &logger.MockLogger{}, time.Hour)
+			handler := IdempotencyMiddleware(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+				t.Fatal("handler must not run")
+			}, service, &logger.MockLogger{})
+
+			// when
+			res := httptest.NewRecorder()
+			handler(res, req, nil)
+
+			// then
+			a.Equal(scenario.expectedStatus, res.Code)
+			var problem Problem
+			a.NoError(json.Unmarshal(res.Body.Bytes(), &problem))
+			a.Equal(scenario.expectedCode, problem.Code)
+		})
+	}
+}
+
+func TestIdempotencyMiddleware_ReleasesKeyOnServerError(t *testing.T) {
+	// given
+	repo := core.MockIdempotencyRepository{}
+	repo.On("ReserveIdempotencyKey", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+	repo.On("DeleteIdempotencyKey", mock.Anything, "anonymous POST /v1/invitations/accept", "key-1").Return(nil)
+	service := core.NewIdempotencyService(&repo, &logger.MockLogger{}, time.Hour)
+	handler := IdempotencyMiddleware(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}, service, &logger.MockLogger{})
+
+	// when
+	handler(httptest.NewRecorder(), newIdempotentRequest("key-1", `{}`), nil)
+
+	// then
+	repo.AssertExpectations(t)
+	repo.AssertNotCalled(t, "CompleteIdempotencyKey", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestIdempotencyScope(t *testing.T) {
+	testScenarios := []struct {
+		name          string
+		prefix        string
+		accept        string
+		expectedScope string
+	}{
+		{name: "root", expectedScope: "anonymous POST /v1/users"},
+		{name: "v1 prefix", prefix: "v1", expectedScope: "anonymous POST /v1/users"},
+		{name: "v2 prefix", prefix: "v2", expectedScope: "anonymous POST /v2/users"},
+		{name: "v2 by accept", accept: "application/vnd.gorestapi.v2+json", expectedScope: "anonymous POST /v2/users"},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			var scope string
+			handler := VersionMiddleware(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+				scope = idempotencyScope(r)
+			}, []APIVersion{{Name: "v1"}, {Name: "v2"}}, scenario.prefix, "/users", "POST")
+			path := "/users"
+			if scenario.prefix != "" {
+				path = "/" + scenario.prefix + path
+			}
+			req := httptest.NewRequest(http.MethodPost, path, nil)
+			if scenario.accept != "" {
+				req.Header.Set("Accept", scenario.accept)
+			}
+
+			// when
+			handler(httptest.NewRecorder(), req, nil)
+
+			// then
+			a.Equal(scenario.expectedScope, scope)
+		})
+	}
+}
+
+func TestRequestFingerprint(t *testing.T) {
+	testScenarios := []struct {
+		name           string
+		accept         string
+		acceptLanguage string
+		body           string
+		expectedSame   bool
+	}{
+		{name: "same request", expectedSame: true},
+		{name: "same negotiated encoding", accept: "application/json, */*;q=0.1", expectedSame: true},
+		{name: "other body", body: `{"token":"u"}`},
+		{name: "other encoding", accept: "application/msgpack"},
+		{name: "other locale", acceptLanguage: "fr"},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			original := newIdempotentRequest("key-1", `{"token":"t"}`)
+			body := `{"token":"t"}`
+			if scenario.body != "" {
+				body = scenario.body
+			}
+			retry := newIdempotentRequest("key-1", body)
+			if scenario.accept != "" {
+				retry.Header.Set("Accept", scenario.accept)
+			}
+			if scenario.acceptLanguage != "" {
+				retry.Header.Set("Accept-Language", scenario.acceptLanguage)
+			}
+
+			// when
+			originalFingerprint := requestFingerprint(original, []byte(`{"token":"t"}`))
+			retryFingerprint := requestFingerprint(retry, []byte(body))
+
+			// then
+			a.Equal(scenario.expectedSame, originalFingerprint == retryFingerprint)
+		})
+	}
+}
+
+func TestIdempotencyMiddleware_WithoutKey(t *testing.T) {
+	// given
+	called := false
+	handler := IdempotencyMiddleware(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		called = true
+	}, nil, &logger.MockLogger{})
+
+	// when
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/users", nil), nil)
+
+	// then
+	assert.True(t, called)
+}
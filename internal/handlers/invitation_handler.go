@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"context"
+	"go-rest-api/internal/core"
+	"go-rest-api/pkg/logger"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type InvitationService interface {
+	CreateInvitation(ctx context.Context, email string, roles []string, expiresAt time.Time, invitedBy uuid.UUID) (*core.Invitation, error)
+	ListPendingInvitations(ctx context.Context) ([]*core.Invitation, error)
+	RevokeInvitation(ctx context.Context, id string) (*core.Invitation, error)
+	AcceptInvitation(ctx context.Context, token, username, password string) (*core.User, error)
+}
+
+type InvitationHandler struct {
+	invitationService InvitationService
+	Logger            logger.CustomLogger
+}
+
+func NewInvitationHandler(invitationService InvitationService, logger logger.CustomLogger) *InvitationHandler {
+	return &InvitationHandler{
+		invitationService: invitationService,
+		Logger:            logger,
+	}
+}
+
+func ToInvitationResponse(i core.Invitation) InvitationResponse {
+	return InvitationResponse{
+		Id:        i.ID,
+		Email:     i.Email,
+		Roles:     i.Roles,
+		InvitedBy: i.InvitedBy,
+		ExpiresAt: i.ExpiresAt,
+		CreatedAt: i.CreatedAt,
+	}
+}
+
+// Validate checks the validate tags and that the expiry, which depends on the
+// current time, lies within the allowed window.
+func (req *CreateInvitationRequest) Validate(now time.Time) error {
+	if err := validateStruct(req); err != nil {
+		return err
+	}
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(now) {
+			return &ValidationError{Fields: []FieldError{newFieldError("expires_at", "future", "validation.future", nil)}}
+		}
+		if req.ExpiresAt.After(now.Add(core.MaxInvitationTTL)) {
+			return &ValidationError{Fields: []FieldError{newFieldError("expires_at", "max_ttl", "validation.max_ttl", nil)}}
+		}
+	}
+	return nil
+}
+
+func (req *AcceptInvitationRequest) Validate() error {
+	return validateStruct(req)
+}
+
+func (h *InvitationHandler) CreateInvitation(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	encoder, err := negotiateEncoderOf(r, jsonContentType, msgpackContentType)
+	if err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
+	userID, _ := r.Context().Value("user_id").(string)
+	invitedBy, err := uuid.Parse(userID)
+	if err != nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var invitationReq CreateInvitationRequest
+	if err := decodeJSON(w, r, &invitationReq); err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
+	now := time.Now()
+	if err := invitationReq.Validate(now); err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
+	expiresAt := now.Add(core.DefaultInvitationTTL)
+	if invitationReq.ExpiresAt != nil {
+		expiresAt = *invitationReq.ExpiresAt
+	}
+
+	invitation, err := h.invitationService.CreateInvitation(ctx, strings.ToLower(invitationReq.Email), invitationReq.Roles, expiresAt, invitedBy)
+	if err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
+	writeResponse(w, r, h.Logger, encoder, http.StatusCreated, ToInvitationResponse(*invitation))
+}
+
+func (h *InvitationHandler) ListInvitations(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	encoder, err := negotiateEncoderOf(r, jsonContentType, msgpackContentType)
+	if err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
+	invitations, err := h.invitationService.ListPendingInvitations(ctx)
+	if err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
+	response := ListInvitationsResponse{Invitations: make([]InvitationResponse, 0, len(invitations))}
+	for _, invitation := range invitations {
+		response.Invitations = append(response.Invitations, ToInvitationResponse(*invitation))
+	}
+
+	writeResponse(w, r, h.Logger, encoder, http.StatusOK, response)
+}
+
+func (h *InvitationHandler) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	id := r.URL.Path[len("/invitations/"):]
+	if _, err := uuid.Parse(id); err != nil {
+		writeError(w, r, h.Logger, core.NewError(core.ErrValidation, "invalid_invitation_id", "valid invitation id is required"))
+		return
+	}
+
+	if _, err := h.invitationService.RevokeInvitation(ctx, id); err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *InvitationHandler) AcceptInvitation(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	encoder, err := negotiateEncoder(r)
+	if err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
+	var acceptReq AcceptInvitationRequest
+	if err := decodeJSON(w, r, &acceptReq); err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
+	if err := acceptReq.Validate(); err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
+	user, err := h.invitationService.AcceptInvitation(ctx, acceptReq.Token, acceptReq.Username, acceptReq.Password)
+	if err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
+	writeResponse(w, r, h.Logger, encoder, http.StatusCreated, userResponse(r, *user))
+}
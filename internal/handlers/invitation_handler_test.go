@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"go-rest-api/internal/core"
+	"go-rest-api/internal/db"
+	"go-rest-api/pkg/logger"
+	"go-rest-api/pkg/schema"
+	"go-rest-api/test"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type InvitationHandlerTestSuite struct {
+	suite.Suite
+	invitationHandler *InvitationHandler
+	mailer            *core.MockMailer
+	adminID           uuid.UUID
+	dbPool            *pgxpool.Pool
+	tearDown          func()
+}
+
+func (testSuite *InvitationHandlerTestSuite) SetupSuite() {
+	ctx := context.Background()
+	t := testSuite.T()
+	dbPool, teardown := test.CreateDbTestContainer(ctx, t)
+	testSuite.dbPool = dbPool
+	testSuite.tearDown = teardown
+
+	mockLogger := logger.MockLogger{}
+	mockLogger.On("Error", mock.Anything, mock.Anything).Return()
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+	mockUserEvent := core.MockUserEventService{}
+	mockUserEvent.On("PublishUserCreatedEvent", mock.Anything, mock.Anything).Return(nil)
+	profileValidator, err := schema.LoadProfileValidator("")
+	if err != nil {
+		t.Fatalf("Failed to load profile schema: %v", err)
+	}
+
+	userRepo := db.NewUserRepository(dbPool, &mockLogger)
+	userServ := core.NewUserService(userRepo, &mockLogger, &mockUserEvent, &core.MockBlobStore{}, profileValidator)
+	testSuite.mailer = &core.MockMailer{}
+	invitationServ := core.NewInvitationService(db.NewInvitationRepository(dbPool, &mockLogger), userRepo, userServ, testSuite.mailer, &mockLogger, "https://app.example.com/accept")
+	testSuite.invitationHandler = NewInvitationHandler(invitationServ, &mockLogger)
+
+	testSuite.adminID = uuid.New()
+	const query = `INSERT INTO users (id, username, email, password, roles) VALUES ($1, $2, $3, $4, $5)`
+	if _, err := dbPool.Exec(ctx, query, testSuite.adminID, "invitationadmin", "invitationadmin@gmail.com", "password123", []string{core.RoleAdmin}); err != nil {
+		t.Fatalf("Failed to create admin user: %v", err)
+	}
+}
+
+func (testSuite *InvitationHandlerTestSuite) TearDownSuite() {
+	if testSuite.tearDown != nil {
+		testSuite.tearDown()
+	}
+}
+
+func (testSuite *InvitationHandlerTestSuite) newRouter() *httprouter.Router {
+	router := httprouter.New()
+	router.POST("/invitations", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		testSuite.invitationHandler.CreateInvitation(w, r)
+	})
+	router.POST("/invitations/accept", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		testSuite.invitationHandler.AcceptInvitation(w, r)
+	})
+	router.DELETE("/invitations/:id", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		testSuite.invitationHandler.RevokeInvitation(w, r)
+	})
+	return router
+}
+
+func (testSuite *InvitationHandlerTestSuite) createInvitation(router *httprouter.Router, email string) (*httptest.ResponseRecorder, string) {
+	var mailBody string
+	testSuite.mailer.On("SendMail", mock.Anything, email, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		mailBody = args.String(3)
+	}).Return(nil).Once()
+
+	reqBody, _ := json.Marshal(CreateInvitationRequest{Email: email, Roles: []string{core.RoleAdmin}})
+	req := httptest.NewRequest(http.MethodPost, "/invitations", bytes.NewBuffer(reqBody))
+	req = req.WithContext(context.WithValue(req.Context(), "user_id", testSuite.adminID.String()))
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	token := ""
+	if parts := strings.SplitN(mailBody, "token=", 2); len(parts) == 2 {
+		token = strings.Fields(parts[1])[0]
+	}
+	return res, token
+}
+
+func (testSuite *InvitationHandlerTestSuite) TestAcceptInvitation() {
+	t := testSuite.T()
+	a := assert.New(t)
+
+	// given
+	router := testSuite.newRouter()
+	createRes, token := testSuite.createInvitation(router, "newhire@gmail.com")
+	a.Equal(http.StatusCreated, createRes.Code)
+	a.NotEmpty(token)
+
+	// when
+	acceptBody, _ := json.Marshal(AcceptInvitationRequest{Token: token, Username: "newhire", Password: "password123"})
+	acceptReq := httptest.NewRequest(http.MethodPost, "/invitations/accept", bytes.NewBuffer(acceptBody))
+	acceptRes := httptest.NewRecorder()
+	router.ServeHTTP(acceptRes, acceptReq)
+
+	// then
+	a.Equal(http.StatusCreated, acceptRes.Code)
+	a.Equal("application/json", acceptRes.Header().Get("Content-Type"))
+	var user UserResponse
+	a.NoError(json.Unmarshal(acceptRes.Body.Bytes(), &user))
+	a.Equal("newhire@gmail.com", user.Email)
+	a.Equal([]string{core.RoleAdmin}, user.Roles)
+
+	// when ... the same invitation is accepted again
+	replayReq := httptest.NewRequest(http.MethodPost, "/invitations/accept", bytes.NewBuffer(acceptBody))
+	replayRes := httptest.NewRecorder()
+	router.ServeHTTP(replayRes, replayReq)
+
+	// then
+	a.Equal(http.StatusGone, replayRes.Code)
+}
+
+func (testSuite *InvitationHandlerTestSuite) TestRevokeInvitation() {
+	t := testSuite.T()
+	a := assert.New(t)
+
+	// given
+	router := testSuite.newRouter()
+	createRes, token := testSuite.createInvitation(router, "revoked@gmail.com")
+	a.Equal(http.StatusCreated, createRes.Code)
+	var invitation InvitationResponse
+	a.NoError(json.Unmarshal(createRes.Body.Bytes(), &invitation))
+
+	// when
+	revokeReq := httptest.NewRequest(http.MethodDelete, "/invitations/"+invitation.Id.String(), nil)
+	revokeRes := httptest.NewRecorder()
+	router.ServeHTTP(revokeRes, revokeReq)
+
+	// then
+	a.Equal(http.StatusNoContent, revokeRes.Code)
+	acceptBody, _ := json.Marshal(AcceptInvitationRequest{Token: token, Username: "revoked", Password: "password123"})
+	acceptReq := httptest.NewRequest(http.MethodPost, "/invitations/accept", bytes.NewBuffer(acceptBody))
+	acceptRes := httptest.NewRecorder()
+	router.ServeHTTP(acceptRes, acceptReq)
+	a.Equal(http.StatusGone, acceptRes.Code)
+}
+
+func TestCreateInvitationRequest_Validate(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	tooFar := now.Add(core.MaxInvitationTTL + time.Hour)
+	testScenarios := []struct {
+		name    string
+		request CreateInvitationRequest
+	}{
+		{name: "invalid email", request: CreateInvitationRequest{Email: "invalid-email"}},
+		{name: "unknown role", request: CreateInvitationRequest{Email: "a@b.com", Roles: []string{"superuser"}}},
+		{name: "expiry in the past", request: CreateInvitationRequest{Email: "a@b.com", ExpiresAt: &past}},
+		{name: "expiry too far", request: CreateInvitationRequest{Email: "a@b.com", ExpiresAt: &tooFar}},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			assert.Error(t, scenario.request.Validate(now))
+		})
+	}
+}
+
+func TestInvitationHandlerTestSuite(t *testing.T) {
+	suite.Run(t, new(InvitationHandlerTestSuite))
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"go-rest-api/pkg/logger"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type LogLevelHandler struct {
+	levels *logger.Levels
+	// overrideTTL is how long a level set without a ttl lasts.
+	overrideTTL time.Duration
+	Logger      logger.CustomLogger
+}
+
+func NewLogLevelHandler(levels *logger.Levels, overrideTTL time.Duration, logger logger.CustomLogger) *LogLevelHandler {
+	return &LogLevelHandler{
+		levels:      levels,
+		overrideTTL: overrideTTL,
+		Logger:      logger,
+	}
+}
+
+func ToLogLevelResponse(l logger.ComponentLevel) LogLevelResponse {
+	response := LogLevelResponse{Component: l.Component, Level: l.Level, ConfiguredLevel: l.ConfiguredLevel}
+	if !l.ExpiresAt.IsZero() {
+		expiresAt := l.ExpiresAt
+		response.ExpiresAt = &expiresAt
+	}
+	return response
+}
+
+// Validate checks the validate tags and that the ttl is a duration that is
+// not negative.
+func (req *SetLogLevelRequest) Validate() error {
+	if err := validateStruct(req); err != nil {
+		return err
+	}
+	if req.TTL != nil {
+		if ttl, err := time.ParseDuration(*req.TTL); err != nil || ttl < 0 {
+			return &ValidationError{Fields: []FieldError{newFieldError("ttl", "format", "validation.format", map[string]string{"param": "duration"})}}
+		}
+	}
+	return nil
+}
+
+func (h *LogLevelHandler) ListLogLevels(w http.ResponseWriter, r *http.Request) {
+	response := ListLogLevelsResponse{Components: []LogLevelResponse{}}
+	for _, level := range h.levels.Components() {
+		response.Components = append(response.Components, ToLogLevelResponse(level))
+	}
+
+	w.Header().Set("Content-Type", jsonContentType)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// SetLogLevel changes the level of the component named in the path, such as
+// /admin/log-levels/db.
+func (h *LogLevelHandler) SetLogLevel(w http.ResponseWriter, r *http.Request) {
+	component := strings.TrimPrefix(r.URL.Path, "/admin/log-levels/")
+
+	var levelReq SetLogLevelRequest
+	if err := decodeJSON(w, r, &levelReq); err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+	if err := levelReq.Validate(); err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+	ttl := h.overrideTTL
+	if levelReq.TTL != nil {
+		ttl, _ = time.ParseDuration(*levelReq.TTL)
+	}
+
+	level, err := h.levels.Set(component, levelReq.Level, ttl)
+	if err != nil {
+		if errors.Is(err, logger.ErrUnknownComponent) {
+			err = &requestError{status: http.StatusNotFound, code: "log_component_not_found"}
+		}
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
+	userID, _ := r.Context().Value("user_id").(string)
+	h.Logger.FromContext(r.Context()).Warn("log level changed", "component", component, "level", levelReq.Level, "ttl", ttl, "user_id", userID)
+
+	w.Header().Set("Content-Type", jsonContentType)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(ToLogLevelResponse(level))
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"go-rest-api/pkg/logger"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newLogLevelHandler(t *testing.T) *LogLevelHandler {
+	l, err := logger.NewLogger(logger.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Named("db")
+	mockLogger := &logger.MockLogger{}
+	mockLogger.On("Warn", mock.Anything, mock.Anything).Return()
+	mockLogger.On("Error", mock.Anything, mock.Anything).Return()
+	return NewLogLevelHandler(l.Levels(), 15*time.Minute, mockLogger)
+}
+
+func TestLogLevelHandler_ListLogLevels(t *testing.T) {
+	a := assert.New(t)
+	// given
+	req := httptest.NewRequest(http.MethodGet, "/admin/log-levels", nil)
+	res := httptest.NewRecorder()
+
+	// when
+	newLogLevelHandler(t).ListLogLevels(res, req)
+
+	// then
+	a.Equal(http.StatusOK, res.Code)
+	var response ListLogLevelsResponse
+	a.NoError(json.NewDecoder(res.Body).Decode(&response))
+	a.Equal([]LogLevelResponse{
+		{Component: "db", Level: "info", ConfiguredLevel: "info"},
+		{Component: "default", Level: "info", ConfiguredLevel: "info"},
+	}, response.Components)
+}
+
+func TestLogLevelHandler_SetLogLevel(t *testing.T) {
+	testScenarios := []struct {
+		name                    string
+		component               string
+		body                    string
+		expectedStatus          int
+		expectedCode            string
+		expectedConfiguredLevel string
+		expectedExpiry          bool
+	}{
+		{name: "default ttl", component: "db", body: `{"level":"debug"}`, expectedStatus: http.StatusOK, expectedConfiguredLevel: "info", expectedExpiry: true},
+		{name: "until restart", component: "db", body: `{"level":"debug","ttl":"0s"}`, expectedStatus: http.StatusOK, expectedConfiguredLevel: "debug"},
+		{name: "unknown component", component: "cache", body: `{"level":"debug"}`, expectedStatus: http.StatusNotFound, expectedCode: "log_component_not_found"},
+		{name: "unknown level", component: "db", body: `{"level":"verbose"}`, expectedStatus: http.StatusBadRequest, expectedCode: "validation_failed"},
+		{name: "invalid ttl", component: "db", body: `{"level":"debug","ttl":"soon"}`, expectedStatus: http.StatusBadRequest, expectedCode: "validation_failed"},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			req := httptest.NewRequest(http.MethodPut, "/admin/log-levels/"+scenario.component, bytes.NewBufferString(scenario.body))
+			res := httptest.NewRecorder()
+
+			// when
+			newLogLevelHandler(t).SetLogLevel(res, req)
+
+			// then
+			a.Equal(scenario.expectedStatus, res.Code)
+			if scenario.expectedCode != "" {
+				var problem Problem
+				a.NoError(json.NewDecoder(res.Body).Decode(&problem))
+				a.Equal(scenario.expectedCode, problem.Code)
+				return
+			}
+			var response LogLevelResponse
+			a.NoError(json.NewDecoder(res.Body).Decode(&response))
+			a.Equal(scenario.component, response.Component)
+			a.Equal("debug", response.Level)
+			a.Equal(scenario.expectedConfiguredLevel, response.ConfiguredLevel)
+			a.Equal(scenario.expectedExpiry, response.ExpiresAt != nil)
+		})
+	}
+}
@@ -2,27 +2,39 @@ package handlers
 
 import (
 	"context"
+	"go-rest-api/internal/i18n"
 	"go-rest-api/internal/metrics"
 	"go-rest-api/pkg/logger"
+	"io"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/julienschmidt/httprouter"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func AuthMiddleware(next httprouter.Handle, jwtKey string, logger logger.CustomLogger) httprouter.Handle {
+// AuthMiddleware authenticates requests with the JWT of the Authorization
+// header. Every request made with an impersonation token is written to
+// auditLogger, which should be unsampled so that none is lost.
+func AuthMiddleware(next httprouter.Handle, jwtKey string, logger, auditLogger logger.CustomLogger) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		logger := logger.FromContext(r.Context())
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			writeProblem(w, r, http.StatusUnauthorized, "auth_header_required")
 			return
 		}
 
 		if !strings.HasPrefix(authHeader, "Bearer ") {
 			logger.Error("Authorization header is missing")
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			writeProblem(w, r, http.StatusUnauthorized, "auth_scheme_invalid")
 			return
 		}
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
@@ -35,22 +47,59 @@ func AuthMiddleware(next httprouter.Handle, jwtKey string, logger logger.CustomL
 		})
 
 		if err != nil || !token.Valid {
-			logger.Error("Invalid token: ", err)
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			logger.Error("Invalid token", "error", err)
+			writeProblem(w, r, http.StatusUnauthorized, "invalid_token")
 			return
 		}
 
 		claims, ok := token.Claims.(jwt.MapClaims)
 		if !ok || !token.Valid {
 			logger.Error("Invalid token claims")
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			writeProblem(w, r, http.StatusUnauthorized, "invalid_token")
 			return
 		}
 
-		userID := claims["user_id"].(string)
+		// ... impersonation tokens carry the target in "sub" as well
+		userID, _ := claims["user_id"].(string)
+		if userID == "" {
+			userID, _ = claims["sub"].(string)
+		}
+		if userID == "" {
+			logger.Error("Token has no subject")
+			writeProblem(w, r, http.StatusUnauthorized, "token_subject_missing")
+			return
+		}
+
+		var roles []string
+		if claimedRoles, ok := claims["roles"].([]interface{}); ok {
+			for _, role := range claimedRoles {
+				if roleName, ok := role.(string); ok {
+					roles = append(roles, roleName)
+				}
+			}
+		}
 
-		// ... add userID to context
+		// ... add userID and roles to context
 		ctx := context.WithValue(r.Context(), "user_id", userID)
+		ctx = context.WithValue(ctx, "roles", roles)
+		if entry := requestAccessLogEntry(r); entry != nil {
+			entry.userID = userID
+		}
+
+		// ... expose the impersonating admin and audit every request they make
+		if actor, ok := claims["act"].(map[string]interface{}); ok {
+			actorID, _ := actor["sub"].(string)
+			if actorID == "" {
+				logger.Error("Invalid actor claim")
+				writeProblem(w, r, http.StatusUnauthorized, "invalid_actor_claim")
+				return
+			}
+			ctx = context.WithValue(ctx, "actor_id", actorID)
+			if entry := requestAccessLogEntry(r); entry != nil {
+				entry.actorID = actorID
+			}
+			auditLogger.FromContext(r.Context()).Info("impersonated request", "actor_id", actorID, "subject_id", userID, "method", r.Method, "path", r.URL.Path)
+		}
 		r = r.WithContext(ctx)
 
 		// ... call next handler
@@ -58,29 +107,204 @@ func AuthMiddleware(next httprouter.Handle, jwtKey string, logger logger.CustomL
 	}
 }
 
+// RequireRole rejects requests whose token does not carry the given role. It
+// must be wrapped by AuthMiddleware, which puts the roles into the context.
+func RequireRole(next httprouter.Handle, role string) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		roles, _ := r.Context().Value("roles").([]string)
+		if !slices.Contains(roles, role) {
+			writeError(w, r, nil, &requestError{http.StatusForbidden, "role_required", map[string]string{"role": role}})
+			return
+		}
+		next(w, r, ps)
+	}
+}
+
+// BlockImpersonation rejects requests made with an impersonation token. Wrap
+// sensitive endpoints (credential changes, MFA, admin actions) with it so that
+// support staff cannot act on them while impersonating a user.
+func BlockImpersonation(next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if actorID, _ := r.Context().Value("actor_id").(string); actorID != "" {
+			writeProblem(w, r, http.StatusForbidden, "impersonation_not_allowed")
+			return
+		}
+		next(w, r, ps)
+	}
+}
+
+// LocaleMiddleware negotiates the response language from Accept-Language and
+// stores it in the request context for error messages. It wraps the whole
+// router rather than individual routes.
+func LocaleMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locale := i18n.Negotiate(r.Header.Get("Accept-Language"))
+		w.Header().Set("Content-Language", locale)
+		w.Header().Add("Vary", "Accept-Language")
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), "locale", locale)))
+	})
+}
+
+// maxRequestIDLength bounds the request ids accepted from clients, which end
+// up on every log line of their request.
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware gives every request an id: the X-Request-ID sent by the
+// client or a proxy, or a new one when it is missing or unusable. The id is
+// echoed in the response and stored in the request context, where
+// logger.FromContext and the Kafka producer pick it up.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logger.ContextWithRequestID(r.Context(), id)))
+	})
+}
+
+// validRequestID accepts ids of visible ASCII characters only, so that a
+// client cannot break up log lines with the id it sends.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// apiContentSecurityPolicy forbids browsers from running or embedding
+// anything an API response might contain. The Swagger UI sets its own.
+const apiContentSecurityPolicy = "default-src 'none'; frame-ancestors 'none'"
+
+// SecurityHeadersMiddleware sets the headers that keep browsers from sniffing,
+// framing or running API responses. hstsMaxAge is announced in
+// Strict-Transport-Security, which is omitted when it is zero.
+func SecurityHeadersMiddleware(next http.Handler, hstsMaxAge time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("X-Frame-Options", "DENY")
+		header.Set("Referrer-Policy", "no-referrer")
+		header.Set("Content-Security-Policy", apiContentSecurityPolicy)
+		if hstsMaxAge > 0 {
+			header.Set("Strict-Transport-Security", "max-age="+strconv.Itoa(int(hstsMaxAge.Seconds()))+"; includeSubDomains")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestLocale returns the locale chosen by LocaleMiddleware, negotiating it
+// directly when the middleware did not run.
+func requestLocale(r *http.Request) string {
+	if locale, ok := r.Context().Value("locale").(string); ok && locale != "" {
+		return locale
+	}
+	return i18n.Negotiate(r.Header.Get("Accept-Language"))
+}
+
+// pollingRoutes are polled by infrastructure rather than called by clients,
+// so they are logged at debug level only and not traced.
+var pollingRoutes = []string{"/health", "/livez", "/readyz", "/metrics"}
+
+// TracingMiddleware starts a server span for every request, continuing the
+// trace of the caller when it sends W3C trace context. Spans are named after
+// the method until MetricsMiddleware renames them after the route.
+func TracingMiddleware(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, "http.server",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method
+		}),
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			return !slices.Contains(pollingRoutes, r.URL.Path)
+		}),
+	)
+}
+
+// MetricsMiddleware records the count, duration, in-flight number and body
+// sizes of the requests to a route. Requests whose length is not known in
+// advance, such as chunked uploads, are sized by the bytes the handler reads.
 func MetricsMiddleware(next httprouter.Handle, path, method string) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		start := time.Now()
+		if entry := requestAccessLogEntry(r); entry != nil {
+			entry.route = path
+		}
+		span := trace.SpanFromContext(r.Context())
+		span.SetName(method + " " + path)
+		span.SetAttributes(semconv.HTTPRoute(path))
+
+		inFlight := metrics.RequestsInFlight.WithLabelValues(path, method)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		var body *countingReadCloser
+		if r.ContentLength < 0 && r.Body != nil {
+			body = &countingReadCloser{ReadCloser: r.Body}
+			r.Body = body
+		}
 
 		// Use a custom response writer to capture the status code
 		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
 		next(rw, r, p)
 
 		duration := time.Since(start)
+		requestSize := r.ContentLength
+		if body != nil {
+			requestSize = body.bytes
+		}
 
 		// Record the metrics
-		metrics.RequestCount.WithLabelValues(path, method, http.StatusText(rw.status)).Inc()
+		metrics.RequestCount.WithLabelValues(path, method, strconv.Itoa(rw.status), statusClass(rw.status)).Inc()
 		metrics.RequestDuration.WithLabelValues(path, method).Observe(duration.Seconds())
+		metrics.RequestSize.WithLabelValues(path, method).Observe(float64(requestSize))
+		metrics.ResponseSize.WithLabelValues(path, method).Observe(float64(rw.bytes))
 	}
 }
 
-// responseWriter is a wrapper to capture the HTTP status code.
+// statusClass returns the class of an HTTP status, such as "2xx".
+func statusClass(status int) string {
+	return strconv.Itoa(status/100) + "xx"
+}
+
+// responseWriter is a wrapper to capture the HTTP status code and the size of
+// the body.
 type responseWriter struct {
 	http.ResponseWriter
 	status int
+	bytes  int
 }
 
 func (rw *responseWriter) WriteHeader(status int) {
 	rw.status = status
 	rw.ResponseWriter.WriteHeader(status)
 }
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to
+// flush a streamed response.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// countingReadCloser counts the bytes read from a request body.
+type countingReadCloser struct {
+	io.ReadCloser
+	bytes int64
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.bytes += int64(n)
+	return n, err
+}
@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go-rest-api/internal/core"
+	"go-rest-api/internal/metrics"
+	"go-rest-api/pkg/logger"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const testJwtSecret = "testsecret"
+
+func TestAuthMiddleware_ExposesUserAndRoles(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockLogger := logger.MockLogger{}
+	userID := uuid.New()
+	token, err := core.GenerateAuthToken(userID, []string{core.RoleAdmin}, testJwtSecret)
+	a.NoError(err)
+
+	var ctxUserID, ctxActorID interface{}
+	var ctxRoles []string
+	handler := AuthMiddleware(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		ctxUserID = r.Context().Value("user_id")
+		ctxActorID = r.Context().Value("actor_id")
+		ctxRoles, _ = r.Context().Value("roles").([]string)
+	}, testJwtSecret, &mockLogger, &mockLogger)
+
+	// when
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	handler(httptest.NewRecorder(), req, nil)
+
+	// then
+	a.Equal(userID.String(), ctxUserID)
+	a.Nil(ctxActorID)
+	a.Equal([]string{core.RoleAdmin}, ctxRoles)
+}
+
+func TestAuthMiddleware_ImpersonationToken(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockLogger := logger.MockLogger{}
+	auditLogger := logger.MockLogger{}
+	auditLogger.On("Info", mock.Anything, mock.Anything).Return()
+	target := core.User{ID: uuid.New()}
+	actorID := uuid.New()
+	token, _, err := core.GenerateImpersonationToken(&target, actorID, testJwtSecret)
+	a.NoError(err)
+
+	var ctxUserID, ctxActorID interface{}
+	handler := AuthMiddleware(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		ctxUserID = r.Context().Value("user_id")
+		ctxActorID = r.Context().Value("actor_id")
+	}, testJwtSecret, &mockLogger, &auditLogger)
+
+	// when
+	req := httptest.NewRequest(http.MethodGet, "/users/"+target.ID.String(), nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	handler(httptest.NewRecorder(), req, nil)
+
+	// then
+	a.Equal(target.ID.String(), ctxUserID)
+	a.Equal(actorID.String(), ctxActorID)
+	auditLogger.AssertCalled(t, "Info", "impersonated request", mock.Anything)
+	mockLogger.AssertNotCalled(t, "Info", mock.Anything, mock.Anything)
+}
+
+func TestBlockImpersonation(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockLogger := logger.MockLogger{}
+	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
+	target := core.User{ID: uuid.New()}
+	impersonationToken, _, err := core.GenerateImpersonationToken(&target, uuid.New(), testJwtSecret)
+	a.NoError(err)
+	userToken, err := core.GenerateAuthToken(target.ID, nil, testJwtSecret)
+	a.NoError(err)
+
+	handler := AuthMiddleware(BlockImpersonation(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		w.WriteHeader(http.StatusNoContent)
+	}), testJwtSecret, &mockLogger, &mockLogger)
+
+	testScenarios := []struct {
+		name           string
+		token          string
+		expectedStatus int
+	}{
+		{name: "regular token", token: userToken, expectedStatus: http.StatusNoContent},
+		{name: "impersonation token", token: impersonationToken, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			// when
+			req := httptest.NewRequest(http.MethodPut, "/users/me/password", nil)
+			req.Header.Set("Authorization", "Bearer "+scenario.token)
+			res := httptest.NewRecorder()
+			handler(res, req, nil)
+
+			// then
+			a.Equal(scenario.expectedStatus, res.Code)
+		})
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	a := assert.New(t)
+	// given
+	mockLogger := logger.MockLogger{}
+	adminToken, err := core.GenerateAuthToken(uuid.New(), []string{core.RoleAdmin}, testJwtSecret)
+	a.NoError(err)
+	userToken, err := core.GenerateAuthToken(uuid.New(), nil, testJwtSecret)
+	a.NoError(err)
+
+	handler := AuthMiddleware(RequireRole(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		w.WriteHeader(http.StatusNoContent)
+	}, core.RoleAdmin), testJwtSecret, &mockLogger, &mockLogger)
+
+	// when
+	adminReq := httptest.NewRequest(http.MethodGet, "/invitations", nil)
+	adminReq.Header.Set("Authorization", "Bearer "+adminToken)
+	adminRes := httptest.NewRecorder()
+	handler(adminRes, adminReq, nil)
+
+	userReq := httptest.NewRequest(http.MethodGet, "/invitations", nil)
+	userReq.Header.Set("Authorization", "Bearer "+userToken)
+	userRes := httptest.NewRecorder()
+	handler(userRes, userReq, nil)
+
+	// then
+	a.Equal(http.StatusNoContent, adminRes.Code)
+	a.Equal(http.StatusForbidden, userRes.Code)
+}
+
+func TestSecurityHeadersMiddleware(t *testing.T) {
+	testScenarios := []struct {
+		name         string
+		hstsMaxAge   time.Duration
+		expectedHSTS string
+	}{
+		{name: "hsts", hstsMaxAge: 365 * 24 * time.Hour, expectedHSTS: "max-age=31536000; includeSubDomains"},
+		{name: "no hsts"},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			handler := SecurityHeadersMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			}), scenario.hstsMaxAge)
+			res := httptest.NewRecorder()
+
+			// when
+			handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/users", nil))
+
+			// then
+			a.Equal(http.StatusNoContent, res.Code)
+			a.Equal("nosniff", res.Header().Get("X-Content-Type-Options"))
+			a.Equal("DENY", res.Header().Get("X-Frame-Options"))
+			a.Equal("no-referrer", res.Header().Get("Referrer-Policy"))
+			a.Equal(apiContentSecurityPolicy, res.Header().Get("Content-Security-Policy"))
+			a.Equal(scenario.expectedHSTS, res.Header().Get("Strict-Transport-Security"))
+		})
+	}
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	testScenarios := []struct {
+		name       string
+		requestID  string
+		expectedID string
+	}{
+		{name: "from client", requestID: "req-123", expectedID: "req-123"},
+		{name: "missing"},
+		{name: "with spaces", requestID: "req 123\nlevel=error"},
+		{name: "too long", requestID: strings.Repeat("a", maxRequestIDLength+1)},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			var contextID string
+			handler := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				contextID = logger.RequestIDFromContext(r.Context())
+				writeProblem(w, r, http.StatusNotFound, "route_not_found")
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+			req.Header.Set("X-Request-ID", scenario.requestID)
+			res := httptest.NewRecorder()
+
+			// when
+			handler.ServeHTTP(res, req)
+
+			// then
+			id := res.Header().Get("X-Request-ID")
+			if scenario.expectedID != "" {
+				a.Equal(scenario.expectedID, id)
+			} else {
+				a.NoError(uuid.Validate(id))
+			}
+			a.Equal(id, contextID)
+			var problem Problem
+			a.NoError(json.Unmarshal(res.Body.Bytes(), &problem))
+			a.Equal(id, problem.RequestID)
+		})
+	}
+}
+
+func TestTracingMiddleware(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	testScenarios := []struct {
+		name            string
+		path            string
+		route           string
+		traceparent     string
+		expectedName    string
+		expectedTraceID string
+	}{
+		{name: "route", path: "/users/42", route: "/users/:id", expectedName: "GET /users/:id"},
+		{name: "unmatched", path: "/nowhere", expectedName: "GET"},
+		{name: "continued trace", path: "/users/42", route: "/users/:id", traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", expectedName: "GET /users/:id", expectedTraceID: "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{name: "polled", path: "/health", route: "/health"},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			recorder.Reset()
+			var loggedTraceID string
+			handler := TracingMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if scenario.route == "" {
+					http.NotFound(w, r)
+					return
+				}
+				MetricsMiddleware(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+					loggedTraceID = trace.SpanContextFromContext(r.Context()).TraceID().String()
+				}, scenario.route, http.MethodGet)(w, r, nil)
+			}))
+			req := httptest.NewRequest(http.MethodGet, scenario.path, nil)
+			if scenario.traceparent != "" {
+				req.Header.Set("traceparent", scenario.traceparent)
+			}
+
+			// when
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			// then
+			ended := recorder.Ended()
+			if scenario.expectedName == "" {
+				a.Empty(ended)
+				return
+			}
+			a.Len(ended, 1)
+			a.Equal(scenario.expectedName, ended[0].Name())
+			a.Equal(trace.SpanKindServer, ended[0].SpanKind())
+			if scenario.expectedTraceID != "" {
+				a.Equal(scenario.expectedTraceID, ended[0].SpanContext().TraceID().String())
+			}
+			if scenario.route != "" {
+				a.Equal(ended[0].SpanContext().TraceID().String(), loggedTraceID)
+			}
+		})
+	}
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	testScenarios := []struct {
+		name                string
+		body                io.Reader
+		contentLength       int64
+		expectedRequestSize float64
+	}{
+		{name: "known length", body: strings.NewReader(`{"name":"John"}`), contentLength: 15, expectedRequestSize: 15},
+		{name: "chunked", body: strings.NewReader(`{"name":"John"}`), contentLength: -1, expectedRequestSize: 15},
+		{name: "no body", contentLength: 0, expectedRequestSize: 0},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			path := "/metrics-test/" + strings.ReplaceAll(scenario.name, " ", "-")
+			var inFlight float64
+			handle := MetricsMiddleware(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+				inFlight = testutil.ToFloat64(metrics.RequestsInFlight.WithLabelValues(path, http.MethodPost))
+				io.Copy(io.Discard, r.Body)
+				w.WriteHeader(http.StatusNotFound)
+				io.WriteString(w, `{"code":"not_found"}`)
+			}, path, http.MethodPost)
+			req := httptest.NewRequest(http.MethodPost, path, scenario.body)
+			req.ContentLength = scenario.contentLength
+
+			// when
+			handle(httptest.NewRecorder(), req, nil)
+
+			// then
+			a.Equal(1.0, inFlight)
+			a.Equal(0.0, testutil.ToFloat64(metrics.RequestsInFlight.WithLabelValues(path, http.MethodPost)))
+			a.Equal(1.0, testutil.ToFloat64(metrics.RequestCount.WithLabelValues(path, http.MethodPost, "404", "4xx")))
+			a.Equal(scenario.expectedRequestSize, histogramSum(t, metrics.RequestSize.WithLabelValues(path, http.MethodPost)))
+			a.Equal(20.0, histogramSum(t, metrics.ResponseSize.WithLabelValues(path, http.MethodPost)))
+		})
+	}
+}
+
+func histogramSum(t *testing.T, observer prometheus.Observer) float64 {
+	var metric dto.Metric
+	if err := observer.(prometheus.Metric).Write(&metric); err != nil {
+		t.Fatal(err)
+	}
+	return metric.GetHistogram().GetSampleSum()
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"go-rest-api/pkg/logger"
+	"go-rest-api/pkg/openapi"
+	"net/http"
+
+	swaggerFiles "github.com/swaggo/files/v2"
+)
+
+// swaggerInitializer replaces the initializer of the Swagger UI bundle, which
+// points at the petstore example, with one that loads our document.
+const swaggerInitializer = `window.onload = function() {
+  window.ui = SwaggerUIBundle({
+    url: "/openapi.json",
+    dom_id: "#swagger-ui",
+    deepLinking: true,
+    presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
+    plugins: [SwaggerUIBundle.plugins.DownloadUrl],
+    layout: "StandaloneLayout"
+  });
+};
+`
+
+var swaggerFileServer = http.FileServerFS(swaggerFiles.FS)
+
+// swaggerContentSecurityPolicy lets the Swagger UI load its own assets, the
+// inline styles and data: icons it renders, and call the API.
+const swaggerContentSecurityPolicy = "default-src 'self'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; frame-ancestors 'none'; form-action 'self'"
+
+// OpenAPISpec serves the OpenAPI document of the API.
+func OpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openapi.Spec())
+}
+
+// SwaggerUI serves the bundled Swagger UI for the OpenAPI document. filepath
+// is the asset requested below /docs/.
+func SwaggerUI(w http.ResponseWriter, r *http.Request, filepath string) {
+	w.Header().Set("Content-Security-Policy", swaggerContentSecurityPolicy)
+	if filepath == "/swagger-initializer.js" {
+		w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(swaggerInitializer))
+		return
+	}
+	r = r.Clone(r.Context())
+	r.URL.Path = filepath
+	swaggerFileServer.ServeHTTP(w, r)
+}
+
+// OpenAPIValidationMiddleware rejects requests whose parameters or JSON body
+// do not match the OpenAPI document with a validation problem, before they
+// reach a handler.
+func OpenAPIValidationMiddleware(next http.Handler, validator *openapi.Validator, logger logger.CustomLogger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := validator.ValidateRequest(r)
+		if err == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		requestErr, ok := err.(*openapi.RequestError)
+		if !ok {
+			writeError(w, r, logger, err)
+			return
+		}
+		fields := make([]FieldError, 0, len(requestErr.Violations))
+		for _, violation := range requestErr.Violations {
+			code, params := violationMessage(violation)
+			fields = append(fields, newFieldError(violation.Field, violation.Rule, code, params))
+		}
+		writeError(w, r, logger, &ValidationError{Fields: fields})
+	})
+}
+
+// violationMessage returns the catalog code and parameters describing a
+// violation, like fieldErrorMessage does for validate tags.
+func violationMessage(violation openapi.Violation) (string, map[string]string) {
+	param := map[string]string{"param": violation.Param}
+	switch violation.Rule {
+	case "required", "email", "unknown":
+		return "validation." + violation.Rule, nil
+	case "min", "max":
+		if violation.Length {
+			return "validation." + violation.Rule + "_length", param
+		}
+		return "validation." + violation.Rule, param
+	case "type", "oneof", "format":
+		return "validation." + violation.Rule, param
+	}
+	return "validation.default", map[string]string{"rule": violation.Rule}
+}
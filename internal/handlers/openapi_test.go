@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"go-rest-api/pkg/logger"
+	"go-rest-api/pkg/openapi"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPIValidationMiddleware(t *testing.T) {
+	a := assert.New(t)
+	// given
+	validator, err := openapi.NewValidator()
+	a.NoError(err)
+	called := false
+	handler := LocaleMiddleware(OpenAPIValidationMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}), validator, &logger.MockLogger{}))
+
+	// when
+	req := httptest.NewRequest(http.MethodPost, "/users", bytes.NewBufferString(`{"username":"al","email":"alice@example.com","password":"password123"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Language", "fr")
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	// then
+	a.False(called)
+	a.Equal(http.StatusBadRequest, res.Code)
+	var problem Problem
+	a.NoError(json.NewDecoder(res.Body).Decode(&problem))
+	a.Equal("validation_failed", problem.Code)
+	a.Equal([]FieldError{{Field: "username", Rule: "min", Message: "doit contenir au moins 3 caractères"}}, problem.Errors)
+}
+
+func TestOpenAPIValidationMiddleware_ValidRequest(t *testing.T) {
+	a := assert.New(t)
+	// given
+	validator, err := openapi.NewValidator()
+	a.NoError(err)
+	var body bytes.Buffer
+	handler := OpenAPIValidationMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body.ReadFrom(r.Body)
+	}), validator, &logger.MockLogger{})
+
+	// when
+	req := httptest.NewRequest(http.MethodPost, "/users/login", bytes.NewBufferString(`{"email":"alice@example.com","password":"password123"}`))
+	req.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// then
+	a.Equal(`{"email":"alice@example.com","password":"password123"}`, body.String())
+}
+
+func TestSwaggerUI(t *testing.T) {
+	testScenarios := []struct {
+		filepath        string
+		expectedContent string
+	}{
+		{filepath: "/", expectedContent: `<div id="swagger-ui"></div>`},
+		{filepath: "/swagger-initializer.js", expectedContent: `url: "/openapi.json"`},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.filepath, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			req := httptest.NewRequest(http.MethodGet, "/docs"+scenario.filepath, nil)
+			res := httptest.NewRecorder()
+
+			// when
+			SwaggerUI(res, req, scenario.filepath)
+
+			// then
+			a.Equal(http.StatusOK, res.Code)
+			a.Contains(res.Body.String(), scenario.expectedContent)
+			a.Equal(swaggerContentSecurityPolicy, res.Header().Get("Content-Security-Policy"))
+		})
+	}
+}
@@ -8,23 +8,128 @@ import (
 
 type CreateUserRequest struct {
 	Username string `json:"username" validate:"required,min=3,max=50"`
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6"`
+	Email    string `json:"email" validate:"required,email" redact:"email"`
+	Password string `json:"password" validate:"required,min=6" redact:"secret"`
 }
 
 type LoginUserRequest struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required"`
+	Email    string `json:"email" validate:"required,email" redact:"email"`
+	Password string `json:"password" validate:"required" redact:"secret"`
 }
 
 type UserResponse struct {
 	Id        uuid.UUID `json:"id"`
 	Username  string    `json:"username"`
-	Email     string    `json:"email"`
+	Email     string    `json:"email" redact:"email"`
+	AvatarURL string    `json:"avatar_url"`
+	Roles     []string  `json:"roles"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// UserResponseV2 is the user of API v2. Unlike UserResponse it carries the
+// profile and the version that ETags are derived from, and roles is an empty
+// array rather than null for users without roles.
+type UserResponseV2 struct {
+	Id        uuid.UUID      `json:"id"`
+	Username  string         `json:"username"`
+	Email     string         `json:"email" redact:"email"`
+	AvatarURL string         `json:"avatar_url"`
+	Roles     []string       `json:"roles"`
+	Profile   map[string]any `json:"profile"`
+	Version   int64          `json:"version"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
 type LoginUserResponse struct {
-	Token string `json:"token"`
+	Token string `json:"token" redact:"secret"`
+}
+
+type ImpersonationResponse struct {
+	Token     string    `json:"token" redact:"secret"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type ListUsersResponse struct {
+	Users  []UserResponse `json:"users"`
+	Limit  int            `json:"limit"`
+	Offset int            `json:"offset"`
+}
+
+// ListUsersResponseV2 is a page of users in API v2, which moves the paging
+// parameters into their own object.
+type ListUsersResponseV2 struct {
+	Data       []UserResponseV2 `json:"data"`
+	Pagination Pagination       `json:"pagination"`
+}
+
+type Pagination struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+type CreateInvitationRequest struct {
+	Email     string     `json:"email" validate:"required,email" redact:"email"`
+	Roles     []string   `json:"roles" validate:"dive,role"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type AcceptInvitationRequest struct {
+	Token    string `json:"token" validate:"required" redact:"secret"`
+	Username string `json:"username" validate:"required,min=3,max=50"`
+	Password string `json:"password" validate:"required,min=6" redact:"secret"`
+}
+
+type InvitationResponse struct {
+	Id        uuid.UUID `json:"id"`
+	Email     string    `json:"email" redact:"email"`
+	Roles     []string  `json:"roles"`
+	InvitedBy uuid.UUID `json:"invited_by"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ListInvitationsResponse struct {
+	Invitations []InvitationResponse `json:"invitations"`
+}
+
+type UserImportResponse struct {
+	Id            uuid.UUID  `json:"id"`
+	Status        string     `json:"status"`
+	Format        string     `json:"format"`
+	TotalRows     int        `json:"total_rows"`
+	ProcessedRows int        `json:"processed_rows"`
+	ImportedRows  int        `json:"imported_rows"`
+	FailedRows    int        `json:"failed_rows"`
+	ErrorsURL     string     `json:"errors_url"`
+	CreatedAt     time.Time  `json:"created_at"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+}
+
+// GraphQLRequest is the body of a POST /graphql request.
+type GraphQLRequest struct {
+	Query         string         `json:"query" validate:"required"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// SetLogLevelRequest changes the level of one log component. TTL is a
+// duration such as "30m" after which the level reverts; it defaults to the
+// configured override TTL, and "0s" makes the change last until restart.
+type SetLogLevelRequest struct {
+	Level string  `json:"level" validate:"required,oneof=debug info warn error"`
+	TTL   *string `json:"ttl"`
+}
+
+type LogLevelResponse struct {
+	Component       string     `json:"component"`
+	Level           string     `json:"level"`
+	ConfiguredLevel string     `json:"configured_level"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+}
+
+type ListLogLevelsResponse struct {
+	Components []LogLevelResponse `json:"components"`
 }
@@ -2,36 +2,55 @@ package handlers
 
 import (
 	"context"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"go-rest-api/internal/core"
 	"go-rest-api/pkg/logger"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 type UserService interface {
 	CreateUser(ctx context.Context, user *core.User) (*core.User, error)
 	GetUserByID(ctx context.Context, id string) (*core.User, error)
+	GetUsersByIDs(ctx context.Context, ids []string) ([]*core.User, error)
 	LoginUser(ctx context.Context, email, password, jwtSecret string) (string, error)
+	ImpersonateUser(ctx context.Context, actorID uuid.UUID, targetID, jwtSecret string) (string, time.Time, error)
+	UpdateAvatar(ctx context.Context, userID string, data []byte, version int64) (*core.User, error)
+	UpdateUserProfile(ctx context.Context, id string, patch map[string]any, version int64) (*core.User, error)
+	ListUsers(ctx context.Context, filter core.UserFilter) ([]*core.User, error)
+	ExportUsers(ctx context.Context, filter core.UserFilter, fn func(*core.User) error) error
 }
 
 type UserHandler struct {
 	userService UserService
 	Logger      logger.CustomLogger
+	// AuditLogger writes the audit trail of impersonated requests, Logger
+	// unless set.
+	AuditLogger logger.CustomLogger
 	JwtSecret   string
+	// OpenSignupDisabled turns off POST /users so that accounts can only be
+	// created by accepting an invitation.
+	OpenSignupDisabled bool
 }
 
 func NewUserHandler(userService UserService, logger logger.CustomLogger, jwtSecret string) *UserHandler {
 	return &UserHandler{
 		userService: userService,
 		Logger:      logger,
+		AuditLogger: logger,
 		JwtSecret:   jwtSecret,
 	}
 }
 
 func HeathCheck(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", jsonContentType)
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(`{"status":"available"}`))
 }
@@ -49,107 +68,523 @@ func ToUserResponse(u core.User) UserResponse {
 		Id:        u.ID,
 		Username:  u.Username,
 		Email:     u.Email,
+		AvatarURL: u.AvatarURL,
+		Roles:     u.Roles,
 		CreatedAt: u.CreatedAt,
 		UpdatedAt: u.UpdatedAt,
 	}
 }
 
-func (req *CreateUserRequest) Validate() error {
-	if req.Username == "" {
-		return errors.New("Username is required")
+func ToUserResponseV2(u core.User) UserResponseV2 {
+	response := UserResponseV2{
+		Id:        u.ID,
+		Username:  u.Username,
+		Email:     u.Email,
+		AvatarURL: u.AvatarURL,
+		Roles:     u.Roles,
+		Profile:   u.Profile,
+		Version:   u.Version,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+	}
+	if response.Roles == nil {
+		response.Roles = []string{}
 	}
-	if req.Email == "" || !strings.Contains(req.Email, "@") {
-		return errors.New("Valid email is required")
+	if response.Profile == nil {
+		response.Profile = map[string]any{}
 	}
-	if len(req.Password) < 6 {
-		return errors.New("Password must be at least 6 characters long")
+	return response
+}
+
+// userResponse maps u to the representation of the API version of r.
+func userResponse(r *http.Request, u core.User) any {
+	if requestAPIVersion(r) == "v2" {
+		return ToUserResponseV2(u)
 	}
-	return nil
+	return ToUserResponse(u)
+}
+
+func (req *CreateUserRequest) Validate() error {
+	return validateStruct(req)
+}
+
+func (req *LoginUserRequest) Validate() error {
+	return validateStruct(req)
 }
 
 func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 30*time.Second)
 	defer cancel()
 
+	encoder, err := negotiateEncoder(r)
+	if err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
+	if h.OpenSignupDisabled {
+		writeProblem(w, r, http.StatusForbidden, "signup_disabled")
+		return
+	}
+
 	var userReq CreateUserRequest
-	if err := json.NewDecoder(r.Body).Decode(&userReq); err != nil {
-		writeJSONErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+	if err := decodeBody(w, r, &userReq); err != nil {
+		writeError(w, r, h.Logger, err)
 		return
 	}
 
 	if err := userReq.Validate(); err != nil {
-		writeJSONErrorResponse(w, http.StatusBadRequest, err.Error())
+		writeError(w, r, h.Logger, err)
 		return
 	}
 
 	user := userReq.ToUser()
 	result, err := h.userService.CreateUser(ctx, &user)
 	if err != nil {
-		writeJSONErrorResponse(w, http.StatusInternalServerError, "Failed to create user")
+		writeError(w, r, h.Logger, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(ToUserResponse(*result))
+	writeResponse(w, r, h.Logger, encoder, http.StatusCreated, userResponse(r, *result))
 }
 
 func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(context.Background(), 1000*time.Second)
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 1000*time.Second)
 	defer cancel()
 
+	encoder, err := negotiateEncoder(r)
+	if err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
 	id := r.URL.Path[len("/users/"):]
 	if id == "" {
-		writeJSONErrorResponse(w, http.StatusBadRequest, "User Id is required")
+		writeProblem(w, r, http.StatusBadRequest, "user_id_required")
 		return
 	}
 
 	user, err := h.userService.GetUserByID(ctx, id)
 	if err != nil {
-		writeJSONErrorResponse(w, http.StatusInternalServerError, "User not found")
+		writeError(w, r, h.Logger, err)
 		return
 	}
-	if user == nil {
-		writeJSONErrorResponse(w, http.StatusNotFound, "User not found")
+	if writeNotModified(w, r, userETag(r, encoder, user)) {
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(ToUserResponse(*user))
+	writeResponse(w, r, h.Logger, encoder, http.StatusOK, userResponse(r, *user))
 }
 
 func (h *UserHandler) LoginUser(w http.ResponseWriter, r *http.Request) {
 	_, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	encoder, err := negotiateEncoder(r)
+	if err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
 	var userReq LoginUserRequest
-	if err := json.NewDecoder(r.Body).Decode(&userReq); err != nil {
-		writeJSONErrorResponse(w, http.StatusBadRequest, "Invalid request payload")
+	if err := decodeBody(w, r, &userReq); err != nil {
+		writeError(w, r, h.Logger, err)
 		return
 	}
 
 	// ... validate request data
-	if userReq.Email == "" || userReq.Password == "" {
-		writeJSONErrorResponse(w, http.StatusBadRequest, "Email and password are required")
+	if err := userReq.Validate(); err != nil {
+		writeError(w, r, h.Logger, err)
 		return
 	}
 
 	token, err := h.userService.LoginUser(r.Context(), strings.ToLower(userReq.Email), userReq.Password, h.JwtSecret)
 	if err != nil {
-		writeJSONErrorResponse(w, http.StatusInternalServerError, "Failed to login user")
+		writeError(w, r, h.Logger, err)
 		return
 	}
 
-	if token == "" {
-		writeJSONErrorResponse(w, http.StatusUnauthorized, "Invalid email or password")
+	writeResponse(w, r, h.Logger, encoder, http.StatusOK, LoginUserResponse{token})
+}
+
+func (h *UserHandler) ImpersonateUser(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	encoder, err := negotiateEncoder(r)
+	if err != nil {
+		writeError(w, r, h.Logger, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(LoginUserResponse{token})
+	userID, _ := r.Context().Value("user_id").(string)
+	actorID, err := uuid.Parse(userID)
+	if err != nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	targetID := strings.TrimSuffix(r.URL.Path[len("/admin/users/"):], "/impersonate")
+	if _, err := uuid.Parse(targetID); err != nil {
+		writeError(w, r, h.Logger, core.NewError(core.ErrValidation, "invalid_user_id", "valid user id is required"))
+		return
+	}
+
+	token, expiresAt, err := h.userService.ImpersonateUser(ctx, actorID, targetID, h.JwtSecret)
+	if err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
+	writeResponse(w, r, h.Logger, encoder, http.StatusOK, ImpersonationResponse{Token: token, ExpiresAt: expiresAt})
+}
+
+func (h *UserHandler) UpdateAvatar(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	encoder, err := negotiateEncoder(r)
+	if err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
+	userID, ok := r.Context().Value("user_id").(string)
+	if !ok || userID == "" {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	current, err := h.userService.GetUserByID(ctx, userID)
+	if err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+	version, err := ifMatchVersion(r, encoder, current)
+	if err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
+	// ... allow some headroom over the file limit for the multipart framing
+	r.Body = http.MaxBytesReader(w, r.Body, core.MaxAvatarSize+(1<<20))
+	if err := r.ParseMultipartForm(core.MaxAvatarSize); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeProblem(w, r, http.StatusRequestEntityTooLarge, "avatar_too_large")
+			return
+		}
+		writeProblem(w, r, http.StatusBadRequest, "invalid_multipart_body")
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, _, err := r.FormFile("avatar")
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "avatar_required")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, core.MaxAvatarSize+1))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, "avatar_unreadable")
+		return
+	}
+	if int64(len(data)) > core.MaxAvatarSize {
+		writeProblem(w, r, http.StatusRequestEntityTooLarge, "avatar_too_large")
+		return
+	}
+
+	// ... trust the bytes, not the client supplied Content-Type
+	if !core.IsAllowedAvatarContentType(http.DetectContentType(data)) {
+		writeProblem(w, r, http.StatusUnsupportedMediaType, "avatar_unsupported_type")
+		return
+	}
+
+	user, err := h.userService.UpdateAvatar(ctx, userID, data, version)
+	if err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
+	w.Header().Set("ETag", userETag(r, encoder, user))
+	writeResponse(w, r, h.Logger, encoder, http.StatusOK, userResponse(r, *user))
+}
+
+func (h *UserHandler) GetUserProfile(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	encoder, err := negotiateEncoder(r)
+	if err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
+	id := strings.TrimSuffix(r.URL.Path[len("/users/"):], "/profile")
+	if id == "" {
+		writeProblem(w, r, http.StatusBadRequest, "user_id_required")
+		return
+	}
+
+	user, err := h.userService.GetUserByID(ctx, id)
+	if err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+	if writeNotModified(w, r, userETag(r, encoder, user)) {
+		return
+	}
+
+	writeResponse(w, r, h.Logger, encoder, http.StatusOK, user.Profile)
+}
+
+func (h *UserHandler) UpdateUserProfile(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	encoder, err := negotiateEncoder(r)
+	if err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
+	id := strings.TrimSuffix(r.URL.Path[len("/users/"):], "/profile")
+	if id == "" {
+		writeProblem(w, r, http.StatusBadRequest, "user_id_required")
+		return
+	}
+
+	// ... users may only edit their own profile
+	if userID, _ := r.Context().Value("user_id").(string); userID != id {
+		writeError(w, r, h.Logger, core.NewError(core.ErrForbidden, "profile_forbidden", "cannot update another user's profile"))
+		return
+	}
+
+	current, err := h.userService.GetUserByID(ctx, id)
+	if err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+	version, err := ifMatchVersion(r, encoder, current)
+	if err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
+	var patch map[string]any
+	if err := decodeBody(w, r, &patch); err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+	if patch == nil {
+		writeProblem(w, r, http.StatusBadRequest, "profile_patch_not_object")
+		return
+	}
+
+	user, err := h.userService.UpdateUserProfile(ctx, id, patch, version)
+	if err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
+	w.Header().Set("ETag", userETag(r, encoder, user))
+	writeResponse(w, r, h.Logger, encoder, http.StatusOK, user.Profile)
+}
+
+func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	encoder, err := negotiateEncoder(r)
+	if err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
+	filter, err := ParseUserFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
+	users, err := h.userService.ListUsers(ctx, filter)
+	if err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
+	if requestAPIVersion(r) == "v2" {
+		response := ListUsersResponseV2{
+			Data:       make([]UserResponseV2, 0, len(users)),
+			Pagination: Pagination{Limit: filter.Limit, Offset: filter.Offset},
+		}
+		for _, user := range users {
+			response.Data = append(response.Data, ToUserResponseV2(*user))
+		}
+		writeResponse(w, r, h.Logger, encoder, http.StatusOK, response)
+		return
+	}
+
+	response := ListUsersResponse{
+		Users:  make([]UserResponse, 0, len(users)),
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+	}
+	for _, user := range users {
+		response.Users = append(response.Users, ToUserResponse(*user))
+	}
+
+	writeResponse(w, r, h.Logger, encoder, http.StatusOK, response)
 }
 
-func writeJSONErrorResponse(w http.ResponseWriter, status int, errorMsg string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(APIError{errorMsg})
+// userExportFlushEvery is the number of rows an export writes between flushes.
+const userExportFlushEvery = 100
+
+// userExportFormats maps the export formats to their content types.
+var userExportFormats = map[string]string{
+	"ndjson": "application/x-ndjson",
+	"csv":    "text/csv; charset=utf-8",
+}
+
+// ExportUsers streams every user matching the listing filters as NDJSON or
+// CSV, flushing as it goes. Pagination parameters are ignored. Once the first
+// row is sent the status can no longer change, so a failure after that aborts
+// the connection rather than leaving the client with a silently short file.
+func (h *UserHandler) ExportUsers(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "ndjson"
+	}
+	contentType, ok := userExportFormats[format]
+	if !ok {
+		writeError(w, r, h.Logger, core.NewError(core.ErrValidation, "invalid_export_format", "format must be ndjson or csv"))
+		return
+	}
+
+	filter, err := ParseUserFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
+	controller := http.NewResponseController(w)
+	encoder := json.NewEncoder(w)
+	csvWriter := csv.NewWriter(w)
+	flush := func() error {
+		csvWriter.Flush()
+		if err := csvWriter.Error(); err != nil {
+			return err
+		}
+		return controller.Flush()
+	}
+
+	started := false
+	start := func() {
+		started = true
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="users.`+format+`"`)
+		w.WriteHeader(http.StatusOK)
+		if format == "csv" {
+			csvWriter.Write([]string{"id", "username", "email", "avatar_url", "roles", "created_at", "updated_at"})
+		}
+	}
+
+	// ... no timeout, an export takes as long as the client keeps reading
+	rows := 0
+	err = h.userService.ExportUsers(r.Context(), filter, func(user *core.User) error {
+		if !started {
+			start()
+		}
+		if format == "csv" {
+			csvWriter.Write(userExportRecord(ToUserResponse(*user)))
+		} else if err := encoder.Encode(userResponse(r, *user)); err != nil {
+			return err
+		}
+		if rows++; rows%userExportFlushEvery == 0 {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		if !started {
+			writeError(w, r, h.Logger, err)
+			return
+		}
+		h.Logger.FromContext(r.Context()).Error("user export aborted", "error", err)
+		panic(http.ErrAbortHandler)
+	}
+	if !started {
+		start()
+	}
+	flush()
+}
+
+func userExportRecord(user UserResponse) []string {
+	return []string{
+		user.Id.String(),
+		user.Username,
+		user.Email,
+		user.AvatarURL,
+		strings.Join(user.Roles, ";"),
+		user.CreatedAt.Format(time.RFC3339Nano),
+		user.UpdatedAt.Format(time.RFC3339Nano),
+	}
+}
+
+// ParseUserFilter builds a core.UserFilter from query parameters. Profile keys
+// are given as profile.<key>=<value>, with dots addressing nested objects.
+// Values that parse as JSON scalars (numbers, booleans) are matched as such,
+// everything else is matched as a string.
+func ParseUserFilter(query map[string][]string) (core.UserFilter, error) {
+	filter := core.UserFilter{Limit: 20}
+	for key, values := range query {
+		if len(values) == 0 {
+			continue
+		}
+		value := values[0]
+		switch {
+		case key == "limit":
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit < 1 || limit > 100 {
+				return filter, core.NewError(core.ErrValidation, "invalid_limit", "limit must be a number between 1 and 100")
+			}
+			filter.Limit = limit
+		case key == "offset":
+			offset, err := strconv.Atoi(value)
+			if err != nil || offset < 0 {
+				return filter, core.NewError(core.ErrValidation, "invalid_offset", "offset must be a non-negative number")
+			}
+			filter.Offset = offset
+		case strings.HasPrefix(key, "profile."):
+			path := strings.Split(strings.TrimPrefix(key, "profile."), ".")
+			if filter.Profile == nil {
+				filter.Profile = map[string]any{}
+			}
+			setProfileFilterValue(filter.Profile, path, value)
+		}
+	}
+	return filter, nil
+}
+
+func setProfileFilterValue(target map[string]any, path []string, value string) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := target[key].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			target[key] = next
+		}
+		target = next
+	}
+
+	var parsed any
+	if err := json.Unmarshal([]byte(value), &parsed); err == nil {
+		switch parsed.(type) {
+		case float64, bool:
+			target[path[len(path)-1]] = parsed
+			return
+		}
+	}
+	target[path[len(path)-1]] = value
 }
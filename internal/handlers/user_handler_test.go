@@ -4,13 +4,20 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"go-rest-api/internal/core"
 	"go-rest-api/internal/db"
 	"go-rest-api/pkg/logger"
+	"go-rest-api/pkg/schema"
+	"go-rest-api/pkg/storage"
 	"go-rest-api/test"
+	"image"
+	"image/png"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -37,14 +44,20 @@ func (testSuite *UserHandlerTestSuite) SetupSuite() {
 	testSuite.tearDown = teardown
 
 	mockLogger := logger.MockLogger{}
-	mockLogger.On("Error", mock.Anything).Return()
 	mockLogger.On("Error", mock.Anything, mock.Anything).Return()
-	mockLogger.On("Error", mock.Anything, mock.Anything, mock.Anything).Return()
 	mockLogger.On("Info", mock.Anything, mock.Anything).Return()
 	userRepo := db.NewUserRepository(dbPool, &mockLogger)
 	mockUserEvent := core.MockUserEventService{}
 	mockUserEvent.On("PublishUserCreatedEvent", mock.Anything, mock.Anything).Return(nil)
-	userServ := core.NewUserService(userRepo, &mockLogger, &mockUserEvent)
+	blobStore, err := storage.NewLocalStore(t.TempDir(), "http://localhost:8080/static")
+	if err != nil {
+		t.Fatalf("Failed to create blob store: %v", err)
+	}
+	profileValidator, err := schema.LoadProfileValidator("")
+	if err != nil {
+		t.Fatalf("Failed to load profile schema: %v", err)
+	}
+	userServ := core.NewUserService(userRepo, &mockLogger, &mockUserEvent, blobStore, profileValidator)
 	userHandler := NewUserHandler(userServ, &mockLogger, "testsecret")
 	testSuite.userHandler = userHandler
 }
@@ -108,6 +121,38 @@ func (testSuite *UserHandlerTestSuite) TestCreateUser_InvalidPayload() {
 	a.Equal(http.StatusBadRequest, res.Code)
 }
 
+func (testSuite *UserHandlerTestSuite) TestCreateUser_DuplicateEmail() {
+	t := testSuite.T()
+	a := assert.New(t)
+
+	// given
+	router := httprouter.New()
+	path := "/users"
+	router.POST(path, func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		testSuite.userHandler.CreateUser(w, r)
+	})
+	first, err := json.Marshal(CreateUserRequest{Username: "original", Email: "duplicate@gmail.com", Password: "password123"})
+	a.NoError(err)
+	second, err := json.Marshal(CreateUserRequest{Username: "copycat", Email: "duplicate@gmail.com", Password: "password123"})
+	a.NoError(err)
+
+	// when
+	firstRes := httptest.NewRecorder()
+	router.ServeHTTP(firstRes, httptest.NewRequest(http.MethodPost, path, bytes.NewBuffer(first)))
+	secondRes := httptest.NewRecorder()
+	router.ServeHTTP(secondRes, httptest.NewRequest(http.MethodPost, path, bytes.NewBuffer(second)))
+
+	// then
+	a.Equal(http.StatusCreated, firstRes.Code)
+	a.Equal(http.StatusConflict, secondRes.Code)
+	a.Equal("application/problem+json", secondRes.Header().Get("Content-Type"))
+	var problem Problem
+	a.NoError(json.Unmarshal(secondRes.Body.Bytes(), &problem))
+	a.Equal("/problems/conflict", problem.Type)
+	a.Equal(core.ErrUserAlreadyExists.Code, problem.Code)
+	a.Equal(path, problem.Instance)
+}
+
 func (testSuite *UserHandlerTestSuite) TestCreateUser_InvalidRequestBodyData() {
 	testScenarios := []struct {
 		name string
@@ -162,6 +207,9 @@ func (testSuite *UserHandlerTestSuite) TestCreateUser_InvalidRequestBodyData() {
 
 			// then
 			a.Equal(http.StatusBadRequest, res.Code)
+			var problem Problem
+			a.NoError(json.Unmarshal(res.Body.Bytes(), &problem))
+			a.Len(problem.Errors, 1)
 		})
 	}
 }
@@ -208,6 +256,7 @@ func (testSuite *UserHandlerTestSuite) TestGetUser() {
 		Id:       Id,
 		Username: username,
 		Email:    email,
+		Roles:    []string{},
 	}
 	a.NoError(err)
 	if diff := cmp.Diff(expectedResult, resultBody, cmpopts.IgnoreFields(UserResponse{}, "CreatedAt", "UpdatedAt")); diff != "" {
@@ -215,6 +264,34 @@ func (testSuite *UserHandlerTestSuite) TestGetUser() {
 	}
 }
 
+func (testSuite *UserHandlerTestSuite) TestGetUser_NotModified() {
+	t := testSuite.T()
+	a := assert.New(t)
+
+	// given
+	Id := uuid.New()
+	router := httprouter.New()
+	path := "/users/" + Id.String()
+	router.GET(path, func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		testSuite.userHandler.GetUser(w, r)
+	})
+
+	const query = `INSERT INTO users (id, username, email, password) VALUES ($1, $2, $3, $4)`
+	_, err := testSuite.dbPool.Exec(context.Background(), query, Id, "etaguser", "etaguser@gmail.com", "password123")
+	a.NoError(err)
+
+	// when
+	getReq := httptest.NewRequest(http.MethodGet, path, nil)
+	getReq.Header.Set("If-None-Match", `"1-v1-json"`)
+	getRes := httptest.NewRecorder()
+	router.ServeHTTP(getRes, getReq)
+
+	// then
+	a.Equal(http.StatusNotModified, getRes.Code)
+	a.Equal(`"1-v1-json"`, getRes.Header().Get("ETag"))
+	a.Empty(getRes.Body.Bytes())
+}
+
 func (testSuite *UserHandlerTestSuite) TestGetUser_NotFound() {
 	t := testSuite.T()
 	a := assert.New(t)
@@ -419,6 +496,426 @@ func (testSuite *UserHandlerTestSuite) TestLoginUser_MissingEmail() {
 	a.Equal(http.StatusBadRequest, loginRes.Code)
 }
 
+func newAvatarUploadRequest(t *testing.T, path string, userID string, data []byte) *http.Request {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("avatar", "avatar.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPut, path, body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("If-Match", `"1-v1-json"`)
+	return req.WithContext(context.WithValue(req.Context(), "user_id", userID))
+}
+
+func (testSuite *UserHandlerTestSuite) TestUpdateAvatar() {
+	t := testSuite.T()
+	a := assert.New(t)
+
+	// given
+	Id := uuid.New()
+	router := httprouter.New()
+	path := "/users/me/avatar"
+	router.PUT(path, func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		testSuite.userHandler.UpdateAvatar(w, r)
+	})
+
+	const query = `INSERT INTO users (id, username, email, password) VALUES ($1, $2, $3, $4)`
+	_, err := testSuite.dbPool.Exec(context.Background(), query, Id, "avataruser", "avataruser@gmail.com", "password123")
+	a.NoError(err)
+
+	var img bytes.Buffer
+	a.NoError(png.Encode(&img, image.NewRGBA(image.Rect(0, 0, 300, 200))))
+
+	// when
+	req := newAvatarUploadRequest(t, path, Id.String(), img.Bytes())
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	// then
+	a.Equal(http.StatusOK, res.Code)
+	var resultBody UserResponse
+	a.NoError(json.Unmarshal(res.Body.Bytes(), &resultBody))
+	a.Contains(resultBody.AvatarURL, "/avatars/"+Id.String()+"/256.png")
+}
+
+func (testSuite *UserHandlerTestSuite) TestUpdateAvatar_UnsupportedContentType() {
+	t := testSuite.T()
+	a := assert.New(t)
+
+	// given
+	Id := uuid.New()
+	router := httprouter.New()
+	path := "/users/me/avatar"
+	router.PUT(path, func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		testSuite.userHandler.UpdateAvatar(w, r)
+	})
+
+	const query = `INSERT INTO users (id, username, email, password) VALUES ($1, $2, $3, $4)`
+	_, err := testSuite.dbPool.Exec(context.Background(), query, Id, "pdfavataruser", "pdfavataruser@gmail.com", "password123")
+	a.NoError(err)
+
+	// when
+	req := newAvatarUploadRequest(t, path, Id.String(), []byte("%PDF-1.4 not an image"))
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	// then
+	a.Equal(http.StatusUnsupportedMediaType, res.Code)
+}
+
+func (testSuite *UserHandlerTestSuite) TestUpdateUserProfile() {
+	t := testSuite.T()
+	a := assert.New(t)
+
+	// given
+	Id := uuid.New()
+	router := httprouter.New()
+	path := "/users/:id/profile"
+	router.PATCH(path, func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		testSuite.userHandler.UpdateUserProfile(w, r)
+	})
+	router.GET(path, func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		testSuite.userHandler.GetUserProfile(w, r)
+	})
+
+	const query = `INSERT INTO users (id, username, email, password) VALUES ($1, $2, $3, $4)`
+	_, err := testSuite.dbPool.Exec(context.Background(), query, Id, "profileuser", "profileuser@gmail.com", "password123")
+	a.NoError(err)
+
+	// when
+	patchBody := []byte(`{"locale": "zu", "preferences": {"theme": "dark"}}`)
+	patchReq := httptest.NewRequest(http.MethodPatch, "/users/"+Id.String()+"/profile", bytes.NewBuffer(patchBody))
+	patchReq.Header.Set("If-Match", `"1-v1-json"`)
+	patchReq = patchReq.WithContext(context.WithValue(patchReq.Context(), "user_id", Id.String()))
+	patchRes := httptest.NewRecorder()
+	router.ServeHTTP(patchRes, patchReq)
+
+	getReq := httptest.NewRequest(http.MethodGet, "/users/"+Id.String()+"/profile", nil)
+	getRes := httptest.NewRecorder()
+	router.ServeHTTP(getRes, getReq)
+
+	// then
+	a.Equal(http.StatusOK, patchRes.Code)
+	a.Equal(`"2-v1-json"`, patchRes.Header().Get("ETag"))
+	a.Equal(http.StatusOK, getRes.Code)
+	a.Equal(`"2-v1-json"`, getRes.Header().Get("ETag"))
+	var profile map[string]any
+	a.NoError(json.Unmarshal(getRes.Body.Bytes(), &profile))
+	expectedProfile := map[string]any{"locale": "zu", "preferences": map[string]any{"theme": "dark"}}
+	if diff := cmp.Diff(expectedProfile, profile); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func (testSuite *UserHandlerTestSuite) TestUpdateUserProfile_InvalidProfile() {
+	t := testSuite.T()
+	a := assert.New(t)
+
+	// given
+	Id := uuid.New()
+	router := httprouter.New()
+	path := "/users/:id/profile"
+	router.PATCH(path, func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		testSuite.userHandler.UpdateUserProfile(w, r)
+	})
+
+	const query = `INSERT INTO users (id, username, email, password) VALUES ($1, $2, $3, $4)`
+	_, err := testSuite.dbPool.Exec(context.Background(), query, Id, "badprofileuser", "badprofileuser@gmail.com", "password123")
+	a.NoError(err)
+
+	// when
+	patchReq := httptest.NewRequest(http.MethodPatch, "/users/"+Id.String()+"/profile", bytes.NewBufferString(`{"locale": 12}`))
+	patchReq.Header.Set("If-Match", `"1-v1-json"`)
+	patchReq = patchReq.WithContext(context.WithValue(patchReq.Context(), "user_id", Id.String()))
+	patchRes := httptest.NewRecorder()
+	router.ServeHTTP(patchRes, patchReq)
+
+	// then
+	a.Equal(http.StatusUnprocessableEntity, patchRes.Code)
+}
+
+func (testSuite *UserHandlerTestSuite) TestUpdateUserProfile_Preconditions() {
+	t := testSuite.T()
+
+	// given
+	Id := uuid.New()
+	router := httprouter.New()
+	path := "/users/:id/profile"
+	router.PATCH(path, func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		testSuite.userHandler.UpdateUserProfile(w, r)
+	})
+
+	const query = `INSERT INTO users (id, username, email, password, version) VALUES ($1, $2, $3, $4, 5)`
+	_, err := testSuite.dbPool.Exec(context.Background(), query, Id, "staleprofileuser", "staleprofileuser@gmail.com", "password123")
+	assert.NoError(t, err)
+
+	testScenarios := []struct {
+		name           string
+		ifMatch        string
+		expectedStatus int
+		expectedCode   string
+	}{
+		{name: "missing", ifMatch: "", expectedStatus: http.StatusPreconditionRequired, expectedCode: "if_match_required"},
+		{name: "stale", ifMatch: `"4-v1-json"`, expectedStatus: http.StatusPreconditionFailed, expectedCode: "version_mismatch"},
+		{name: "weak", ifMatch: `W/"5-v1-json"`, expectedStatus: http.StatusPreconditionFailed, expectedCode: "version_mismatch"},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+
+			// when
+			patchReq := httptest.NewRequest(http.MethodPatch, "/users/"+Id.String()+"/profile", bytes.NewBufferString(`{"locale": "fr"}`))
+			if scenario.ifMatch != "" {
+				patchReq.Header.Set("If-Match", scenario.ifMatch)
+			}
+			patchReq = patchReq.WithContext(context.WithValue(patchReq.Context(), "user_id", Id.String()))
+			patchRes := httptest.NewRecorder()
+			router.ServeHTTP(patchRes, patchReq)
+
+			// then
+			a.Equal(scenario.expectedStatus, patchRes.Code)
+			var problem Problem
+			a.NoError(json.Unmarshal(patchRes.Body.Bytes(), &problem))
+			a.Equal(scenario.expectedCode, problem.Code)
+		})
+	}
+}
+
+func (testSuite *UserHandlerTestSuite) TestUpdateUserProfile_AnotherUser() {
+	t := testSuite.T()
+	a := assert.New(t)
+
+	// given
+	router := httprouter.New()
+	path := "/users/:id/profile"
+	router.PATCH(path, func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		testSuite.userHandler.UpdateUserProfile(w, r)
+	})
+
+	// when
+	patchReq := httptest.NewRequest(http.MethodPatch, "/users/"+uuid.New().String()+"/profile", bytes.NewBufferString(`{"locale": "fr"}`))
+	patchReq = patchReq.WithContext(context.WithValue(patchReq.Context(), "user_id", uuid.New().String()))
+	patchRes := httptest.NewRecorder()
+	router.ServeHTTP(patchRes, patchReq)
+
+	// then
+	a.Equal(http.StatusForbidden, patchRes.Code)
+}
+
+func (testSuite *UserHandlerTestSuite) TestImpersonateUser() {
+	t := testSuite.T()
+	a := assert.New(t)
+
+	// given
+	Id := uuid.New()
+	router := httprouter.New()
+	path := "/admin/users/:id/impersonate"
+	router.POST(path, func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		testSuite.userHandler.ImpersonateUser(w, r)
+	})
+
+	const query = `INSERT INTO users (id, username, email, password) VALUES ($1, $2, $3, $4)`
+	_, err := testSuite.dbPool.Exec(context.Background(), query, Id, "impersonated", "impersonated@gmail.com", "password123")
+	a.NoError(err)
+
+	// when
+	req := httptest.NewRequest(http.MethodPost, "/admin/users/"+Id.String()+"/impersonate", nil)
+	req = req.WithContext(context.WithValue(req.Context(), "user_id", uuid.New().String()))
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+
+	// then
+	a.Equal(http.StatusOK, res.Code)
+	var resultBody ImpersonationResponse
+	a.NoError(json.Unmarshal(res.Body.Bytes(), &resultBody))
+	a.NotEmpty(resultBody.Token)
+	a.True(resultBody.ExpiresAt.After(time.Now()))
+}
+
+func TestParseUserFilter(t *testing.T) {
+	a := assert.New(t)
+
+	// when
+	filter, err := ParseUserFilter(map[string][]string{
+		"limit":                     {"50"},
+		"offset":                    {"10"},
+		"profile.locale":            {"fr"},
+		"profile.preferences.theme": {"dark"},
+		"profile.preferences.beta":  {"true"},
+	})
+
+	// then
+	a.NoError(err)
+	expected := core.UserFilter{
+		Limit:  50,
+		Offset: 10,
+		Profile: map[string]any{
+			"locale":      "fr",
+			"preferences": map[string]any{"theme": "dark", "beta": true},
+		},
+	}
+	if diff := cmp.Diff(expected, filter); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestParseUserFilter_InvalidLimit(t *testing.T) {
+	a := assert.New(t)
+
+	// when
+	_, err := ParseUserFilter(map[string][]string{"limit": {"1000"}})
+
+	// then
+	a.Error(err)
+}
+
+func TestGetUser_ETagVariesByRepresentation(t *testing.T) {
+	user := &core.User{ID: uuid.New(), Username: "john", Email: "john@gmail.com", Version: 3}
+	versions := []APIVersion{{Name: "v1"}, {Name: "v2"}}
+	testScenarios := []struct {
+		name         string
+		prefix       string
+		accept       string
+		expectedETag string
+	}{
+		{name: "v1", prefix: "v1", expectedETag: `"3-v1-json"`},
+		{name: "v2", prefix: "v2", expectedETag: `"3-v2-json"`},
+		{name: "v2 by accept", accept: "application/vnd.gorestapi.v2+json", expectedETag: `"3-v2-json"`},
+		{name: "v2 msgpack", prefix: "v2", accept: msgpackContentType, expectedETag: `"3-v2-msgpack"`},
+		{name: "v1 protobuf", prefix: "v1", accept: protobufContentType, expectedETag: `"3-v1-protobuf"`},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			mockLogger := &logger.MockLogger{}
+			mockUserRepo := &core.MockUserRepository{}
+			mockUserRepo.On("GetUserByID", mock.Anything, user.ID.String()).Return(user, nil)
+			userService := core.NewUserService(mockUserRepo, mockLogger, &core.MockUserEventService{}, &core.MockBlobStore{}, &core.MockProfileValidator{})
+			handler := NewUserHandler(userService, mockLogger, "secret")
+			getUser := VersionMiddleware(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+				handler.GetUser(w, r)
+			}, versions, scenario.prefix, "/users/:id", "GET")
+			path := "/users/" + user.ID.String()
+			if scenario.prefix != "" {
+				path = "/" + scenario.prefix + path
+			}
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			if scenario.accept != "" {
+				req.Header.Set("Accept", scenario.accept)
+			}
+			res := httptest.NewRecorder()
+
+			// when
+			getUser(res, req, nil)
+
+			// then
+			a.Equal(http.StatusOK, res.Code)
+			a.Equal(scenario.expectedETag, res.Header().Get("ETag"))
+		})
+	}
+}
+
+func newExportUserHandler(users []*core.User, err error) *UserHandler {
+	mockLogger := &logger.MockLogger{}
+	mockLogger.On("Error", mock.Anything, mock.Anything).Return()
+	mockUserRepo := &core.MockUserRepository{}
+	mockUserRepo.On("StreamUsers", mock.Anything, mock.Anything).Return(users, err)
+	userService := core.NewUserService(mockUserRepo, mockLogger, &core.MockUserEventService{}, &core.MockBlobStore{}, &core.MockProfileValidator{})
+	return NewUserHandler(userService, mockLogger, "secret")
+}
+
+func TestExportUsers(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	user := &core.User{ID: uuid.MustParse("8f7a6c1e-2b3d-4e5f-9a0b-1c2d3e4f5a6b"), Username: "john", Email: "john@gmail.com", Password: "hash", Roles: []string{"admin", "exporter"}, CreatedAt: createdAt, UpdatedAt: createdAt}
+	testScenarios := []struct {
+		name                string
+		query               string
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "ndjson",
+			query:               "",
+			expectedContentType: "application/x-ndjson",
+			expectedBody:        `{"id":"8f7a6c1e-2b3d-4e5f-9a0b-1c2d3e4f5a6b","username":"john","email":"john@gmail.com","avatar_url":"","roles":["admin","exporter"],"created_at":"2024-05-01T12:00:00Z","updated_at":"2024-05-01T12:00:00Z"}` + "\n",
+		},
+		{
+			name:                "csv",
+			query:               "?format=csv&profile.locale=fr",
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedBody:        "id,username,email,avatar_url,roles,created_at,updated_at\n8f7a6c1e-2b3d-4e5f-9a0b-1c2d3e4f5a6b,john,john@gmail.com,,admin;exporter,2024-05-01T12:00:00Z,2024-05-01T12:00:00Z\n",
+		},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			req := httptest.NewRequest(http.MethodGet, "/users/export"+scenario.query, nil)
+			res := httptest.NewRecorder()
+
+			// when
+			newExportUserHandler([]*core.User{user}, nil).ExportUsers(res, req)
+
+			// then
+			a.Equal(http.StatusOK, res.Code)
+			a.Equal(scenario.expectedContentType, res.Header().Get("Content-Type"))
+			a.Equal(scenario.expectedBody, res.Body.String())
+			a.True(res.Flushed)
+		})
+	}
+}
+
+func TestExportUsers_InvalidFormat(t *testing.T) {
+	a := assert.New(t)
+	// given
+	req := httptest.NewRequest(http.MethodGet, "/users/export?format=xml", nil)
+	res := httptest.NewRecorder()
+
+	// when
+	newExportUserHandler(nil, nil).ExportUsers(res, req)
+
+	// then
+	a.Equal(http.StatusBadRequest, res.Code)
+}
+
+func TestExportUsers_FailsBeforeFirstRow(t *testing.T) {
+	a := assert.New(t)
+	// given
+	req := httptest.NewRequest(http.MethodGet, "/users/export", nil)
+	res := httptest.NewRecorder()
+
+	// when
+	newExportUserHandler(nil, errors.New("connection refused")).ExportUsers(res, req)
+
+	// then
+	a.Equal(http.StatusInternalServerError, res.Code)
+}
+
+func TestExportUsers_AbortsAfterFirstRow(t *testing.T) {
+	a := assert.New(t)
+	// given
+	req := httptest.NewRequest(http.MethodGet, "/users/export", nil)
+	res := httptest.NewRecorder()
+	handler := newExportUserHandler([]*core.User{{ID: uuid.New()}}, errors.New("connection reset"))
+
+	// when
+	call := func() { handler.ExportUsers(res, req) }
+
+	// then
+	a.PanicsWithValue(http.ErrAbortHandler, call)
+	a.Equal(http.StatusOK, res.Code)
+}
+
 func TestUserHandlerTestSuite(t *testing.T) {
 	suite.Run(t, new(UserHandlerTestSuite))
 }
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"go-rest-api/internal/core"
+	"go-rest-api/internal/i18n"
+	"go-rest-api/pkg/logger"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// userImportFormats maps the accepted request content types to import formats.
+var userImportFormats = map[string]string{
+	"text/csv":             core.UserImportFormatCSV,
+	"application/x-ndjson": core.UserImportFormatNDJSON,
+	"application/ndjson":   core.UserImportFormatNDJSON,
+}
+
+type UserImportService interface {
+	CreateImport(ctx context.Context, format string, data []byte, createdBy uuid.UUID) (*core.UserImport, error)
+	GetImport(ctx context.Context, id string) (*core.UserImport, error)
+}
+
+type UserImportHandler struct {
+	userImportService UserImportService
+	Logger            logger.CustomLogger
+}
+
+func NewUserImportHandler(userImportService UserImportService, logger logger.CustomLogger) *UserImportHandler {
+	return &UserImportHandler{
+		userImportService: userImportService,
+		Logger:            logger,
+	}
+}
+
+func userImportPath(id uuid.UUID) string {
+	return "/users/imports/" + id.String()
+}
+
+func ToUserImportResponse(i core.UserImport) UserImportResponse {
+	return UserImportResponse{
+		Id:            i.ID,
+		Status:        i.Status,
+		Format:        i.Format,
+		TotalRows:     i.TotalRows,
+		ProcessedRows: i.ProcessedRows,
+		ImportedRows:  i.ImportedRows,
+		FailedRows:    i.FailedRows,
+		ErrorsURL:     userImportPath(i.ID) + "/errors",
+		CreatedAt:     i.CreatedAt,
+		StartedAt:     i.StartedAt,
+		FinishedAt:    i.FinishedAt,
+	}
+}
+
+// userImportResponse maps i to its representation with links below the API
+// version prefix of r.
+func userImportResponse(r *http.Request, i core.UserImport) UserImportResponse {
+	response := ToUserImportResponse(i)
+	response.ErrorsURL = versionedPath(r, response.ErrorsURL)
+	return response
+}
+
+// CreateImport queues a CSV or NDJSON file of users for import and answers
+// 202 with the job, which is processed in the background.
+func (h *UserImportHandler) CreateImport(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	encoder, err := negotiateEncoderOf(r, jsonContentType, msgpackContentType)
+	if err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
+	userID, _ := r.Context().Value("user_id").(string)
+	createdBy, err := uuid.Parse(userID)
+	if err != nil {
+		writeProblem(w, r, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	format, ok := userImportFormats[mediaType]
+	if !ok {
+		writeProblem(w, r, http.StatusUnsupportedMediaType, "import_unsupported_type")
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, core.MaxUserImportSize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeError(w, r, h.Logger, &requestError{http.StatusRequestEntityTooLarge, "body_too_large", map[string]string{"limit": strconv.Itoa(core.MaxUserImportSize)}})
+			return
+		}
+		writeProblem(w, r, http.StatusBadRequest, "body_invalid")
+		return
+	}
+
+	userImport, err := h.userImportService.CreateImport(ctx, format, data, createdBy)
+	if err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
+	w.Header().Set("Location", versionedPath(r, userImportPath(userImport.ID)))
+	writeResponse(w, r, h.Logger, encoder, http.StatusAccepted, userImportResponse(r, *userImport))
+}
+
+func (h *UserImportHandler) GetImport(w http.ResponseWriter, r *http.Request) {
+	encoder, err := negotiateEncoderOf(r, jsonContentType, msgpackContentType)
+	if err != nil {
+		writeError(w, r, h.Logger, err)
+		return
+	}
+
+	userImport, ok := h.getImport(w, r)
+	if !ok {
+		return
+	}
+
+	writeResponse(w, r, h.Logger, encoder, http.StatusOK, userImportResponse(r, *userImport))
+}
+
+// GetImportErrors downloads the rows that were not imported as CSV, with the
+// messages in the request's language.
+func (h *UserImportHandler) GetImportErrors(w http.ResponseWriter, r *http.Request) {
+	userImport, ok := h.getImport(w, r)
+	if !ok {
+		return
+	}
+
+	locale := requestLocale(r)
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="import-`+userImport.ID.String()+`-errors.csv"`)
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"row", "field", "code", "message"})
+	for _, rowErr := range userImport.Errors {
+		writer.Write([]string{strconv.Itoa(rowErr.Row), rowErr.Field, rowErr.Code, i18n.Translate(locale, rowErr.Code, rowErr.Params)})
+	}
+	writer.Flush()
+}
+
+func (h *UserImportHandler) getImport(w http.ResponseWriter, r *http.Request) (*core.UserImport, bool) {
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	id := strings.TrimSuffix(r.URL.Path[len("/users/imports/"):], "/errors")
+	if _, err := uuid.Parse(id); err != nil {
+		writeError(w, r, h.Logger, core.NewError(core.ErrValidation, "invalid_import_id", "valid import id is required"))
+		return nil, false
+	}
+
+	userImport, err := h.userImportService.GetImport(ctx, id)
+	if err != nil {
+		writeError(w, r, h.Logger, err)
+		return nil, false
+	}
+	return userImport, true
+}
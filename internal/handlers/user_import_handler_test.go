@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"go-rest-api/internal/core"
+	"go-rest-api/pkg/logger"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newUserImportHandler(repo *core.MockUserImportRepository) *UserImportHandler {
+	mockLogger := &logger.MockLogger{}
+	mockLogger.On("Error", mock.Anything, mock.Anything).Return()
+	return NewUserImportHandler(core.NewUserImportService(repo, &core.MockUserEventService{}, mockLogger), mockLogger)
+}
+
+func TestUserImportHandler_CreateImport(t *testing.T) {
+	a := assert.New(t)
+	// given
+	adminID := uuid.New()
+	repo := &core.MockUserImportRepository{}
+	repo.On("CreateUserImport", mock.Anything, mock.MatchedBy(func(i *core.UserImport) bool {
+		return i.Format == core.UserImportFormatNDJSON && i.TotalRows == 2 && i.CreatedBy == adminID
+	}), mock.Anything).Return(&core.UserImport{ID: uuid.New(), Status: core.UserImportStatusPending, Format: core.UserImportFormatNDJSON, TotalRows: 2}, nil)
+	body := `{"username":"alice","email":"alice@gmail.com","password":"secret"}` + "\n" + `{"username":"bob"}` + "\n"
+	req := httptest.NewRequest(http.MethodPost, "/users/imports", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req = req.WithContext(context.WithValue(req.Context(), "user_id", adminID.String()))
+	res := httptest.NewRecorder()
+
+	// when
+	newUserImportHandler(repo).CreateImport(res, req)
+
+	// then
+	a.Equal(http.StatusAccepted, res.Code)
+	a.Equal("application/json", res.Header().Get("Content-Type"))
+	var userImport UserImportResponse
+	a.NoError(json.NewDecoder(res.Body).Decode(&userImport))
+	a.Equal(core.UserImportStatusPending, userImport.Status)
+	a.Equal(2, userImport.TotalRows)
+	a.Equal("/users/imports/"+userImport.Id.String(), res.Header().Get("Location"))
+	repo.AssertExpectations(t)
+}
+
+func TestUserImportHandler_CreateImportLinksBelowVersionPrefix(t *testing.T) {
+	testScenarios := []struct {
+		name           string
+		prefix         string
+		expectedPrefix string
+	}{
+		{name: "root", prefix: "", expectedPrefix: ""},
+		{name: "v2", prefix: "v2", expectedPrefix: "/v2"},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			id := uuid.New()
+			repo := &core.MockUserImportRepository{}
+			repo.On("CreateUserImport", mock.Anything, mock.Anything, mock.Anything).Return(&core.UserImport{ID: id, Status: core.UserImportStatusPending, Format: core.UserImportFormatCSV, TotalRows: 1}, nil)
+			userImportHandler := newUserImportHandler(repo)
+			handler := VersionMiddleware(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+				userImportHandler.CreateImport(w, r)
+			}, []APIVersion{{Name: "v1"}, {Name: "v2"}}, scenario.prefix, "/users/imports", "POST")
+			req := httptest.NewRequest(http.MethodPost, scenario.expectedPrefix+"/users/imports", bytes.NewBufferString("username,email,password\nalice,alice@gmail.com,secret123\n"))
+			req.Header.Set("Content-Type", "text/csv")
+			req = req.WithContext(context.WithValue(req.Context(), "user_id", uuid.NewString()))
+			res := httptest.NewRecorder()
+
+			// when
+			handler(res, req, nil)
+
+			// then
+			a.Equal(http.StatusAccepted, res.Code)
+			a.Equal(scenario.expectedPrefix+"/users/imports/"+id.String(), res.Header().Get("Location"))
+			var userImport UserImportResponse
+			a.NoError(json.NewDecoder(res.Body).Decode(&userImport))
+			a.Equal(scenario.expectedPrefix+"/users/imports/"+id.String()+"/errors", userImport.ErrorsURL)
+		})
+	}
+}
+
+func TestUserImportHandler_CreateImportRejectsRequest(t *testing.T) {
+	testScenarios := []struct {
+		name           string
+		contentType    string
+		body           string
+		expectedStatus int
+		expectedCode   string
+	}{
+		{name: "unsupported type", contentType: "application/json", body: `[]`, expectedStatus: http.StatusUnsupportedMediaType, expectedCode: "import_unsupported_type"},
+		{name: "missing column", contentType: "text/csv", body: "username,email\nalice,alice@gmail.com\n", expectedStatus: http.StatusBadRequest, expectedCode: "import_missing_column"},
+		{name: "empty", contentType: "text/csv; charset=utf-8", body: "", expectedStatus: http.StatusBadRequest, expectedCode: "import_empty"},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			req := httptest.NewRequest(http.MethodPost, "/users/imports", bytes.NewBufferString(scenario.body))
+			req.Header.Set("Content-Type", scenario.contentType)
+			req = req.WithContext(context.WithValue(req.Context(), "user_id", uuid.NewString()))
+			res := httptest.NewRecorder()
+
+			// when
+			newUserImportHandler(&core.MockUserImportRepository{}).CreateImport(res, req)
+
+			// then
+			a.Equal(scenario.expectedStatus, res.Code)
+			var problem Problem
+			a.NoError(json.NewDecoder(res.Body).Decode(&problem))
+			a.Equal(scenario.expectedCode, problem.Code)
+		})
+	}
+}
+
+func TestUserImportHandler_GetImportErrors(t *testing.T) {
+	a := assert.New(t)
+	// given
+	id := uuid.New()
+	repo := &core.MockUserImportRepository{}
+	repo.On("GetUserImport", mock.Anything, id.String()).Return(&core.UserImport{
+		ID:     id,
+		Status: core.UserImportStatusCompleted,
+		Errors: []core.UserImportRowError{{Row: 2, Field: "email", Code: "user_already_exists"}},
+	}, nil)
+	req := httptest.NewRequest(http.MethodGet, "/users/imports/"+id.String()+"/errors", nil)
+	req.Header.Set("Accept-Language", "fr")
+	res := httptest.NewRecorder()
+
+	// when
+	newUserImportHandler(repo).GetImportErrors(res, req)
+
+	// then
+	a.Equal(http.StatusOK, res.Code)
+	a.Equal("text/csv; charset=utf-8", res.Header().Get("Content-Type"))
+	a.Equal("row,field,code,message\n2,email,user_already_exists,Un utilisateur avec cette adresse e-mail existe déjà\n", res.Body.String())
+}
+
+func TestUserImportHandler_GetImportNegotiatesEncoding(t *testing.T) {
+	testScenarios := []struct {
+		name                string
+		accept              string
+		expectedStatus      int
+		expectedContentType string
+	}{
+		{name: "default", accept: "", expectedStatus: http.StatusOK, expectedContentType: "application/json"},
+		{name: "msgpack", accept: "application/msgpack", expectedStatus: http.StatusOK, expectedContentType: "application/msgpack"},
+		{name: "protobuf", accept: "application/x-protobuf", expectedStatus: http.StatusNotAcceptable, expectedContentType: "application/problem+json"},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			id := uuid.New()
+			repo := &core.MockUserImportRepository{}
+			repo.On("GetUserImport", mock.Anything, id.String()).Return(&core.UserImport{ID: id, Status: core.UserImportStatusCompleted}, nil)
+			req := httptest.NewRequest(http.MethodGet, "/users/imports/"+id.String(), nil)
+			if scenario.accept != "" {
+				req.Header.Set("Accept", scenario.accept)
+			}
+			res := httptest.NewRecorder()
+
+			// when
+			newUserImportHandler(repo).GetImport(res, req)
+
+			// then
+			a.Equal(scenario.expectedStatus, res.Code)
+			a.Equal(scenario.expectedContentType, res.Header().Get("Content-Type"))
+		})
+	}
+}
+
+func TestUserImportHandler_GetImportInvalidID(t *testing.T) {
+	a := assert.New(t)
+	// given
+	req := httptest.NewRequest(http.MethodGet, "/users/imports/not-a-uuid", nil)
+	res := httptest.NewRecorder()
+
+	// when
+	newUserImportHandler(&core.MockUserImportRepository{}).GetImport(res, req)
+
+	// then
+	a.Equal(http.StatusBadRequest, res.Code)
+}
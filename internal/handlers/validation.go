@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"go-rest-api/internal/core"
+	"go-rest-api/internal/i18n"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// maxRequestBodySize caps JSON request bodies. Uploads such as avatars set
+// their own limit.
+const maxRequestBodySize = 1 << 20
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// ... report fields by their JSON name, which is what clients send
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	v.RegisterValidation("role", func(fl validator.FieldLevel) bool {
+		return core.IsValidRole(fl.Field().String())
+	})
+	return v
+}
+
+// FieldError describes why a single request field was rejected. Rule is the
+// stable name of the failed rule; Message is translated.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+
+	code   string
+	params map[string]string
+}
+
+func newFieldError(field, rule, code string, params map[string]string) FieldError {
+	return FieldError{
+		Field:   field,
+		Rule:    rule,
+		Message: i18n.Translate(i18n.DefaultLocale, code, params),
+		code:    code,
+		params:  params,
+	}
+}
+
+// ValidationError is returned when a request DTO fails validation. It is a
+// core.ErrValidation, so it maps to a validation problem that lists the
+// offending fields.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, field.Field+" "+field.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return core.ErrValidation
+}
+
+// Localize returns the field errors with their messages in the given locale.
+func (e *ValidationError) Localize(locale string) []FieldError {
+	fields := make([]FieldError, len(e.Fields))
+	for i, field := range e.Fields {
+		fields[i] = field
+		if field.code != "" {
+			fields[i].Message = i18n.Translate(locale, field.code, field.params)
+		}
+	}
+	return fields
+}
+
+// validateStruct checks the validate tags of a request DTO.
+func validateStruct(req any) error {
+	err := validate.Struct(req)
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return err
+	}
+
+	fields := make([]FieldError, 0, len(validationErrs))
+	for _, fieldErr := range validationErrs {
+		code, params := fieldErrorMessage(fieldErr)
+		fields = append(fields, newFieldError(fieldPath(fieldErr), fieldErr.Tag(), code, params))
+	}
+	return &ValidationError{Fields: fields}
+}
+
+// fieldPath returns the JSON path of the field without the struct name, e.g.
+// "roles[1]" rather than "CreateInvitationRequest.roles[1]".
+func fieldPath(fieldErr validator.FieldError) string {
+	_, path, found := strings.Cut(fieldErr.Namespace(), ".")
+	if !found {
+		return fieldErr.Field()
+	}
+	return path
+}
+
+// fieldErrorMessage returns the catalog code and parameters describing a
+// failed validate rule.
+func fieldErrorMessage(fieldErr validator.FieldError) (string, map[string]string) {
+	param := map[string]string{"param": fieldErr.Param()}
+	switch fieldErr.Tag() {
+	case "required", "email", "role":
+		return "validation." + fieldErr.Tag(), nil
+	case "oneof":
+		return "validation.oneof", param
+	case "min", "max":
+		if fieldErr.Kind() == reflect.String {
+			return "validation." + fieldErr.Tag() + "_length", param
+		}
+		return "validation." + fieldErr.Tag(), param
+	}
+	return "validation.default", map[string]string{"rule": fieldErr.Tag()}
+}
+
+// decodeJSON strictly decodes a JSON request body into dst: unknown fields,
+// trailing data and bodies over maxRequestBodySize are rejected.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+	if err := decoder.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return decodeError(err)
+		}
+		return &requestError{status: http.StatusBadRequest, code: "body_multiple_values"}
+	}
+	return nil
+}
+
+func decodeError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return &requestError{http.StatusRequestEntityTooLarge, "body_too_large", map[string]string{"limit": strconv.FormatInt(maxBytesErr.Limit, 10)}}
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return &requestError{status: http.StatusBadRequest, code: "body_malformed"}
+	case errors.As(err, &typeErr):
+		if typeErr.Field != "" {
+			return &requestError{http.StatusBadRequest, "body_wrong_field_type", map[string]string{"field": typeErr.Field}}
+		}
+		return &requestError{status: http.StatusBadRequest, code: "body_wrong_type"}
+	case errors.Is(err, io.EOF):
+		return &requestError{status: http.StatusBadRequest, code: "body_empty"}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return &requestError{http.StatusBadRequest, "body_unknown_field", map[string]string{"field": field}}
+	}
+	return &requestError{status: http.StatusBadRequest, code: "body_invalid"}
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"bytes"
+	"go-rest-api/internal/core"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateStruct_ReportsEveryField(t *testing.T) {
+	a := assert.New(t)
+
+	// given
+	req := CreateUserRequest{Username: "ab", Email: "not-an-email@", Password: "123"}
+
+	// when
+	err := req.Validate()
+
+	// then
+	a.ErrorIs(err, core.ErrValidation)
+	var validationErr *ValidationError
+	a.ErrorAs(err, &validationErr)
+	a.Equal([]FieldError{
+		newFieldError("username", "min", "validation.min_length", map[string]string{"param": "3"}),
+		newFieldError("email", "email", "validation.email", nil),
+		newFieldError("password", "min", "validation.min_length", map[string]string{"param": "6"}),
+	}, validationErr.Fields)
+	a.Equal("must be at least 3 characters long", validationErr.Fields[0].Message)
+}
+
+func TestValidateStruct_NestedFieldPath(t *testing.T) {
+	a := assert.New(t)
+
+	// given
+	req := CreateInvitationRequest{Email: "a@b.com", Roles: []string{core.RoleAdmin, "superuser"}}
+
+	// when
+	err := validateStruct(&req)
+
+	// then
+	var validationErr *ValidationError
+	a.ErrorAs(err, &validationErr)
+	a.Len(validationErr.Fields, 1)
+	a.Equal("roles[1]", validationErr.Fields[0].Field)
+	a.Equal("role", validationErr.Fields[0].Rule)
+	a.Equal("is not a known role", validationErr.Fields[0].Message)
+}
+
+func TestValidateStruct_Valid(t *testing.T) {
+	req := LoginUserRequest{Email: "john@gmail.com", Password: "secret"}
+	assert.NoError(t, req.Validate())
+}
+
+func TestDecodeJSON(t *testing.T) {
+	testScenarios := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedDetail string
+	}{
+		{name: "unknown field", body: `{"email":"a@b.com","password":"x","admin":true}`, expectedStatus: http.StatusBadRequest, expectedDetail: `Request body has unknown field "admin"`},
+		{name: "trailing data", body: `{"email":"a@b.com","password":"x"}{}`, expectedStatus: http.StatusBadRequest, expectedDetail: "Request body must contain a single JSON value"},
+		{name: "malformed", body: `{"email":`, expectedStatus: http.StatusBadRequest, expectedDetail: "Request body contains malformed JSON"},
+		{name: "wrong type", body: `{"email":42}`, expectedStatus: http.StatusBadRequest, expectedDetail: `Request body has the wrong type for field "email"`},
+		{name: "empty", body: ``, expectedStatus: http.StatusBadRequest, expectedDetail: "Request body must not be empty"},
+		{name: "too large", body: `{"email":"` + strings.Repeat("a", maxRequestBodySize) + `"}`, expectedStatus: http.StatusRequestEntityTooLarge, expectedDetail: "Request body must not exceed 1048576 bytes"},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+
+			// given
+			req := httptest.NewRequest(http.MethodPost, "/users/login", bytes.NewBufferString(scenario.body))
+			res := httptest.NewRecorder()
+			var dst LoginUserRequest
+
+			// when
+			err := decodeJSON(res, req, &dst)
+
+			// then
+			var reqErr *requestError
+			a.ErrorAs(err, &reqErr)
+			a.Equal(scenario.expectedStatus, reqErr.status)
+			a.Equal(scenario.expectedDetail, reqErr.Error())
+		})
+	}
+}
+
+func TestDecodeJSON_TrailingWhitespace(t *testing.T) {
+	a := assert.New(t)
+
+	// given
+	req := httptest.NewRequest(http.MethodPost, "/users/login", bytes.NewBufferString("{\"email\":\"a@b.com\",\"password\":\"x\"}\n"))
+	var dst LoginUserRequest
+
+	// when
+	err := decodeJSON(httptest.NewRecorder(), req, &dst)
+
+	// then
+	a.NoError(err)
+	a.Equal(LoginUserRequest{Email: "a@b.com", Password: "x"}, dst)
+}
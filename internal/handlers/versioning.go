@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"go-rest-api/internal/metrics"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// versionMediaType is the vendor media type clients put in Accept to select
+// an API version on routes without a version prefix, e.g.
+// application/vnd.gorestapi.v2+json.
+func versionMediaType(name string) string {
+	return "application/vnd.gorestapi." + name + "+json"
+}
+
+// APIVersion is a version of the REST API, served below /<Name>.
+type APIVersion struct {
+	Name string
+	// DeprecatedAt is announced in the Deprecation header of the version's
+	// responses. It is zero while the version is supported.
+	DeprecatedAt time.Time
+	// SunsetAt is announced in the Sunset header of the version's responses.
+	// It is zero when no date has been set.
+	SunsetAt time.Time
+}
+
+// Deprecated reports whether the version has been deprecated.
+func (v APIVersion) Deprecated() bool {
+	return !v.DeprecatedAt.IsZero()
+}
+
+// VersionMiddleware selects the API version of a request and stores its name
+// in the request context for the handlers to pick a representation. prefix is
+// the version the route is registered under, e.g. "v1"; it is stripped from
+// the request path so that handlers see the same paths in every version. On
+// routes without a prefix the version is negotiated from the Accept header,
+// falling back to the first of versions, which is the API served at the root
+// before versioning. path and method label the deprecated requests metric.
+func VersionMiddleware(next httprouter.Handle, versions []APIVersion, prefix, path, method string) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		version, negotiated, ok := selectVersion(r, versions, prefix)
+		if !ok {
+			names := make([]string, 0, len(versions))
+			for _, v := range versions {
+				names = append(names, v.Name)
+			}
+			writeError(w, r, nil, &requestError{http.StatusNotAcceptable, "api_version_not_acceptable", map[string]string{"versions": strings.Join(names, ", ")}})
+			return
+		}
+
+		if prefix == "" {
+			w.Header().Add("Vary", "Accept")
+		}
+		if negotiated {
+			// ... answer in the media type the client asked for, handlers
+			// that send another type, such as problems, override it
+			w.Header().Set("Content-Type", versionMediaType(version.Name))
+		}
+		if version.Deprecated() {
+			w.Header().Set("Deprecation", "@"+strconv.FormatInt(version.DeprecatedAt.Unix(), 10))
+			if !version.SunsetAt.IsZero() {
+				w.Header().Set("Sunset", version.SunsetAt.UTC().Format(http.TimeFormat))
+			}
+			metrics.DeprecatedRequestCount.WithLabelValues(version.Name, path, method).Inc()
+		}
+
+		ctx := context.WithValue(r.Context(), "api_version", version.Name)
+		if prefix != "" {
+			ctx = context.WithValue(ctx, "api_prefix", "/"+prefix)
+			r = r.Clone(ctx)
+			r.URL.Path = strings.TrimPrefix(r.URL.Path, "/"+prefix)
+			r.URL.RawPath = strings.TrimPrefix(r.URL.RawPath, "/"+prefix)
+		}
+		next(w, r.WithContext(ctx), ps)
+	}
+}
+
+// selectVersion returns the version a request is for and whether it was
+// negotiated from the Accept header. ok is false when Accept asks for a
+// version that is not served.
+func selectVersion(r *http.Request, versions []APIVersion, prefix string) (version APIVersion, negotiated, ok bool) {
+	if prefix != "" {
+		for _, v := range versions {
+			if v.Name == prefix {
+				return v, false, true
+			}
+		}
+	}
+
+	requested := false
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil || !strings.HasPrefix(mediaType, "application/vnd.gorestapi.") {
+			continue
+		}
+		requested = true
+		for _, v := range versions {
+			if mediaType == versionMediaType(v.Name) {
+				return v, true, true
+			}
+		}
+	}
+	// ... a client asking only for versions we do not serve gets no default
+	return versions[0], false, !requested
+}
+
+// requestAPIVersion returns the version chosen by VersionMiddleware, or "v1"
+// when the middleware did not run.
+func requestAPIVersion(r *http.Request) string {
+	if version, ok := r.Context().Value("api_version").(string); ok && version != "" {
+		return version
+	}
+	return "v1"
+}
+
+// versionedPath returns path below the version prefix r was routed under,
+// e.g. /v2/users/imports/<id>, so that links keep clients on the version they
+// chose. Requests to routes without a prefix get path as it is.
+func versionedPath(r *http.Request, path string) string {
+	prefix, _ := r.Context().Value("api_prefix").(string)
+	return prefix + path
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"go-rest-api/internal/core"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionMiddleware(t *testing.T) {
+	versions := []APIVersion{
+		{Name: "v1", DeprecatedAt: time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), SunsetAt: time.Date(2027, 4, 19, 0, 0, 0, 0, time.UTC)},
+		{Name: "v2"},
+	}
+
+	testScenarios := []struct {
+		name                string
+		prefix              string
+		target              string
+		accept              string
+		expectedStatus      int
+		expectedVersion     string
+		expectedPath        string
+		expectedContentType string
+		expectedDeprecation string
+		expectedSunset      string
+	}{
+		{
+			name:            "version prefix",
+			prefix:          "v2",
+			target:          "/v2/users/42",
+			expectedStatus:  http.StatusOK,
+			expectedVersion: "v2",
+			expectedPath:    "/users/42",
+		},
+		{
+			name:                "deprecated version prefix",
+			prefix:              "v1",
+			target:              "/v1/users/42",
+			expectedStatus:      http.StatusOK,
+			expectedVersion:     "v1",
+			expectedPath:        "/users/42",
+			expectedDeprecation: "@1792368000",
+			expectedSunset:      "Mon, 19 Apr 2027 00:00:00 GMT",
+		},
+		{
+			name:                "root defaults to the first version",
+			target:              "/users/42",
+			accept:              "application/json",
+			expectedStatus:      http.StatusOK,
+			expectedVersion:     "v1",
+			expectedPath:        "/users/42",
+			expectedDeprecation: "@1792368000",
+			expectedSunset:      "Mon, 19 Apr 2027 00:00:00 GMT",
+		},
+		{
+			name:                "root negotiates from accept",
+			target:              "/users/42",
+			accept:              "application/vnd.gorestapi.v3+json, application/vnd.gorestapi.v2+json",
+			expectedStatus:      http.StatusOK,
+			expectedVersion:     "v2",
+			expectedPath:        "/users/42",
+			expectedContentType: "application/vnd.gorestapi.v2+json",
+		},
+		{
+			name:           "unknown version",
+			target:         "/users/42",
+			accept:         "application/vnd.gorestapi.v3+json",
+			expectedStatus: http.StatusNotAcceptable,
+		},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			var version, path string
+			handle := VersionMiddleware(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+				version, path = requestAPIVersion(r), r.URL.Path
+			}, versions, scenario.prefix, "/users/:id", "GET")
+			req := httptest.NewRequest(http.MethodGet, scenario.target, nil)
+			req.Header.Set("Accept", scenario.accept)
+			res := httptest.NewRecorder()
+
+			// when
+			handle(res, req, nil)
+
+			// then
+			a.Equal(scenario.expectedStatus, res.Code)
+			a.Equal(scenario.expectedVersion, version)
+			a.Equal(scenario.expectedPath, path)
+			if scenario.expectedStatus == http.StatusOK {
+				a.Equal(scenario.expectedContentType, res.Header().Get("Content-Type"))
+			}
+			a.Equal(scenario.expectedDeprecation, res.Header().Get("Deprecation"))
+			a.Equal(scenario.expectedSunset, res.Header().Get("Sunset"))
+		})
+	}
+}
+
+func TestVersionMiddleware_NotAcceptableProblem(t *testing.T) {
+	a := assert.New(t)
+	// given
+	handle := VersionMiddleware(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {}, []APIVersion{{Name: "v1"}, {Name: "v2"}}, "", "/users", "GET")
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("Accept", "application/vnd.gorestapi.v3+json")
+	res := httptest.NewRecorder()
+
+	// when
+	handle(res, req, nil)
+
+	// then
+	var problem Problem
+	a.NoError(json.NewDecoder(res.Body).Decode(&problem))
+	a.Equal("api_version_not_acceptable", problem.Code)
+	a.Equal("The requested API version is not served, ask for one of v1, v2", problem.Detail)
+}
+
+func TestUserResponse(t *testing.T) {
+	a := assert.New(t)
+	// given
+	user := core.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com", Version: 3}
+	v1 := httptest.NewRequest(http.MethodGet, "/users", nil)
+	v2 := v1.WithContext(context.WithValue(v1.Context(), "api_version", "v2"))
+
+	// when
+	responseV1, _ := json.Marshal(userResponse(v1, user))
+	responseV2, _ := json.Marshal(userResponse(v2, user))
+
+	// then
+	a.JSONEq(`{"id":"`+user.ID.String()+`","username":"alice","email":"alice@example.com","avatar_url":"","roles":null,"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}`, string(responseV1))
+	a.JSONEq(`{"id":"`+user.ID.String()+`","username":"alice","email":"alice@example.com","avatar_url":"","roles":[],"profile":{},"version":3,"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z"}`, string(responseV2))
+}
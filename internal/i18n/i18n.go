@@ -0,0 +1,80 @@
+// Package i18n holds the message catalogs for client facing API messages and
+// negotiates which of the supported languages a client gets.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// DefaultLocale is used when the client accepts none of the supported locales
+// and as the fallback for messages missing from a catalog.
+const DefaultLocale = "en"
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// supported lists the catalog locales; the first entry is the default.
+var supported = []language.Tag{language.English, language.French, language.Zulu}
+
+var (
+	matcher  = language.NewMatcher(supported)
+	catalogs = mustLoadCatalogs()
+)
+
+func mustLoadCatalogs() map[string]map[string]string {
+	catalogs := make(map[string]map[string]string, len(supported))
+	for _, tag := range supported {
+		locale := tag.String()
+		data, err := localeFiles.ReadFile("locales/" + locale + ".json")
+		if err != nil {
+			panic(fmt.Sprintf("i18n: missing catalog for %s: %v", locale, err))
+		}
+		catalog := map[string]string{}
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			panic(fmt.Sprintf("i18n: invalid catalog for %s: %v", locale, err))
+		}
+		catalogs[locale] = catalog
+	}
+	return catalogs
+}
+
+// Negotiate picks the best supported locale for an Accept-Language header
+// value, falling back to DefaultLocale.
+func Negotiate(acceptLanguage string) string {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return DefaultLocale
+	}
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return DefaultLocale
+	}
+	return supported[index].String()
+}
+
+// Translate returns the message for code in the given locale with {name}
+// placeholders replaced from params. Missing translations fall back to the
+// default locale, and unknown codes to the code itself.
+func Translate(locale, code string, params map[string]string) string {
+	message, ok := catalogs[locale][code]
+	if !ok {
+		message, ok = catalogs[DefaultLocale][code]
+	}
+	if !ok {
+		return code
+	}
+	if len(params) == 0 {
+		return message
+	}
+
+	replacements := make([]string, 0, len(params)*2)
+	for name, value := range params {
+		replacements = append(replacements, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(replacements...).Replace(message)
+}
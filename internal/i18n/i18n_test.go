@@ -0,0 +1,47 @@
+package i18n
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatalogs_HaveTheSameCodes(t *testing.T) {
+	for locale, catalog := range catalogs {
+		for code := range catalogs[DefaultLocale] {
+			assert.Contains(t, catalog, code, "%s catalog is missing %s", locale, code)
+		}
+		for code := range catalog {
+			assert.Contains(t, catalogs[DefaultLocale], code, "%s catalog has unknown code %s", locale, code)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	testScenarios := []struct {
+		acceptLanguage string
+		expected       string
+	}{
+		{acceptLanguage: "", expected: "en"},
+		{acceptLanguage: "fr", expected: "fr"},
+		{acceptLanguage: "fr-BE", expected: "fr"},
+		{acceptLanguage: "de-DE,zu;q=0.8,en;q=0.5", expected: "zu"},
+		{acceptLanguage: "en-ZA,zu;q=0.9", expected: "en"},
+		{acceptLanguage: "de-DE", expected: "en"},
+		{acceptLanguage: "not a header;;", expected: "en"},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.acceptLanguage, func(t *testing.T) {
+			assert.Equal(t, scenario.expected, Negotiate(scenario.acceptLanguage))
+		})
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	a := assert.New(t)
+
+	a.Equal("Le rôle admin est obligatoire", Translate("fr", "role_required", map[string]string{"role": "admin"}))
+	a.Equal("User not found", Translate("de", "user_not_found", nil))
+	a.Equal("no_such_code", Translate("fr", "no_such_code", nil))
+}
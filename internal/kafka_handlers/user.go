@@ -4,8 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"go-rest-api/internal/core"
+	"go-rest-api/internal/metrics"
 	"go-rest-api/pkg/kafka"
 	"go-rest-api/pkg/logger"
+	"time"
 )
 
 type UserEventService struct {
@@ -25,14 +27,65 @@ func NewUserEventService(producer *kafka.Producer, logger logger.CustomLogger, t
 func (s UserEventService) PublishUserCreatedEvent(ctx context.Context, user *core.User) error {
 	userDataBytes, err := json.Marshal(user)
 	if err != nil {
-		s.logger.Error("failed to marshal user data: ", err)
+		s.logger.FromContext(ctx).Error("failed to marshal user data", "error", err)
 		return err
 	}
-	err = s.producer.Produce(s.topic, user.ID.String(), userDataBytes)
+	err = s.produce(ctx, user.ID.String(), userDataBytes)
 	if err != nil {
-		s.logger.Error("failed to produce user created event: ", err)
+		s.logger.FromContext(ctx).Error("failed to produce user created event", "error", err)
 		return err
 	}
-	s.logger.Info("user created event successfully published to topic: ", s.topic)
+	s.logger.FromContext(ctx).Info("user created event published", "topic", s.topic, "user_id", user.ID)
 	return nil
 }
+
+// UsersImportedEvent summarises a finished bulk import.
+type UsersImportedEvent struct {
+	ImportID     string    `json:"import_id"`
+	Status       string    `json:"status"`
+	CreatedBy    string    `json:"created_by"`
+	TotalRows    int       `json:"total_rows"`
+	ImportedRows int       `json:"imported_rows"`
+	FailedRows   int       `json:"failed_rows"`
+	FinishedAt   time.Time `json:"finished_at"`
+}
+
+func (s UserEventService) PublishUsersImportedEvent(ctx context.Context, userImport *core.UserImport) error {
+	event := UsersImportedEvent{
+		ImportID:     userImport.ID.String(),
+		Status:       userImport.Status,
+		CreatedBy:    userImport.CreatedBy.String(),
+		TotalRows:    userImport.TotalRows,
+		ImportedRows: userImport.ImportedRows,
+		FailedRows:   userImport.FailedRows,
+	}
+	if userImport.FinishedAt != nil {
+		event.FinishedAt = *userImport.FinishedAt
+	}
+	eventBytes, err := json.Marshal(event)
+	if err != nil {
+		s.logger.FromContext(ctx).Error("failed to marshal users imported event", "error", err)
+		return err
+	}
+	err = s.produce(ctx, userImport.ID.String(), eventBytes)
+	if err != nil {
+		s.logger.FromContext(ctx).Error("failed to produce users imported event", "error", err)
+		return err
+	}
+	s.logger.FromContext(ctx).Info("users imported event published", "topic", s.topic, "import_id", userImport.ID)
+	return nil
+}
+
+// produce sends a message to the topic of the service and records its
+// delivery metrics.
+func (s UserEventService) produce(ctx context.Context, key string, value []byte) error {
+	start := time.Now()
+	err := s.producer.Produce(ctx, s.topic, key, value)
+	metrics.KafkaDeliveryDuration.WithLabelValues(s.topic).Observe(time.Since(start).Seconds())
+	result := "delivered"
+	if err != nil {
+		result = "failed"
+	}
+	metrics.KafkaMessagesProduced.WithLabelValues(s.topic, result).Inc()
+	return err
+}
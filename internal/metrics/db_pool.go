@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DBPoolCollector exposes the statistics of a pgx connection pool, read
+// afresh on every scrape.
+type DBPoolCollector struct {
+	pool *pgxpool.Pool
+
+	acquiredConns       *prometheus.Desc
+	idleConns           *prometheus.Desc
+	constructingConns   *prometheus.Desc
+	totalConns          *prometheus.Desc
+	maxConns            *prometheus.Desc
+	acquires            *prometheus.Desc
+	acquireDuration     *prometheus.Desc
+	emptyAcquires       *prometheus.Desc
+	emptyAcquireWait    *prometheus.Desc
+	canceledAcquires    *prometheus.Desc
+	newConns            *prometheus.Desc
+	maxLifetimeDestroys *prometheus.Desc
+	maxIdleDestroys     *prometheus.Desc
+}
+
+// NewDBPoolCollector returns a collector for pool, to be registered once.
+func NewDBPoolCollector(pool *pgxpool.Pool) *DBPoolCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName("go_rest_api", "db_pool", name), help, nil, nil)
+	}
+	return &DBPoolCollector{
+		pool:                pool,
+		acquiredConns:       desc("acquired_connections", "Number of connections currently in use"),
+		idleConns:           desc("idle_connections", "Number of idle connections"),
+		constructingConns:   desc("constructing_connections", "Number of connections being established"),
+		totalConns:          desc("connections", "Number of open connections, in use, idle or being established"),
+		maxConns:            desc("max_connections", "Maximum number of connections"),
+		acquires:            desc("acquires_total", "Total number of connections acquired"),
+		acquireDuration:     desc("acquire_duration_seconds_total", "Total time spent acquiring connections"),
+		emptyAcquires:       desc("empty_acquires_total", "Total number of acquires that had to wait for a connection"),
+		emptyAcquireWait:    desc("empty_acquire_wait_seconds_total", "Total time spent waiting for a connection when none was idle"),
+		canceledAcquires:    desc("canceled_acquires_total", "Total number of acquires canceled by their context"),
+		newConns:            desc("new_connections_total", "Total number of connections opened"),
+		maxLifetimeDestroys: desc("max_lifetime_destroys_total", "Total number of connections closed for reaching their maximum lifetime"),
+		maxIdleDestroys:     desc("max_idle_destroys_total", "Total number of connections closed for staying idle too long"),
+	}
+}
+
+func (c *DBPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *DBPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(c.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.constructingConns, prometheus.GaugeValue, float64(stat.ConstructingConns()))
+	ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquires, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquires, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquireWait, prometheus.CounterValue, stat.EmptyAcquireWaitTime().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.canceledAcquires, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.newConns, prometheus.CounterValue, float64(stat.NewConnsCount()))
+	ch <- prometheus.MustNewConstMetric(c.maxLifetimeDestroys, prometheus.CounterValue, float64(stat.MaxLifetimeDestroyCount()))
+	ch <- prometheus.MustNewConstMetric(c.maxIdleDestroys, prometheus.CounterValue, float64(stat.MaxIdleDestroyCount()))
+}
@@ -5,11 +5,37 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+// RequestCount labels requests with their numeric status, such as "404", and
+// its class, such as "4xx", for alerting on error rates.
 var RequestCount = promauto.NewCounterVec(prometheus.CounterOpts{
 	Namespace: "go_rest_api",
 	Name:      "http_requests_total",
 	Help:      "Total number of HTTP requests",
-}, []string{"path", "method", "status"})
+}, []string{"path", "method", "status", "status_class"})
+
+var RequestsInFlight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "go_rest_api",
+	Name:      "http_requests_in_flight",
+	Help:      "Number of HTTP requests being served",
+}, []string{"path", "method"})
+
+// sizeBuckets go from 100 bytes to 10 MB, the largest avatar accepted.
+var sizeBuckets = prometheus.ExponentialBuckets(100, 10, 6)
+
+var RequestSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "go_rest_api",
+	Name:      "http_request_size_bytes",
+	Help:      "Size of HTTP request bodies",
+	Buckets:   sizeBuckets,
+}, []string{"path", "method"})
+
+// ResponseSize is measured before compression.
+var ResponseSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "go_rest_api",
+	Name:      "http_response_size_bytes",
+	Help:      "Size of HTTP response bodies, before compression",
+	Buckets:   sizeBuckets,
+}, []string{"path", "method"})
 
 var RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
 	Namespace: "go_rest_api",
@@ -17,3 +43,58 @@ var RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
 	Help:      "Duration of HTTP requests",
 	Buckets:   prometheus.DefBuckets,
 }, []string{"path", "method"})
+
+var GRPCRequestCount = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "go_rest_api",
+	Name:      "grpc_requests_total",
+	Help:      "Total number of gRPC requests",
+}, []string{"method", "code"})
+
+var GRPCRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "go_rest_api",
+	Name:      "grpc_request_duration_seconds",
+	Help:      "Duration of gRPC requests",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"method"})
+
+var DeprecatedRequestCount = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "go_rest_api",
+	Name:      "deprecated_api_requests_total",
+	Help:      "Total number of HTTP requests to deprecated API versions",
+}, []string{"version", "path", "method"})
+
+// KafkaMessagesProduced labels messages with their result, "delivered" or
+// "failed".
+var KafkaMessagesProduced = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "go_rest_api",
+	Name:      "kafka_messages_produced_total",
+	Help:      "Total number of Kafka messages produced",
+}, []string{"topic", "result"})
+
+var KafkaDeliveryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "go_rest_api",
+	Name:      "kafka_delivery_duration_seconds",
+	Help:      "Time from producing a Kafka message to its delivery report",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"topic"})
+
+var Signups = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: "go_rest_api",
+	Name:      "signups_total",
+	Help:      "Total number of users created by signing up or accepting an invitation",
+})
+
+// Logins labels login attempts with their result, "succeeded" or "failed".
+// Unknown emails and wrong passwords both count as failed.
+var Logins = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "go_rest_api",
+	Name:      "logins_total",
+	Help:      "Total number of login attempts",
+}, []string{"result"})
+
+// TokensIssued labels tokens with their type, "auth" or "impersonation".
+var TokensIssued = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "go_rest_api",
+	Name:      "tokens_issued_total",
+	Help:      "Total number of JWTs issued",
+}, []string{"type"})
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_users_profile;
+ALTER TABLE users DROP COLUMN IF EXISTS profile;
\ No newline at end of file
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS user_imports (
+    id UUID PRIMARY KEY,
+    status VARCHAR(20) NOT NULL,
+    format VARCHAR(10) NOT NULL,
+    payload BYTEA NOT NULL,
+    created_by UUID NOT NULL REFERENCES users(id),
+    total_rows INTEGER NOT NULL DEFAULT 0,
+    processed_rows INTEGER NOT NULL DEFAULT 0,
+    imported_rows INTEGER NOT NULL DEFAULT 0,
+    failed_rows INTEGER NOT NULL DEFAULT 0,
+    errors JSONB NOT NULL DEFAULT '[]'::jsonb,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_imports_status ON user_imports(status, created_at);
\ No newline at end of file
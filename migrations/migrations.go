@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// files are the migrations applied by the migrate tool, embedded so that the
+// API knows which schema version it was built for.
+//
+//go:embed *.up.sql
+var files embed.FS
+
+// LatestVersion returns the version of the newest migration, the one the
+// database must be at for the API to be ready.
+func LatestVersion() (uint, error) {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return 0, err
+	}
+	var latest uint
+	for _, entry := range entries {
+		prefix, _, _ := strings.Cut(entry.Name(), "_")
+		version, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("migration %s has no version: %w", entry.Name(), err)
+		}
+		latest = max(latest, uint(version))
+	}
+	return latest, nil
+}
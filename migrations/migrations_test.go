@@ -0,0 +1,17 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatestVersion(t *testing.T) {
+	a := assert.New(t)
+	// when
+	version, err := LatestVersion()
+
+	// then
+	a.NoError(err)
+	a.NotZero(version)
+}
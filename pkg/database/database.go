@@ -22,6 +22,9 @@ func Connect(cfg DbConfig, logger logger.CustomLogger) (*pgxpool.Pool, error) {
 	connConfig.MinConns = 1
 	connConfig.MaxConnIdleTime = 2 * time.Minute // 2 minutes
 
+	// ... trace every query as a child of the span of the caller
+	connConfig.ConnConfig.Tracer = NewQueryTracer()
+
 	dbpool, err := pgxpool.NewWithConfig(context.Background(), connConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create database connection pool: %w", err)
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrMigrationsPending is returned by CheckMigrations when the schema is older
+// than the API expects.
+var ErrMigrationsPending = errors.New("migrations pending")
+
+// CheckMigrations returns an error unless the schema_migrations table of the
+// migrate tool is at version and was not left dirty by a failed migration.
+// A newer schema is accepted, so that instances of the previous release stay
+// ready while a deployment migrates the database.
+func CheckMigrations(ctx context.Context, pool *pgxpool.Pool, version uint) error {
+	var current uint
+	var dirty bool
+	err := pool.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&current, &dirty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("%w: no migration applied, want version %d", ErrMigrationsPending, version)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("migration %d is dirty", current)
+	}
+	if current < version {
+		return fmt.Errorf("%w: schema at version %d, want %d", ErrMigrationsPending, current, version)
+	}
+	return nil
+}
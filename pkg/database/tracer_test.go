@@ -0,0 +1,59 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestQueryTracer(t *testing.T) {
+	testScenarios := []struct {
+		name           string
+		sql            string
+		err            error
+		expectedName   string
+		expectedStatus codes.Code
+	}{
+		{name: "insert", sql: "INSERT INTO users (id) VALUES ($1)", expectedName: "INSERT"},
+		{name: "no rows", sql: "\n\tselect * FROM users WHERE id = $1", err: pgx.ErrNoRows, expectedName: "SELECT"},
+		{name: "failure", sql: "UPDATE users SET version = version + 1", err: assert.AnError, expectedName: "UPDATE", expectedStatus: codes.Error},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			recorder := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			tracer := &QueryTracer{tracer: provider.Tracer(tracerName)}
+			ctx, parent := provider.Tracer("test").Start(context.Background(), "UserRepository.CreateUser")
+
+			// when
+			queryCtx := tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: scenario.sql, Args: []any{"secret"}})
+			tracer.TraceQueryEnd(queryCtx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("INSERT 0 1"), Err: scenario.err})
+			parent.End()
+
+			// then
+			spans := recorder.Ended()
+			a.Len(spans, 2)
+			span := spans[0]
+			a.Equal(scenario.expectedName, span.Name())
+			a.Equal(trace.SpanKindClient, span.SpanKind())
+			a.Equal(parent.SpanContext().SpanID(), span.Parent().SpanID())
+			a.Equal(scenario.expectedStatus, span.Status().Code)
+			a.Contains(span.Attributes(), attribute.String("db.query.text", scenario.sql))
+			a.Contains(span.Attributes(), attribute.Int64("db.rows_affected", 1))
+			for _, attr := range span.Attributes() {
+				a.NotEqual("secret", attr.Value.Emit())
+			}
+		})
+	}
+}
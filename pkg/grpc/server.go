@@ -0,0 +1,36 @@
+package grpc
+
+import (
+	"fmt"
+	"go-rest-api/pkg/logger"
+	"net"
+	"strconv"
+
+	"google.golang.org/grpc"
+)
+
+// StartServer serves server on port in the background. Stop it with
+// GracefulStop once the HTTP server has shut down.
+func StartServer(port string, server *grpc.Server, logger logger.CustomLogger) {
+	// validate port
+	if port == "" {
+		port = "9090" // default port
+	}
+	// validate port is an number
+	_, err := strconv.Atoi(port)
+	if err != nil {
+		logger.Fatal("gRPC port must be a number.")
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%s", port))
+	if err != nil {
+		logger.Fatal("Failed to listen for gRPC", "error", err)
+	}
+
+	go func() {
+		if err := server.Serve(listener); err != nil && err != grpc.ErrServerStopped {
+			logger.Fatal("Failed to start grpc server", "error", err)
+		}
+	}()
+	logger.Info("Starting grpc server", "port", port)
+}
@@ -0,0 +1,183 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusOK      = "ok"
+	StatusFailing = "failing"
+)
+
+// Checker checks a dependency the API cannot serve requests without.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc lets a function, such as the Ping method of a pool, be used as
+// a Checker.
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Options are the defaults of the checks registered without their own.
+type Options struct {
+	// Timeout bounds each run of a check. A check still running when it
+	// expires fails, even if it ignores its context.
+	Timeout time.Duration
+	// CacheTTL is how long the result of a check is reused, so that frequent
+	// probes from several sources do not load the dependency.
+	CacheTTL time.Duration
+}
+
+// Check is a named Checker with its own timeout and cache TTL. Zero values
+// take those of the Registry; a negative CacheTTL runs the check every time.
+type Check struct {
+	Name     string
+	Checker  Checker
+	Timeout  time.Duration
+	CacheTTL time.Duration
+}
+
+// Result is the outcome of the last run of a check. Error is left out of the
+// JSON form, since the probe is public and errors can reveal hosts and
+// credentials; log it instead.
+type Result struct {
+	Status    string    `json:"status"`
+	LatencyMs float64   `json:"latency_ms"`
+	Error     error     `json:"-"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Report is the readiness of the API: ok when every check passes.
+type Report struct {
+	Status       string            `json:"status"`
+	ShuttingDown bool              `json:"shutting_down,omitempty"`
+	Checks       map[string]Result `json:"checks"`
+}
+
+// Registry runs the registered checks for the readiness probe.
+type Registry struct {
+	options      Options
+	mu           sync.RWMutex
+	checks       []*check
+	shuttingDown atomic.Bool
+}
+
+func NewRegistry(options Options) *Registry {
+	return &Registry{options: options}
+}
+
+// Register adds c to the checks run by Check.
+func (r *Registry) Register(c Check) {
+	if c.Timeout == 0 {
+		c.Timeout = r.options.Timeout
+	}
+	if c.CacheTTL == 0 {
+		c.CacheTTL = r.options.CacheTTL
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, &check{Check: c})
+}
+
+// Shutdown marks the API as no longer ready, so that load balancers stop
+// sending it requests while it drains the ones in flight. It is called at the
+// start of a graceful shutdown and cannot be undone.
+func (r *Registry) Shutdown() {
+	r.shuttingDown.Store(true)
+}
+
+// Check runs the registered checks concurrently, or reuses their cached
+// results, and reports whether all of them passed. Once Shutdown is called it
+// fails without running them.
+func (r *Registry) Check(ctx context.Context) Report {
+	report := Report{Status: StatusOK, Checks: map[string]Result{}}
+	if r.shuttingDown.Load() {
+		report.Status = StatusFailing
+		report.ShuttingDown = true
+		return report
+	}
+
+	r.mu.RLock()
+	checks := r.checks
+	r.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.result(ctx)
+		}()
+	}
+	wg.Wait()
+
+	for i, c := range checks {
+		report.Checks[c.Name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFailing
+		}
+	}
+	return report
+}
+
+// check holds the cached result of a Check. Concurrent probes wait for a
+// single run rather than each running the check.
+type check struct {
+	Check
+	mu      sync.Mutex
+	last    Result
+	expires time.Time
+}
+
+func (c *check) result(ctx context.Context) Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Now().Before(c.expires) {
+		return c.last
+	}
+
+	start := time.Now()
+	err := c.run(ctx)
+	c.last = Result{
+		Status:    StatusOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt: start.UTC(),
+	}
+	if err != nil {
+		c.last.Status = StatusFailing
+		c.last.Error = err
+	}
+	c.expires = start.Add(c.CacheTTL)
+	return c.last
+}
+
+// run runs the checker within the timeout of the check, giving up on it when
+// the timeout expires. A probe that goes away does not cancel the run, which
+// would cache a failure the dependency is not to blame for.
+func (c *check) run(ctx context.Context) error {
+	ctx = context.WithoutCancel(ctx)
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Checker.Check(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timed out after %s", c.Timeout)
+	}
+}
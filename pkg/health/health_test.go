@@ -0,0 +1,86 @@
+package health
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_Check(t *testing.T) {
+	testScenarios := []struct {
+		name           string
+		checker        CheckerFunc
+		expectedStatus string
+		expectedError  string
+	}{
+		{name: "passing", checker: func(ctx context.Context) error { return nil }, expectedStatus: StatusOK},
+		{name: "failing", checker: func(ctx context.Context) error { return assert.AnError }, expectedStatus: StatusFailing, expectedError: assert.AnError.Error()},
+		{name: "ignoring its context", checker: func(ctx context.Context) error { time.Sleep(time.Second); return nil }, expectedStatus: StatusFailing, expectedError: "timed out after 10ms"},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			registry := NewRegistry(Options{Timeout: 10 * time.Millisecond})
+			registry.Register(Check{Name: "other", Checker: CheckerFunc(func(ctx context.Context) error { return nil })})
+			registry.Register(Check{Name: "dependency", Checker: scenario.checker})
+
+			// when
+			report := registry.Check(context.Background())
+
+			// then
+			a.Equal(scenario.expectedStatus, report.Status)
+			a.Equal(StatusOK, report.Checks["other"].Status)
+			a.Equal(scenario.expectedStatus, report.Checks["dependency"].Status)
+			if scenario.expectedError == "" {
+				a.NoError(report.Checks["dependency"].Error)
+				return
+			}
+			a.EqualError(report.Checks["dependency"].Error, scenario.expectedError)
+		})
+	}
+}
+
+func TestRegistry_Check_CachesResults(t *testing.T) {
+	a := assert.New(t)
+	// given
+	var runs atomic.Int32
+	registry := NewRegistry(Options{Timeout: time.Second, CacheTTL: time.Minute})
+	registry.Register(Check{Name: "cached", Checker: CheckerFunc(func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	})})
+	registry.Register(Check{Name: "uncached", CacheTTL: -1, Checker: CheckerFunc(func(ctx context.Context) error {
+		runs.Add(10)
+		return nil
+	})})
+
+	// when
+	first := registry.Check(context.Background())
+	second := registry.Check(context.Background())
+
+	// then
+	a.Equal(int32(21), runs.Load())
+	a.Equal(first.Checks["cached"], second.Checks["cached"])
+}
+
+func TestRegistry_Shutdown(t *testing.T) {
+	a := assert.New(t)
+	// given
+	registry := NewRegistry(Options{Timeout: time.Second})
+	registry.Register(Check{Name: "dependency", Checker: CheckerFunc(func(ctx context.Context) error { return nil })})
+	a.Equal(StatusOK, registry.Check(context.Background()).Status)
+
+	// when
+	registry.Shutdown()
+
+	// then
+	report := registry.Check(context.Background())
+	a.Equal(StatusFailing, report.Status)
+	a.True(report.ShuttingDown)
+	a.Empty(report.Checks)
+}
@@ -10,11 +10,13 @@ import (
 	"strconv"
 	"syscall"
 	"time"
-
-	"github.com/julienschmidt/httprouter"
 )
 
-func StartServer(port string, router *httprouter.Router, logger logger.CustomLogger) {
+// StartServer serves handler until an interrupt or terminate signal, then
+// shuts down gracefully. onShutdown, if not nil, runs as soon as the signal
+// arrives, while requests are still served, e.g. to fail the readiness probe
+// and wait for load balancers to notice.
+func StartServer(port string, handler http.Handler, logger logger.CustomLogger, onShutdown func()) {
 	// validate port
 	if port == "" {
 		port = "8080" // default port
@@ -27,7 +29,7 @@ func StartServer(port string, router *httprouter.Router, logger logger.CustomLog
 
 	server := &http.Server{
 		Addr:    fmt.Sprintf(":%s", port),
-		Handler: router,
+		Handler: handler,
 	}
 
 	// ... create a channel to listen for interrupt or terminate signals
@@ -40,11 +42,14 @@ func StartServer(port string, router *httprouter.Router, logger logger.CustomLog
 			logger.Fatal("Failed to start http server", "error", err)
 		}
 	}()
-	logger.Info("Starting http server on port " + port)
+	logger.Info("Starting http server", "port", port)
 
 	// ... block until we receive our signal
 	<-quit
 	logger.Info("Shutting down server...")
+	if onShutdown != nil {
+		onShutdown()
+	}
 
 	// ... create a context with a timeout for the shutdown process
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
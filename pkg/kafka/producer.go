@@ -1,11 +1,22 @@
 package kafka
 
 import (
+	"context"
 	"go-rest-api/pkg/logger"
+	"strconv"
+	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// RequestIDHeader carries the id of the HTTP request that caused a message, so
+// consumers can correlate it with the API's logs.
+const RequestIDHeader = "X-Request-ID"
+
 type Producer struct {
 	producer *kafka.Producer
 	log      logger.CustomLogger
@@ -16,7 +27,7 @@ func NewProducer(brokers string, logger logger.CustomLogger) (Producer, error) {
 		"bootstrap.servers": brokers,
 	})
 	if err != nil {
-		logger.Error("failed to create kafka producer", err)
+		logger.Error("failed to create kafka producer", "error", err)
 		return Producer{}, err
 	}
 	logger.Info("kafka producer created")
@@ -26,13 +37,39 @@ func NewProducer(brokers string, logger logger.CustomLogger) (Producer, error) {
 	}, nil
 }
 
-func (p *Producer) Produce(topic string, key string, value []byte) error {
-	deliveryChan := make(chan kafka.Event)
-	err := p.producer.Produce(&kafka.Message{
+var tracer = otel.Tracer("go-rest-api/pkg/kafka")
+
+// Produce sends a message and waits for its delivery, within a producer span.
+// The request id stored in ctx, if any, is sent in the RequestIDHeader header
+// and the trace context in the W3C traceparent and tracestate headers, so
+// consumers can continue the trace.
+func (p *Producer) Produce(ctx context.Context, topic string, key string, value []byte) (err error) {
+	ctx, span := tracer.Start(ctx, "send "+topic, trace.WithSpanKind(trace.SpanKindProducer), trace.WithAttributes(
+		semconv.MessagingSystemKafka,
+		semconv.MessagingOperationTypeSend,
+		semconv.MessagingDestinationName(topic),
+		semconv.MessagingKafkaMessageKey(key),
+	))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	message := &kafka.Message{
 		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
 		Key:            []byte(key),
 		Value:          value,
-	}, deliveryChan)
+	}
+	if requestID := logger.RequestIDFromContext(ctx); requestID != "" {
+		message.Headers = append(message.Headers, kafka.Header{Key: RequestIDHeader, Value: []byte(requestID)})
+	}
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier{message})
+
+	deliveryChan := make(chan kafka.Event)
+	err = p.producer.Produce(message, deliveryChan)
 	if err != nil {
 		return err
 	}
@@ -43,10 +80,59 @@ func (p *Producer) Produce(topic string, key string, value []byte) error {
 	if m.TopicPartition.Error != nil {
 		return m.TopicPartition.Error
 	}
+	span.SetAttributes(
+		semconv.MessagingDestinationPartitionID(strconv.Itoa(int(m.TopicPartition.Partition))),
+		semconv.MessagingKafkaOffset(int(m.TopicPartition.Offset)),
+	)
 	close(deliveryChan)
 	return nil
 }
 
+// headerCarrier lets propagators read and write the headers of a message.
+type headerCarrier struct {
+	message *kafka.Message
+}
+
+func (c headerCarrier) Get(key string) string {
+	for _, header := range c.message.Headers {
+		if header.Key == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+// Set replaces any header with the same key, so that a message sent again
+// does not carry two trace contexts.
+func (c headerCarrier) Set(key, value string) {
+	headers := c.message.Headers[:0]
+	for _, header := range c.message.Headers {
+		if header.Key != key {
+			headers = append(headers, header)
+		}
+	}
+	c.message.Headers = append(headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, len(c.message.Headers))
+	for i, header := range c.message.Headers {
+		keys[i] = header.Key
+	}
+	return keys
+}
+
+// Check requests the metadata of the cluster, which fails when no broker can
+// be reached before the deadline of ctx.
+func (p *Producer) Check(ctx context.Context) error {
+	timeout := 5 * time.Second
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	_, err := p.producer.GetMetadata(nil, false, int(timeout.Milliseconds()))
+	return err
+}
+
 func (p *Producer) Close() {
 	p.producer.Close()
 }
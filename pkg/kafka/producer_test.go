@@ -0,0 +1,35 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestHeaderCarrier(t *testing.T) {
+	a := assert.New(t)
+	// given
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled}))
+	message := &kafka.Message{Headers: []kafka.Header{
+		{Key: RequestIDHeader, Value: []byte("req-123")},
+		{Key: "traceparent", Value: []byte("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")},
+	}}
+
+	// when
+	propagation.TraceContext{}.Inject(ctx, headerCarrier{message})
+
+	// then
+	a.Equal([]kafka.Header{
+		{Key: RequestIDHeader, Value: []byte("req-123")},
+		{Key: "traceparent", Value: []byte("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")},
+	}, message.Headers)
+	extracted := trace.SpanContextFromContext(propagation.TraceContext{}.Extract(context.Background(), headerCarrier{message}))
+	a.Equal(traceID, extracted.TraceID())
+	a.Equal(spanID, extracted.SpanID())
+}
@@ -0,0 +1,33 @@
+package logger
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ContextWithRequestID returns a copy of ctx carrying the id of the request
+// being served, for FromContext and the Kafka producer to pick up.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, "request_id", id)
+}
+
+// RequestIDFromContext returns the request id stored in ctx, or "" outside of
+// a request.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value("request_id").(string)
+	return id
+}
+
+// contextFields returns the key-value pairs FromContext adds to log lines: the
+// request id and the ids of the current trace and span, when there are.
+func contextFields(ctx context.Context) []interface{} {
+	var keysAndValues []interface{}
+	if id := RequestIDFromContext(ctx); id != "" {
+		keysAndValues = append(keysAndValues, "request_id", id)
+	}
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		keysAndValues = append(keysAndValues, "trace_id", spanContext.TraceID().String(), "span_id", spanContext.SpanID().String())
+	}
+	return keysAndValues
+}
@@ -0,0 +1,179 @@
+package logger
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// DefaultComponent is the component of loggers that were not given one with
+// Logger.Named.
+const DefaultComponent = "default"
+
+var (
+	ErrUnknownComponent = errors.New("unknown log component")
+	ErrInvalidLevel     = errors.New("invalid log level")
+)
+
+// Levels holds the level of every component's logger. Levels can be changed
+// while the API runs, for good or until an override expires.
+type Levels struct {
+	mu         sync.Mutex
+	components map[string]*componentLevel
+}
+
+type componentLevel struct {
+	level zap.AtomicLevel
+	// configured is the level an override returns to when it expires.
+	configured zapcore.Level
+	expiresAt  time.Time
+	timer      *time.Timer
+}
+
+// ComponentLevel is the state of one component's level.
+type ComponentLevel struct {
+	Component       string
+	Level           string
+	ConfiguredLevel string
+	// ExpiresAt is when Level reverts to ConfiguredLevel, zero when Level is
+	// not an override.
+	ExpiresAt time.Time
+}
+
+func newLevels() *Levels {
+	return &Levels{components: map[string]*componentLevel{}}
+}
+
+// register returns the level of component, adding it at level if it is new.
+func (l *Levels) register(component string, level zapcore.Level) zap.AtomicLevel {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if existing, ok := l.components[component]; ok {
+		return existing.level
+	}
+	l.components[component] = &componentLevel{level: zap.NewAtomicLevelAt(level), configured: level}
+	return l.components[component].level
+}
+
+// level returns the level of component, adding a component that was not
+// configured at the configured level of DefaultComponent.
+func (l *Levels) level(component string) zap.AtomicLevel {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if existing, ok := l.components[component]; ok {
+		return existing.level
+	}
+	configured := zapcore.InfoLevel
+	if defaultLevel, ok := l.components[DefaultComponent]; ok {
+		configured = defaultLevel.configured
+	}
+	l.components[component] = &componentLevel{level: zap.NewAtomicLevelAt(configured), configured: configured}
+	return l.components[component].level
+}
+
+// Set changes the level of component and returns its new state. With a ttl
+// the change is an override that reverts to the configured level once ttl has
+// passed; without one, it becomes the configured level.
+func (l *Levels) Set(component, level string, ttl time.Duration) (ComponentLevel, error) {
+	parsed, err := zapcore.ParseLevel(level)
+	if err != nil || level == "" || parsed < zapcore.DebugLevel || parsed > zapcore.ErrorLevel {
+		return ComponentLevel{}, ErrInvalidLevel
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c, ok := l.components[component]
+	if !ok {
+		return ComponentLevel{}, ErrUnknownComponent
+	}
+	l.set(c, parsed, ttl)
+	return c.state(component), nil
+}
+
+// Shift makes every component more verbose for negative steps, less verbose
+// for positive ones, staying between debug and error. Like Set, a ttl makes
+// the change an override.
+func (l *Levels) Shift(steps int, ttl time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, c := range l.components {
+		level := min(max(c.level.Level()+zapcore.Level(steps), zapcore.DebugLevel), zapcore.ErrorLevel)
+		l.set(c, level, ttl)
+	}
+}
+
+func (l *Levels) set(c *componentLevel, level zapcore.Level, ttl time.Duration) {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.level.SetLevel(level)
+	c.expiresAt = time.Time{}
+	if ttl <= 0 {
+		c.configured = level
+		return
+	}
+
+	expiresAt := time.Now().Add(ttl)
+	c.expiresAt = expiresAt
+	c.timer = time.AfterFunc(ttl, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		// ... unless the override was replaced in the meantime
+		if c.expiresAt.Equal(expiresAt) {
+			c.level.SetLevel(c.configured)
+			c.expiresAt = time.Time{}
+			c.timer = nil
+		}
+	})
+}
+
+// Components returns the level of every component, sorted by name.
+func (l *Levels) Components() []ComponentLevel {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	levels := make([]ComponentLevel, 0, len(l.components))
+	for name, c := range l.components {
+		levels = append(levels, c.state(name))
+	}
+	slices.SortFunc(levels, func(a, b ComponentLevel) int {
+		return strings.Compare(a.Component, b.Component)
+	})
+	return levels
+}
+
+func (c *componentLevel) state(component string) ComponentLevel {
+	return ComponentLevel{
+		Component:       component,
+		Level:           c.level.Level().String(),
+		ConfiguredLevel: c.configured.String(),
+		ExpiresAt:       c.expiresAt,
+	}
+}
+
+// levelCore gates the core all components write to with one component's
+// level. The shared core itself lets every level through.
+type levelCore struct {
+	zapcore.Core
+	level zap.AtomicLevel
+}
+
+func (c levelCore) Enabled(level zapcore.Level) bool {
+	return c.level.Enabled(level)
+}
+
+func (c levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return levelCore{Core: c.Core.With(fields), level: c.level}
+}
+
+func (c levelCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.level.Enabled(entry.Level) {
+		return checked
+	}
+	return c.Core.Check(entry, checked)
+}
@@ -0,0 +1,150 @@
+package logger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newObservedLogger(level zapcore.Level) (Logger, *observer.ObservedLogs) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	levels := newLevels()
+	defaultLevel := levels.register(DefaultComponent, level)
+	base := zap.New(core)
+	logger := base.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return levelCore{Core: core, level: defaultLevel}
+	}))
+	return Logger{logger: logger.Sugar(), base: base, levels: levels}, logs
+}
+
+func TestLogger_Named(t *testing.T) {
+	a := assert.New(t)
+	// given
+	l, logs := newObservedLogger(zapcore.InfoLevel)
+	db := l.Named("db")
+	_, err := l.Levels().Set("db", "debug", 0)
+	a.NoError(err)
+
+	// when
+	l.Debug("skipped")
+	db.Debug("query", "sql", "SELECT 1")
+
+	// then
+	a.Equal(1, logs.Len())
+	a.Equal("db", logs.All()[0].LoggerName)
+	a.Equal("query", logs.All()[0].Message)
+}
+
+func TestLevels_Set(t *testing.T) {
+	testScenarios := []struct {
+		name        string
+		component   string
+		level       string
+		expectedErr error
+	}{
+		{name: "known component", component: DefaultComponent, level: "warn"},
+		{name: "unknown component", component: "cache", level: "warn", expectedErr: ErrUnknownComponent},
+		{name: "unknown level", component: DefaultComponent, level: "verbose", expectedErr: ErrInvalidLevel},
+		{name: "fatal", component: DefaultComponent, level: "fatal", expectedErr: ErrInvalidLevel},
+		{name: "empty level", component: DefaultComponent, expectedErr: ErrInvalidLevel},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			levels := newLevels()
+			levels.register(DefaultComponent, zapcore.InfoLevel)
+
+			// when
+			state, err := levels.Set(scenario.component, scenario.level, 0)
+
+			// then
+			a.Equal(scenario.expectedErr, err)
+			if err == nil {
+				a.Equal(ComponentLevel{Component: scenario.component, Level: scenario.level, ConfiguredLevel: scenario.level}, state)
+			}
+		})
+	}
+}
+
+func TestLevels_SetOverrideExpires(t *testing.T) {
+	a := assert.New(t)
+	// given
+	levels := newLevels()
+	level := levels.register("db", zapcore.InfoLevel)
+
+	// when
+	state, err := levels.Set("db", "debug", 50*time.Millisecond)
+
+	// then
+	a.NoError(err)
+	a.Equal("debug", state.Level)
+	a.Equal("info", state.ConfiguredLevel)
+	a.False(state.ExpiresAt.IsZero())
+	a.True(level.Enabled(zapcore.DebugLevel))
+	a.Eventually(func() bool {
+		return !level.Enabled(zapcore.DebugLevel)
+	}, time.Second, 10*time.Millisecond)
+	a.True(levels.Components()[0].ExpiresAt.IsZero())
+}
+
+func TestLevels_Shift(t *testing.T) {
+	a := assert.New(t)
+	// given
+	levels := newLevels()
+	levels.register(DefaultComponent, zapcore.InfoLevel)
+	levels.register("db", zapcore.DebugLevel)
+
+	// when
+	levels.Shift(-1, time.Minute)
+
+	// then
+	components := levels.Components()
+	a.Equal("db", components[0].Component)
+	a.Equal("debug", components[0].Level)
+	a.Equal(DefaultComponent, components[1].Component)
+	a.Equal("debug", components[1].Level)
+	a.Equal("info", components[1].ConfiguredLevel)
+
+	// when
+	levels.Shift(5, time.Minute)
+
+	// then
+	a.Equal("error", levels.Components()[0].Level)
+}
+
+func TestLogger_UnsampledComponents(t *testing.T) {
+	a := assert.New(t)
+	// given
+	core, logs := observer.New(zapcore.DebugLevel)
+	l, err := newLogger(zap.New(core), Options{
+		ComponentLevels:     map[string]string{"access": "debug"},
+		SamplingInitial:     1,
+		SamplingThereafter:  100,
+		UnsampledComponents: []string{"access", "audit"},
+	})
+	a.NoError(err)
+	http := l.Named("http")
+	access := l.Named("access")
+	audit := l.Named("audit")
+	_, err = l.Levels().Set("access", "error", 0)
+	a.ErrorIs(err, ErrUnknownComponent)
+	l.Levels().Shift(2, 0)
+
+	// when
+	for range 10 {
+		http.Error("request failed")
+		access.Debug("GET /health")
+		audit.Info("impersonated request")
+	}
+
+	// then
+	a.Equal(1, logs.FilterMessage("request failed").Len())
+	a.Equal(10, logs.FilterMessage("GET /health").Len())
+	a.Equal(10, logs.FilterMessage("impersonated request").Len())
+}
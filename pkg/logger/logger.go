@@ -1,35 +1,164 @@
 package logger
 
-import "go.uber.org/zap"
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Options configure NewLogger.
+type Options struct {
+	// Env "production" selects JSON output; anything else selects the
+	// human-readable console output of development.
+	Env string
+	// Level is the least severe level written: debug, info, warn or error.
+	// Empty means info.
+	Level string
+	// ComponentLevels overrides Level for the loggers returned by Named, by
+	// component name.
+	ComponentLevels map[string]string
+	// SamplingInitial lines with the same level and message are written each
+	// second, then only every SamplingThereafter-th. Zero SamplingThereafter
+	// writes them all.
+	SamplingInitial    int
+	SamplingThereafter int
+	// UnsampledComponents are written in full, such as the access log and the
+	// audit trail: their lines are never sampled and their level, from
+	// ComponentLevels or Level, cannot be changed while the API runs.
+	UnsampledComponents []string
+}
 
 type Logger struct {
 	logger *zap.SugaredLogger
+	// base writes every level; Named gates it with the component's level.
+	base *zap.Logger
+	// unsampled is base without sampling, for the UnsampledComponents, whose
+	// fixed levels are in unsampledLevels.
+	unsampled       *zap.Logger
+	unsampledLevels map[string]zapcore.Level
+	levels          *Levels
+}
+
+func (l Logger) Debug(msg string, keysAndValues ...interface{}) {
+	l.logger.Debugw(msg, keysAndValues...)
+}
+
+func (l Logger) Info(msg string, keysAndValues ...interface{}) {
+	l.logger.Infow(msg, keysAndValues...)
 }
 
-func (l Logger) Info(args ...interface{}) {
-	l.logger.Info(args...)
+func (l Logger) Warn(msg string, keysAndValues ...interface{}) {
+	l.logger.Warnw(msg, keysAndValues...)
 }
 
-func (l Logger) Error(args ...interface{}) {
-	l.logger.Error(args...)
+func (l Logger) Error(msg string, keysAndValues ...interface{}) {
+	l.logger.Errorw(msg, keysAndValues...)
 }
 
-func (l Logger) Fatal(args ...interface{}) {
-	l.logger.Fatal(args...)
+func (l Logger) Fatal(msg string, keysAndValues ...interface{}) {
+	l.logger.Fatalw(msg, keysAndValues...)
+}
+
+func (l Logger) With(keysAndValues ...interface{}) CustomLogger {
+	l.logger = l.logger.With(keysAndValues...)
+	return l
+}
+
+func (l Logger) FromContext(ctx context.Context) CustomLogger {
+	if keysAndValues := contextFields(ctx); len(keysAndValues) > 0 {
+		return l.With(keysAndValues...)
+	}
+	return l
+}
+
+// Named returns the logger of component, such as "db" or "http", which is
+// written with the component's name and at the component's level. Unsampled
+// components are written in full at their configured level.
+func (l Logger) Named(component string) Logger {
+	var named *zap.Logger
+	if level, ok := l.unsampledLevels[component]; ok {
+		named = l.unsampled.WithOptions(zap.IncreaseLevel(level))
+	} else {
+		level := l.levels.level(component)
+		named = l.base.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return levelCore{Core: core, level: level}
+		}))
+	}
+	l.logger = named.Named(component).Sugar()
+	return l
 }
 
-func (l Logger) Debug(args ...interface{}) {
-	l.logger.Debug(args...)
+// Levels returns the levels of l and of the loggers Named derived from it.
+func (l Logger) Levels() *Levels {
+	return l.levels
 }
 
-func NewLogger() (CustomLogger, error) {
-	l := Logger{}
-	logger, err := zap.NewDevelopment()
+// SlogHandler returns a log/slog handler writing to the same output, at the
+// same level, as l.
+func (l Logger) SlogHandler() slog.Handler {
+	return slogHandler{core: l.logger.Desugar().Core()}
+}
+
+// Sync flushes buffered lines. Call it before the process exits.
+func (l Logger) Sync() error {
+	return l.logger.Sync()
+}
+
+func NewLogger(options Options) (Logger, error) {
+	config := zap.NewDevelopmentConfig()
+	if options.Env == "production" {
+		config = zap.NewProductionConfig()
+	}
+	config.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+	config.Sampling = nil
+
+	// ... report the caller of Logger's methods rather than the methods
+	unsampled, err := config.Build(zap.AddCallerSkip(1))
+	if err != nil {
+		return Logger{}, err
+	}
+	return newLogger(unsampled, options)
+}
+
+// newLogger returns a Logger writing to unsampled, which lets every level
+// through, with the levels and sampling of options.
+func newLogger(unsampled *zap.Logger, options Options) (Logger, error) {
+	level, err := zapcore.ParseLevel(options.Level)
 	if err != nil {
-		return l, err
+		return Logger{}, err
+	}
+	levels := newLevels()
+	defaultLevel := levels.register(DefaultComponent, level)
+	unsampledLevels := map[string]zapcore.Level{}
+	for _, component := range options.UnsampledComponents {
+		unsampledLevels[component] = level
+	}
+	for component, componentLevel := range options.ComponentLevels {
+		if componentLevel == "" {
+			continue
+		}
+		parsed, err := zapcore.ParseLevel(componentLevel)
+		if err != nil {
+			return Logger{}, err
+		}
+		if _, ok := unsampledLevels[component]; ok {
+			unsampledLevels[component] = parsed
+			continue
+		}
+		levels.register(component, parsed)
+	}
+
+	base := unsampled
+	if options.SamplingThereafter > 0 {
+		base = unsampled.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewSamplerWithOptions(core, time.Second, options.SamplingInitial, options.SamplingThereafter)
+		}))
 	}
-	defer logger.Sync() // flushes buffer, if any
-	s := logger.Sugar()
-	l.logger = s
-	return l, nil
+	logger := base.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return levelCore{Core: core, level: defaultLevel}
+	}))
+	return Logger{logger: logger.Sugar(), base: base, unsampled: unsampled, unsampledLevels: unsampledLevels, levels: levels}, nil
 }
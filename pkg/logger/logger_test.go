@@ -0,0 +1,100 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogger_FromContext(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	spanCtx := trace.ContextWithSpanContext(ContextWithRequestID(context.Background(), "req-123"), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+
+	testScenarios := []struct {
+		name           string
+		ctx            context.Context
+		expectedFields map[string]interface{}
+	}{
+		{name: "request", ctx: ContextWithRequestID(context.Background(), "req-123"), expectedFields: map[string]interface{}{"request_id": "req-123", "id": "42"}},
+		{name: "traced request", ctx: spanCtx, expectedFields: map[string]interface{}{"request_id": "req-123", "trace_id": traceID.String(), "span_id": spanID.String(), "id": "42"}},
+		{name: "no request", ctx: context.Background(), expectedFields: map[string]interface{}{"id": "42"}},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			core, logs := observer.New(zap.InfoLevel)
+			l := Logger{logger: zap.New(core).Sugar()}
+
+			// when
+			l.FromContext(scenario.ctx).Error("failed to get user by id", "id", "42")
+
+			// then
+			a.Equal(1, logs.Len())
+			entry := logs.All()[0]
+			a.Equal("failed to get user by id", entry.Message)
+			a.Equal(zapcore.ErrorLevel, entry.Level)
+			a.Equal(scenario.expectedFields, entry.ContextMap())
+		})
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	testScenarios := []struct {
+		options     Options
+		expectedErr bool
+	}{
+		{options: Options{}},
+		{options: Options{Env: "production", Level: "warn"}},
+		{options: Options{Level: "verbose"}, expectedErr: true},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.options.Env+"/"+scenario.options.Level, func(t *testing.T) {
+			a := assert.New(t)
+			// when
+			l, err := NewLogger(scenario.options)
+
+			// then
+			if scenario.expectedErr {
+				a.Error(err)
+				return
+			}
+			a.NoError(err)
+			a.Equal(scenario.options.Level == "", l.logger.Desugar().Core().Enabled(zapcore.InfoLevel))
+		})
+	}
+}
+
+func TestLogger_SlogHandler(t *testing.T) {
+	a := assert.New(t)
+	// given
+	core, logs := observer.New(zap.InfoLevel)
+	l := Logger{logger: zap.New(core).Sugar()}
+	slogger := slog.New(l.SlogHandler()).With("library", "pgx").WithGroup("query")
+
+	// when
+	slogger.DebugContext(context.Background(), "skipped")
+	slogger.WarnContext(ContextWithRequestID(context.Background(), "req-123"), "slow query", "duration_ms", 250, slog.Group("args", "limit", 20))
+
+	// then
+	a.Equal(1, logs.Len())
+	entry := logs.All()[0]
+	a.Equal("slow query", entry.Message)
+	a.Equal(zapcore.WarnLevel, entry.Level)
+	a.True(entry.Caller.Defined)
+	a.Equal(map[string]interface{}{
+		"library":           "pgx",
+		"request_id":        "req-123",
+		"query.duration_ms": int64(250),
+		"query.args.limit":  int64(20),
+	}, entry.ContextMap())
+}
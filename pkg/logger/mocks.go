@@ -1,23 +1,43 @@
 package logger
 
-import "github.com/stretchr/testify/mock"
+import (
+	"context"
 
+	"github.com/stretchr/testify/mock"
+)
+
+// MockLogger records every call with two arguments, the message and the
+// key-value pairs, so expectations read On("Error", mock.Anything, mock.Anything).
 type MockLogger struct {
 	mock.Mock
 }
 
-func (m *MockLogger) Info(args ...interface{}) {
-	m.Called(args...)
+func (m *MockLogger) Debug(msg string, keysAndValues ...interface{}) {
+	m.Called(msg, keysAndValues)
+}
+
+func (m *MockLogger) Info(msg string, keysAndValues ...interface{}) {
+	m.Called(msg, keysAndValues)
+}
+
+func (m *MockLogger) Warn(msg string, keysAndValues ...interface{}) {
+	m.Called(msg, keysAndValues)
+}
+
+func (m *MockLogger) Error(msg string, keysAndValues ...interface{}) {
+	m.Called(msg, keysAndValues)
 }
 
-func (m *MockLogger) Error(args ...interface{}) {
-	m.Called(args...)
+func (m *MockLogger) Fatal(msg string, keysAndValues ...interface{}) {
+	m.Called(msg, keysAndValues)
 }
 
-func (m *MockLogger) Fatal(args ...interface{}) {
-	m.Called(args...)
+// With returns m itself, so expectations set on m hold for derived loggers.
+func (m *MockLogger) With(keysAndValues ...interface{}) CustomLogger {
+	return m
 }
 
-func (m *MockLogger) Debug(args ...interface{}) {
-	m.Called(args...)
+// FromContext returns m itself, like With.
+func (m *MockLogger) FromContext(ctx context.Context) CustomLogger {
+	return m
 }
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package logger
+
+import "time"
+
+// HandleLevelSignals does nothing where SIGUSR1 and SIGUSR2 do not exist; use
+// the admin endpoint instead.
+func (l Logger) HandleLevelSignals(ttl time.Duration) {}
@@ -0,0 +1,28 @@
+//go:build unix
+
+package logger
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// HandleLevelSignals makes every component one level more verbose on SIGUSR1
+// and one level less verbose on SIGUSR2, as overrides lasting ttl.
+func (l Logger) HandleLevelSignals(ttl time.Duration) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for sig := range signals {
+			steps := 1
+			if sig == syscall.SIGUSR1 {
+				steps = -1
+			}
+			l.levels.Shift(steps, ttl)
+			// ... written whatever the levels are now
+			Logger{logger: l.base.Sugar()}.Warn("log levels changed", "signal", sig.String(), "levels", l.levels.Components(), "ttl", ttl)
+		}
+	}()
+}
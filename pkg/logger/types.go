@@ -1,8 +1,19 @@
 package logger
 
+import "context"
+
+// CustomLogger writes structured log lines: a message followed by alternating
+// keys and values, such as Error("failed to get user", "error", err, "id", id).
 type CustomLogger interface {
-	Info(args ...interface{})
-	Error(args ...interface{})
-	Fatal(args ...interface{})
-	Debug(args ...interface{})
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+	Fatal(msg string, keysAndValues ...interface{})
+	// With returns a logger that adds the key-value pairs to every line.
+	With(keysAndValues ...interface{}) CustomLogger
+	// FromContext returns a logger that adds the request id and the trace and
+	// span ids stored in ctx to every line, so that everything logged while
+	// serving one request can be found together, and next to its trace.
+	FromContext(ctx context.Context) CustomLogger
 }
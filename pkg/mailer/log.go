@@ -0,0 +1,22 @@
+package mailer
+
+import (
+	"context"
+	"go-rest-api/pkg/logger"
+)
+
+// LogMailer writes mail to the logger instead of sending it. It is meant for
+// local development where no SMTP relay is available: mail bodies, such as
+// invitation links, are logged in full, secrets included.
+type LogMailer struct {
+	logger logger.CustomLogger
+}
+
+func NewLogMailer(logger logger.CustomLogger) *LogMailer {
+	return &LogMailer{logger: logger}
+}
+
+func (m *LogMailer) SendMail(ctx context.Context, to, subject, body string) error {
+	m.logger.Info("mail sent", "to", to, "subject", subject, "body", body)
+	return nil
+}
@@ -0,0 +1,9 @@
+package mailer
+
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
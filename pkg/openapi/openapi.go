@@ -0,0 +1,380 @@
+package openapi
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+const specURL = "openapi.json"
+
+// maxBodySize is the largest JSON body that is validated. Larger bodies are
+// passed on untouched for the handler to reject.
+const maxBodySize = 1 << 20
+
+// versionPrefix matches the version segment that the REST endpoints are also
+// served below, as in /v2/users. Every version takes the same requests, so
+// they are checked against the unprefixed operations.
+var versionPrefix = regexp.MustCompile(`^/v[0-9]+/`)
+
+// Spec returns the OpenAPI document describing the API.
+func Spec() []byte {
+	return spec
+}
+
+// Violation is a part of a request that does not match the OpenAPI document.
+type Violation struct {
+	// Field is the name of a path or query parameter, or the path of a body
+	// field such as "roles[1]".
+	Field string
+	// Rule is the failed rule, named like the validate tags of the request
+	// DTOs where there is one: required, email, min, max, oneof, type, format
+	// or unknown.
+	Rule string
+	// Param is the limit, type or values the rule expected, if any.
+	Param string
+	// Length is set when a min or max rule applies to the length of a string.
+	Length bool
+}
+
+// RequestError is returned for a request that does not match the document.
+type RequestError struct {
+	Violations []Violation
+}
+
+func (e *RequestError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		messages = append(messages, violation.Field+" failed the "+violation.Rule+" rule")
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Validator checks requests against the operations of the OpenAPI document.
+type Validator struct {
+	operations []*operation
+}
+
+type operation struct {
+	method   string
+	segments []string
+	params   []*parameter
+	body     *jsonschema.Schema
+}
+
+type parameter struct {
+	name     string
+	in       string
+	required bool
+	types    []string
+	schema   *jsonschema.Schema
+}
+
+// NewValidator compiles the parameter and JSON body schemas of every
+// operation in the embedded document.
+func NewValidator() (*Validator, error) {
+	var document struct {
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Parameters map[string]json.RawMessage `json:"parameters"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(spec, &document); err != nil {
+		return nil, fmt.Errorf("failed to parse openapi document: %w", err)
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(spec))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse openapi document: %w", err)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.AssertFormat()
+	if err := compiler.AddResource(specURL, doc); err != nil {
+		return nil, fmt.Errorf("failed to add openapi document: %w", err)
+	}
+
+	validator := &Validator{}
+	for path, methods := range document.Paths {
+		for method, raw := range methods {
+			var definition struct {
+				Parameters  []json.RawMessage `json:"parameters"`
+				RequestBody struct {
+					Content map[string]json.RawMessage `json:"content"`
+				} `json:"requestBody"`
+			}
+			if err := json.Unmarshal(raw, &definition); err != nil {
+				return nil, fmt.Errorf("failed to parse %s %s: %w", method, path, err)
+			}
+			location := "#/paths/" + escapePointer(path) + "/" + method
+			op := &operation{method: strings.ToUpper(method), segments: strings.Split(path, "/")}
+
+			for i, rawParam := range definition.Parameters {
+				paramLocation := location + "/parameters/" + strconv.Itoa(i)
+				var ref struct {
+					Ref string `json:"$ref"`
+				}
+				json.Unmarshal(rawParam, &ref)
+				if name, ok := strings.CutPrefix(ref.Ref, "#/components/parameters/"); ok {
+					rawParam = document.Components.Parameters[name]
+					paramLocation = ref.Ref
+				}
+				param, err := compileParameter(compiler, rawParam, paramLocation)
+				if err != nil {
+					return nil, fmt.Errorf("failed to compile %s %s: %w", method, path, err)
+				}
+				if param != nil {
+					op.params = append(op.params, param)
+				}
+			}
+
+			if _, ok := definition.RequestBody.Content["application/json"]; ok {
+				op.body, err = compiler.Compile(specURL + location + "/requestBody/content/application~1json/schema")
+				if err != nil {
+					return nil, fmt.Errorf("failed to compile %s %s: %w", method, path, err)
+				}
+			}
+			validator.operations = append(validator.operations, op)
+		}
+	}
+	return validator, nil
+}
+
+// compileParameter compiles the schema of a path or query parameter. Other
+// parameters, and deepObject ones such as the profile filter, are not
+// validated and nil is returned for them.
+func compileParameter(compiler *jsonschema.Compiler, raw json.RawMessage, location string) (*parameter, error) {
+	var definition struct {
+		Name     string `json:"name"`
+		In       string `json:"in"`
+		Required bool   `json:"required"`
+		Style    string `json:"style"`
+		Schema   struct {
+			Type any `json:"type"`
+		} `json:"schema"`
+	}
+	if err := json.Unmarshal(raw, &definition); err != nil {
+		return nil, err
+	}
+	if definition.In != "path" && definition.In != "query" || definition.Style == "deepObject" {
+		return nil, nil
+	}
+
+	schema, err := compiler.Compile(specURL + location + "/schema")
+	if err != nil {
+		return nil, err
+	}
+	param := &parameter{name: definition.Name, in: definition.In, required: definition.Required, schema: schema}
+	switch schemaType := definition.Schema.Type.(type) {
+	case string:
+		param.types = []string{schemaType}
+	case []any:
+		for _, t := range schemaType {
+			if s, ok := t.(string); ok {
+				param.types = append(param.types, s)
+			}
+		}
+	}
+	return param, nil
+}
+
+// ValidateRequest checks the path and query parameters and the JSON body of
+// r against the operation it matches, and returns a RequestError listing
+// what does not match. Requests that match no operation are left to the
+// router. The body is restored for the handler to read.
+func (v *Validator) ValidateRequest(r *http.Request) error {
+	op, pathParams := v.match(r.Method, versionPrefix.ReplaceAllString(r.URL.Path, "/"))
+	if op == nil {
+		return nil
+	}
+
+	var violations []Violation
+	query := r.URL.Query()
+	for _, param := range op.params {
+		value, ok := pathParams[param.name]
+		if param.in == "query" {
+			value, ok = query.Get(param.name), query.Has(param.name)
+		}
+		if !ok {
+			if param.required {
+				violations = append(violations, Violation{Field: param.name, Rule: "required"})
+			}
+			continue
+		}
+		if err := param.schema.Validate(param.coerce(value)); err != nil {
+			violations = append(violations, schemaViolations(err, param.name)...)
+		}
+	}
+
+	if op.body != nil && r.Body != nil && isJSON(r.Header.Get("Content-Type")) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+		if err != nil {
+			return err
+		}
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+
+		// ... malformed, empty and oversized bodies are the handler's to report
+		if instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(body)); err == nil && len(body) < maxBodySize {
+			if err := op.body.Validate(instance); err != nil {
+				violations = append(violations, schemaViolations(err, "")...)
+			}
+		}
+	}
+
+	if len(violations) > 0 {
+		return &RequestError{Violations: violations}
+	}
+	return nil
+}
+
+// match returns the operation for method and path and the values of its path
+// parameters. Literal segments win over parameters, so /users/export matches
+// its own operation rather than /users/{id}.
+func (v *Validator) match(method, path string) (*operation, map[string]string) {
+	segments := strings.Split(path, "/")
+	var best *operation
+	var bestParams map[string]string
+	bestLiterals := -1
+	for _, op := range v.operations {
+		if op.method != method || len(op.segments) != len(segments) {
+			continue
+		}
+		params := map[string]string{}
+		literals := 0
+		for i, segment := range op.segments {
+			if name, ok := strings.CutPrefix(segment, "{"); ok {
+				params[strings.TrimSuffix(name, "}")] = segments[i]
+				continue
+			}
+			if segment != segments[i] {
+				literals = -1
+				break
+			}
+			literals++
+		}
+		if literals > bestLiterals {
+			best, bestParams, bestLiterals = op, params, literals
+		}
+	}
+	return best, bestParams
+}
+
+// coerce converts a parameter value to the JSON type its schema expects, so
+// that "20" is checked as the number 20. Values that do not convert are
+// kept as strings and fail the type rule.
+func (p *parameter) coerce(value string) any {
+	switch {
+	case slices.Contains(p.types, "integer"), slices.Contains(p.types, "number"):
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			return json.Number(value)
+		}
+	case slices.Contains(p.types, "boolean"):
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+// schemaViolations flattens a schema validation error into one violation per
+// failed rule. prefix is the name of the validated parameter, if any.
+func schemaViolations(err error, prefix string) []Violation {
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return []Violation{{Field: prefix, Rule: "type"}}
+	}
+
+	var violations []Violation
+	var walk func(e *jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) > 0 {
+			for _, cause := range e.Causes {
+				walk(cause)
+			}
+			return
+		}
+		field := fieldPath(prefix, e.InstanceLocation)
+		switch k := e.ErrorKind.(type) {
+		case *kind.Required:
+			for _, missing := range k.Missing {
+				violations = append(violations, Violation{Field: fieldPath(field, []string{missing}), Rule: "required"})
+			}
+		case *kind.AdditionalProperties:
+			for _, property := range k.Properties {
+				violations = append(violations, Violation{Field: fieldPath(field, []string{property}), Rule: "unknown"})
+			}
+		case *kind.MinLength:
+			violations = append(violations, Violation{Field: field, Rule: "min", Param: strconv.Itoa(k.Want), Length: true})
+		case *kind.MaxLength:
+			violations = append(violations, Violation{Field: field, Rule: "max", Param: strconv.Itoa(k.Want), Length: true})
+		case *kind.Minimum:
+			violations = append(violations, Violation{Field: field, Rule: "min", Param: k.Want.RatString()})
+		case *kind.Maximum:
+			violations = append(violations, Violation{Field: field, Rule: "max", Param: k.Want.RatString()})
+		case *kind.Format:
+			if k.Want == "email" {
+				violations = append(violations, Violation{Field: field, Rule: "email"})
+			} else {
+				violations = append(violations, Violation{Field: field, Rule: "format", Param: k.Want})
+			}
+		case *kind.Type:
+			violations = append(violations, Violation{Field: field, Rule: "type", Param: strings.Join(k.Want, " or ")})
+		case *kind.Enum:
+			values := make([]string, 0, len(k.Want))
+			for _, want := range k.Want {
+				values = append(values, fmt.Sprint(want))
+			}
+			violations = append(violations, Violation{Field: field, Rule: "oneof", Param: strings.Join(values, ", ")})
+		default:
+			keyword := "schema"
+			if path := e.ErrorKind.KeywordPath(); len(path) > 0 {
+				keyword = path[len(path)-1]
+			}
+			violations = append(violations, Violation{Field: field, Rule: keyword})
+		}
+	}
+	walk(validationErr)
+	return violations
+}
+
+// fieldPath appends a JSON instance location to prefix in the notation of
+// the request validation errors, e.g. "roles[1]".
+func fieldPath(prefix string, location []string) string {
+	path := prefix
+	for _, token := range location {
+		if _, err := strconv.Atoi(token); err == nil {
+			path += "[" + token + "]"
+		} else if path == "" {
+			path = token
+		} else {
+			path += "." + token
+		}
+	}
+	return path
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/json"
+}
+
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
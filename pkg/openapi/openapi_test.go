@@ -0,0 +1,119 @@
+package openapi
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidator_ValidateRequest(t *testing.T) {
+	validator, err := NewValidator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testScenarios := []struct {
+		name               string
+		method             string
+		target             string
+		body               string
+		expectedViolations []Violation
+	}{
+		{
+			name:   "valid body",
+			method: http.MethodPost,
+			target: "/users",
+			body:   `{"username":"alice","email":"alice@example.com","password":"password123"}`,
+		},
+		{
+			name:   "invalid body",
+			method: http.MethodPost,
+			target: "/users",
+			body:   `{"username":"al","password":"password123","admin":true}`,
+			expectedViolations: []Violation{
+				{Field: "email", Rule: "required"},
+				{Field: "admin", Rule: "unknown"},
+				{Field: "username", Rule: "min", Param: "3", Length: true},
+			},
+		},
+		{
+			name:   "nested field",
+			method: http.MethodPost,
+			target: "/invitations",
+			body:   `{"email":"bob@example.com","roles":["admin","owner"]}`,
+			expectedViolations: []Violation{
+				{Field: "roles[1]", Rule: "oneof", Param: "admin, exporter"},
+			},
+		},
+		{
+			name:   "malformed body is left to the handler",
+			method: http.MethodPost,
+			target: "/users",
+			body:   `{"username":`,
+		},
+		{
+			name:   "query parameters",
+			method: http.MethodGet,
+			target: "/users?limit=500&offset=abc&profile.locale=fr",
+			expectedViolations: []Violation{
+				{Field: "limit", Rule: "max", Param: "100"},
+				{Field: "offset", Rule: "type", Param: "integer"},
+			},
+		},
+		{
+			name:   "literal segment wins over parameter",
+			method: http.MethodGet,
+			target: "/users/export?format=xml",
+			expectedViolations: []Violation{
+				{Field: "format", Rule: "oneof", Param: "ndjson, csv"},
+			},
+		},
+		{
+			name:   "path parameter",
+			method: http.MethodGet,
+			target: "/users/not-a-uuid",
+			expectedViolations: []Violation{
+				{Field: "id", Rule: "format", Param: "uuid"},
+			},
+		},
+		{
+			name:   "version prefix",
+			method: http.MethodGet,
+			target: "/v2/users?limit=0",
+			expectedViolations: []Violation{
+				{Field: "limit", Rule: "min", Param: "1"},
+			},
+		},
+		{
+			name:   "unknown route",
+			method: http.MethodGet,
+			target: "/unknown",
+		},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// given
+			req := httptest.NewRequest(scenario.method, scenario.target, bytes.NewBufferString(scenario.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			// when
+			err := validator.ValidateRequest(req)
+
+			// then
+			if scenario.expectedViolations == nil {
+				a.NoError(err)
+			} else {
+				a.IsType(&RequestError{}, err)
+				a.ElementsMatch(scenario.expectedViolations, err.(*RequestError).Violations)
+			}
+			body, _ := io.ReadAll(req.Body)
+			a.Equal(scenario.body, string(body))
+		})
+	}
+}
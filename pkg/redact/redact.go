@@ -0,0 +1,203 @@
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// Mask replaces the values of secret fields.
+const Mask = "[REDACTED]"
+
+// Mode says how a value is masked.
+type Mode string
+
+const (
+	// Secret replaces the whole value with Mask.
+	Secret Mode = "secret"
+	// Email keeps the first character of the local part and the domain, so
+	// that "john@gmail.com" becomes "j***@gmail.com".
+	Email Mode = "email"
+)
+
+// ErrInvalidPath is returned for JSON paths Redactor does not understand.
+var ErrInvalidPath = errors.New("invalid JSON path")
+
+// Redactor masks sensitive values in JSON documents. Values are found by JSON
+// path, such as "$.password" or "$.users[*].email", and by member name at any
+// depth. Paths are usually derived from the redact tags of the request and
+// response types with Struct.
+type Redactor struct {
+	paths map[string]Mode
+	keys  map[string]Mode
+}
+
+func New() *Redactor {
+	return &Redactor{paths: map[string]Mode{}, keys: map[string]Mode{}}
+}
+
+// Path masks the value at path, which starts at "$" and goes on with
+// ".member" and "[*]" for every element of an array.
+func (r *Redactor) Path(path string, mode Mode) error {
+	rest, ok := strings.CutPrefix(path, "$")
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrInvalidPath, path)
+	}
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, "[*]"):
+			rest = rest[len("[*]"):]
+		case strings.HasPrefix(rest, "."):
+			end := strings.IndexAny(rest[1:], ".[")
+			if end == -1 {
+				end = len(rest) - 1
+			}
+			if end == 0 {
+				return fmt.Errorf("%w: %q", ErrInvalidPath, path)
+			}
+			rest = rest[1+end:]
+		default:
+			return fmt.Errorf("%w: %q", ErrInvalidPath, path)
+		}
+	}
+	r.paths[path] = mode
+	return nil
+}
+
+// Key masks the value of every object member named key, wherever it is.
+// Member names are compared case-insensitively.
+func (r *Redactor) Key(key string, mode Mode) {
+	r.keys[strings.ToLower(key)] = mode
+}
+
+// Struct adds a path for every field of v's type tagged with redact:"secret"
+// or redact:"email", following nested structs, pointers and slices. Paths use
+// the names of the json tags. Any other non-empty tag value masks the whole
+// value.
+func (r *Redactor) Struct(v any) {
+	r.addStruct(reflect.TypeOf(v), "$", map[reflect.Type]bool{})
+}
+
+func (r *Redactor) addStruct(t reflect.Type, prefix string, visiting map[reflect.Type]bool) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || visiting[t] {
+		return
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			// ... members of embedded structs are promoted like encoding/json does
+			if field.Anonymous {
+				r.addStruct(field.Type, prefix, visiting)
+				continue
+			}
+			name = field.Name
+		}
+		path := prefix + "." + name
+
+		if tag := field.Tag.Get("redact"); tag != "" {
+			mode := Secret
+			if Mode(tag) == Email {
+				mode = Email
+			}
+			r.paths[path] = mode
+			continue
+		}
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Pointer || fieldType.Kind() == reflect.Slice || fieldType.Kind() == reflect.Array {
+			if fieldType.Kind() != reflect.Pointer {
+				path += "[*]"
+			}
+			fieldType = fieldType.Elem()
+		}
+		r.addStruct(fieldType, path, visiting)
+	}
+}
+
+// JSON returns data with the sensitive values masked. Members come out in the
+// order encoding/json writes map keys. It fails on anything but a single JSON
+// value, so that callers never log a body it could not look into.
+func (r *Redactor) JSON(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("more than one JSON value")
+	}
+	return json.Marshal(r.redact(value, "$"))
+}
+
+func (r *Redactor) redact(value any, path string) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, member := range value {
+			memberPath := path + "." + key
+			if mode, ok := r.paths[memberPath]; ok {
+				value[key] = mask(member, mode)
+			} else if mode, ok := r.keys[strings.ToLower(key)]; ok {
+				value[key] = mask(member, mode)
+			} else {
+				value[key] = r.redact(member, memberPath)
+			}
+		}
+		return value
+	case []any:
+		elementPath := path + "[*]"
+		if mode, ok := r.paths[elementPath]; ok {
+			for i, element := range value {
+				value[i] = mask(element, mode)
+			}
+			return value
+		}
+		for i, element := range value {
+			value[i] = r.redact(element, elementPath)
+		}
+		return value
+	}
+	return value
+}
+
+// mask hides value according to mode. Nulls stay null, they give nothing
+// away.
+func mask(value any, mode Mode) any {
+	if value == nil {
+		return nil
+	}
+	if mode == Email {
+		if email, ok := value.(string); ok {
+			return MaskEmail(email)
+		}
+	}
+	return Mask
+}
+
+// MaskEmail keeps the first character of the local part of email and its
+// domain. Anything that does not look like an email is masked entirely.
+func MaskEmail(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || local == "" || domain == "" {
+		return Mask
+	}
+	_, size := utf8.DecodeRuneInString(local)
+	return local[:size] + "***@" + domain
+}
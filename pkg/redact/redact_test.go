@@ -0,0 +1,134 @@
+package redact
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type credentials struct {
+	Email    string `json:"email" redact:"email"`
+	Password string `json:"password" redact:"secret"`
+}
+
+type account struct {
+	Name        string         `json:"name"`
+	Credentials *credentials   `json:"credentials"`
+	Members     []credentials  `json:"members"`
+	Internal    string         `json:"-" redact:"secret"`
+	Settings    map[string]any `json:"settings"`
+}
+
+func TestRedactor_JSON(t *testing.T) {
+	redactor := New()
+	redactor.Struct(account{})
+	redactor.Key("token", Secret)
+	assert.NoError(t, redactor.Path("$.settings.phone", Secret))
+
+	testScenarios := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{
+			name:     "nested struct",
+			body:     `{"name":"acme","credentials":{"email":"john@gmail.com","password":"secret"}}`,
+			expected: `{"name":"acme","credentials":{"email":"j***@gmail.com","password":"[REDACTED]"}}`,
+		},
+		{
+			name:     "slice of structs",
+			body:     `{"members":[{"email":"jane@gmail.com","password":"secret"},{"email":"nope","password":null}]}`,
+			expected: `{"members":[{"email":"j***@gmail.com","password":"[REDACTED]"},{"email":"[REDACTED]","password":null}]}`,
+		},
+		{
+			name:     "key at any depth",
+			body:     `{"settings":{"sso":{"Token":"abc"}},"data":[{"token":42}]}`,
+			expected: `{"settings":{"sso":{"Token":"[REDACTED]"}},"data":[{"token":"[REDACTED]"}]}`,
+		},
+		{
+			name:     "path",
+			body:     `{"settings":{"phone":"+33612345678","locale":"fr"}}`,
+			expected: `{"settings":{"phone":"[REDACTED]","locale":"fr"}}`,
+		},
+		{
+			name:     "other paths are kept",
+			body:     `{"password":"not a credentials path","count":12345678901234567890}`,
+			expected: `{"password":"not a credentials path","count":12345678901234567890}`,
+		},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			a := assert.New(t)
+			// when
+			redacted, err := redactor.JSON([]byte(scenario.body))
+
+			// then
+			a.NoError(err)
+			a.JSONEq(scenario.expected, string(redacted))
+		})
+	}
+}
+
+func TestRedactor_JSONInvalid(t *testing.T) {
+	redactor := New()
+
+	for _, body := range []string{`{"password":"sec`, `{"a":1} {"b":2}`, ``} {
+		t.Run(body, func(t *testing.T) {
+			// when
+			_, err := redactor.JSON([]byte(body))
+
+			// then
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestRedactor_Path(t *testing.T) {
+	testScenarios := []struct {
+		path    string
+		isValid bool
+	}{
+		{path: "$", isValid: true},
+		{path: "$.password", isValid: true},
+		{path: "$.users[*].email", isValid: true},
+		{path: "$[*][*].token", isValid: true},
+		{path: "password"},
+		{path: "$..password"},
+		{path: "$.users[0].email"},
+		{path: "$.users."},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.path, func(t *testing.T) {
+			// when
+			err := New().Path(scenario.path, Secret)
+
+			// then
+			if scenario.isValid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidPath)
+			}
+		})
+	}
+}
+
+func TestMaskEmail(t *testing.T) {
+	testScenarios := []struct {
+		email    string
+		expected string
+	}{
+		{email: "john@gmail.com", expected: "j***@gmail.com"},
+		{email: "émile@exemple.fr", expected: "é***@exemple.fr"},
+		{email: "john", expected: Mask},
+		{email: "@gmail.com", expected: Mask},
+		{email: "", expected: Mask},
+	}
+
+	for _, scenario := range testScenarios {
+		t.Run(scenario.email, func(t *testing.T) {
+			assert.Equal(t, scenario.expected, MaskEmail(scenario.email))
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package schema
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+//go:embed profile.schema.json
+var defaultProfileSchema []byte
+
+const schemaURL = "profile.schema.json"
+
+// Validator validates JSON documents decoded with encoding/json against a
+// compiled JSON Schema.
+type Validator struct {
+	schema *jsonschema.Schema
+}
+
+// NewValidator compiles the given JSON Schema document.
+func NewValidator(schemaJSON []byte) (*Validator, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(schemaJSON))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse json schema: %w", err)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(schemaURL, doc); err != nil {
+		return nil, fmt.Errorf("failed to add json schema: %w", err)
+	}
+	compiled, err := compiler.Compile(schemaURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile json schema: %w", err)
+	}
+	return &Validator{schema: compiled}, nil
+}
+
+// LoadProfileValidator compiles the profile schema at path, falling back to the
+// embedded default schema when path is empty.
+func LoadProfileValidator(path string) (*Validator, error) {
+	if path == "" {
+		return NewValidator(defaultProfileSchema)
+	}
+	schemaJSON, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read json schema: %w", err)
+	}
+	return NewValidator(schemaJSON)
+}
+
+func (v *Validator) ValidateProfile(profile map[string]any) error {
+	err := v.schema.Validate(profile)
+	if err == nil {
+		return nil
+	}
+
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return err
+	}
+
+	// ... flatten the nested validation tree into one line per failure
+	var messages []string
+	for _, unit := range validationErr.BasicOutput().Errors {
+		if unit.Error == nil {
+			continue
+		}
+		location := unit.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		messages = append(messages, location+": "+unit.Error.String())
+	}
+	if len(messages) == 0 {
+		return err
+	}
+	return errors.New(strings.Join(messages, "; "))
+}
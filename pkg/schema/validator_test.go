@@ -0,0 +1,54 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidator_ValidateProfile(t *testing.T) {
+	a := assert.New(t)
+	// given
+	validator, err := LoadProfileValidator("")
+	a.NoError(err)
+
+	// when
+	err = validator.ValidateProfile(map[string]any{
+		"display_name":  "Jane",
+		"locale":        "fr-FR",
+		"timezone":      "Africa/Johannesburg",
+		"preferences":   map[string]any{"theme": "dark"},
+		"team_specific": 42.0,
+	})
+
+	// then
+	a.NoError(err)
+}
+
+func TestValidator_ValidateProfile_Invalid(t *testing.T) {
+	a := assert.New(t)
+	// given
+	validator, err := LoadProfileValidator("")
+	a.NoError(err)
+
+	// when
+	err = validator.ValidateProfile(map[string]any{
+		"locale":      "not a locale",
+		"preferences": "dark",
+	})
+
+	// then
+	a.Error(err)
+	a.Contains(err.Error(), "/locale")
+	a.Contains(err.Error(), "/preferences")
+}
+
+func TestNewValidator_InvalidSchema(t *testing.T) {
+	a := assert.New(t)
+
+	// when
+	_, err := NewValidator([]byte(`{"type": 12}`))
+
+	// then
+	a.Error(err)
+}
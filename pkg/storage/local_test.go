@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalStore_Put(t *testing.T) {
+	a := assert.New(t)
+	// given
+	dir := t.TempDir()
+	store, err := NewLocalStore(dir, "http://localhost:8080/static/")
+	a.NoError(err)
+
+	// when
+	url, err := store.Put(context.Background(), "avatars/123/64.png", strings.NewReader("data"), 4, "image/png")
+
+	// then
+	a.NoError(err)
+	a.Equal("http://localhost:8080/static/avatars/123/64.png", url)
+	content, err := os.ReadFile(filepath.Join(dir, "avatars", "123", "64.png"))
+	a.NoError(err)
+	a.Equal("data", string(content))
+}
+
+func TestLocalStore_Put_RejectsPathTraversal(t *testing.T) {
+	a := assert.New(t)
+	// given
+	store, err := NewLocalStore(t.TempDir(), "http://localhost:8080/static")
+	a.NoError(err)
+
+	// when
+	_, err = store.Put(context.Background(), "../escape.png", strings.NewReader("data"), 4, "image/png")
+
+	// then
+	a.Error(err)
+}
+
+func TestLocalStore_Delete(t *testing.T) {
+	a := assert.New(t)
+	// given
+	dir := t.TempDir()
+	store, err := NewLocalStore(dir, "http://localhost:8080/static")
+	a.NoError(err)
+	_, err = store.Put(context.Background(), "avatars/123/64.png", strings.NewReader("data"), 4, "image/png")
+	a.NoError(err)
+
+	// when
+	err = store.Delete(context.Background(), "avatars/123/64.png")
+
+	// then
+	a.NoError(err)
+	_, err = os.Stat(filepath.Join(dir, "avatars", "123", "64.png"))
+	a.True(os.IsNotExist(err))
+	a.NoError(store.Delete(context.Background(), "avatars/123/64.png"))
+}
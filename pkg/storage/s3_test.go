@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"go-rest-api/test"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type S3StoreTestSuite struct {
+	suite.Suite
+	store    *S3Store
+	tearDown func()
+}
+
+func (testSuite *S3StoreTestSuite) SetupSuite() {
+	ctx := context.Background()
+	t := testSuite.T()
+	endpoint, accessKey, secretKey, tearDown := test.CreateMinioTestContainer(ctx, t)
+	testSuite.tearDown = tearDown
+
+	store, err := NewS3Store(ctx, S3Config{
+		Endpoint:  endpoint,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		Bucket:    "avatars-test",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create s3 store: %v", err)
+	}
+	testSuite.store = store
+}
+
+func (testSuite *S3StoreTestSuite) TearDownSuite() {
+	if testSuite.tearDown != nil {
+		testSuite.tearDown()
+	}
+}
+
+func (testSuite *S3StoreTestSuite) TestS3Store_PutAndDelete() {
+	t := testSuite.T()
+	a := assert.New(t)
+	ctx := context.Background()
+
+	// when
+	url, err := testSuite.store.Put(ctx, "avatars/123/64.png", strings.NewReader("data"), 4, "image/png")
+
+	// then
+	a.NoError(err)
+	a.True(strings.HasSuffix(url, "/avatars-test/avatars/123/64.png"))
+	object, err := testSuite.store.client.GetObject(ctx, "avatars-test", "avatars/123/64.png", minio.GetObjectOptions{})
+	a.NoError(err)
+	content, err := io.ReadAll(object)
+	a.NoError(err)
+	a.Equal("data", string(content))
+	stat, err := object.Stat()
+	a.NoError(err)
+	a.Equal("image/png", stat.ContentType)
+
+	// when ... the object is deleted
+	a.NoError(testSuite.store.Delete(ctx, "avatars/123/64.png"))
+
+	// then
+	_, err = testSuite.store.client.StatObject(ctx, "avatars-test", "avatars/123/64.png", minio.StatObjectOptions{})
+	a.Error(err)
+}
+
+func TestS3StoreTestSuite(t *testing.T) {
+	suite.Run(t, new(S3StoreTestSuite))
+}
@@ -0,0 +1,13 @@
+package storage
+
+type S3Config struct {
+	Endpoint  string
+	AccessKey string
+	SecretKey string
+	Bucket    string
+	Region    string
+	UseSSL    bool
+	// PublicURL overrides the base URL returned for stored objects, e.g. a CDN
+	// in front of the bucket. Defaults to <endpoint>/<bucket>.
+	PublicURL string
+}
@@ -59,3 +59,48 @@ func CreateDbTestContainer(ctx context.Context, t *testing.T) (*pgxpool.Pool, fu
 
 	return pool, cleanup
 }
+
+// CreateMinioTestContainer starts a MinIO server and returns its endpoint
+// (host:port) together with the credentials it was started with.
+func CreateMinioTestContainer(ctx context.Context, t *testing.T) (endpoint, accessKey, secretKey string, cleanup func()) {
+	t.Helper()
+
+	accessKey = "minioadmin"
+	secretKey = "minioadmin"
+	req := testcontainers.ContainerRequest{
+		Image:        "minio/minio:latest",
+		ExposedPorts: []string{"9000/tcp"},
+		Env: map[string]string{
+			"MINIO_ROOT_USER":     accessKey,
+			"MINIO_ROOT_PASSWORD": secretKey,
+		},
+		Cmd:        []string{"server", "/data"},
+		WaitingFor: wait.ForHTTP("/minio/health/live").WithPort("9000/tcp"),
+	}
+
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to start container: %v", err)
+	}
+
+	host, err := container.Host(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get container host: %v", err)
+	}
+
+	port, err := container.MappedPort(ctx, "9000")
+	if err != nil {
+		t.Fatalf("Failed to get mapped port: %v", err)
+	}
+
+	cleanup = func() {
+		if err := container.Terminate(ctx); err != nil {
+			t.Fatalf("Failed to terminate container: %v", err)
+		}
+	}
+
+	return host + ":" + port.Port(), accessKey, secretKey, cleanup
+}